@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"warehouse-service/config"
+	"warehouse-service/conn/mysql"
+	"warehouse-service/entity"
+	binRepo "warehouse-service/repository/bin"
+	bulkImportRepo "warehouse-service/repository/bulk_import"
+	consistencyRepo "warehouse-service/repository/consistency"
+	productWarehouseRepo "warehouse-service/repository/product_warehouse"
+	valuationRepo "warehouse-service/repository/valuation"
+	binUsecase "warehouse-service/usecase/bin"
+	bulkImportUsecase "warehouse-service/usecase/bulk_import"
+	consistencyUsecase "warehouse-service/usecase/consistency"
+	productWarehouseUsecase "warehouse-service/usecase/product_warehouse"
+	valuationUsecase "warehouse-service/usecase/valuation"
+)
+
+// runCommand runs the command line tools, started as
+// "warehouse-service <command> [flags]" instead of the server.
+func runCommand(cfg *config.Config, logger *slog.Logger, args []string) {
+	switch args[0] {
+	case "reconcile":
+		reconcile(cfg, logger, args[1:])
+	case "import":
+		importFile(cfg, logger, args[1:])
+	default:
+		fatal(logger, "command failed", fmt.Errorf("unknown command %q", args[0]))
+	}
+}
+
+// reconcile prints the consistency report as JSON and exits with status 1
+// while findings are left unrepaired.
+func reconcile(cfg *config.Config, logger *slog.Logger, args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	repair := flags.Bool("repair", false, "release orphaned reservations and correct reserved stock through ledger entries")
+	flags.Parse(args)
+
+	err := mysql.Connect(cfg.MySQL)
+	if err != nil {
+		fatal(logger, "mysql connect failed", err)
+	}
+	consistencyUsecase := consistencyUsecase.NewConsistencyUsecase(consistencyRepo.NewConsistencyRepository(mysql.MySQL), productWarehouseRepo.NewProductWarehouseRepository(mysql.MySQL), mysql.MySQL, logger)
+	report, err := consistencyUsecase.Check(context.Background(), *repair)
+	if err != nil {
+		fatal(logger, "reconcile failed", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	for _, finding := range report.Findings {
+		if !finding.Repaired {
+			os.Exit(1)
+		}
+	}
+}
+
+// importFile prints the import report as JSON and exits with status 1 when
+// any line failed. The format follows the file extension.
+func importFile(cfg *config.Config, logger *slog.Logger, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	kind := flags.String("kind", "", "warehouses, product-warehouses or opening-balances")
+	path := flags.String("file", "", "csv or ndjson file to import")
+	dryRun := flags.Bool("dry-run", false, "validate the file and report errors without applying it")
+	flags.Parse(args)
+
+	format := ""
+	switch filepath.Ext(*path) {
+	case ".csv":
+		format = entity.FormatCSV
+	case ".ndjson", ".jsonl":
+		format = entity.FormatNDJSON
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		fatal(logger, "import file open failed", err)
+	}
+	defer file.Close()
+
+	err = mysql.Connect(cfg.MySQL)
+	if err != nil {
+		fatal(logger, "mysql connect failed", err)
+	}
+	valuationUsecase := valuationUsecase.NewValuationUsecase(valuationRepo.NewValuationRepository(mysql.MySQL))
+	productWarehouseRepository := productWarehouseRepo.NewProductWarehouseRepository(mysql.MySQL)
+	capacityChecker := productWarehouseUsecase.NewCapacityChecker(productWarehouseRepository)
+	binUsecase := binUsecase.NewBinUsecase(binRepo.NewBinRepository(mysql.MySQL), productWarehouseRepository, mysql.MySQL)
+	bulkImportUsecase := bulkImportUsecase.NewBulkImportUsecase(bulkImportRepo.NewBulkImportRepository(mysql.MySQL), capacityChecker, binUsecase, valuationUsecase, mysql.MySQL)
+	report, err := bulkImportUsecase.Import(context.Background(), *kind, format, file, *dryRun)
+	if err != nil {
+		fatal(logger, "import failed", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	if len(report.Errors) > 0 {
+		file.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lookupEnv(env map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestLoad_FileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("http:\n  addr: \":9000\"\nrabbitmq:\n  exchange: stock_events_v2\n  queues:\n    stock_add: add_queue\njobs:\n  snapshot_interval: 30m\n"), 0o600)
+
+	config, err := load(path, lookupEnv(map[string]string{
+		"WAREHOUSE_JWT_SECRET":           "secret",
+		"WAREHOUSE_HTTP_ADDR":            ":9100",
+		"WAREHOUSE_MYSQL_DSN":            "user:pass@tcp(db:3306)/warehouse",
+		"WAREHOUSE_MYSQL_MAX_OPEN_CONNS": "20",
+	}))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, ":9100", config.HTTP.Addr)
+	assert.Equal(t, "user:pass@tcp(db:3306)/warehouse", config.MySQL.DSN)
+	assert.Equal(t, 20, config.MySQL.MaxOpenConns)
+	assert.Equal(t, "stock_events_v2", config.RabbitMQ.Exchange)
+	assert.Equal(t, "add_queue", config.RabbitMQ.Queues.StockAdd)
+	assert.Equal(t, "queue_stock_deduct", config.RabbitMQ.Queues.StockDeduct)
+	assert.Equal(t, 30*time.Minute, config.Jobs.SnapshotInterval)
+	assert.Equal(t, "secret", config.JWT.Secret)
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"jwt": {"secret": "secret"}, "rabbitmq": {"publish_timeout": "2s"}}`), 0o600)
+
+	config, err := load(path, lookupEnv(nil))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, config.RabbitMQ.PublishTimeout)
+}
+
+func TestLoad_UnknownFileField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("mysql:\n  dns: typo\n"), 0o600)
+
+	config, err := load(path, lookupEnv(map[string]string{"WAREHOUSE_JWT_SECRET": "secret"}))
+
+	// Assertions
+	assert.Nil(t, config)
+	assert.Error(t, err)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	config, err := load("", lookupEnv(map[string]string{"WAREHOUSE_JOBS_SNAPSHOT_INTERVAL": "0s"}))
+
+	// Assertions
+	assert.Nil(t, config)
+	assert.ErrorContains(t, err, "SnapshotInterval")
+}
+
+func TestLoad_WithoutJWTSecret(t *testing.T) {
+	config, err := load("", lookupEnv(map[string]string{}))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.ErrorContains(t, config.ValidateServer(), "JWT.Secret")
+}
+
+func TestLoad_InvalidEnvValue(t *testing.T) {
+	config, err := load("", lookupEnv(map[string]string{"WAREHOUSE_HTTP_READ_TIMEOUT": "soon"}))
+
+	// Assertions
+	assert.Nil(t, config)
+	assert.ErrorContains(t, err, "WAREHOUSE_HTTP_READ_TIMEOUT")
+}
+
+func TestLoad_TracingEnv(t *testing.T) {
+	config, err := load("", lookupEnv(map[string]string{
+		"WAREHOUSE_JWT_SECRET":            "secret",
+		"WAREHOUSE_TRACING_EXPORTER":      "otlp",
+		"WAREHOUSE_TRACING_OTLP_INSECURE": "true",
+		"WAREHOUSE_TRACING_SAMPLE_RATIO":  "0.25",
+	}))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "otlp", config.Tracing.Exporter)
+	assert.Equal(t, "localhost:4318", config.Tracing.OTLPEndpoint)
+	assert.True(t, config.Tracing.OTLPInsecure)
+	assert.Equal(t, 0.25, config.Tracing.SampleRatio)
+}
+
+func TestLoad_LoggingRedactList(t *testing.T) {
+	config, err := load("", lookupEnv(map[string]string{
+		"WAREHOUSE_JWT_SECRET": "secret",
+		"WAREHOUSE_LOG_LEVEL":  "debug",
+		"WAREHOUSE_LOG_REDACT": "authorization, destination,",
+	}))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "debug", config.Logging.Level)
+	assert.Equal(t, []string{"authorization", "destination"}, config.Logging.Redact)
+}
+
+func TestString_RedactsSecrets(t *testing.T) {
+	config := Default()
+	config.JWT.Secret = "myjwtsecret"
+
+	content := config.String()
+
+	// Assertions
+	assert.NotContains(t, content, "myjwtsecret")
+	assert.NotContains(t, content, "root:password")
+	assert.NotContains(t, content, "guest:guest")
+	assert.Equal(t, 3, strings.Count(content, redacted))
+	assert.Contains(t, content, "addr: :8003")
+	assert.Equal(t, "myjwtsecret", config.JWT.Secret)
+}
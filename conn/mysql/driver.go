@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+	"warehouse-service/metrics"
+)
+
+// instrumentedConnector hands out connections whose transactions record
+// their duration. The usecases keep using *sqlx.DB as they are.
+type instrumentedConnector struct {
+	driver.Connector
+}
+
+func (c instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn}, nil
+}
+
+// instrumentedConn passes the optional driver interfaces through, database/sql
+// would otherwise fall back to prepared statements, skip pings and never
+// reset or validate pooled connections.
+type instrumentedConn struct {
+	driver.Conn
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var tx driver.Tx
+	var err error
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedTx{Tx: tx, start: start}, nil
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *instrumentedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type instrumentedTx struct {
+	driver.Tx
+	start time.Time
+}
+
+func (t *instrumentedTx) Commit() error {
+	err := t.Tx.Commit()
+	t.observe("commit", err)
+	return err
+}
+
+func (t *instrumentedTx) Rollback() error {
+	err := t.Tx.Rollback()
+	t.observe("rollback", err)
+	return err
+}
+
+func (t *instrumentedTx) observe(result string, err error) {
+	if err != nil {
+		result += "_failed"
+	}
+	metrics.TransactionDuration.WithLabelValues(result).Observe(time.Since(t.start).Seconds())
+}
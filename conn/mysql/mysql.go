@@ -1,9 +1,10 @@
 package mysql
 
 import (
-	"log"
+	"database/sql"
+	"warehouse-service/config"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -11,11 +12,27 @@ var (
 	MySQL *sqlx.DB
 )
 
-func Connect() {
-	var err error
-	dsn := "root:password@tcp(127.0.0.1:3306)/test_database"
-	MySQL, err = sqlx.Connect("mysql", dsn)
+// Connect opens MySQL and pings it, the error is returned for the caller to
+// exit with.
+func Connect(mysqlConfig config.MySQL) error {
+	dsn, err := mysql.ParseDSN(mysqlConfig.DSN)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	// Repositories scan DATETIME columns into time.Time, which the driver
+	// only does with parseTime, whatever the DSN says.
+	dsn.ParseTime = true
+	connector, err := mysql.NewConnector(dsn)
+	if err != nil {
+		return err
+	}
+	MySQL = sqlx.NewDb(sql.OpenDB(instrumentedConnector{Connector: connector}), "mysql")
+	err = MySQL.Ping()
+	if err != nil {
+		return err
+	}
+	MySQL.SetMaxOpenConns(mysqlConfig.MaxOpenConns)
+	MySQL.SetMaxIdleConns(mysqlConfig.MaxIdleConns)
+	MySQL.SetConnMaxLifetime(mysqlConfig.ConnMaxLifetime)
+	return nil
 }
@@ -0,0 +1,103 @@
+// Package mysqltest provides a database for usecase tests whose repositories
+// are mocked: transactions begin, commit and roll back without a server, and
+// are counted so tests can assert what was committed.
+package mysqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DB counts the transactions committed and rolled back on it.
+type DB struct {
+	*sqlx.DB
+	mutex     sync.Mutex
+	commits   int
+	rollbacks int
+	commitErr error
+}
+
+func NewDB() *DB {
+	db := &DB{}
+	db.DB = sqlx.NewDb(sql.OpenDB(connector{db: db}), "mysql")
+	return db
+}
+
+func (d *DB) Commits() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.commits
+}
+
+// FailCommits makes the commits after it fail with err, as they do when the
+// connection drops before the server acknowledges them.
+func (d *DB) FailCommits(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.commitErr = err
+}
+
+func (d *DB) Rollbacks() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.rollbacks
+}
+
+type connector struct {
+	db *DB
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return conn{db: c.db}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return nil
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("mysqltest: statements are not supported, mock the repository")
+}
+
+func (c conn) Close() error {
+	return nil
+}
+
+func (c conn) Begin() (driver.Tx, error) {
+	return tx{db: c.db}, nil
+}
+
+// BeginTx accepts any isolation level, there is nothing to isolate.
+func (c conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return tx{db: c.db}, nil
+}
+
+type tx struct {
+	db *DB
+}
+
+func (t tx) Commit() error {
+	t.db.mutex.Lock()
+	defer t.db.mutex.Unlock()
+	if t.db.commitErr != nil {
+		return t.db.commitErr
+	}
+	t.db.commits++
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.db.mutex.Lock()
+	defer t.db.mutex.Unlock()
+	t.db.rollbacks++
+	return nil
+}
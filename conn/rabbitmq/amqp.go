@@ -0,0 +1,65 @@
+package rabbitmq
+
+import (
+	"context"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Connection is the part of an amqp091 connection the manager and the
+// consumer use, so they can be run against a fake broker in tests.
+type Connection interface {
+	Channel() (Channel, error)
+	NotifyClose(receiver chan *amqp091.Error) chan *amqp091.Error
+	IsClosed() bool
+	Close() error
+}
+
+// Channel is the part of an amqp091 channel the manager and the consumer use.
+// PublishConfirmed publishes on a channel in confirm mode and waits for the
+// broker's ack.
+type Channel interface {
+	Confirm(noWait bool) error
+	PublishConfirmed(ctx context.Context, exchange string, routingKey string, publishing amqp091.Publishing) (bool, error)
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp091.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp091.Table) (amqp091.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp091.Table) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp091.Table) (<-chan amqp091.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	NotifyClose(receiver chan *amqp091.Error) chan *amqp091.Error
+	IsClosed() bool
+	Close() error
+}
+
+type amqpConnection struct {
+	*amqp091.Connection
+}
+
+func dialAMQP(url string) (Connection, error) {
+	conn, err := amqp091.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return &amqpConnection{Connection: conn}, nil
+}
+
+func (c *amqpConnection) Channel() (Channel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return &amqpChannel{Channel: ch}, nil
+}
+
+type amqpChannel struct {
+	*amqp091.Channel
+}
+
+func (c *amqpChannel) PublishConfirmed(ctx context.Context, exchange string, routingKey string, publishing amqp091.Publishing) (bool, error) {
+	confirmation, err := c.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, publishing)
+	if err != nil {
+		return false, err
+	}
+	return confirmation.WaitContext(ctx)
+}
@@ -1,123 +1,388 @@
 package rabbitmq
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"warehouse-service/config"
 	"warehouse-service/entity"
+	"warehouse-service/lifecycle"
+	"warehouse-service/logging"
+	"warehouse-service/metrics"
+	"warehouse-service/tracing"
 
 	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type StockHandler interface {
-	TransferStock(data interface{}) error
-	AddStock(data interface{}) error
-	DeductStock(data interface{}) error
-	ReleaseReservedStock(data interface{}) error
-	ReturnReservedStock(data interface{}) error
-	ReserveStock(data interface{}) error
+	TransferStock(ctx context.Context, data interface{}) error
+	AddStock(ctx context.Context, data interface{}) error
+	DeductStock(ctx context.Context, data interface{}) error
+	ReleaseReservedStock(ctx context.Context, data interface{}) error
+	ReturnReservedStock(ctx context.Context, data interface{}) error
+	ReserveStock(ctx context.Context, data interface{}) error
 }
 
 type RabbitConsumer struct {
-	rabbitConn   *amqp091.Connection
+	manager      *ConnectionManager
+	rabbitConfig config.RabbitMQ
 	stockHandler StockHandler
+	mutex        sync.Mutex
+	channels     map[string]Channel
+	locks        *keyLocks
+	stopped      bool
+	dispatching  sync.WaitGroup
+	logger       *slog.Logger
 }
 
-func NewRabbitConsumer(rabbitConn *amqp091.Connection, stockHandler StockHandler) *RabbitConsumer {
+func NewRabbitConsumer(manager *ConnectionManager, rabbitConfig config.RabbitMQ, stockHandler StockHandler, logger *slog.Logger) *RabbitConsumer {
 	return &RabbitConsumer{
-		rabbitConn:   rabbitConn,
+		manager:      manager,
+		rabbitConfig: rabbitConfig,
 		stockHandler: stockHandler,
+		channels:     make(map[string]Channel),
+		locks:        newKeyLocks(),
+		logger:       logger,
 	}
 }
 
+// ConsumeEvents starts the consumers on the current connection and again on
+// every reconnect.
 func (r *RabbitConsumer) ConsumeEvents() {
-	ch, err := r.rabbitConn.Channel()
-	if err != nil {
-		log.Fatal(err)
+	r.manager.OnConnect(r.consume)
+	r.logger.Info("consumer started")
+}
+
+// Stop cancels the consumers and waits until the deliveries already received
+// are handled and acked, or ctx is done. Consumers are not started again.
+func (r *RabbitConsumer) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	r.stopped = true
+	for queueName, ch := range r.channels {
+		ch.Cancel(queueName, false)
 	}
-	defer ch.Close()
+	r.mutex.Unlock()
 
-	err = ch.ExchangeDeclare(
-		exhangeName,
-		"topic",
-		true, false, false, false, nil)
+	return lifecycle.WaitGroup(ctx, &r.dispatching)
+}
+
+// Check fails when a queue has no running consumer, after Stop or while its
+// channel or the connection is being replaced.
+func (r *RabbitConsumer) Check(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopped {
+		return entity.ErrorConsumerNotRunning
+	}
+
+	var missing []string
+	for _, queueName := range r.topics() {
+		if _, ok := r.channels[queueName]; !ok {
+			missing = append(missing, queueName)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s", entity.ErrorConsumerNotRunning, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// topics maps each consumed routing key to its queue.
+func (r *RabbitConsumer) topics() map[string]string {
+	queues := r.rabbitConfig.Queues
+	return map[string]string{
+		entity.StockTransferEvent: queues.StockTransfer,
+		entity.StockAddEvent:      queues.StockAdd,
+		entity.StockDeductEvent:   queues.StockDeduct,
+		entity.StockReleaseEvent:  queues.StockRelease,
+		entity.StockReturnEvent:   queues.StockReturn,
+		entity.StockReserveEvent:  queues.StockReserve,
+	}
+}
+
+func (r *RabbitConsumer) consume(conn Connection) error {
+	for routingKey, queueName := range r.topics() {
+		err := r.startConsumer(conn, queueName, routingKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startConsumer consumes the queue on a channel of its own, so a slow queue
+// only holds back its own deliveries. A channel error restarts the queue
+// alone while the connection stays up.
+func (r *RabbitConsumer) startConsumer(conn Connection, queueName, routingKey string) error {
+	ch, err := conn.Channel()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	topics := map[string]string{
-		entity.StockTransferEvent: "queue_stock_transfer",
-		entity.StockAddEvent:      "queue_stock_add",
-		entity.StockDeductEvent:   "queue_stock_deduct",
-		entity.StockReleaseEvent:  "queue_stock_release",
-		entity.StockReturnEvent:   "queue_stock_return",
-		entity.StockReserveEvent:  "queue_stock_reserve",
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopped {
+		ch.Close()
+		return nil
 	}
 
-	for routingKey, queueName := range topics {
-		go r.startConsumer(ch, queueName, routingKey)
+	msgs, err := r.subscribe(ch, queueName, routingKey)
+	if err != nil {
+		ch.Close()
+		return err
 	}
+	r.channels[queueName] = ch
 
-	log.Println("consumer started")
-	select {}
+	closed := ch.NotifyClose(make(chan *amqp091.Error, 1))
+	r.dispatching.Add(1)
+	go func() {
+		r.dispatch(msgs, queueName, r.workers(routingKey))
+		r.mutex.Lock()
+		if r.channels[queueName] == ch {
+			delete(r.channels, queueName)
+		}
+		r.mutex.Unlock()
+		r.dispatching.Done()
+
+		closeErr := <-closed
+		if closeErr == nil || conn.IsClosed() || r.isStopped() {
+			return
+		}
+		r.logger.Warn("consumer channel closed", "queue", queueName, "error", closeErr)
+		err := r.startConsumer(conn, queueName, routingKey)
+		if err != nil {
+			r.logger.Error("consumer restart failed", "queue", queueName, "error", err)
+			r.manager.Reconnect(conn)
+		}
+	}()
+	return nil
 }
 
-func (r *RabbitConsumer) startConsumer(ch *amqp091.Channel, queueName, routingKey string) {
+func (r *RabbitConsumer) subscribe(ch Channel, queueName, routingKey string) (<-chan amqp091.Delivery, error) {
+	err := ch.Qos(r.rabbitConfig.Prefetch, 0, false)
+	if err != nil {
+		return nil, err
+	}
+
 	q, err := ch.QueueDeclare(
 		queueName,
 		true, false, false, false, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	err = ch.QueueBind(q.Name, routingKey, exhangeName, false, nil)
+	err = ch.QueueBind(q.Name, routingKey, r.rabbitConfig.Exchange, false, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	msgs, err := ch.Consume(q.Name, "", false, false, false, false, nil)
-	if err != nil {
-		log.Fatal(err)
+	return ch.Consume(q.Name, queueName, false, false, false, false, nil)
+}
+
+func (r *RabbitConsumer) isStopped() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.stopped
+}
+
+// dispatch hands the deliveries to the workers until the channel closes and
+// waits for the handled ones to be acked. A body that does not decode is
+// handed over with its error and dead lettered.
+func (r *RabbitConsumer) dispatch(msgs <-chan amqp091.Delivery, queueName string, workers int) {
+	partitioner := newPartitioner(r.locks, workers)
+	for d := range msgs {
+		var event Event
+		decodeErr := json.Unmarshal(d.Body, &event)
+		if decodeErr != nil {
+			decodeErr = fmt.Errorf("%w: %w", entity.ErrorInvalidBody, decodeErr)
+		}
+
+		delivery := d
+		partitioner.Submit(partitionKeys(event.Data), func() {
+			r.handleDelivery(delivery, queueName, event, decodeErr)
+		})
 	}
+	partitioner.Wait()
+}
 
-	go func() {
-		for d := range msgs {
-			var event Event
-			json.Unmarshal(d.Body, &event)
-			log.Printf("Received from %s: %+v\n", queueName, event)
-			err := r.handleEvent(event)
-			if err != nil {
-				if err.Error() == entity.ErrorInsufficientStock {
-					log.Printf("Error processing event with data %s: %v\n", event, err)
-					d.Ack(false)
-				} else {
-					log.Printf("Error processing event with data %s: %v\n", event, err)
-					d.Nack(false, true)
-				}
-			} else {
-				log.Printf("Succes processing event with data: %v\n", event)
-				d.Ack(false)
-			}
+// handleDelivery gives the handler the event timeout, an event running out of
+// time is nacked and redelivered. The handling continues the trace of the
+// publisher from the message headers, and logs with the message id and the
+// order and product ids of the event. An event that failed to decode is not
+// handled, it fails with decodeErr.
+func (r *RabbitConsumer) handleDelivery(d amqp091.Delivery, queueName string, event Event, decodeErr error) {
+	start := time.Now()
+	messageId := d.MessageId
+	if messageId == "" {
+		messageId = logging.NewID()
+	}
+	ctx := logging.With(context.Background(), append([]any{logging.MessageID, messageId}, eventFields(event.Data)...)...)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier(d.Headers))
+	ctx, span := tracing.Start(ctx, d.RoutingKey+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", queueName),
+			attribute.Bool("messaging.rabbitmq.redelivered", d.Redelivered),
+		))
+	ctx, cancel := context.WithTimeout(ctx, r.rabbitConfig.EventTimeout)
+	defer cancel()
+
+	r.logger.DebugContext(ctx, "event received", "event", event.Type, "queue", queueName, "redelivered", d.Redelivered)
+	err := decodeErr
+	if err == nil {
+		err = r.handleEvent(ctx, event)
+	}
+	tracing.End(span, err)
+	durationMs := time.Since(start).Milliseconds()
+	metrics.ConsumerMessagesProcessed.WithLabelValues(queueName).Inc()
+	if err == nil {
+		r.logger.DebugContext(ctx, "event handled", "event", event.Type, "duration_ms", durationMs)
+		d.Ack(false)
+		metrics.ConsumerMessagesAcked.WithLabelValues(queueName).Inc()
+		return
+	}
+
+	switch outcomeOf(err) {
+	case outcomeDrop:
+		r.logger.WarnContext(ctx, "event dropped", "event", event.Type, "duration_ms", durationMs, "error", err)
+		d.Ack(false)
+		metrics.ConsumerMessagesAcked.WithLabelValues(queueName).Inc()
+	case outcomeDeadLetter:
+		deadLetterErr := r.deadLetter(ctx, d, queueName, err)
+		if deadLetterErr != nil {
+			r.logger.ErrorContext(ctx, "event requeued", "event", event.Type, "duration_ms", durationMs, "error", err, "dead_letter_error", deadLetterErr)
+			d.Nack(false, true)
+			metrics.ConsumerMessagesNacked.WithLabelValues(queueName, "true").Inc()
+			return
 		}
-	}()
+		r.logger.WarnContext(ctx, "event dead lettered", "event", event.Type, "duration_ms", durationMs, "error", err)
+		d.Ack(false)
+		metrics.ConsumerMessagesAcked.WithLabelValues(queueName).Inc()
+		metrics.ConsumerMessagesDeadLettered.WithLabelValues(queueName).Inc()
+	default:
+		r.logger.ErrorContext(ctx, "event requeued", "event", event.Type, "duration_ms", durationMs, "error", err)
+		d.Nack(false, true)
+		metrics.ConsumerMessagesNacked.WithLabelValues(queueName, "true").Inc()
+	}
 }
 
-func (r *RabbitConsumer) handleEvent(event Event) error {
+// outcome is what becomes of a delivery its handler failed on.
+type outcome int
+
+const (
+	// outcomeRequeue redelivers the event, the error may pass.
+	outcomeRequeue outcome = iota
+	// outcomeDrop acks the event, it was rejected by the stock rules and
+	// would be rejected again.
+	outcomeDrop
+	// outcomeDeadLetter moves the event to the dead letter exchange, it is
+	// malformed, refers to data that does not exist or would push a
+	// warehouse over its capacity, which needs someone to look at it.
+	outcomeDeadLetter
+)
+
+// outcomeOf decides from the kind of the domain error. Errors that are not
+// domain errors, such as a lost database connection or the event timeout,
+// are requeued.
+func outcomeOf(err error) outcome {
+	domainError, ok := entity.AsDomainError(err)
+	if !ok {
+		return outcomeRequeue
+	}
+	switch domainError.Kind {
+	case entity.KindInsufficientStock, entity.KindConflict, entity.KindInvalidState:
+		return outcomeDrop
+	case entity.KindBadRequest, entity.KindValidation, entity.KindNotFound, entity.KindOverCapacity:
+		return outcomeDeadLetter
+	default:
+		return outcomeRequeue
+	}
+}
+
+// deadLetter publishes the delivery as it was received to the dead letter
+// exchange, with the queue and the error in the headers.
+func (r *RabbitConsumer) deadLetter(ctx context.Context, d amqp091.Delivery, queueName string, err error) error {
+	headers := amqp091.Table{}
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	headers["x-queue"] = queueName
+	headers["x-error"] = err.Error()
+	if domainError, ok := entity.AsDomainError(err); ok {
+		headers["x-error-code"] = domainError.Code
+	}
+
+	// the event timeout may be what is left of ctx
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.rabbitConfig.PublishTimeout)
+	defer cancel()
+	return r.manager.PublishDeadLetter(ctx, d.RoutingKey, amqp091.Publishing{
+		Headers:      headers,
+		MessageId:    d.MessageId,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp091.Persistent,
+		Body:         d.Body,
+	})
+}
+
+func (r *RabbitConsumer) workers(routingKey string) int {
+	if workers, ok := r.rabbitConfig.WorkersByEvent[routingKey]; ok {
+		return workers
+	}
+	return r.rabbitConfig.Workers
+}
+
+func (r *RabbitConsumer) handleEvent(ctx context.Context, event Event) error {
 	switch event.Type {
 	case entity.StockTransferEvent:
-		return r.stockHandler.TransferStock(event.Data)
+		return r.stockHandler.TransferStock(ctx, event.Data)
 	case entity.StockAddEvent:
-		return r.stockHandler.AddStock(event.Data)
+		return r.stockHandler.AddStock(ctx, event.Data)
 	case entity.StockDeductEvent:
-		return r.stockHandler.DeductStock(event.Data)
+		return r.stockHandler.DeductStock(ctx, event.Data)
 	case entity.StockReleaseEvent:
-		return r.stockHandler.ReleaseReservedStock(event.Data)
+		return r.stockHandler.ReleaseReservedStock(ctx, event.Data)
 	case entity.StockReturnEvent:
-		return r.stockHandler.ReturnReservedStock(event.Data)
+		return r.stockHandler.ReturnReservedStock(ctx, event.Data)
 	case entity.StockReserveEvent:
-		return r.stockHandler.ReserveStock(event.Data)
+		return r.stockHandler.ReserveStock(ctx, event.Data)
 	default:
-		fmt.Println("Unknown event:", event.Type)
+		r.logger.WarnContext(ctx, "unknown event", "event", event.Type)
 		return nil
 	}
 }
+
+// eventFields returns the order id and the product ids of the event data as
+// log fields, for the events carrying them.
+func eventFields(data interface{}) []any {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	args := []any{}
+	if orderId, ok := fields["order_id"]; ok {
+		args = append(args, logging.OrderID, orderId)
+	}
+	if productId, ok := fields["product_id"]; ok {
+		args = append(args, logging.ProductID, productId)
+	} else if stockOperations, ok := fields["stock_operations"].([]interface{}); ok {
+		productIds := []interface{}{}
+		for _, stockOperation := range stockOperations {
+			if operation, ok := stockOperation.(map[string]interface{}); ok {
+				productIds = append(productIds, operation["product_id"])
+			}
+		}
+		args = append(args, logging.ProductID, productIds)
+	}
+	return args
+}
@@ -0,0 +1,82 @@
+package rabbitmq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"warehouse-service/entity"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAcknowledger records how the deliveries were settled.
+type fakeAcknowledger struct {
+	mutex sync.Mutex
+	acks  int
+	nacks int
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.acks++
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.nacks++
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestOutcomeOf(t *testing.T) {
+	// Assertions
+	assert.Equal(t, outcomeDrop, outcomeOf(entity.ErrorInsufficientStock))
+	assert.Equal(t, outcomeDeadLetter, outcomeOf(fmt.Errorf("transfer: %w", entity.ErrorOverCapacity)))
+	assert.Equal(t, outcomeDrop, outcomeOf(entity.ErrorInboundNotPending))
+	assert.Equal(t, outcomeDeadLetter, outcomeOf(entity.NewValidationError(entity.FieldError{Field: "quantity", Message: "is required"})))
+	assert.Equal(t, outcomeDeadLetter, outcomeOf(fmt.Errorf("%w: %w", entity.ErrorInvalidBody, errors.New("unexpected end of JSON input"))))
+	assert.Equal(t, outcomeDeadLetter, outcomeOf(sql.ErrNoRows))
+	assert.Equal(t, outcomeRequeue, outcomeOf(entity.ErrorBrokerNotConnected))
+	assert.Equal(t, outcomeRequeue, outcomeOf(context.DeadlineExceeded))
+	assert.Equal(t, outcomeRequeue, outcomeOf(errors.New("connection refused")))
+}
+
+func TestDispatch_DeadLettersAnUndecodableBody(t *testing.T) {
+	broker := &fakeBroker{}
+	manager := newTestManager(broker)
+	manager.Connect()
+	defer manager.Close()
+	consumer := NewRabbitConsumer(manager, testRabbitConfig, nil, slog.Default())
+	acknowledger := &fakeAcknowledger{}
+	msgs := make(chan amqp091.Delivery, 1)
+	msgs <- amqp091.Delivery{
+		Acknowledger: acknowledger,
+		RoutingKey:   entity.StockAddEvent,
+		MessageId:    "message-1",
+		Body:         []byte(`{"type":`),
+	}
+	close(msgs)
+
+	consumer.dispatch(msgs, "stock.add", 1)
+
+	// Assertions
+	published := broker.connection(0).channel(1)
+	assert.Equal(t, 1, published.publishedCount())
+	assert.Equal(t, []string{"stock_events.dlx"}, published.exchanges)
+	assert.Equal(t, "invalid_body", published.published[0].Headers["x-error-code"])
+	assert.Equal(t, "stock.add", published.published[0].Headers["x-queue"])
+	assert.Equal(t, []byte(`{"type":`), published.published[0].Body)
+	assert.Equal(t, 1, acknowledger.acks)
+	assert.Equal(t, 0, acknowledger.nacks)
+}
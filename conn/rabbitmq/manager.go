@@ -0,0 +1,340 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"warehouse-service/config"
+	"warehouse-service/entity"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+var errManagerClosed = errors.New("rabbitmq: connection manager closed")
+
+// Status is the state of the broker connection reported to readiness checks.
+type Status struct {
+	Connected  bool      `json:"connected"`
+	Since      time.Time `json:"since"`
+	LastError  string    `json:"last_error,omitempty"`
+	Reconnects int       `json:"reconnects"`
+}
+
+// ConnectionManager keeps one connection to the broker open. When the
+// connection closes it redials with backoff, declares the exchange again,
+// reruns the consumer setups and refills the pool of publisher channels.
+type ConnectionManager struct {
+	rabbitConfig config.RabbitMQ
+	dialer       func(url string) (Connection, error)
+	mutex        sync.RWMutex
+	conn         Connection
+	channels     chan Channel
+	setups       []func(conn Connection) error
+	status       Status
+	closing      chan struct{}
+	closeOnce    sync.Once
+	logger       *slog.Logger
+}
+
+func NewConnectionManager(rabbitConfig config.RabbitMQ, logger *slog.Logger) *ConnectionManager {
+	return &ConnectionManager{
+		rabbitConfig: rabbitConfig,
+		dialer:       dialAMQP,
+		logger:       logger,
+		status:       Status{Since: time.Now()},
+		closing:      make(chan struct{}),
+	}
+}
+
+// Connect blocks until the first connection is up, then keeps it open in the
+// background until Close. Run it on its own goroutine to serve without the
+// broker: publishes fail with entity.ErrorBrokerNotConnected and Check reports
+// the broker down until the connection is up, consumer setups registered with
+// OnConnect run once it is.
+func (m *ConnectionManager) Connect() {
+	if m.connect() {
+		go m.watch()
+	}
+}
+
+// OnConnect registers setup to run on every new connection, and right away
+// when connected already. A setup error closes the connection, so it is
+// retried on the next one.
+func (m *ConnectionManager) OnConnect(setup func(conn Connection) error) {
+	m.mutex.Lock()
+	m.setups = append(m.setups, setup)
+	conn := m.conn
+	m.mutex.Unlock()
+
+	if conn != nil {
+		err := setup(conn)
+		if err != nil {
+			m.logger.Error("rabbitmq setup failed", "error", err)
+			m.Reconnect(conn)
+		}
+	}
+}
+
+// Reconnect closes conn to have it replaced, unless it was replaced already.
+func (m *ConnectionManager) Reconnect(conn Connection) {
+	m.mutex.RLock()
+	current := m.conn == conn
+	m.mutex.RUnlock()
+	if current {
+		conn.Close()
+	}
+}
+
+func (m *ConnectionManager) Status() Status {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.status
+}
+
+// Check fails when the connection is down. It reads the state of the
+// connection instead of opening a channel, so probes put no load on the
+// broker and a closed connection fails before the watcher has reconnected.
+func (m *ConnectionManager) Check(ctx context.Context) error {
+	m.mutex.RLock()
+	conn, status := m.conn, m.status
+	m.mutex.RUnlock()
+	if !status.Connected || conn.IsClosed() {
+		if status.LastError != "" {
+			return fmt.Errorf("%w: %s", entity.ErrorBrokerNotConnected, status.LastError)
+		}
+		return entity.ErrorBrokerNotConnected
+	}
+	return nil
+}
+
+// Publish sends the message to the exchange on a pooled channel and waits
+// for the broker to confirm it.
+func (m *ConnectionManager) Publish(ctx context.Context, routingKey string, publishing amqp091.Publishing) error {
+	return m.publish(ctx, m.rabbitConfig.Exchange, routingKey, publishing)
+}
+
+// PublishDeadLetter sends the message to the dead letter exchange, confirmed
+// like Publish.
+func (m *ConnectionManager) PublishDeadLetter(ctx context.Context, routingKey string, publishing amqp091.Publishing) error {
+	return m.publish(ctx, m.rabbitConfig.DeadLetterExchange, routingKey, publishing)
+}
+
+func (m *ConnectionManager) publish(ctx context.Context, exchange string, routingKey string, publishing amqp091.Publishing) error {
+	m.mutex.RLock()
+	conn, channels, connected := m.conn, m.channels, m.status.Connected
+	m.mutex.RUnlock()
+	if !connected {
+		return entity.ErrorBrokerNotConnected
+	}
+
+	var ch Channel
+	select {
+	case ch = <-channels:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// A channel closed by a channel error is replaced on its next use, the
+	// closed one stays in the pool when that fails so the pool keeps its size.
+	defer func() { channels <- ch }()
+	if ch.IsClosed() {
+		reopened, err := openPublisherChannel(conn)
+		if err != nil {
+			return err
+		}
+		ch = reopened
+	}
+
+	acked, err := ch.PublishConfirmed(ctx, exchange, routingKey, publishing)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return entity.ErrorPublishNotConfirmed
+	}
+	return nil
+}
+
+func (m *ConnectionManager) Close() {
+	m.closeOnce.Do(func() { close(m.closing) })
+
+	m.mutex.Lock()
+	conn := m.conn
+	m.status.Connected = false
+	m.status.Since = time.Now()
+	m.mutex.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// connect dials with backoff until it succeeds, or returns false once the
+// manager is closed.
+func (m *ConnectionManager) connect() bool {
+	backoff := m.rabbitConfig.ReconnectMinBackoff
+	for {
+		err := m.dial()
+		if err == nil {
+			return true
+		}
+		if errors.Is(err, errManagerClosed) {
+			return false
+		}
+
+		m.mutex.Lock()
+		m.status.LastError = err.Error()
+		m.mutex.Unlock()
+		m.logger.Warn("rabbitmq connection failed", "retry_in", backoff.String(), "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-m.closing:
+			return false
+		}
+		backoff = nextBackoff(backoff, m.rabbitConfig.ReconnectMaxBackoff)
+	}
+}
+
+func (m *ConnectionManager) dial() error {
+	conn, err := m.dialer(m.rabbitConfig.URL)
+	if err != nil {
+		return err
+	}
+
+	err = m.declareTopology(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	channels := make(chan Channel, m.rabbitConfig.PublisherChannels)
+	for i := 0; i < m.rabbitConfig.PublisherChannels; i++ {
+		ch, err := openPublisherChannel(conn)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		channels <- ch
+	}
+
+	// Close may have run while dialing, it must not leave this connection open
+	m.mutex.Lock()
+	select {
+	case <-m.closing:
+		m.mutex.Unlock()
+		conn.Close()
+		return errManagerClosed
+	default:
+	}
+	m.conn = conn
+	m.channels = channels
+	setups := append([]func(conn Connection) error{}, m.setups...)
+	m.mutex.Unlock()
+
+	for _, setup := range setups {
+		err = setup(conn)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	m.mutex.Lock()
+	m.status.Connected = true
+	m.status.Since = time.Now()
+	m.status.LastError = ""
+	m.mutex.Unlock()
+	m.logger.Info("rabbitmq connected")
+	return nil
+}
+
+func (m *ConnectionManager) declareTopology(conn Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	err = ch.ExchangeDeclare(
+		m.rabbitConfig.Exchange,
+		"topic",
+		true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	err = ch.ExchangeDeclare(
+		m.rabbitConfig.DeadLetterExchange,
+		"topic",
+		true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	q, err := ch.QueueDeclare(
+		m.rabbitConfig.DeadLetterQueue,
+		true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	return ch.QueueBind(q.Name, "#", m.rabbitConfig.DeadLetterExchange, false, nil)
+}
+
+// watch replaces the connection whenever it closes, until Close.
+func (m *ConnectionManager) watch() {
+	for {
+		m.mutex.RLock()
+		conn := m.conn
+		m.mutex.RUnlock()
+
+		closed := conn.NotifyClose(make(chan *amqp091.Error, 1))
+		select {
+		case err := <-closed:
+			select {
+			case <-m.closing:
+				return
+			default:
+			}
+
+			m.mutex.Lock()
+			m.status.Connected = false
+			m.status.Since = time.Now()
+			if err != nil {
+				m.status.LastError = err.Error()
+			}
+			m.mutex.Unlock()
+			m.logger.Error("rabbitmq connection lost", "error", err)
+
+			if !m.connect() {
+				return
+			}
+			m.mutex.Lock()
+			m.status.Reconnects++
+			m.mutex.Unlock()
+		case <-m.closing:
+			return
+		}
+	}
+}
+
+func openPublisherChannel(conn Connection) (Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	err = ch.Confirm(false)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+	return ch, nil
+}
+
+func nextBackoff(backoff time.Duration, maxBackoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
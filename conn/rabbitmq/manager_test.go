@@ -0,0 +1,361 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"warehouse-service/config"
+	"warehouse-service/entity"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBroker dials fake connections, failing the first failures dials.
+type fakeBroker struct {
+	mutex       sync.Mutex
+	failures    int
+	connections []*fakeConnection
+}
+
+func (b *fakeBroker) dial(url string) (Connection, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.failures > 0 {
+		b.failures--
+		return nil, errors.New("connection refused")
+	}
+	conn := &fakeConnection{}
+	b.connections = append(b.connections, conn)
+	return conn, nil
+}
+
+func (b *fakeBroker) connection(i int) *fakeConnection {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.connections[i]
+}
+
+func (b *fakeBroker) connectionCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.connections)
+}
+
+type fakeConnection struct {
+	mutex     sync.Mutex
+	channels  []*fakeChannel
+	receivers []chan *amqp091.Error
+	closed    bool
+}
+
+func (c *fakeConnection) Channel() (Channel, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ch := &fakeChannel{acked: true}
+	c.channels = append(c.channels, ch)
+	return ch, nil
+}
+
+// NotifyClose closes receiver right away on a closed connection, as amqp091
+// does.
+func (c *fakeConnection) NotifyClose(receiver chan *amqp091.Error) chan *amqp091.Error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		close(receiver)
+		return receiver
+	}
+	c.receivers = append(c.receivers, receiver)
+	return receiver
+}
+
+func (c *fakeConnection) IsClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+func (c *fakeConnection) Close() error {
+	c.drop(nil)
+	return nil
+}
+
+// drop closes the connection like the broker would, with err as the reason.
+func (c *fakeConnection) drop(err *amqp091.Error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for _, receiver := range c.receivers {
+		if err != nil {
+			receiver <- err
+		}
+		close(receiver)
+	}
+}
+
+func (c *fakeConnection) channel(i int) *fakeChannel {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.channels[i]
+}
+
+func (c *fakeConnection) channelCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.channels)
+}
+
+type fakeChannel struct {
+	mutex     sync.Mutex
+	acked     bool
+	confirmed bool
+	closed    bool
+	exchanges []string
+	published []amqp091.Publishing
+}
+
+func (c *fakeChannel) Confirm(noWait bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.confirmed = true
+	return nil
+}
+
+func (c *fakeChannel) PublishConfirmed(ctx context.Context, exchange string, routingKey string, publishing amqp091.Publishing) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return false, amqp091.ErrClosed
+	}
+	c.exchanges = append(c.exchanges, exchange)
+	c.published = append(c.published, publishing)
+	return c.acked, nil
+}
+
+func (c *fakeChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp091.Table) error {
+	return nil
+}
+
+func (c *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp091.Table) (amqp091.Queue, error) {
+	return amqp091.Queue{Name: name}, nil
+}
+
+func (c *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp091.Table) error {
+	return nil
+}
+
+func (c *fakeChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	return nil
+}
+
+func (c *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp091.Table) (<-chan amqp091.Delivery, error) {
+	return make(chan amqp091.Delivery), nil
+}
+
+func (c *fakeChannel) Cancel(consumer string, noWait bool) error {
+	return nil
+}
+
+func (c *fakeChannel) NotifyClose(receiver chan *amqp091.Error) chan *amqp091.Error {
+	return receiver
+}
+
+func (c *fakeChannel) IsClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+func (c *fakeChannel) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeChannel) publishedCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.published)
+}
+
+var testRabbitConfig = config.RabbitMQ{
+	Exchange:            "stock_events",
+	DeadLetterExchange:  "stock_events.dlx",
+	PublisherChannels:   1,
+	PublishTimeout:      time.Second,
+	EventTimeout:        time.Second,
+	ReconnectMinBackoff: time.Millisecond,
+	ReconnectMaxBackoff: 4 * time.Millisecond,
+}
+
+func newTestManager(broker *fakeBroker) *ConnectionManager {
+	manager := NewConnectionManager(testRabbitConfig, slog.Default())
+	manager.dialer = broker.dial
+	return manager
+}
+
+func TestNextBackoff(t *testing.T) {
+	// Assertions
+	assert.Equal(t, 2*time.Second, nextBackoff(time.Second, 30*time.Second))
+	assert.Equal(t, 30*time.Second, nextBackoff(20*time.Second, 30*time.Second))
+	assert.Equal(t, 30*time.Second, nextBackoff(30*time.Second, 30*time.Second))
+}
+
+func TestConnect_RetriesUntilTheBrokerIsUp(t *testing.T) {
+	broker := &fakeBroker{failures: 3}
+	manager := newTestManager(broker)
+
+	manager.Connect()
+	defer manager.Close()
+
+	// Assertions
+	assert.Equal(t, 1, broker.connectionCount())
+	assert.True(t, manager.Status().Connected)
+	assert.Empty(t, manager.Status().LastError)
+}
+
+func TestConnect_InTheBackground(t *testing.T) {
+	broker := &fakeBroker{failures: 1000}
+	manager := newTestManager(broker)
+	connected := make(chan struct{})
+	var setups atomic.Int32
+	manager.OnConnect(func(conn Connection) error {
+		setups.Add(1)
+		return nil
+	})
+
+	go func() {
+		manager.Connect()
+		close(connected)
+	}()
+	errCheck := manager.Check(context.Background())
+	errPublish := manager.Publish(context.Background(), entity.StockAddEvent, amqp091.Publishing{})
+	manager.Close()
+
+	// Assertions
+	assert.ErrorIs(t, errCheck, entity.ErrorBrokerNotConnected)
+	assert.ErrorIs(t, errPublish, entity.ErrorBrokerNotConnected)
+	assert.Eventually(t, func() bool {
+		select {
+		case <-connected:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	assert.EqualValues(t, 0, setups.Load())
+	assert.False(t, manager.Status().Connected)
+}
+
+func TestConnect_ClosedWhileDialing(t *testing.T) {
+	broker := &fakeBroker{}
+	manager := newTestManager(broker)
+	manager.Close()
+
+	err := manager.dial()
+
+	// Assertions
+	assert.ErrorIs(t, err, errManagerClosed)
+	assert.True(t, broker.connection(0).IsClosed())
+	assert.False(t, manager.Status().Connected)
+}
+
+func TestWatch_ReconnectsWhenTheConnectionCloses(t *testing.T) {
+	broker := &fakeBroker{}
+	manager := newTestManager(broker)
+	var setups atomic.Int32
+	manager.OnConnect(func(conn Connection) error {
+		setups.Add(1)
+		return nil
+	})
+
+	manager.Connect()
+	defer manager.Close()
+	broker.connection(0).drop(&amqp091.Error{Code: amqp091.ConnectionForced, Reason: "CONNECTION_FORCED"})
+
+	// Assertions
+	assert.Eventually(t, func() bool {
+		status := manager.Status()
+		return status.Connected && status.Reconnects == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 2, broker.connectionCount())
+	assert.EqualValues(t, 2, setups.Load())
+	assert.NoError(t, manager.Publish(context.Background(), entity.StockAddEvent, amqp091.Publishing{}))
+	assert.Equal(t, 1, broker.connection(1).channel(1).publishedCount())
+}
+
+func TestClose_StopsReconnecting(t *testing.T) {
+	broker := &fakeBroker{}
+	manager := newTestManager(broker)
+
+	manager.Connect()
+	manager.Close()
+
+	// Assertions
+	assert.Never(t, func() bool { return broker.connectionCount() > 1 }, 20*time.Millisecond, time.Millisecond)
+	assert.False(t, manager.Status().Connected)
+	assert.ErrorIs(t, manager.Publish(context.Background(), entity.StockAddEvent, amqp091.Publishing{}), entity.ErrorBrokerNotConnected)
+}
+
+func TestPublish_ReopensAClosedChannel(t *testing.T) {
+	broker := &fakeBroker{}
+	manager := newTestManager(broker)
+	manager.Connect()
+	defer manager.Close()
+	conn := broker.connection(0)
+	// channel 0 declared the topology, channel 1 is the pooled one
+	pooled := conn.channel(1)
+	pooled.Close()
+
+	err := manager.Publish(context.Background(), entity.StockAddEvent, amqp091.Publishing{})
+	errAgain := manager.Publish(context.Background(), entity.StockAddEvent, amqp091.Publishing{})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.NoError(t, errAgain)
+	assert.Equal(t, 3, conn.channelCount())
+	reopened := conn.channel(2)
+	assert.True(t, reopened.confirmed)
+	assert.Equal(t, 2, reopened.publishedCount())
+	assert.Equal(t, 0, pooled.publishedCount())
+}
+
+func TestPublish_NotConfirmed(t *testing.T) {
+	broker := &fakeBroker{}
+	manager := newTestManager(broker)
+	manager.Connect()
+	defer manager.Close()
+	broker.connection(0).channel(1).acked = false
+
+	err := manager.Publish(context.Background(), entity.StockAddEvent, amqp091.Publishing{})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorPublishNotConfirmed)
+}
+
+func TestCheck_ReadsTheConnectionState(t *testing.T) {
+	broker := &fakeBroker{}
+	manager := newTestManager(broker)
+	manager.Connect()
+	defer manager.Close()
+	conn := broker.connection(0)
+	channels := conn.channelCount()
+
+	err := manager.Check(context.Background())
+	conn.drop(nil)
+	errClosed := manager.Check(context.Background())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.ErrorIs(t, errClosed, entity.ErrorBrokerNotConnected)
+	assert.Equal(t, channels, conn.channelCount())
+}
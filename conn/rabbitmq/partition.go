@@ -0,0 +1,116 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sync"
+)
+
+// keyLocks holds the keys of the running tasks. One is shared by the
+// partitioners of every queue, so an event of a product on one queue never
+// runs alongside an event of the same product on another.
+type keyLocks struct {
+	mutex    sync.Mutex
+	released *sync.Cond
+	inFlight map[string]bool
+}
+
+func newKeyLocks() *keyLocks {
+	k := &keyLocks{
+		inFlight: make(map[string]bool),
+	}
+	k.released = sync.NewCond(&k.mutex)
+	return k
+}
+
+// lock blocks until none of the keys is held, then holds them all at once.
+func (k *keyLocks) lock(keys []string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	for k.held(keys) {
+		k.released.Wait()
+	}
+	for _, key := range keys {
+		k.inFlight[key] = true
+	}
+}
+
+func (k *keyLocks) unlock(keys []string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	for _, key := range keys {
+		delete(k.inFlight, key)
+	}
+	k.released.Broadcast()
+}
+
+func (k *keyLocks) held(keys []string) bool {
+	for _, key := range keys {
+		if k.inFlight[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// partitioner runs the tasks of one queue on at most workers goroutines.
+// Tasks sharing a key run one at a time, in the order they were submitted
+// within the queue, so two events of the same product are never handled
+// concurrently.
+type partitioner struct {
+	locks *keyLocks
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newPartitioner(locks *keyLocks, workers int) *partitioner {
+	return &partitioner{
+		locks: locks,
+		slots: make(chan struct{}, workers),
+	}
+}
+
+// Submit blocks until a worker is free and no running task holds one of the
+// keys, then starts task. It must be called from a single goroutine for the
+// submission order to hold.
+func (p *partitioner) Submit(keys []string, task func()) {
+	p.slots <- struct{}{}
+	p.locks.lock(keys)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		task()
+
+		p.locks.unlock(keys)
+		<-p.slots
+	}()
+}
+
+// Wait returns once every submitted task has finished.
+func (p *partitioner) Wait() {
+	p.wg.Wait()
+}
+
+// partitionKeys returns the products an event touches, or its order when the
+// event only names an order. Events without either are not ordered.
+func partitionKeys(data interface{}) []string {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if productId, ok := fields["product_id"]; ok {
+		return []string{fmt.Sprintf("product:%v", productId)}
+	}
+	if stockOperations, ok := fields["stock_operations"].([]interface{}); ok {
+		keys := []string{}
+		for _, stockOperation := range stockOperations {
+			keys = append(keys, partitionKeys(stockOperation)...)
+		}
+		return keys
+	}
+	if orderId, ok := fields["order_id"]; ok {
+		return []string{fmt.Sprintf("order:%v", orderId)}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionKeys(t *testing.T) {
+	decode := func(body string) interface{} {
+		var data interface{}
+		json.Unmarshal([]byte(body), &data)
+		return data
+	}
+
+	// Assertions
+	assert.Equal(t, []string{"product:7"}, partitionKeys(decode(`{"product_id":7,"warehouse_id":1,"quantity":2}`)))
+	assert.Equal(t, []string{"product:7", "product:9"}, partitionKeys(decode(`{"order_id":3,"stock_operations":[{"product_id":7},{"product_id":9}]}`)))
+	assert.Equal(t, []string{"order:3"}, partitionKeys(decode(`{"order_id":3}`)))
+	assert.Nil(t, partitionKeys(decode(`"unknown"`)))
+}
+
+func TestPartitioner_SameKeyInOrder(t *testing.T) {
+	partitioner := newPartitioner(newKeyLocks(), 4)
+
+	var mutex sync.Mutex
+	handled := []int{}
+	running := 0
+	maxRunning := 0
+	for i := 0; i < 20; i++ {
+		i := i
+		partitioner.Submit([]string{"product:1"}, func() {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mutex.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mutex.Lock()
+			running--
+			handled = append(handled, i)
+			mutex.Unlock()
+		})
+	}
+	partitioner.Wait()
+
+	// Assertions
+	assert.Equal(t, 1, maxRunning)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, handled)
+}
+
+func TestPartitioner_DistinctKeysConcurrent(t *testing.T) {
+	partitioner := newPartitioner(newKeyLocks(), 3)
+
+	// Every task waits for all three to start, which only happens when they
+	// run concurrently.
+	var started sync.WaitGroup
+	started.Add(3)
+	done := make(chan struct{})
+	go func() {
+		for _, key := range []string{"product:1", "product:2", "order:1"} {
+			partitioner.Submit([]string{key}, func() {
+				started.Done()
+				started.Wait()
+			})
+		}
+		partitioner.Wait()
+		close(done)
+	}()
+
+	// Assertions
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("tasks with distinct keys did not run concurrently")
+	}
+}
+
+func TestPartitioner_SharedKeyWaits(t *testing.T) {
+	partitioner := newPartitioner(newKeyLocks(), 2)
+
+	var mutex sync.Mutex
+	handled := []string{}
+	handle := func(name string) func() {
+		return func() {
+			time.Sleep(time.Millisecond)
+			mutex.Lock()
+			handled = append(handled, name)
+			mutex.Unlock()
+		}
+	}
+	partitioner.Submit([]string{"product:1", "product:2"}, handle("reserve"))
+	partitioner.Submit([]string{"product:2"}, handle("deduct"))
+	partitioner.Wait()
+
+	// Assertions
+	assert.Equal(t, []string{"reserve", "deduct"}, handled)
+}
+
+func TestPartitioner_SharedKeyAcrossQueues(t *testing.T) {
+	locks := newKeyLocks()
+	adds := newPartitioner(locks, 4)
+	deducts := newPartitioner(locks, 4)
+
+	var mutex sync.Mutex
+	running := 0
+	maxRunning := 0
+	handle := func() {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	}
+	var submitted sync.WaitGroup
+	for _, queue := range []*partitioner{adds, deducts} {
+		submitted.Add(1)
+		go func(queue *partitioner) {
+			defer submitted.Done()
+			for i := 0; i < 10; i++ {
+				queue.Submit([]string{"product:1"}, handle)
+			}
+			queue.Wait()
+		}(queue)
+	}
+	submitted.Wait()
+
+	// Assertions
+	assert.Equal(t, 1, maxRunning)
+}
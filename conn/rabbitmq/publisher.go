@@ -3,57 +3,80 @@ package rabbitmq
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
+	"warehouse-service/config"
+	"warehouse-service/logging"
+	"warehouse-service/metrics"
+	"warehouse-service/tracing"
 
 	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RabbitPublisher struct {
-	rabbitConn *amqp091.Connection
+	manager      *ConnectionManager
+	rabbitConfig config.RabbitMQ
+	logger       *slog.Logger
 }
 
-func NewRabbitPublisher(rabbitConn *amqp091.Connection) *RabbitPublisher {
+func NewRabbitPublisher(manager *ConnectionManager, rabbitConfig config.RabbitMQ, logger *slog.Logger) *RabbitPublisher {
 	return &RabbitPublisher{
-		rabbitConn: rabbitConn,
+		manager:      manager,
+		rabbitConfig: rabbitConfig,
+		logger:       logger,
 	}
 }
 
-func (r *RabbitPublisher) PublishEvent(eventType string, data interface{}) error {
-	ch, err := r.rabbitConn.Channel()
-	if err != nil {
-		return err
-	}
-	defer ch.Close()
-
-	err = ch.ExchangeDeclare(
-		exhangeName,
-		"topic",
-		true, false, false, false, nil,
-	)
-	if err != nil {
-		return err
-	}
+// PublishEvent returns once the broker confirmed the event, or when ctx is
+// done or the publish timeout passed.
+func (r *RabbitPublisher) PublishEvent(ctx context.Context, eventType string, data interface{}) error {
+	return r.Publish(ctx, logging.NewID(), eventType, data)
+}
 
+// Publish is PublishEvent with the message id given, the outbox keeps the
+// id of an event across retries.
+func (r *RabbitPublisher) Publish(ctx context.Context, messageId string, eventType string, data interface{}) error {
 	event := Event{
 		Type: eventType,
 		Data: data,
 	}
 	body, _ := json.Marshal(event)
+	// the consumer logs with the same message id
+	ctx = logging.With(ctx, logging.MessageID, messageId)
 
-	err = ch.PublishWithContext(
-		context.Background(),
-		exhangeName,
+	ctx, span := tracing.Start(ctx, eventType+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", r.rabbitConfig.Exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", eventType),
+		))
+	// the consumer continues the trace from the message headers
+	headers := amqp091.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+
+	ctx, cancel := context.WithTimeout(ctx, r.rabbitConfig.PublishTimeout)
+	defer cancel()
+	err := r.manager.Publish(
+		ctx,
 		eventType,
-		false, false,
 		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        body,
+			Headers:      headers,
+			MessageId:    messageId,
+			ContentType:  "application/json",
+			DeliveryMode: amqp091.Persistent,
+			Body:         body,
 		},
 	)
+	tracing.End(span, err)
 
 	if err != nil {
-		log.Printf("Error publish %s with data: %v\n", eventType, string(body))
+		metrics.PublishFailures.WithLabelValues(eventType).Inc()
+		r.logger.ErrorContext(ctx, "event publish failed", "event", eventType, "error", err)
+		return err
 	}
-	log.Printf("Success publish %s with data: %v\n", eventType, string(body))
+	r.logger.DebugContext(ctx, "event published", "event", eventType)
 	return nil
 }
@@ -0,0 +1,24 @@
+package rabbitmq
+
+import "github.com/rabbitmq/amqp091-go"
+
+// headerCarrier lets the propagator write the trace context into the headers
+// of a published message and read it back from a delivery.
+type headerCarrier amqp091.Table
+
+func (h headerCarrier) Get(key string) string {
+	value, _ := h[key].(string)
+	return value
+}
+
+func (h headerCarrier) Set(key string, value string) {
+	h[key] = value
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,6 @@
+package entity
+
+const (
+	AllocationSequential = "sequential"
+	AllocationNearest    = "nearest"
+)
@@ -0,0 +1,6 @@
+package entity
+
+const (
+	ChannelAllocationFixed      = "fixed"
+	ChannelAllocationPercentage = "percentage"
+)
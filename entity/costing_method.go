@@ -0,0 +1,15 @@
+package entity
+
+const (
+	CostingFIFO            = "fifo"
+	CostingWeightedAverage = "weighted_average"
+)
+
+const (
+	CostMovementReceipt     = "receipt"
+	CostMovementDeduction   = "deduction"
+	CostMovementShipment    = "shipment"
+	CostMovementWriteOff    = "write_off"
+	CostMovementTransferIn  = "transfer_in"
+	CostMovementTransferOut = "transfer_out"
+)
@@ -1,5 +1,116 @@
 package entity
 
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrorKind groups the domain errors by how they are answered: the HTTP
+// status they map to and whether a consumed event is retried.
+type ErrorKind string
+
 const (
-	ErrorInsufficientStock = "stock is less than quantity"
+	KindBadRequest        ErrorKind = "bad_request"
+	KindValidation        ErrorKind = "validation"
+	KindNotFound          ErrorKind = "not_found"
+	KindConflict          ErrorKind = "conflict"
+	KindInvalidState      ErrorKind = "invalid_state"
+	KindInsufficientStock ErrorKind = "insufficient_stock"
+	KindOverCapacity      ErrorKind = "over_capacity"
+	KindUnavailable       ErrorKind = "unavailable"
+	KindTooLarge          ErrorKind = "too_large"
+)
+
+// DomainError is an error with a code clients can match on. The errors below
+// are sentinels, compared with errors.Is whether wrapped or not.
+type DomainError struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+}
+
+func (e *DomainError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrorInvalidBody         = &DomainError{Kind: KindBadRequest, Code: "invalid_body", Message: "invalid request body"}
+	ErrorInvalidParameter    = &DomainError{Kind: KindBadRequest, Code: "invalid_parameter", Message: "invalid parameter"}
+	ErrorValidation          = &DomainError{Kind: KindValidation, Code: "validation_failed", Message: "request is invalid"}
+	ErrorNotFound            = &DomainError{Kind: KindNotFound, Code: "not_found", Message: "resource not found"}
+	ErrorInsufficientStock   = &DomainError{Kind: KindInsufficientStock, Code: "insufficient_stock", Message: "stock is less than quantity"}
+	ErrorInboundNotPending   = &DomainError{Kind: KindInvalidState, Code: "inbound_not_pending", Message: "inbound stock is not pending"}
+	ErrorOverCapacity        = &DomainError{Kind: KindOverCapacity, Code: "over_capacity", Message: "warehouse capacity exceeded"}
+	ErrorBinNotInWarehouse   = &DomainError{Kind: KindValidation, Code: "bin_not_in_warehouse", Message: "bin does not belong to warehouse"}
+	ErrorInvalidPickList     = &DomainError{Kind: KindInvalidState, Code: "invalid_pick_list_status", Message: "pick list is not in the expected status"}
+	ErrorInvalidPickedItem   = &DomainError{Kind: KindValidation, Code: "invalid_picked_item", Message: "picked items do not match the pick list"}
+	ErrorInvalidBundle       = &DomainError{Kind: KindValidation, Code: "invalid_bundle", Message: "invalid bundle"}
+	ErrorUnknownUnit         = &DomainError{Kind: KindValidation, Code: "unknown_unit", Message: "unit is not defined for product"}
+	ErrorInvalidAllocation   = &DomainError{Kind: KindValidation, Code: "invalid_allocation", Message: "percentage allocation must not exceed 100"}
+	ErrorUnknownImportKind   = &DomainError{Kind: KindValidation, Code: "unknown_import_kind", Message: "unknown import kind"}
+	ErrorUnknownFormat       = &DomainError{Kind: KindValidation, Code: "unknown_format", Message: "unknown file format"}
+	ErrorImportTooLarge      = &DomainError{Kind: KindTooLarge, Code: "import_too_large", Message: "import file is too large"}
+	ErrorUnknownExportKind   = &DomainError{Kind: KindValidation, Code: "unknown_export_kind", Message: "unknown export kind"}
+	ErrorBrokerNotConnected  = &DomainError{Kind: KindUnavailable, Code: "broker_not_connected", Message: "message broker is not connected"}
+	ErrorPublishNotConfirmed = &DomainError{Kind: KindUnavailable, Code: "publish_not_confirmed", Message: "publish was not confirmed by the message broker"}
+)
+
+// Errors of the health checks, never returned to API clients.
+var (
+	ErrorConsumerNotRunning = errors.New("consumer is not running")
+	ErrorShuttingDown       = errors.New("service is shutting down")
+	ErrorHealthCheckTimeout = errors.New("health check timed out")
 )
+
+// FieldError names the request field a validation failed on.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError carries the fields of a request that failed validation. It
+// matches ErrorValidation, or ErrorInvalidParameter for path and query
+// parameters, with errors.Is.
+type ValidationError struct {
+	Err    *DomainError
+	Fields []FieldError
+}
+
+func NewValidationError(fields ...FieldError) *ValidationError {
+	return &ValidationError{Err: ErrorValidation, Fields: fields}
+}
+
+// NewInvalidParameterError reports a path or query parameter that could not
+// be parsed, such as a non numeric id.
+func NewInvalidParameterError(field string, message string) *ValidationError {
+	return &ValidationError{Err: ErrorInvalidParameter, Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+" "+field.Message)
+	}
+	if len(messages) == 0 {
+		return e.Err.Message
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// AsDomainError returns the domain error in err's chain. A missing row is
+// reported as ErrorNotFound.
+func AsDomainError(err error) (*DomainError, bool) {
+	var domainError *DomainError
+	if errors.As(err, &domainError) {
+		return domainError, true
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrorNotFound, true
+	}
+	return nil, false
+}
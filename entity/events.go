@@ -7,6 +7,7 @@ const (
 	StockReleaseEvent  = "stock.release"
 	StockReturnEvent   = "stock.return"
 	StockReserveEvent  = "stock.reserve"
+	StockShippedEvent  = "stock.shipped"
 
 	OrderUpdateStatusEvent = "order.update_status"
 )
@@ -0,0 +1,7 @@
+package entity
+
+const (
+	ExportStockLevels  = "stock-levels"
+	ExportMovements    = "movements"
+	ExportReservations = "reservations"
+)
@@ -0,0 +1,6 @@
+package entity
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
@@ -0,0 +1,13 @@
+package entity
+
+const (
+	FindingReservedMismatch         = "reserved_mismatch"
+	FindingNegativeAvailableStock   = "negative_available_stock"
+	FindingNegativeReservedStock    = "negative_reserved_stock"
+	FindingNegativeOrderReservation = "negative_order_reservation"
+	FindingOrphanedReservation      = "orphaned_reservation"
+)
+
+// StockMovementRepair marks the stock ledger entries written by the
+// consistency repair.
+const StockMovementRepair = "consistency_repair"
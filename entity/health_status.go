@@ -0,0 +1,6 @@
+package entity
+
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
@@ -0,0 +1,11 @@
+package entity
+
+const (
+	ImportWarehouses        = "warehouses"
+	ImportProductWarehouses = "product-warehouses"
+	ImportOpeningBalances   = "opening-balances"
+)
+
+// StockMovementOpeningBalance marks the stock ledger entries written by an
+// opening balance import.
+const StockMovementOpeningBalance = "opening_balance"
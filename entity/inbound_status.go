@@ -0,0 +1,9 @@
+package entity
+
+const (
+	InboundPending  = "pending"
+	InboundReceived = "received"
+
+	InboundSourceTransfer = "transfer"
+	InboundSourcePurchase = "purchase"
+)
@@ -0,0 +1,6 @@
+package entity
+
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
@@ -0,0 +1,9 @@
+package entity
+
+const (
+	PickListOpen      = "open"
+	PickListPicked    = "picked"
+	PickListPacked    = "packed"
+	PickListShipped   = "shipped"
+	PickListCancelled = "cancelled"
+)
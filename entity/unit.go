@@ -0,0 +1,5 @@
+package entity
+
+const (
+	BaseUnit = "each"
+)
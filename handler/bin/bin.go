@@ -0,0 +1,191 @@
+package bin
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/bin"
+
+	"github.com/gorilla/mux"
+)
+
+type BinUsecase interface {
+	RegisterZone(ctx context.Context, zone *bin.ZoneRegisterRequest) error
+	RegisterAisle(ctx context.Context, aisle *bin.AisleRegisterRequest) error
+	RegisterBin(ctx context.Context, binRegister *bin.BinRegisterRequest) error
+	Putaway(ctx context.Context, putaway *bin.PutawayRequest) error
+	Move(ctx context.Context, move *bin.MoveRequest) error
+	GetPickLocations(ctx context.Context, orderId int) ([]bin.PickLocation, error)
+}
+
+type BinHandler struct {
+	binUsecase BinUsecase
+	logger     *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewBinHandler(binUsecase BinUsecase, logger *slog.Logger) *BinHandler {
+	return &BinHandler{
+		binUsecase: binUsecase,
+		logger:     logger,
+	}
+}
+
+func (b *BinHandler) RegisterZone(w http.ResponseWriter, req *http.Request) {
+	request := bin.ZoneRegisterRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, b.logger, httperror.Validation(err))
+		return
+	}
+
+	err := b.binUsecase.RegisterZone(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "zone registered"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *BinHandler) RegisterAisle(w http.ResponseWriter, req *http.Request) {
+	request := bin.AisleRegisterRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, b.logger, httperror.Validation(err))
+		return
+	}
+
+	err := b.binUsecase.RegisterAisle(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "aisle registered"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *BinHandler) RegisterBin(w http.ResponseWriter, req *http.Request) {
+	request := bin.BinRegisterRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, b.logger, httperror.Validation(err))
+		return
+	}
+
+	err := b.binUsecase.RegisterBin(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "bin registered"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *BinHandler) Putaway(w http.ResponseWriter, req *http.Request) {
+	request := bin.PutawayRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, b.logger, httperror.Validation(err))
+		return
+	}
+
+	err := b.binUsecase.Putaway(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "putaway success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *BinHandler) Move(w http.ResponseWriter, req *http.Request) {
+	request := bin.MoveRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, b.logger, httperror.Validation(err))
+		return
+	}
+
+	err := b.binUsecase.Move(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "move bin stock success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *BinHandler) GetPickLocations(w http.ResponseWriter, req *http.Request) {
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(req)
+	orderId, err := strconv.Atoi(vars["order_id"])
+	if err != nil {
+		httperror.Write(w, req, b.logger, entity.NewInvalidParameterError("order_id", "must be numeric"))
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.OrderID, orderId))
+
+	pickLocations, err := b.binUsecase.GetPickLocations(req.Context(), orderId)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get pick locations success"
+	response.Data = pickLocations
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,104 @@
+package bulk_import
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/models/bulk_import"
+
+	"github.com/gorilla/mux"
+)
+
+type BulkImportUsecase interface {
+	Import(ctx context.Context, kind string, format string, reader io.Reader, dryRun bool) (*bulk_import.Report, error)
+}
+
+type BulkImportHandler struct {
+	bulkImportUsecase BulkImportUsecase
+	maxSize           int
+	logger            *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewBulkImportHandler(bulkImportUsecase BulkImportUsecase, maxSize int, logger *slog.Logger) *BulkImportHandler {
+	return &BulkImportHandler{
+		bulkImportUsecase: bulkImportUsecase,
+		maxSize:           maxSize,
+		logger:            logger,
+	}
+}
+
+// Import reads the file from the raw request body, up to maxSize bytes. The
+// format comes from the format query parameter, or from the Content-Type when
+// it is not given.
+func (b *BulkImportHandler) Import(w http.ResponseWriter, req *http.Request) {
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+
+	kind := mux.Vars(req)["kind"]
+	if err := validate.Var(kind, "oneof="+entity.ImportWarehouses+" "+entity.ImportProductWarehouses+" "+entity.ImportOpeningBalances); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorUnknownImportKind)
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = formatFromContentType(req.Header.Get("Content-Type"))
+	}
+	if err := validate.Var(format, "oneof="+entity.FormatCSV+" "+entity.FormatNDJSON); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorUnknownFormat)
+		return
+	}
+
+	dryRun := false
+	if value := req.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			httperror.Write(w, req, b.logger, entity.NewInvalidParameterError("dry_run", "must be a boolean"))
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, req.Body, int64(b.maxSize))
+	report, err := b.bulkImportUsecase.Import(req.Context(), kind, format, body, dryRun)
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		err = entity.ErrorImportTooLarge
+	}
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = "import processed"
+	if dryRun {
+		response.Message = "import validated"
+	}
+	response.Data = report
+	json.NewEncoder(w).Encode(response)
+}
+
+func formatFromContentType(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return entity.FormatCSV
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+		return entity.FormatNDJSON
+	}
+	return ""
+}
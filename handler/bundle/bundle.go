@@ -0,0 +1,118 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/bundle"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/gorilla/mux"
+)
+
+type BundleUsecase interface {
+	Register(ctx context.Context, bundleRegister *bundle.RegisterRequest) error
+	GetComponents(ctx context.Context, bundleProductId int) ([]bundle.BundleComponent, error)
+	GetAvailability(ctx context.Context, productShop *product_warehouse.ProductShop) (*bundle.Availability, error)
+}
+
+type BundleHandler struct {
+	bundleUsecase BundleUsecase
+	logger        *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewBundleHandler(bundleUsecase BundleUsecase, logger *slog.Logger) *BundleHandler {
+	return &BundleHandler{
+		bundleUsecase: bundleUsecase,
+		logger:        logger,
+	}
+}
+
+func (b *BundleHandler) Register(w http.ResponseWriter, req *http.Request) {
+	request := bundle.RegisterRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.BundleProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, b.logger, httperror.Validation(err))
+		return
+	}
+
+	err := b.bundleUsecase.Register(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "bundle registered"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *BundleHandler) GetComponents(w http.ResponseWriter, req *http.Request) {
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(req)
+	bundleProductId, err := strconv.Atoi(vars["bundle_product_id"])
+	if err != nil {
+		httperror.Write(w, req, b.logger, entity.NewInvalidParameterError("bundle_product_id", "must be numeric"))
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, bundleProductId))
+
+	components, err := b.bundleUsecase.GetComponents(req.Context(), bundleProductId)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get bundle components success"
+	response.Data = components
+	json.NewEncoder(w).Encode(response)
+}
+
+func (b *BundleHandler) GetAvailability(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.ProductShop{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, b.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, b.logger, httperror.Validation(err))
+		return
+	}
+
+	availability, err := b.bundleUsecase.GetAvailability(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, b.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get bundle availability success"
+	response.Data = availability
+	json.NewEncoder(w).Encode(response)
+}
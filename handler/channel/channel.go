@@ -0,0 +1,126 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/channel"
+	"warehouse-service/models/product_warehouse"
+)
+
+type ChannelUsecase interface {
+	Register(ctx context.Context, allocation *channel.RegisterRequest) error
+	Remove(ctx context.Context, allocation *channel.RemoveRequest) error
+	GetAvailabilityBulk(ctx context.Context, productShops []product_warehouse.ProductShop) ([]channel.ProductAvailability, error)
+}
+
+type ChannelHandler struct {
+	channelUsecase ChannelUsecase
+	logger         *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewChannelHandler(channelUsecase ChannelUsecase, logger *slog.Logger) *ChannelHandler {
+	return &ChannelHandler{
+		channelUsecase: channelUsecase,
+		logger:         logger,
+	}
+}
+
+func (c *ChannelHandler) Register(w http.ResponseWriter, req *http.Request) {
+	request := channel.RegisterRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, c.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, c.logger, httperror.Validation(err))
+		return
+	}
+
+	err := c.channelUsecase.Register(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, c.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "channel allocation registered"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c *ChannelHandler) Remove(w http.ResponseWriter, req *http.Request) {
+	request := channel.RemoveRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, c.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, c.logger, httperror.Validation(err))
+		return
+	}
+
+	err := c.channelUsecase.Remove(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, c.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "channel allocation removed"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c *ChannelHandler) GetAvailableStock(w http.ResponseWriter, req *http.Request) {
+	var request []product_warehouse.ProductShop
+	response := Response{}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, c.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	productIds := make([]int, 0, len(request))
+	for _, item := range request {
+		productIds = append(productIds, item.ProductId)
+	}
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, productIds))
+
+	for _, item := range request {
+		if err := validate.Struct(item); err != nil {
+			httperror.Write(w, req, c.logger, httperror.Validation(err))
+			return
+		}
+	}
+
+	productAvailabilities, err := c.channelUsecase.GetAvailabilityBulk(req.Context(), request)
+	if err != nil {
+		httperror.Write(w, req, c.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get channel available stock success"
+	response.Data = productAvailabilities
+	json.NewEncoder(w).Encode(response)
+}
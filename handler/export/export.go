@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/models/export"
+
+	"github.com/gorilla/mux"
+)
+
+type ExportUsecase interface {
+	Export(ctx context.Context, request *export.ExportRequest, writer io.Writer) (int, error)
+}
+
+type ExportHandler struct {
+	exportUsecase ExportUsecase
+	logger        *slog.Logger
+}
+
+var validate = httperror.NewValidator()
+
+func NewExportHandler(exportUsecase ExportUsecase, logger *slog.Logger) *ExportHandler {
+	return &ExportHandler{
+		exportUsecase: exportUsecase,
+		logger:        logger,
+	}
+}
+
+// Export streams the rows as the response body. A page with a limit is read
+// before the response starts, so the X-Next-Cursor header can hold the cursor
+// of the next call when the limit stopped the export early. Without a limit
+// the export runs to the end and has no next cursor.
+func (e *ExportHandler) Export(w http.ResponseWriter, req *http.Request) {
+	request, err := exportRequest(mux.Vars(req)["kind"], req.URL.Query())
+	if err == nil {
+		err = httperror.Validation(validate.Struct(request))
+	}
+	if err != nil {
+		httperror.Write(w, req, e.logger, err)
+		return
+	}
+
+	if request.Format == entity.FormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+
+	if request.Limit > 0 {
+		e.exportPage(w, req, &request)
+		return
+	}
+
+	writer := &flushWriter{writer: w}
+	_, err = e.exportUsecase.Export(req.Context(), &request, writer)
+	if err != nil && !writer.written {
+		httperror.Write(w, req, e.logger, err)
+		return
+	}
+	if err != nil {
+		// The status is sent already, abort the connection so the client
+		// does not take the truncated body for a complete export.
+		e.logger.ErrorContext(req.Context(), "export aborted", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportPage buffers the page, validation keeps it to 10000 rows.
+func (e *ExportHandler) exportPage(w http.ResponseWriter, req *http.Request, request *export.ExportRequest) {
+	var body bytes.Buffer
+	nextCursor, err := e.exportUsecase.Export(req.Context(), request, &body)
+	if err != nil {
+		httperror.Write(w, req, e.logger, err)
+		return
+	}
+	if nextCursor != 0 {
+		w.Header().Set("X-Next-Cursor", strconv.Itoa(nextCursor))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+func exportRequest(kind string, query url.Values) (export.ExportRequest, error) {
+	request := export.ExportRequest{Kind: kind, Format: query.Get("format")}
+	if request.Format == "" {
+		request.Format = entity.FormatNDJSON
+	}
+	if err := validate.Var(request.Kind, "oneof="+entity.ExportStockLevels+" "+entity.ExportMovements+" "+entity.ExportReservations); err != nil {
+		return request, entity.ErrorUnknownExportKind
+	}
+	if err := validate.Var(request.Format, "oneof="+entity.FormatCSV+" "+entity.FormatNDJSON); err != nil {
+		return request, entity.ErrorUnknownFormat
+	}
+
+	ints := map[string]*int{
+		"shop_id":      &request.Filter.ShopId,
+		"warehouse_id": &request.Filter.WarehouseId,
+		"product_id":   &request.Filter.ProductId,
+		"cursor":       &request.Cursor,
+		"limit":        &request.Limit,
+	}
+	for name, field := range ints {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return request, entity.NewInvalidParameterError(name, "must be numeric")
+		}
+		*field = parsed
+	}
+
+	times := map[string]**time.Time{
+		"from": &request.Filter.From,
+		"to":   &request.Filter.To,
+	}
+	for name, field := range times {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := parseTime(value)
+		if err != nil {
+			return request, entity.NewInvalidParameterError(name, "must be a date or an RFC 3339 time")
+		}
+		*field = &parsed
+	}
+	return request, nil
+}
+
+// parseTime accepts a date, meaning its start in UTC, or an RFC 3339 time.
+func parseTime(value string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Parse(time.DateOnly, value)
+	}
+	return parsed, nil
+}
+
+// flushWriter sends every write to the client right away, the usecase writes
+// once per page.
+type flushWriter struct {
+	writer  http.ResponseWriter
+	written bool
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	f.written = true
+	n, err := f.writer.Write(p)
+	if flusher, ok := f.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,50 @@
+package export
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"warehouse-service/models/export"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeExportUsecase writes one row and stops at the limit.
+type fakeExportUsecase struct{}
+
+func (fakeExportUsecase) Export(ctx context.Context, request *export.ExportRequest, writer io.Writer) (int, error) {
+	io.WriteString(writer, "{\"id\":8}\n")
+	if request.Limit > 0 {
+		return 8, nil
+	}
+	return 0, nil
+}
+
+func serve(target string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc("/export/{kind}", NewExportHandler(fakeExportUsecase{}, slog.Default()).Export)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+func TestExport_LimitedPageHasNextCursorHeader(t *testing.T) {
+	recorder := serve("/export/movements?limit=1")
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "8", recorder.Header().Get("X-Next-Cursor"))
+	assert.Equal(t, "{\"id\":8}\n", recorder.Body.String())
+}
+
+func TestExport_LimitAboveMaximum(t *testing.T) {
+	recorder := serve("/export/movements?limit=10001")
+
+	// Assertions
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("X-Next-Cursor"))
+}
@@ -0,0 +1,167 @@
+package fulfillment
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/fulfillment"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/gorilla/mux"
+)
+
+type FulfillmentUsecase interface {
+	GeneratePickLists(ctx context.Context, order *product_warehouse.Order) error
+	GetPickLists(ctx context.Context, orderId int) ([]fulfillment.PickList, error)
+	ConfirmPick(ctx context.Context, pickConfirm *fulfillment.PickConfirmRequest) (*fulfillment.PickConfirmation, error)
+	ConfirmPack(ctx context.Context, pickListRequest *fulfillment.PickListRequest) error
+	Ship(ctx context.Context, pickListRequest *fulfillment.PickListRequest) (*fulfillment.ShippedEvent, error)
+}
+
+type FulfillmentHandler struct {
+	fulfillmentUsecase FulfillmentUsecase
+	logger             *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewFulfillmentHandler(fulfillmentUsecase FulfillmentUsecase, logger *slog.Logger) *FulfillmentHandler {
+	return &FulfillmentHandler{
+		fulfillmentUsecase: fulfillmentUsecase,
+		logger:             logger,
+	}
+}
+
+func (f *FulfillmentHandler) GeneratePickLists(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.Order{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, f.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.OrderID, request.OrderId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, f.logger, httperror.Validation(err))
+		return
+	}
+
+	err := f.fulfillmentUsecase.GeneratePickLists(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, f.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "pick lists generated"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (f *FulfillmentHandler) GetPickLists(w http.ResponseWriter, req *http.Request) {
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(req)
+	orderId, err := strconv.Atoi(vars["order_id"])
+	if err != nil {
+		httperror.Write(w, req, f.logger, entity.NewInvalidParameterError("order_id", "must be numeric"))
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.OrderID, orderId))
+
+	pickLists, err := f.fulfillmentUsecase.GetPickLists(req.Context(), orderId)
+	if err != nil {
+		httperror.Write(w, req, f.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get pick lists success"
+	response.Data = pickLists
+	json.NewEncoder(w).Encode(response)
+}
+
+func (f *FulfillmentHandler) ConfirmPick(w http.ResponseWriter, req *http.Request) {
+	request := fulfillment.PickConfirmRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, f.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, f.logger, httperror.Validation(err))
+		return
+	}
+
+	pickConfirmation, err := f.fulfillmentUsecase.ConfirmPick(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, f.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "pick confirmed"
+	response.Data = pickConfirmation
+	json.NewEncoder(w).Encode(response)
+}
+
+func (f *FulfillmentHandler) ConfirmPack(w http.ResponseWriter, req *http.Request) {
+	request := fulfillment.PickListRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, f.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, f.logger, httperror.Validation(err))
+		return
+	}
+
+	err := f.fulfillmentUsecase.ConfirmPack(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, f.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "pack confirmed"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (f *FulfillmentHandler) Ship(w http.ResponseWriter, req *http.Request) {
+	request := fulfillment.PickListRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, f.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, f.logger, httperror.Validation(err))
+		return
+	}
+
+	shipment, err := f.fulfillmentUsecase.Ship(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, f.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "shipment created"
+	response.Data = shipment
+	json.NewEncoder(w).Encode(response)
+}
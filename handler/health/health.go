@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/health"
+)
+
+type check struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// HealthHandler answers the liveness and readiness probes. Readiness runs
+// every registered check concurrently, each under the check timeout.
+type HealthHandler struct {
+	checks       []check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+func NewHealthHandler(timeout time.Duration) *HealthHandler {
+	return &HealthHandler{
+		timeout: timeout,
+	}
+}
+
+// AddCheck registers a dependency for readiness, check returns nil while the
+// dependency is usable.
+func (h *HealthHandler) AddCheck(name string, dependencyCheck func(ctx context.Context) error) {
+	h.checks = append(h.checks, check{name: name, check: dependencyCheck})
+}
+
+// Shutdown marks the service not ready, so it is taken out of rotation
+// before the HTTP server stops taking requests.
+func (h *HealthHandler) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *HealthHandler) Liveness(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Response{Message: "alive"})
+}
+
+func (h *HealthHandler) Readiness(w http.ResponseWriter, req *http.Request) {
+	readiness := h.readiness(req.Context())
+	response := Response{Data: readiness}
+	w.Header().Set("Content-Type", "application/json")
+	if readiness.Status != entity.HealthUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		response.Message = "not ready"
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "ready"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *HealthHandler) readiness(ctx context.Context) health.Readiness {
+	readiness := health.Readiness{
+		Status: entity.HealthUp,
+		Checks: make(map[string]health.Check, len(h.checks)+1),
+	}
+	if h.shuttingDown.Load() {
+		readiness.Status = entity.HealthDown
+		readiness.Checks["shutdown"] = health.Check{Status: entity.HealthDown, Error: entity.ErrorShuttingDown.Error()}
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			result := h.run(ctx, c)
+			mutex.Lock()
+			defer mutex.Unlock()
+			readiness.Checks[c.name] = result
+			if result.Status != entity.HealthUp {
+				readiness.Status = entity.HealthDown
+			}
+		}(c)
+	}
+	wg.Wait()
+	return readiness
+}
+
+func (h *HealthHandler) run(ctx context.Context, c check) health.Check {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.check(ctx)
+	result := health.Check{
+		Status:    entity.HealthUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = entity.ErrorHealthCheckTimeout
+		}
+		result.Status = entity.HealthDown
+		result.Error = err.Error()
+	}
+	return result
+}
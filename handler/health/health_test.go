@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type readinessResponse struct {
+	Message string           `json:"message"`
+	Data    health.Readiness `json:"data"`
+}
+
+func probe(t *testing.T, handler *HealthHandler) (int, readinessResponse) {
+	recorder := httptest.NewRecorder()
+	handler.Readiness(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var response readinessResponse
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	return recorder.Code, response
+}
+
+// blockingCheck waits for ctx, the check has to be cancelled by the timeout.
+func blockingCheck(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestReadiness_AllChecksUp(t *testing.T) {
+	handler := NewHealthHandler(time.Second)
+	handler.AddCheck("mysql", func(ctx context.Context) error { return nil })
+	handler.AddCheck("rabbitmq", func(ctx context.Context) error { return nil })
+
+	code, response := probe(t, handler)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", response.Message)
+	assert.Equal(t, entity.HealthUp, response.Data.Status)
+	assert.Equal(t, entity.HealthUp, response.Data.Checks["mysql"].Status)
+	assert.Equal(t, entity.HealthUp, response.Data.Checks["rabbitmq"].Status)
+}
+
+func TestReadiness_RunsChecksConcurrently(t *testing.T) {
+	handler := NewHealthHandler(time.Second)
+	// each check waits for the other, run one after the other both time out
+	mysqlStarted := make(chan struct{})
+	rabbitStarted := make(chan struct{})
+	handler.AddCheck("mysql", func(ctx context.Context) error {
+		close(mysqlStarted)
+		<-rabbitStarted
+		return nil
+	})
+	handler.AddCheck("rabbitmq", func(ctx context.Context) error {
+		close(rabbitStarted)
+		<-mysqlStarted
+		return errors.New("connection refused")
+	})
+
+	code, response := probe(t, handler)
+
+	// Assertions
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready", response.Message)
+	assert.Equal(t, entity.HealthDown, response.Data.Status)
+	assert.Equal(t, entity.HealthUp, response.Data.Checks["mysql"].Status)
+	assert.Equal(t, entity.HealthDown, response.Data.Checks["rabbitmq"].Status)
+	assert.Equal(t, "connection refused", response.Data.Checks["rabbitmq"].Error)
+}
+
+func TestReadiness_CheckTimeout(t *testing.T) {
+	handler := NewHealthHandler(10 * time.Millisecond)
+	handler.AddCheck("mysql", func(ctx context.Context) error { return nil })
+	handler.AddCheck("rabbitmq", blockingCheck)
+
+	start := time.Now()
+	code, response := probe(t, handler)
+
+	// Assertions
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, entity.HealthUp, response.Data.Checks["mysql"].Status)
+	assert.Equal(t, entity.HealthDown, response.Data.Checks["rabbitmq"].Status)
+	assert.Equal(t, entity.ErrorHealthCheckTimeout.Error(), response.Data.Checks["rabbitmq"].Error)
+}
+
+func TestReadiness_ShutdownIsNotReady(t *testing.T) {
+	handler := NewHealthHandler(time.Second)
+	handler.AddCheck("mysql", func(ctx context.Context) error { return nil })
+
+	handler.Shutdown()
+	code, response := probe(t, handler)
+
+	// Assertions
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, entity.HealthDown, response.Data.Status)
+	assert.Equal(t, entity.HealthDown, response.Data.Checks["shutdown"].Status)
+	assert.Equal(t, entity.ErrorShuttingDown.Error(), response.Data.Checks["shutdown"].Error)
+	assert.Equal(t, entity.HealthUp, response.Data.Checks["mysql"].Status)
+}
+
+func TestLiveness_UpDuringShutdown(t *testing.T) {
+	handler := NewHealthHandler(time.Second)
+	handler.Shutdown()
+	recorder := httptest.NewRecorder()
+
+	handler.Liveness(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
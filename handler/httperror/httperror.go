@@ -0,0 +1,182 @@
+// Package httperror writes the errors of the handlers in one shape, with the
+// status given by the kind of the domain error.
+package httperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+	"warehouse-service/entity"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const codeInternal = "internal"
+
+type Response struct {
+	Message string              `json:"message"`
+	Code    string              `json:"code"`
+	Details []entity.FieldError `json:"details,omitempty"`
+}
+
+var statuses = map[entity.ErrorKind]int{
+	entity.KindBadRequest:        http.StatusBadRequest,
+	entity.KindValidation:        http.StatusUnprocessableEntity,
+	entity.KindNotFound:          http.StatusNotFound,
+	entity.KindConflict:          http.StatusConflict,
+	entity.KindInvalidState:      http.StatusConflict,
+	entity.KindInsufficientStock: http.StatusConflict,
+	entity.KindOverCapacity:      http.StatusConflict,
+	entity.KindUnavailable:       http.StatusServiceUnavailable,
+	entity.KindTooLarge:          http.StatusRequestEntityTooLarge,
+}
+
+// Status returns the HTTP status of err, 500 for errors that are not domain
+// errors.
+func Status(err error) int {
+	domainError, ok := entity.AsDomainError(err)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	status, ok := statuses[domainError.Kind]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// Write answers the request with err. Internal errors are logged and their
+// message is not returned to the client.
+func Write(w http.ResponseWriter, req *http.Request, logger *slog.Logger, err error) {
+	status := Status(err)
+	response := Response{Message: err.Error(), Code: codeInternal}
+	if domainError, ok := entity.AsDomainError(err); ok {
+		response.Code = domainError.Code
+		if !errors.As(err, new(*entity.DomainError)) {
+			// A missing row reads as a driver error, not meant for clients.
+			response.Message = domainError.Message
+		}
+	}
+	var validationError *entity.ValidationError
+	if errors.As(err, &validationError) {
+		response.Message = validationError.Err.Message
+		response.Details = validationError.Fields
+	}
+	if status >= http.StatusInternalServerError {
+		logger.ErrorContext(req.Context(), "request failed", "error", err)
+		if status == http.StatusInternalServerError {
+			response.Message = "internal server error"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// NewValidator returns a validator reporting fields by their JSON name.
+func NewValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return validate
+}
+
+// Validation turns the error of validate.Struct into an
+// entity.ValidationError with one detail per failed field. Other errors are
+// returned as they are.
+func Validation(err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+	fields := make([]entity.FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		fields = append(fields, entity.FieldError{
+			Field:   fieldPath(fieldError),
+			Message: fieldMessage(fieldError),
+		})
+	}
+	return entity.NewValidationError(fields...)
+}
+
+// fieldPath drops the name of the request struct from the namespace, so a
+// nested field reads as items[0].quantity.
+func fieldPath(fieldError validator.FieldError) string {
+	_, path, found := strings.Cut(fieldError.Namespace(), ".")
+	if !found {
+		return fieldError.Field()
+	}
+	return path
+}
+
+func fieldMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "gt":
+		return "must be greater than " + fieldError.Param()
+	case "gte", "min":
+		if fieldError.Kind() == reflect.Slice {
+			return "must have at least " + fieldError.Param() + " items"
+		}
+		return "must be at least " + fieldError.Param()
+	case "lte", "max":
+		if fieldError.Kind() == reflect.Slice {
+			return "must have at most " + fieldError.Param() + " items"
+		}
+		return "must be at most " + fieldError.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	case "ne":
+		return "must not be " + fieldError.Param()
+	case "nefield":
+		return "must differ from " + snakeCase(fieldError.Param())
+	case "required_if", "required_with":
+		return "is required with " + snakeCase(strings.Fields(fieldError.Param())[0])
+	case "datetime":
+		return "must be a time in the format " + fieldError.Param()
+	case "latitude", "longitude":
+		return "must be a valid " + fieldError.Tag()
+	case "gtfield", "gtefield", "ltfield", "ltefield":
+		return fmt.Sprintf("must be %s %s", comparisons[fieldError.Tag()], snakeCase(fieldError.Param()))
+	default:
+		return "failed the " + fieldError.Tag() + " check"
+	}
+}
+
+var comparisons = map[string]string{
+	"gtfield":  "greater than",
+	"gtefield": "at least",
+	"ltfield":  "less than",
+	"ltefield": "at most",
+}
+
+// snakeCase turns the struct field a rule refers to into its JSON name, the
+// request models name their fields alike.
+func snakeCase(field string) string {
+	var builder strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				builder.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
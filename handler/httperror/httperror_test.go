@@ -0,0 +1,78 @@
+package httperror
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"warehouse-service/entity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func write(err error) (int, Response) {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	Write(recorder, req, slog.Default(), err)
+
+	response := Response{}
+	json.NewDecoder(recorder.Body).Decode(&response)
+	return recorder.Code, response
+}
+
+func TestWrite_DomainErrors(t *testing.T) {
+	notFoundStatus, notFound := write(fmt.Errorf("get warehouse: %w", sql.ErrNoRows))
+	stockStatus, stock := write(entity.ErrorInsufficientStock)
+	stateStatus, state := write(fmt.Errorf("%w: shipped", entity.ErrorInvalidPickList))
+	paramStatus, param := write(entity.NewInvalidParameterError("id", "must be numeric"))
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, notFoundStatus)
+	assert.Equal(t, Response{Message: "resource not found", Code: "not_found"}, notFound)
+	assert.Equal(t, http.StatusConflict, stockStatus)
+	assert.Equal(t, Response{Message: "stock is less than quantity", Code: "insufficient_stock"}, stock)
+	assert.Equal(t, http.StatusConflict, stateStatus)
+	assert.Equal(t, "pick list is not in the expected status: shipped", state.Message)
+	assert.Equal(t, http.StatusBadRequest, paramStatus)
+	assert.Equal(t, "invalid_parameter", param.Code)
+	assert.Equal(t, []entity.FieldError{{Field: "id", Message: "must be numeric"}}, param.Details)
+}
+
+func TestWrite_InternalError(t *testing.T) {
+	status, response := write(errors.New("dial tcp 127.0.0.1:3306: connection refused"))
+
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, Response{Message: "internal server error", Code: "internal"}, response)
+}
+
+func TestValidation(t *testing.T) {
+	type item struct {
+		ProductId int `json:"product_id" validate:"required"`
+	}
+	type request struct {
+		FromWarehouseId int    `json:"from_warehouse_id" validate:"required"`
+		ToWarehouseId   int    `json:"to_warehouse_id" validate:"required,nefield=FromWarehouseId"`
+		Quantity        int    `json:"quantity" validate:"gt=0"`
+		Items           []item `json:"items" validate:"dive"`
+	}
+	validate := NewValidator()
+
+	err := Validation(validate.Struct(request{FromWarehouseId: 1, ToWarehouseId: 1, Items: []item{{}}}))
+	status, response := write(err)
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorValidation)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, "validation_failed", response.Code)
+	assert.Equal(t, []entity.FieldError{
+		{Field: "to_warehouse_id", Message: "must differ from from_warehouse_id"},
+		{Field: "quantity", Message: "must be greater than 0"},
+		{Field: "items[0].product_id", Message: "is required"},
+	}, response.Details)
+	assert.NoError(t, Validation(validate.Struct(request{FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 1})))
+}
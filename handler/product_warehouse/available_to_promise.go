@@ -0,0 +1,88 @@
+package product_warehouse
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/gorilla/mux"
+)
+
+func (p *ProductWarehouseHandler) RegisterInbound(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.InboundRegisterRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
+		return
+	}
+
+	err := p.productWarehouseUsecase.RegisterInbound(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, p.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "inbound stock registered"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *ProductWarehouseHandler) ReceiveInbound(w http.ResponseWriter, req *http.Request) {
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(req)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		httperror.Write(w, req, p.logger, entity.NewInvalidParameterError("id", "must be numeric"))
+		return
+	}
+
+	err = p.productWarehouseUsecase.ReceiveInbound(req.Context(), id)
+	if err != nil {
+		httperror.Write(w, req, p.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "inbound stock received"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *ProductWarehouseHandler) GetAvailableToPromise(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.AvailableToPromiseRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
+		return
+	}
+
+	atp, err := p.productWarehouseUsecase.GetAvailableToPromise(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, p.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get available to promise success"
+	response.Data = atp
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,88 @@
+package product_warehouse
+
+import (
+	"encoding/json"
+	"net/http"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/product_warehouse"
+)
+
+func (p *ProductWarehouseHandler) EnableFlashSale(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.FlashSaleRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
+		return
+	}
+
+	err := p.productWarehouseUsecase.EnableFlashSale(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, p.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "flash sale enabled"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *ProductWarehouseHandler) DisableFlashSale(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.FlashSaleProductRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
+		return
+	}
+
+	err := p.productWarehouseUsecase.DisableFlashSale(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, p.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "flash sale disabled"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *ProductWarehouseHandler) ReconcileFlashSale(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.FlashSaleProductRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
+		return
+	}
+
+	err := p.productWarehouseUsecase.ReconcileFlashSale(req.Context(), request.ProductId)
+	if err != nil {
+		httperror.Write(w, req, p.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "flash sale reconciled"
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,29 +1,41 @@
 package product_warehouse
 
 import (
+	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
 	"warehouse-service/models/product_warehouse"
-
-	"github.com/go-playground/validator/v10"
 )
 
 type ProductWarehouseUsecase interface {
-	Register(productWarehouseRegister *product_warehouse.RegisterRequest) error
-	TransferStockRequest(transferStock *product_warehouse.TransferStockRequest) error
-	TransferStock(transferStock *product_warehouse.TransferStockRequest) error
-	AddStockRequest(addStock *product_warehouse.StockOperationRequest) error
-	AddStock(addStock *product_warehouse.StockOperationRequest) error
-	DeductStockRequest(deductStock *product_warehouse.StockOperationRequest) error
-	DeductStock(deductStock *product_warehouse.StockOperationRequest) error
-	ReleaseReservedStock(order *product_warehouse.Order) error
-	ReturnReservedStock(order *product_warehouse.Order) error
-	GetAvailableStockBulk(getAvailableStock []product_warehouse.ProductShop) (map[int]int, error)
-	ReserveStock(operationStock *product_warehouse.StockOperationOrderRequest) error
+	Register(ctx context.Context, productWarehouseRegister *product_warehouse.RegisterRequest) error
+	UpdateSafetyStock(ctx context.Context, safetyStock *product_warehouse.SafetyStockRequest) error
+	UpdateDimension(ctx context.Context, dimension *product_warehouse.DimensionRequest) error
+	TransferStockRequest(ctx context.Context, transferStock *product_warehouse.TransferStockRequest) error
+	TransferStock(ctx context.Context, transferStock *product_warehouse.TransferStockRequest) error
+	AddStockRequest(ctx context.Context, addStock *product_warehouse.StockOperationRequest) error
+	AddStock(ctx context.Context, addStock *product_warehouse.StockOperationRequest) error
+	DeductStockRequest(ctx context.Context, deductStock *product_warehouse.StockOperationRequest) error
+	DeductStock(ctx context.Context, deductStock *product_warehouse.StockOperationRequest) error
+	ReleaseReservedStock(ctx context.Context, order *product_warehouse.Order) error
+	ReturnReservedStock(ctx context.Context, order *product_warehouse.Order) error
+	GetAvailableStockBulk(ctx context.Context, getAvailableStock []product_warehouse.ProductShop) (map[int]int, error)
+	ReserveStock(ctx context.Context, operationStock *product_warehouse.StockOperationOrderRequest) error
+	RegisterInbound(ctx context.Context, inbound *product_warehouse.InboundRegisterRequest) error
+	ReceiveInbound(ctx context.Context, id int) error
+	GetAvailableToPromise(ctx context.Context, atpRequest *product_warehouse.AvailableToPromiseRequest) (*product_warehouse.AvailableToPromise, error)
+	EnableFlashSale(ctx context.Context, flashSale *product_warehouse.FlashSaleRequest) error
+	DisableFlashSale(ctx context.Context, flashSale *product_warehouse.FlashSaleProductRequest) error
+	ReconcileFlashSale(ctx context.Context, productId int) error
 }
 
 type ProductWarehouseHandler struct {
 	productWarehouseUsecase ProductWarehouseUsecase
+	logger                  *slog.Logger
 }
 
 type Response struct {
@@ -31,11 +43,12 @@ type Response struct {
 	Data    interface{} `json:"data"`
 }
 
-var validate = validator.New()
+var validate = httperror.NewValidator()
 
-func NewProductWarehouseHandler(productWarehouseUsecase ProductWarehouseUsecase) *ProductWarehouseHandler {
+func NewProductWarehouseHandler(productWarehouseUsecase ProductWarehouseUsecase, logger *slog.Logger) *ProductWarehouseHandler {
 	return &ProductWarehouseHandler{
 		productWarehouseUsecase: productWarehouseUsecase,
+		logger:                  logger,
 	}
 }
 
@@ -44,23 +57,20 @@ func (p *ProductWarehouseHandler) Register(w http.ResponseWriter, req *http.Requ
 	response := Response{}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "invalid request body"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
 		return
 	}
 
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
 	if err := validate.Struct(request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
 		return
 	}
 
-	err := p.productWarehouseUsecase.Register(&request)
+	err := p.productWarehouseUsecase.Register(req.Context(), &request)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		response.Message = err.Error()
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -68,35 +78,77 @@ func (p *ProductWarehouseHandler) Register(w http.ResponseWriter, req *http.Requ
 	json.NewEncoder(w).Encode(response)
 }
 
-func (p *ProductWarehouseHandler) TranserStockRequest(w http.ResponseWriter, req *http.Request) {
-	request := product_warehouse.TransferStockRequest{}
+func (p *ProductWarehouseHandler) UpdateSafetyStock(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.SafetyStockRequest{}
 	response := Response{}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "invalid request body"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
 		return
 	}
 
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
 	if err := validate.Struct(request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
 		return
 	}
 
-	if request.FromWarehouseId == request.ToWarehouseId {
-		w.WriteHeader(http.StatusInternalServerError)
-		response.Message = "transfer must be to different warehouse"
-		json.NewEncoder(w).Encode(response)
+	err := p.productWarehouseUsecase.UpdateSafetyStock(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, p.logger, err)
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "safety stock updated"
+	json.NewEncoder(w).Encode(response)
+}
 
-	err := p.productWarehouseUsecase.TransferStockRequest(&request)
+func (p *ProductWarehouseHandler) UpdateDimension(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.DimensionRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
+		return
+	}
+
+	err := p.productWarehouseUsecase.UpdateDimension(req.Context(), &request)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		response.Message = err.Error()
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "product dimension updated"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *ProductWarehouseHandler) TranserStockRequest(w http.ResponseWriter, req *http.Request) {
+	request := product_warehouse.TransferStockRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
+		return
+	}
+
+	err := p.productWarehouseUsecase.TransferStockRequest(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, p.logger, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -109,23 +161,20 @@ func (p *ProductWarehouseHandler) AddStockRequest(w http.ResponseWriter, req *ht
 	response := Response{}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "invalid request body"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
 		return
 	}
 
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
 	if err := validate.Struct(request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
 		return
 	}
 
-	err := p.productWarehouseUsecase.AddStockRequest(&request)
+	err := p.productWarehouseUsecase.AddStockRequest(req.Context(), &request)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		response.Message = err.Error()
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -138,23 +187,20 @@ func (p *ProductWarehouseHandler) DeductStockRequest(w http.ResponseWriter, req
 	response := Response{}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "invalid request body"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
 		return
 	}
 
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
 	if err := validate.Struct(request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		httperror.Write(w, req, p.logger, httperror.Validation(err))
 		return
 	}
 
-	err := p.productWarehouseUsecase.DeductStockRequest(&request)
+	err := p.productWarehouseUsecase.DeductStockRequest(req.Context(), &request)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		response.Message = err.Error()
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -169,25 +215,26 @@ func (p *ProductWarehouseHandler) GetAvailableStock(w http.ResponseWriter, req *
 	w.Header().Set("Content-Type", "application/json")
 
 	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "invalid request body"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, entity.ErrorInvalidBody)
 		return
 	}
 
+	productIds := make([]int, 0, len(request))
+	for _, item := range request {
+		productIds = append(productIds, item.ProductId)
+	}
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, productIds))
+
 	for _, item := range request {
 		if err := validate.Struct(item); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+			httperror.Write(w, req, p.logger, httperror.Validation(err))
 			return
 		}
 	}
 
-	availableStock, err := p.productWarehouseUsecase.GetAvailableStockBulk(request)
+	availableStock, err := p.productWarehouseUsecase.GetAvailableStockBulk(req.Context(), request)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		response.Message = err.Error()
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, p.logger, err)
 		return
 	}
 
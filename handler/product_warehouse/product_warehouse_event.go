@@ -1,131 +1,134 @@
 package product_warehouse
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
 	"warehouse-service/models/product_warehouse"
 )
 
-func (p *ProductWarehouseHandler) TransferStock(data interface{}) error {
+func (p *ProductWarehouseHandler) TransferStock(ctx context.Context, data interface{}) error {
 	dataByte, err := json.Marshal(data)
 	if err != nil {
-		return errors.New("invalid body request")
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	request := product_warehouse.TransferStockRequest{}
 	err = json.Unmarshal(dataByte, &request)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	if err := validate.Struct(request); err != nil {
-		return err
+		return httperror.Validation(err)
 	}
 
-	err = p.productWarehouseUsecase.TransferStock(&request)
+	err = p.productWarehouseUsecase.TransferStock(ctx, &request)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (p *ProductWarehouseHandler) AddStock(data interface{}) error {
+func (p *ProductWarehouseHandler) AddStock(ctx context.Context, data interface{}) error {
 	dataByte, err := json.Marshal(data)
 	if err != nil {
-		return errors.New("invalid body request")
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	request := product_warehouse.StockOperationRequest{}
 	err = json.Unmarshal(dataByte, &request)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	if err := validate.Struct(request); err != nil {
-		return err
+		return httperror.Validation(err)
 	}
 
-	err = p.productWarehouseUsecase.AddStock(&request)
+	err = p.productWarehouseUsecase.AddStock(ctx, &request)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (p *ProductWarehouseHandler) DeductStock(data interface{}) error {
+func (p *ProductWarehouseHandler) DeductStock(ctx context.Context, data interface{}) error {
 	dataByte, err := json.Marshal(data)
 	if err != nil {
-		return errors.New("invalid body request")
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	request := product_warehouse.StockOperationRequest{}
 	err = json.Unmarshal(dataByte, &request)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	if err := validate.Struct(request); err != nil {
-		return err
+		return httperror.Validation(err)
 	}
 
-	err = p.productWarehouseUsecase.DeductStock(&request)
+	err = p.productWarehouseUsecase.DeductStock(ctx, &request)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (p *ProductWarehouseHandler) ReleaseReservedStock(data interface{}) error {
+func (p *ProductWarehouseHandler) ReleaseReservedStock(ctx context.Context, data interface{}) error {
 	dataByte, err := json.Marshal(data)
 	if err != nil {
-		return errors.New("invalid body request")
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	request := product_warehouse.Order{}
 	err = json.Unmarshal(dataByte, &request)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	if err := validate.Struct(request); err != nil {
-		return err
+		return httperror.Validation(err)
 	}
 
-	err = p.productWarehouseUsecase.ReleaseReservedStock(&request)
+	err = p.productWarehouseUsecase.ReleaseReservedStock(ctx, &request)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (p *ProductWarehouseHandler) ReturnReservedStock(data interface{}) error {
+func (p *ProductWarehouseHandler) ReturnReservedStock(ctx context.Context, data interface{}) error {
 	dataByte, err := json.Marshal(data)
 	if err != nil {
-		return errors.New("invalid body request")
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	request := product_warehouse.Order{}
 	err = json.Unmarshal(dataByte, &request)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	if err := validate.Struct(request); err != nil {
-		return err
+		return httperror.Validation(err)
 	}
 
-	err = p.productWarehouseUsecase.ReturnReservedStock(&request)
+	err = p.productWarehouseUsecase.ReturnReservedStock(ctx, &request)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (p *ProductWarehouseHandler) ReserveStock(data interface{}) error {
+func (p *ProductWarehouseHandler) ReserveStock(ctx context.Context, data interface{}) error {
 	dataByte, err := json.Marshal(data)
 	if err != nil {
-		return errors.New("invalid body request")
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	request := product_warehouse.StockOperationOrderRequest{}
 	err = json.Unmarshal(dataByte, &request)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", entity.ErrorInvalidBody, err)
 	}
 	if err := validate.Struct(request); err != nil {
-		return err
+		return httperror.Validation(err)
 	}
 
-	err = p.productWarehouseUsecase.ReserveStock(&request)
+	err = p.productWarehouseUsecase.ReserveStock(ctx, &request)
 	if err != nil {
 		return err
 	}
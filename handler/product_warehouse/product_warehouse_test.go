@@ -0,0 +1,52 @@
+package product_warehouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"warehouse-service/config"
+	"warehouse-service/logging"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock usecase
+type MockProductWarehouseUsecase struct {
+	mock.Mock
+	ProductWarehouseUsecase
+}
+
+func (m *MockProductWarehouseUsecase) AddStockRequest(ctx context.Context, addStock *product_warehouse.StockOperationRequest) error {
+	args := m.Called(addStock)
+	return args.Error(0)
+}
+
+func TestAddStockRequest_LogsWithProductId(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := logging.New(config.Logging{Level: "info", Format: "json"}, &buffer)
+	usecase := new(MockProductWarehouseUsecase)
+	usecase.On("AddStockRequest", mock.Anything).Return(errors.New("connection refused"))
+	handler := NewProductWarehouseHandler(usecase, logger)
+	body := `{"product_id": 3, "warehouse_id": 1, "quantity": 5}`
+	request := httptest.NewRequest(http.MethodPost, "/product-warehouse/add", strings.NewReader(body))
+	request = request.WithContext(logging.With(request.Context(), logging.RequestID, "abc"))
+	recorder := httptest.NewRecorder()
+
+	handler.AddStockRequest(recorder, request)
+	line := map[string]interface{}{}
+	err := json.Unmarshal(buffer.Bytes(), &line)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "request failed", line["msg"])
+	assert.Equal(t, "abc", line[logging.RequestID])
+	assert.Equal(t, float64(3), line[logging.ProductID])
+}
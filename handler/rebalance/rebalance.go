@@ -0,0 +1,69 @@
+package rebalance
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/rebalance"
+)
+
+type RebalanceUsecase interface {
+	Plan(ctx context.Context, planRequest *rebalance.PlanRequest) (*rebalance.Plan, error)
+}
+
+type RebalanceHandler struct {
+	rebalanceUsecase RebalanceUsecase
+	logger           *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewRebalanceHandler(rebalanceUsecase RebalanceUsecase, logger *slog.Logger) *RebalanceHandler {
+	return &RebalanceHandler{
+		rebalanceUsecase: rebalanceUsecase,
+		logger:           logger,
+	}
+}
+
+func (r *RebalanceHandler) Plan(w http.ResponseWriter, req *http.Request) {
+	request := rebalance.PlanRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, r.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if request.ProductId != 0 {
+		req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, r.logger, httperror.Validation(err))
+		return
+	}
+
+	plan, err := r.rebalanceUsecase.Plan(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, r.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if request.DryRun {
+		response.Message = "rebalance plan created"
+	} else {
+		response.Message = "rebalance transfers in progress"
+	}
+	response.Data = plan
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,113 @@
+package stock_history
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/stock_history"
+)
+
+type StockHistoryUsecase interface {
+	TakeSnapshot(ctx context.Context) (*stock_history.Snapshot, error)
+	GetStockAsOf(ctx context.Context, asOfRequest *stock_history.AsOfRequest) (*stock_history.AsOf, error)
+	GetStockDiff(ctx context.Context, diffRequest *stock_history.DiffRequest) (*stock_history.Diff, error)
+}
+
+type StockHistoryHandler struct {
+	stockHistoryUsecase StockHistoryUsecase
+	logger              *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewStockHistoryHandler(stockHistoryUsecase StockHistoryUsecase, logger *slog.Logger) *StockHistoryHandler {
+	return &StockHistoryHandler{
+		stockHistoryUsecase: stockHistoryUsecase,
+		logger:              logger,
+	}
+}
+
+func (s *StockHistoryHandler) TakeSnapshot(w http.ResponseWriter, req *http.Request) {
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+
+	snapshot, err := s.stockHistoryUsecase.TakeSnapshot(req.Context())
+	if err != nil {
+		httperror.Write(w, req, s.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "stock snapshot taken"
+	response.Data = snapshot
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *StockHistoryHandler) GetStockAsOf(w http.ResponseWriter, req *http.Request) {
+	request := stock_history.AsOfRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, s.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if request.ProductId != 0 {
+		req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, s.logger, httperror.Validation(err))
+		return
+	}
+
+	asOf, err := s.stockHistoryUsecase.GetStockAsOf(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, s.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get stock as of success"
+	response.Data = asOf
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *StockHistoryHandler) GetStockDiff(w http.ResponseWriter, req *http.Request) {
+	request := stock_history.DiffRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, s.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if request.ProductId != 0 {
+		req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, s.logger, httperror.Validation(err))
+		return
+	}
+
+	diff, err := s.stockHistoryUsecase.GetStockDiff(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, s.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get stock diff success"
+	response.Data = diff
+	json.NewEncoder(w).Encode(response)
+}
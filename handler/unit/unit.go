@@ -0,0 +1,89 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/logging"
+	"warehouse-service/models/unit"
+
+	"github.com/gorilla/mux"
+)
+
+type UnitUsecase interface {
+	Register(ctx context.Context, productUnit *unit.RegisterRequest) error
+	GetUnits(ctx context.Context, productId int) ([]unit.ProductUnit, error)
+}
+
+type UnitHandler struct {
+	unitUsecase UnitUsecase
+	logger      *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewUnitHandler(unitUsecase UnitUsecase, logger *slog.Logger) *UnitHandler {
+	return &UnitHandler{
+		unitUsecase: unitUsecase,
+		logger:      logger,
+	}
+}
+
+func (u *UnitHandler) Register(w http.ResponseWriter, req *http.Request) {
+	request := unit.RegisterRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, u.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, request.ProductId))
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, u.logger, httperror.Validation(err))
+		return
+	}
+
+	err := u.unitUsecase.Register(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, u.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	response.Message = "product unit registered"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (u *UnitHandler) GetUnits(w http.ResponseWriter, req *http.Request) {
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(req)
+	productId, err := strconv.Atoi(vars["product_id"])
+	if err != nil {
+		httperror.Write(w, req, u.logger, entity.NewInvalidParameterError("product_id", "must be numeric"))
+		return
+	}
+
+	req = req.WithContext(logging.With(req.Context(), logging.ProductID, productId))
+
+	productUnits, err := u.unitUsecase.GetUnits(req.Context(), productId)
+	if err != nil {
+		httperror.Write(w, req, u.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get product units success"
+	response.Data = productUnits
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,60 @@
+package valuation
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/models/valuation"
+)
+
+type ValuationUsecase interface {
+	GetInventoryValue(ctx context.Context, inventoryValueRequest *valuation.InventoryValueRequest) (*valuation.InventoryValue, error)
+}
+
+type ValuationHandler struct {
+	valuationUsecase ValuationUsecase
+	logger           *slog.Logger
+}
+
+type Response struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+var validate = httperror.NewValidator()
+
+func NewValuationHandler(valuationUsecase ValuationUsecase, logger *slog.Logger) *ValuationHandler {
+	return &ValuationHandler{
+		valuationUsecase: valuationUsecase,
+		logger:           logger,
+	}
+}
+
+func (v *ValuationHandler) GetInventoryValue(w http.ResponseWriter, req *http.Request) {
+	request := valuation.InventoryValueRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, v.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, v.logger, httperror.Validation(err))
+		return
+	}
+
+	inventoryValue, err := v.valuationUsecase.GetInventoryValue(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, v.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get inventory value success"
+	response.Data = inventoryValue
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,22 +1,30 @@
 package warehouse
 
 import (
+	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
 	"warehouse-service/models/warehouse"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
 type WarehouseUsecase interface {
-	Register(warehouseRegister *warehouse.RegisterRequest) error
-	UpdateStatus(updateStatus *warehouse.UpdateStatusRequest) error
+	Register(ctx context.Context, warehouseRegister *warehouse.RegisterRequest) error
+	UpdateStatus(ctx context.Context, updateStatus *warehouse.UpdateStatusRequest) error
+	UpdateLocation(ctx context.Context, updateLocation *warehouse.UpdateLocationRequest) error
+	UpdateCapacity(ctx context.Context, updateCapacity *warehouse.UpdateCapacityRequest) error
+	UpdateCostingMethod(ctx context.Context, updateCostingMethod *warehouse.UpdateCostingMethodRequest) error
+	GetUtilization(ctx context.Context, shopId int) ([]warehouse.Utilization, error)
 }
 
 type WarehouseHandler struct {
 	warehouseUsecase WarehouseUsecase
+	logger           *slog.Logger
 }
 
 type Response struct {
@@ -24,11 +32,12 @@ type Response struct {
 	Data    interface{} `json:"data"`
 }
 
-var validate = validator.New()
+var validate = httperror.NewValidator()
 
-func NewWarehouseHandler(warehouseUsecase WarehouseUsecase) *WarehouseHandler {
+func NewWarehouseHandler(warehouseUsecase WarehouseUsecase, logger *slog.Logger) *WarehouseHandler {
 	return &WarehouseHandler{
 		warehouseUsecase: warehouseUsecase,
+		logger:           logger,
 	}
 }
 
@@ -37,23 +46,18 @@ func (wa *WarehouseHandler) Register(w http.ResponseWriter, req *http.Request) {
 	response := Response{}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "invalid request body"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, wa.logger, entity.ErrorInvalidBody)
 		return
 	}
 
 	if err := validate.Struct(request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		httperror.Write(w, req, wa.logger, httperror.Validation(err))
 		return
 	}
 
-	err := wa.warehouseUsecase.Register(&request)
+	err := wa.warehouseUsecase.Register(req.Context(), &request)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		response.Message = err.Error()
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, wa.logger, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -66,15 +70,12 @@ func (wa *WarehouseHandler) UpdateStatus(w http.ResponseWriter, req *http.Reques
 	response := Response{}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "invalid request body"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, wa.logger, entity.ErrorInvalidBody)
 		return
 	}
 
 	if err := validate.Struct(request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		httperror.Write(w, req, wa.logger, httperror.Validation(err))
 		return
 	}
 
@@ -82,28 +83,158 @@ func (wa *WarehouseHandler) UpdateStatus(w http.ResponseWriter, req *http.Reques
 	id := vars["id"]
 
 	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "id is required"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("id", "is required"))
 		return
 	}
 	var err error
 	request.Id, err = strconv.Atoi(id)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response.Message = "id must be numeric"
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("id", "must be numeric"))
 		return
 	}
 
-	err = wa.warehouseUsecase.UpdateStatus(&request)
+	err = wa.warehouseUsecase.UpdateStatus(req.Context(), &request)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		response.Message = err.Error()
-		json.NewEncoder(w).Encode(response)
+		httperror.Write(w, req, wa.logger, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
 	response.Message = "warehouse status updated"
 	json.NewEncoder(w).Encode(response)
 }
+
+func (wa *WarehouseHandler) UpdateLocation(w http.ResponseWriter, req *http.Request) {
+	request := warehouse.UpdateLocationRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, wa.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, wa.logger, httperror.Validation(err))
+		return
+	}
+
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	if id == "" {
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("id", "is required"))
+		return
+	}
+	var err error
+	request.Id, err = strconv.Atoi(id)
+	if err != nil {
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("id", "must be numeric"))
+		return
+	}
+
+	err = wa.warehouseUsecase.UpdateLocation(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, wa.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "warehouse location updated"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (wa *WarehouseHandler) UpdateCapacity(w http.ResponseWriter, req *http.Request) {
+	request := warehouse.UpdateCapacityRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, wa.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, wa.logger, httperror.Validation(err))
+		return
+	}
+
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	if id == "" {
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("id", "is required"))
+		return
+	}
+	var err error
+	request.Id, err = strconv.Atoi(id)
+	if err != nil {
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("id", "must be numeric"))
+		return
+	}
+
+	err = wa.warehouseUsecase.UpdateCapacity(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, wa.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "warehouse capacity updated"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (wa *WarehouseHandler) UpdateCostingMethod(w http.ResponseWriter, req *http.Request) {
+	request := warehouse.UpdateCostingMethodRequest{}
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		httperror.Write(w, req, wa.logger, entity.ErrorInvalidBody)
+		return
+	}
+
+	if err := validate.Struct(request); err != nil {
+		httperror.Write(w, req, wa.logger, httperror.Validation(err))
+		return
+	}
+
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	if id == "" {
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("id", "is required"))
+		return
+	}
+	var err error
+	request.Id, err = strconv.Atoi(id)
+	if err != nil {
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("id", "must be numeric"))
+		return
+	}
+
+	err = wa.warehouseUsecase.UpdateCostingMethod(req.Context(), &request)
+	if err != nil {
+		httperror.Write(w, req, wa.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "warehouse costing method updated"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (wa *WarehouseHandler) GetUtilization(w http.ResponseWriter, req *http.Request) {
+	response := Response{}
+	w.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(req)
+	shopId, err := strconv.Atoi(vars["shop_id"])
+	if err != nil {
+		httperror.Write(w, req, wa.logger, entity.NewInvalidParameterError("shop_id", "must be numeric"))
+		return
+	}
+
+	utilizations, err := wa.warehouseUsecase.GetUtilization(req.Context(), shopId)
+	if err != nil {
+		httperror.Write(w, req, wa.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response.Message = "get warehouse utilization success"
+	response.Data = utilizations
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,79 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type stage struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Lifecycle stops the parts of the application in the order they were
+// registered, under one shutdown deadline.
+type Lifecycle struct {
+	stages  []stage
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+func NewLifecycle(timeout time.Duration, logger *slog.Logger) *Lifecycle {
+	return &Lifecycle{
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// OnStop registers stop to run after the stages registered before it.
+func (l *Lifecycle) OnStop(name string, stop func(ctx context.Context) error) {
+	l.stages = append(l.stages, stage{name: name, stop: stop})
+}
+
+// Wait blocks until SIGINT or SIGTERM, or until ctx is done, then stops
+// every stage. A stage failing or running out of time is logged and the
+// next one still runs, so the connections are always closed.
+func (l *Lifecycle) Wait(ctx context.Context) {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	cancel()
+	l.logger.Info("shutting down")
+
+	l.Stop()
+}
+
+// Stop runs the stages in order.
+func (l *Lifecycle) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	for _, stage := range l.stages {
+		err := stage.stop(ctx)
+		if err != nil {
+			l.logger.Error("failed to stop", "stage", stage.name, "error", err)
+			continue
+		}
+		l.logger.Info("stopped", "stage", stage.name)
+	}
+}
+
+// WaitGroup waits for wg, or until ctx is done.
+func WaitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,64 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStop_RunsStagesInOrder(t *testing.T) {
+	lifecycle := NewLifecycle(time.Second, slog.Default())
+
+	stopped := []string{}
+	for _, name := range []string{"http server", "consumers", "mysql"} {
+		name := name
+		lifecycle.OnStop(name, func(ctx context.Context) error {
+			stopped = append(stopped, name)
+			if name == "consumers" {
+				return errors.New("consumer channel closed")
+			}
+			return nil
+		})
+	}
+	lifecycle.Stop()
+
+	// Assertions
+	assert.Equal(t, []string{"http server", "consumers", "mysql"}, stopped)
+}
+
+func TestStop_SharesDeadline(t *testing.T) {
+	lifecycle := NewLifecycle(20*time.Millisecond, slog.Default())
+
+	var lastErr error
+	lifecycle.OnStop("outbox", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	lifecycle.OnStop("mysql", func(ctx context.Context) error {
+		lastErr = ctx.Err()
+		return nil
+	})
+	lifecycle.Stop()
+
+	// Assertions
+	assert.ErrorIs(t, lastErr, context.DeadlineExceeded)
+}
+
+func TestWaitGroup(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := WaitGroup(ctx, &wg)
+	wg.Done()
+
+	// Assertions
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, WaitGroup(context.Background(), &wg))
+}
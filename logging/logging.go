@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+	"warehouse-service/config"
+	"warehouse-service/entity"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the correlation fields, the same in every log line.
+const (
+	RequestID = "request_id"
+	MessageID = "message_id"
+	UserID    = "user_id"
+	OrderID   = "order_id"
+	ProductID = "product_id"
+)
+
+const redacted = "[REDACTED]"
+
+type fieldsKey struct{}
+
+// New returns a logger writing to writer. Records logged with a
+// context carry the fields added to it with With, and the trace and span
+// ids of its span.
+func New(loggingConfig config.Logging, writer io.Writer) *slog.Logger {
+	redact := make(map[string]bool, len(loggingConfig.Redact))
+	for _, key := range loggingConfig.Redact {
+		redact[strings.ToLower(key)] = true
+	}
+
+	var level slog.Level
+	level.UnmarshalText([]byte(loggingConfig.Level))
+	options := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if redact[strings.ToLower(attr.Key)] {
+				return slog.String(attr.Key, redacted)
+			}
+			return attr
+		},
+	}
+
+	var handler slog.Handler
+	if loggingConfig.Format == entity.LogFormatText {
+		handler = slog.NewTextHandler(writer, options)
+	} else {
+		handler = slog.NewJSONHandler(writer, options)
+	}
+	return slog.New(contextHandler{Handler: handler})
+}
+
+// With returns a copy of ctx whose log records carry the key value pairs in
+// args, after the fields ctx carries already.
+func With(ctx context.Context, args ...any) context.Context {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	merged := append([]slog.Attr{}, fields...)
+	merged = append(merged, slog.Group("", args...).Value.Group()...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// NewID returns a random id for requests and messages that came without one.
+func NewID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (c contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if fields, ok := ctx.Value(fieldsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(fields...)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return c.Handler.Handle(ctx, record)
+}
+
+func (c contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: c.Handler.WithAttrs(attrs)}
+}
+
+func (c contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: c.Handler.WithGroup(name)}
+}
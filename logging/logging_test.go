@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"warehouse-service/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_ContextFields(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(config.Logging{Level: "info", Format: "json"}, &buffer)
+	ctx := With(context.Background(), RequestID, "abc")
+	ctx = With(ctx, UserID, 7, OrderID, 42)
+
+	logger.InfoContext(ctx, "insufficient stock to reserve", ProductID, 3)
+	line := map[string]interface{}{}
+	err := json.Unmarshal(buffer.Bytes(), &line)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", line[RequestID])
+	assert.Equal(t, float64(7), line[UserID])
+	assert.Equal(t, float64(42), line[OrderID])
+	assert.Equal(t, float64(3), line[ProductID])
+}
+
+func TestNew_RedactsKeys(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(config.Logging{Level: "info", Format: "json", Redact: []string{"Authorization"}}, &buffer)
+
+	logger.Info("http request", "authorization", "Bearer token", "route", "/bin/move")
+
+	// Assertions
+	assert.NotContains(t, buffer.String(), "Bearer token")
+	assert.Contains(t, buffer.String(), `"authorization":"[REDACTED]"`)
+	assert.Contains(t, buffer.String(), `"route":"/bin/move"`)
+}
+
+func TestNew_Level(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(config.Logging{Level: "warn", Format: "text"}, &buffer)
+
+	logger.Info("event handled")
+	logger.Warn("event dropped")
+
+	// Assertions
+	assert.NotContains(t, buffer.String(), "event handled")
+	assert.Contains(t, buffer.String(), "event dropped")
+}
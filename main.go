@@ -1,50 +1,291 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"os"
+	"sync"
+	"time"
+	"warehouse-service/config"
 	"warehouse-service/conn/mysql"
 	"warehouse-service/conn/rabbitmq"
+	binHandler "warehouse-service/handler/bin"
+	bulkImportHandler "warehouse-service/handler/bulk_import"
+	bundleHandler "warehouse-service/handler/bundle"
+	channelHandler "warehouse-service/handler/channel"
+	exportHandler "warehouse-service/handler/export"
+	fulfillmentHandler "warehouse-service/handler/fulfillment"
+	healthHandler "warehouse-service/handler/health"
 	productWarehouseHandler "warehouse-service/handler/product_warehouse"
+	rebalanceHandler "warehouse-service/handler/rebalance"
+	stockHistoryHandler "warehouse-service/handler/stock_history"
+	unitHandler "warehouse-service/handler/unit"
+	valuationHandler "warehouse-service/handler/valuation"
 	warehouseHandler "warehouse-service/handler/warehouse"
+	"warehouse-service/lifecycle"
+	"warehouse-service/logging"
+	"warehouse-service/metrics"
 	"warehouse-service/middleware"
+	binRepo "warehouse-service/repository/bin"
+	bulkImportRepo "warehouse-service/repository/bulk_import"
+	bundleRepo "warehouse-service/repository/bundle"
+	channelRepo "warehouse-service/repository/channel"
+	consistencyRepo "warehouse-service/repository/consistency"
+	exportRepo "warehouse-service/repository/export"
+	fulfillmentRepo "warehouse-service/repository/fulfillment"
+	outboxRepo "warehouse-service/repository/outbox"
 	productWarehouseRepo "warehouse-service/repository/product_warehouse"
+	rebalanceRepo "warehouse-service/repository/rebalance"
+	stockHistoryRepo "warehouse-service/repository/stock_history"
+	unitRepo "warehouse-service/repository/unit"
+	valuationRepo "warehouse-service/repository/valuation"
 	warehouseRepo "warehouse-service/repository/warehouse"
+	"warehouse-service/tracing"
+	binUsecase "warehouse-service/usecase/bin"
+	bulkImportUsecase "warehouse-service/usecase/bulk_import"
+	bundleUsecase "warehouse-service/usecase/bundle"
+	channelUsecase "warehouse-service/usecase/channel"
+	consistencyUsecase "warehouse-service/usecase/consistency"
+	exportUsecase "warehouse-service/usecase/export"
+	fulfillmentUsecase "warehouse-service/usecase/fulfillment"
+	outboxUsecase "warehouse-service/usecase/outbox"
 	productWarehouseUsecase "warehouse-service/usecase/product_warehouse"
+	rebalanceUsecase "warehouse-service/usecase/rebalance"
+	stockHistoryUsecase "warehouse-service/usecase/stock_history"
+	unitUsecase "warehouse-service/usecase/unit"
+	valuationUsecase "warehouse-service/usecase/valuation"
 	warehouseUsecase "warehouse-service/usecase/warehouse"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
-	mysql.Connect()
-	rabbitmq.Connect()
+	cfg, err := config.Load()
+	if err != nil {
+		// no logger is configured yet, the default one writes to stderr
+		fatal(slog.Default(), "config load failed", err)
+	}
+	// Logs are written to stderr as before, the command line tools print
+	// their reports to stdout. Whatever still logs through the log package
+	// goes through the same handler.
+	logger := logging.New(cfg.Logging, os.Stderr)
+	slog.SetDefault(logger)
+
+	if len(os.Args) > 1 {
+		runCommand(cfg, logger, os.Args[1:])
+		return
+	}
+
+	logger.Info("config loaded", "config", cfg.String())
+	err = cfg.ValidateServer()
+	if err != nil {
+		fatal(logger, "config invalid for the server", err)
+	}
+	shutdownTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		fatal(logger, "tracing setup failed", err)
+	}
+	err = mysql.Connect(cfg.MySQL)
+	if err != nil {
+		fatal(logger, "mysql connect failed", err)
+	}
+	rabbitManager := rabbitmq.NewConnectionManager(cfg.RabbitMQ, logger)
+	// the API serves while the broker is down, readiness reports it
+	go rabbitManager.Connect()
+	middleware.SetJWTSecret(cfg.JWT.Secret)
 	router := mux.NewRouter()
+	middlewares := []mux.MiddlewareFunc{middleware.Tracing, middleware.RequestLogger(logger), middleware.Metrics}
+	router.Use(middlewares...)
+	router.NotFoundHandler = middleware.Unmatched(http.StatusNotFound, middlewares...)
+	router.MethodNotAllowedHandler = middleware.Unmatched(http.StatusMethodNotAllowed, middlewares...)
+	router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	// Exports stream for as long as they take and are routed on router, every
+	// other route runs under the request timeout.
+	apiRouter := router.NewRoute().Subrouter()
+	apiRouter.Use(middleware.RequestTimeout(cfg.HTTP.RequestTimeout))
 
-	rabbitPublisher := rabbitmq.NewRabbitPublisher(rabbitmq.RabbitConn)
+	rabbitPublisher := rabbitmq.NewRabbitPublisher(rabbitManager, cfg.RabbitMQ, logger)
+	outboxRepository := outboxRepo.NewOutboxRepository(mysql.MySQL)
+	outboxUsecase := outboxUsecase.NewOutboxUsecase(outboxRepository, rabbitPublisher, mysql.MySQL, logger)
 
 	warehouseRepository := warehouseRepo.NewWarehouseRepository(mysql.MySQL)
 	warehouseUsecase := warehouseUsecase.NewWarehouseUsecase(warehouseRepository)
-	warehouseHandler := warehouseHandler.NewWarehouseHandler(warehouseUsecase)
-	router.Handle("/warehouse/register", middleware.JWTMiddleware(http.HandlerFunc(warehouseHandler.Register))).Methods(http.MethodPost)
-	router.Handle("/warehouse/update-status/{id}", middleware.JWTMiddleware(http.HandlerFunc(warehouseHandler.UpdateStatus))).Methods(http.MethodPut)
+	warehouseHandler := warehouseHandler.NewWarehouseHandler(warehouseUsecase, logger)
+	apiRouter.Handle("/warehouse/register", middleware.JWTMiddleware(http.HandlerFunc(warehouseHandler.Register))).Methods(http.MethodPost)
+	apiRouter.Handle("/warehouse/update-status/{id}", middleware.JWTMiddleware(http.HandlerFunc(warehouseHandler.UpdateStatus))).Methods(http.MethodPut)
+	apiRouter.Handle("/warehouse/update-location/{id}", middleware.JWTMiddleware(http.HandlerFunc(warehouseHandler.UpdateLocation))).Methods(http.MethodPut)
+	apiRouter.Handle("/warehouse/update-capacity/{id}", middleware.JWTMiddleware(http.HandlerFunc(warehouseHandler.UpdateCapacity))).Methods(http.MethodPut)
+	apiRouter.Handle("/warehouse/update-costing-method/{id}", middleware.JWTMiddleware(http.HandlerFunc(warehouseHandler.UpdateCostingMethod))).Methods(http.MethodPut)
+	apiRouter.Handle("/warehouse/utilization/{shop_id}", middleware.JWTMiddleware(http.HandlerFunc(warehouseHandler.GetUtilization))).Methods(http.MethodGet)
 
 	productWarehouseRepository := productWarehouseRepo.NewProductWarehouseRepository(mysql.MySQL)
-	productWarehouseUsecase := productWarehouseUsecase.NewProductWarehouseUsecase(productWarehouseRepository, rabbitPublisher, mysql.MySQL)
-	productWarehouseHandler := productWarehouseHandler.NewProductWarehouseHandler(productWarehouseUsecase)
-	router.Handle("/product-warehouse/register", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.Register))).Methods(http.MethodPost)
-	router.Handle("/product-warehouse/transfer", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.TranserStockRequest))).Methods(http.MethodPost)
-	router.Handle("/product-warehouse/add", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.AddStockRequest))).Methods(http.MethodPost)
-	router.Handle("/product-warehouse/deduct", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.DeductStockRequest))).Methods(http.MethodPost)
-	router.HandleFunc("/product-warehouse/available-stock", productWarehouseHandler.GetAvailableStock).Methods(http.MethodPost)
-
-	rabbitConsumer := rabbitmq.NewRabbitConsumer(rabbitmq.RabbitConn, productWarehouseHandler)
-	go rabbitConsumer.ConsumeEvents()
-
-	fmt.Println("server is running")
-	err := http.ListenAndServe(":8003", router)
-	if err != nil {
-		log.Fatal(err)
+	capacityChecker := productWarehouseUsecase.NewCapacityChecker(productWarehouseRepository)
+
+	valuationRepository := valuationRepo.NewValuationRepository(mysql.MySQL)
+	valuationUsecase := valuationUsecase.NewValuationUsecase(valuationRepository)
+	valuationHandler := valuationHandler.NewValuationHandler(valuationUsecase, logger)
+	apiRouter.Handle("/valuation/inventory-value", middleware.JWTMiddleware(http.HandlerFunc(valuationHandler.GetInventoryValue))).Methods(http.MethodPost)
+
+	binRepository := binRepo.NewBinRepository(mysql.MySQL)
+	binUsecase := binUsecase.NewBinUsecase(binRepository, productWarehouseRepository, mysql.MySQL)
+
+	fulfillmentRepository := fulfillmentRepo.NewFulfillmentRepository(mysql.MySQL)
+	fulfillmentUsecase := fulfillmentUsecase.NewFulfillmentUsecase(fulfillmentRepository, productWarehouseRepository, binUsecase, outboxUsecase, valuationUsecase, mysql.MySQL)
+	fulfillmentHandler := fulfillmentHandler.NewFulfillmentHandler(fulfillmentUsecase, logger)
+	apiRouter.Handle("/fulfillment/pick-list/generate", middleware.JWTMiddleware(http.HandlerFunc(fulfillmentHandler.GeneratePickLists))).Methods(http.MethodPost)
+	apiRouter.Handle("/fulfillment/pick-list/{order_id}", middleware.JWTMiddleware(http.HandlerFunc(fulfillmentHandler.GetPickLists))).Methods(http.MethodGet)
+	apiRouter.Handle("/fulfillment/pick/confirm", middleware.JWTMiddleware(http.HandlerFunc(fulfillmentHandler.ConfirmPick))).Methods(http.MethodPost)
+	apiRouter.Handle("/fulfillment/pack/confirm", middleware.JWTMiddleware(http.HandlerFunc(fulfillmentHandler.ConfirmPack))).Methods(http.MethodPost)
+	apiRouter.Handle("/fulfillment/ship", middleware.JWTMiddleware(http.HandlerFunc(fulfillmentHandler.Ship))).Methods(http.MethodPost)
+
+	unitRepository := unitRepo.NewUnitRepository(mysql.MySQL)
+	unitUsecase := unitUsecase.NewUnitUsecase(unitRepository)
+	unitHandler := unitHandler.NewUnitHandler(unitUsecase, logger)
+	apiRouter.Handle("/product-unit/register", middleware.JWTMiddleware(http.HandlerFunc(unitHandler.Register))).Methods(http.MethodPost)
+	apiRouter.Handle("/product-unit/{product_id}", middleware.JWTMiddleware(http.HandlerFunc(unitHandler.GetUnits))).Methods(http.MethodGet)
+
+	bundleRepository := bundleRepo.NewBundleRepository(mysql.MySQL)
+	bundleUsecase := bundleUsecase.NewBundleUsecase(bundleRepository, unitUsecase, mysql.MySQL)
+	bundleHandler := bundleHandler.NewBundleHandler(bundleUsecase, logger)
+	apiRouter.Handle("/bundle/register", middleware.JWTMiddleware(http.HandlerFunc(bundleHandler.Register))).Methods(http.MethodPost)
+	apiRouter.Handle("/bundle/{bundle_product_id}", middleware.JWTMiddleware(http.HandlerFunc(bundleHandler.GetComponents))).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/bundle/available-stock", bundleHandler.GetAvailability).Methods(http.MethodPost)
+
+	channelRepository := channelRepo.NewChannelRepository(mysql.MySQL)
+	channelUsecase := channelUsecase.NewChannelUsecase(channelRepository, productWarehouseRepository)
+	channelHandler := channelHandler.NewChannelHandler(channelUsecase, logger)
+	apiRouter.Handle("/channel/allocation/register", middleware.JWTMiddleware(http.HandlerFunc(channelHandler.Register))).Methods(http.MethodPost)
+	apiRouter.Handle("/channel/allocation/remove", middleware.JWTMiddleware(http.HandlerFunc(channelHandler.Remove))).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/channel/available-stock", channelHandler.GetAvailableStock).Methods(http.MethodPost)
+
+	productWarehouseUsecase := productWarehouseUsecase.NewProductWarehouseUsecase(productWarehouseRepository, rabbitPublisher, outboxUsecase, fulfillmentUsecase, binUsecase, bundleUsecase, unitUsecase, channelUsecase, valuationUsecase, mysql.MySQL, logger)
+	productWarehouseHandler := productWarehouseHandler.NewProductWarehouseHandler(metrics.NewInstrumentedProductWarehouseUsecase(productWarehouseUsecase), logger)
+	apiRouter.Handle("/product-warehouse/register", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.Register))).Methods(http.MethodPost)
+	apiRouter.Handle("/product-warehouse/dimension", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.UpdateDimension))).Methods(http.MethodPut)
+	apiRouter.Handle("/product-warehouse/safety-stock", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.UpdateSafetyStock))).Methods(http.MethodPut)
+	apiRouter.Handle("/product-warehouse/transfer", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.TranserStockRequest))).Methods(http.MethodPost)
+	apiRouter.Handle("/product-warehouse/add", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.AddStockRequest))).Methods(http.MethodPost)
+	apiRouter.Handle("/product-warehouse/deduct", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.DeductStockRequest))).Methods(http.MethodPost)
+	apiRouter.Handle("/product-warehouse/inbound/register", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.RegisterInbound))).Methods(http.MethodPost)
+	apiRouter.Handle("/product-warehouse/inbound/receive/{id}", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.ReceiveInbound))).Methods(http.MethodPut)
+	apiRouter.Handle("/product-warehouse/flash-sale/enable", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.EnableFlashSale))).Methods(http.MethodPost)
+	apiRouter.Handle("/product-warehouse/flash-sale/disable", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.DisableFlashSale))).Methods(http.MethodPost)
+	apiRouter.Handle("/product-warehouse/flash-sale/reconcile", middleware.JWTMiddleware(http.HandlerFunc(productWarehouseHandler.ReconcileFlashSale))).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/product-warehouse/available-stock", productWarehouseHandler.GetAvailableStock).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/product-warehouse/available-to-promise", productWarehouseHandler.GetAvailableToPromise).Methods(http.MethodPost)
+
+	rebalanceRepository := rebalanceRepo.NewRebalanceRepository(mysql.MySQL)
+	rebalanceUsecase := rebalanceUsecase.NewRebalanceUsecase(rebalanceRepository, productWarehouseUsecase)
+	rebalanceHandler := rebalanceHandler.NewRebalanceHandler(rebalanceUsecase, logger)
+	apiRouter.Handle("/rebalance/plan", middleware.JWTMiddleware(http.HandlerFunc(rebalanceHandler.Plan))).Methods(http.MethodPost)
+
+	binHandler := binHandler.NewBinHandler(binUsecase, logger)
+	apiRouter.Handle("/bin/zone/register", middleware.JWTMiddleware(http.HandlerFunc(binHandler.RegisterZone))).Methods(http.MethodPost)
+	apiRouter.Handle("/bin/aisle/register", middleware.JWTMiddleware(http.HandlerFunc(binHandler.RegisterAisle))).Methods(http.MethodPost)
+	apiRouter.Handle("/bin/register", middleware.JWTMiddleware(http.HandlerFunc(binHandler.RegisterBin))).Methods(http.MethodPost)
+	apiRouter.Handle("/bin/putaway", middleware.JWTMiddleware(http.HandlerFunc(binHandler.Putaway))).Methods(http.MethodPost)
+	apiRouter.Handle("/bin/move", middleware.JWTMiddleware(http.HandlerFunc(binHandler.Move))).Methods(http.MethodPost)
+	apiRouter.Handle("/bin/pick-locations/{order_id}", middleware.JWTMiddleware(http.HandlerFunc(binHandler.GetPickLocations))).Methods(http.MethodGet)
+
+	stockHistoryRepository := stockHistoryRepo.NewStockHistoryRepository(mysql.MySQL)
+	stockHistoryUsecase := stockHistoryUsecase.NewStockHistoryUsecase(stockHistoryRepository, logger)
+	stockHistoryHandler := stockHistoryHandler.NewStockHistoryHandler(stockHistoryUsecase, logger)
+	apiRouter.Handle("/stock-history/snapshot", middleware.JWTMiddleware(http.HandlerFunc(stockHistoryHandler.TakeSnapshot))).Methods(http.MethodPost)
+	apiRouter.Handle("/stock-history/as-of", middleware.JWTMiddleware(http.HandlerFunc(stockHistoryHandler.GetStockAsOf))).Methods(http.MethodPost)
+	apiRouter.Handle("/stock-history/diff", middleware.JWTMiddleware(http.HandlerFunc(stockHistoryHandler.GetStockDiff))).Methods(http.MethodPost)
+
+	consistencyRepository := consistencyRepo.NewConsistencyRepository(mysql.MySQL)
+	consistencyUsecase := consistencyUsecase.NewConsistencyUsecase(consistencyRepository, productWarehouseRepository, mysql.MySQL, logger)
+
+	bulkImportRepository := bulkImportRepo.NewBulkImportRepository(mysql.MySQL)
+	bulkImportUsecase := bulkImportUsecase.NewBulkImportUsecase(bulkImportRepository, capacityChecker, binUsecase, valuationUsecase, mysql.MySQL)
+	bulkImportHandler := bulkImportHandler.NewBulkImportHandler(bulkImportUsecase, cfg.HTTP.MaxImportSize, logger)
+	apiRouter.Handle("/import/{kind}", middleware.JWTMiddleware(http.HandlerFunc(bulkImportHandler.Import))).Methods(http.MethodPost)
+
+	exportRepository := exportRepo.NewExportRepository(mysql.MySQL)
+	exportUsecase := exportUsecase.NewExportUsecase(exportRepository)
+	exportHandler := exportHandler.NewExportHandler(exportUsecase, logger)
+	router.Handle("/export/{kind}", middleware.JWTMiddleware(http.HandlerFunc(exportHandler.Export))).Methods(http.MethodGet)
+
+	rabbitConsumer := rabbitmq.NewRabbitConsumer(rabbitManager, cfg.RabbitMQ, metrics.NewInstrumentedStockHandler(productWarehouseHandler), logger)
+	rabbitConsumer.ConsumeEvents()
+
+	healthHandler := healthHandler.NewHealthHandler(cfg.HTTP.HealthCheckTimeout)
+	healthHandler.AddCheck("mysql", mysql.MySQL.PingContext)
+	healthHandler.AddCheck("rabbitmq", rabbitManager.Check)
+	healthHandler.AddCheck("consumers", rabbitConsumer.Check)
+	router.HandleFunc("/healthz", healthHandler.Liveness).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", healthHandler.Readiness).Methods(http.MethodGet)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	for _, job := range []func(){
+		func() { productWarehouseUsecase.RunFlashSaleReconciler(jobsCtx, cfg.Jobs.FlashSaleReconcileInterval) },
+		func() { stockHistoryUsecase.RunSnapshotJob(jobsCtx, cfg.Jobs.SnapshotInterval) },
+		func() { consistencyUsecase.RunJob(jobsCtx, cfg.Jobs.ConsistencyCheckInterval) },
+		func() { outboxUsecase.RunRelay(jobsCtx, cfg.Jobs.OutboxRelayInterval) },
+	} {
+		jobs.Add(1)
+		go func(job func()) {
+			defer jobs.Done()
+			job()
+		}(job)
+	}
+
+	server := &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
 	}
+	// A server that fails to listen shuts the rest down in order too.
+	serverCtx, serverFailed := context.WithCancel(context.Background())
+	go func() {
+		logger.Info("server is running", "addr", cfg.HTTP.Addr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "error", err)
+			serverFailed()
+		}
+	}()
+
+	// Stages stop in this order: readiness goes down, no new requests or
+	// deliveries come in, the running ones finish, then the events they
+	// committed to the outbox are published before the connections close.
+	appLifecycle := lifecycle.NewLifecycle(cfg.Shutdown.Timeout, logger)
+	appLifecycle.OnStop("readiness", func(ctx context.Context) error {
+		healthHandler.Shutdown()
+		select {
+		case <-time.After(cfg.Shutdown.ReadinessDelay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	appLifecycle.OnStop("http server", server.Shutdown)
+	appLifecycle.OnStop("consumers", rabbitConsumer.Stop)
+	appLifecycle.OnStop("background jobs", func(ctx context.Context) error {
+		stopJobs()
+		return lifecycle.WaitGroup(ctx, &jobs)
+	})
+	appLifecycle.OnStop("outbox", outboxUsecase.Drain)
+	appLifecycle.OnStop("rabbitmq", func(ctx context.Context) error {
+		rabbitManager.Close()
+		return nil
+	})
+	appLifecycle.OnStop("mysql", func(ctx context.Context) error {
+		return mysql.MySQL.Close()
+	})
+	appLifecycle.OnStop("tracing", shutdownTracing)
+	appLifecycle.Wait(serverCtx)
+}
+
+// fatal logs err and exits with status 1, for the errors the service or a
+// command cannot go on after.
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "warehouse"
+
+var (
+	registry = prometheus.NewRegistry()
+	factory  = promauto.With(registry)
+)
+
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	ConsumerMessagesProcessed = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_processed_total",
+		Help:      "Deliveries handled per queue, acked or not.",
+	}, []string{"queue"})
+	ConsumerMessagesAcked = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_acked_total",
+		Help:      "Deliveries acked per queue, including the ones dropped or dead lettered.",
+	}, []string{"queue"})
+	ConsumerMessagesDeadLettered = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_dead_lettered_total",
+		Help:      "Deliveries moved to the dead letter exchange per queue.",
+	}, []string{"queue"})
+	ConsumerMessagesNacked = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_nacked_total",
+		Help:      "Deliveries nacked per queue and whether they were requeued.",
+	}, []string{"queue", "requeued"})
+	ConsumerHandlerDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent handling an event by event type and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event", "result"})
+
+	PublishFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rabbitmq",
+		Name:      "publish_failures_total",
+		Help:      "Events that were not published or not confirmed, by event type.",
+	}, []string{"event"})
+
+	TransactionDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mysql",
+		Name:      "transaction_duration_seconds",
+		Help:      "Time from begin to commit or rollback of a database transaction.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	UnitsReserved = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "stock",
+		Name:      "units_reserved_total",
+		Help:      "Units reserved for orders, in base units.",
+	})
+	InsufficientStock = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "stock",
+		Name:      "insufficient_total",
+		Help:      "Stock operations rejected for insufficient stock, by operation.",
+	}, []string{"operation"})
+	Transfers = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "stock",
+		Name:      "transfers_total",
+		Help:      "Stock transfers between warehouses completed.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
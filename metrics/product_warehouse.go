@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"warehouse-service/entity"
+	productWarehouseHandler "warehouse-service/handler/product_warehouse"
+	"warehouse-service/models/product_warehouse"
+)
+
+// InstrumentedProductWarehouseUsecase counts reserved units, transfers and
+// operations rejected for insufficient stock. The other methods are passed
+// through as they are.
+type InstrumentedProductWarehouseUsecase struct {
+	productWarehouseHandler.ProductWarehouseUsecase
+}
+
+func NewInstrumentedProductWarehouseUsecase(productWarehouseUsecase productWarehouseHandler.ProductWarehouseUsecase) *InstrumentedProductWarehouseUsecase {
+	return &InstrumentedProductWarehouseUsecase{
+		ProductWarehouseUsecase: productWarehouseUsecase,
+	}
+}
+
+// ReserveStock counts the quantities after the call, the usecase converts
+// them to base units in place.
+func (p *InstrumentedProductWarehouseUsecase) ReserveStock(ctx context.Context, operationStock *product_warehouse.StockOperationOrderRequest) error {
+	err := p.ProductWarehouseUsecase.ReserveStock(ctx, operationStock)
+	if err != nil {
+		countInsufficientStock("reserve", err)
+		return err
+	}
+	units := 0
+	for _, operation := range operationStock.StockOperations {
+		units += operation.Quantity
+	}
+	UnitsReserved.Add(float64(units))
+	return nil
+}
+
+func (p *InstrumentedProductWarehouseUsecase) TransferStock(ctx context.Context, transferStock *product_warehouse.TransferStockRequest) error {
+	err := p.ProductWarehouseUsecase.TransferStock(ctx, transferStock)
+	if err != nil {
+		countInsufficientStock("transfer", err)
+		return err
+	}
+	Transfers.Inc()
+	return nil
+}
+
+func (p *InstrumentedProductWarehouseUsecase) DeductStock(ctx context.Context, deductStock *product_warehouse.StockOperationRequest) error {
+	err := p.ProductWarehouseUsecase.DeductStock(ctx, deductStock)
+	if err != nil {
+		countInsufficientStock("deduct", err)
+	}
+	return err
+}
+
+func countInsufficientStock(operation string, err error) {
+	if errors.Is(err, entity.ErrorInsufficientStock) {
+		InsufficientStock.WithLabelValues(operation).Inc()
+	}
+}
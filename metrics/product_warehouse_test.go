@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"warehouse-service/entity"
+	productWarehouseHandler "warehouse-service/handler/product_warehouse"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock usecase, the methods not instrumented are left to the embedded nil
+// interface and must not be called.
+type MockProductWarehouseUsecase struct {
+	productWarehouseHandler.ProductWarehouseUsecase
+	mock.Mock
+}
+
+func (m *MockProductWarehouseUsecase) ReserveStock(ctx context.Context, operationStock *product_warehouse.StockOperationOrderRequest) error {
+	args := m.Called(operationStock)
+	return args.Error(0)
+}
+
+func (m *MockProductWarehouseUsecase) TransferStock(ctx context.Context, transferStock *product_warehouse.TransferStockRequest) error {
+	args := m.Called(transferStock)
+	return args.Error(0)
+}
+
+func TestReserveStock_CountsUnits(t *testing.T) {
+	mockUsecase := new(MockProductWarehouseUsecase)
+	usecase := NewInstrumentedProductWarehouseUsecase(mockUsecase)
+	request := &product_warehouse.StockOperationOrderRequest{
+		OrderId: 1,
+		StockOperations: []product_warehouse.StockOperationRequest{
+			{ProductId: 1, Quantity: 3},
+			{ProductId: 2, Quantity: 4},
+		},
+	}
+	before := testutil.ToFloat64(UnitsReserved)
+
+	mockUsecase.On("ReserveStock", request).Return(nil)
+
+	err := usecase.ReserveStock(context.Background(), request)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, before+7, testutil.ToFloat64(UnitsReserved))
+	mockUsecase.AssertExpectations(t)
+}
+
+func TestReserveStock_InsufficientStock(t *testing.T) {
+	mockUsecase := new(MockProductWarehouseUsecase)
+	usecase := NewInstrumentedProductWarehouseUsecase(mockUsecase)
+	request := &product_warehouse.StockOperationOrderRequest{
+		OrderId:         1,
+		StockOperations: []product_warehouse.StockOperationRequest{{ProductId: 1, Quantity: 3}},
+	}
+	beforeUnits := testutil.ToFloat64(UnitsReserved)
+	beforeRejected := testutil.ToFloat64(InsufficientStock.WithLabelValues("reserve"))
+
+	mockUsecase.On("ReserveStock", request).Return(entity.ErrorInsufficientStock)
+
+	err := usecase.ReserveStock(context.Background(), request)
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+	assert.Equal(t, beforeUnits, testutil.ToFloat64(UnitsReserved))
+	assert.Equal(t, beforeRejected+1, testutil.ToFloat64(InsufficientStock.WithLabelValues("reserve")))
+	mockUsecase.AssertExpectations(t)
+}
+
+func TestTransferStock_OtherErrorNotCounted(t *testing.T) {
+	mockUsecase := new(MockProductWarehouseUsecase)
+	usecase := NewInstrumentedProductWarehouseUsecase(mockUsecase)
+	request := &product_warehouse.TransferStockRequest{ProductId: 1, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 5}
+	beforeTransfers := testutil.ToFloat64(Transfers)
+	beforeRejected := testutil.ToFloat64(InsufficientStock.WithLabelValues("transfer"))
+
+	mockUsecase.On("TransferStock", request).Return(errors.New("connection refused"))
+
+	err := usecase.TransferStock(context.Background(), request)
+
+	// Assertions
+	assert.Error(t, err)
+	assert.Equal(t, beforeTransfers, testutil.ToFloat64(Transfers))
+	assert.Equal(t, beforeRejected, testutil.ToFloat64(InsufficientStock.WithLabelValues("transfer")))
+	mockUsecase.AssertExpectations(t)
+}
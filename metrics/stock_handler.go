@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"time"
+	"warehouse-service/entity"
+)
+
+type StockHandler interface {
+	TransferStock(ctx context.Context, data interface{}) error
+	AddStock(ctx context.Context, data interface{}) error
+	DeductStock(ctx context.Context, data interface{}) error
+	ReleaseReservedStock(ctx context.Context, data interface{}) error
+	ReturnReservedStock(ctx context.Context, data interface{}) error
+	ReserveStock(ctx context.Context, data interface{}) error
+}
+
+// InstrumentedStockHandler records how long the consumed events take to
+// handle and whether the handler failed.
+type InstrumentedStockHandler struct {
+	stockHandler StockHandler
+}
+
+func NewInstrumentedStockHandler(stockHandler StockHandler) *InstrumentedStockHandler {
+	return &InstrumentedStockHandler{
+		stockHandler: stockHandler,
+	}
+}
+
+func (s *InstrumentedStockHandler) TransferStock(ctx context.Context, data interface{}) error {
+	return observeHandler(entity.StockTransferEvent, func() error { return s.stockHandler.TransferStock(ctx, data) })
+}
+
+func (s *InstrumentedStockHandler) AddStock(ctx context.Context, data interface{}) error {
+	return observeHandler(entity.StockAddEvent, func() error { return s.stockHandler.AddStock(ctx, data) })
+}
+
+func (s *InstrumentedStockHandler) DeductStock(ctx context.Context, data interface{}) error {
+	return observeHandler(entity.StockDeductEvent, func() error { return s.stockHandler.DeductStock(ctx, data) })
+}
+
+func (s *InstrumentedStockHandler) ReleaseReservedStock(ctx context.Context, data interface{}) error {
+	return observeHandler(entity.StockReleaseEvent, func() error { return s.stockHandler.ReleaseReservedStock(ctx, data) })
+}
+
+func (s *InstrumentedStockHandler) ReturnReservedStock(ctx context.Context, data interface{}) error {
+	return observeHandler(entity.StockReturnEvent, func() error { return s.stockHandler.ReturnReservedStock(ctx, data) })
+}
+
+func (s *InstrumentedStockHandler) ReserveStock(ctx context.Context, data interface{}) error {
+	return observeHandler(entity.StockReserveEvent, func() error { return s.stockHandler.ReserveStock(ctx, data) })
+}
+
+func observeHandler(event string, handle func() error) error {
+	start := time.Now()
+	err := handle()
+	ConsumerHandlerDuration.WithLabelValues(event, result(err)).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -7,10 +7,18 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"warehouse-service/logging"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+var jwtSecret []byte
+
+// SetJWTSecret sets the key JWTMiddleware verifies tokens with.
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
 type Response struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
@@ -52,19 +60,18 @@ func JWTMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		r.Header.Set("X-User-ID", fmt.Sprintf("%d", int(userID)))
+		ctx := logging.With(r.Context(), logging.UserID, int(userID))
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
 func validateJWT(tokenString string) (jwt.MapClaims, error) {
-	secretKey := []byte("myjwtsecret")
-
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return secretKey, nil
+		return jwtSecret, nil
 	})
 
 	if err != nil || !token.Valid {
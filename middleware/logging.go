@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+	"warehouse-service/logging"
+)
+
+// RequestLogger gives every request an id, the caller's X-Request-Id when it
+// sent one, and logs the request once it is done. Probes and scrapes are
+// logged at debug level, failed requests at warn and error.
+func RequestLogger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := r.Header.Get("X-Request-Id")
+			if requestID == "" {
+				requestID = logging.NewID()
+			}
+			w.Header().Set("X-Request-Id", requestID)
+			ctx := logging.With(r.Context(), logging.RequestID, requestID)
+
+			// only JWTMiddleware sets the user id, on the header shared
+			// with r, so it can be read once the request is done
+			r.Header.Del("X-User-ID")
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				route := routeTemplate(r)
+				args := []any{
+					"method", r.Method,
+					"route", route,
+					"status", recorder.status,
+					"duration_ms", time.Since(start).Milliseconds(),
+				}
+				if userID := r.Header.Get("X-User-ID"); userID != "" {
+					args = append(args, logging.UserID, userID)
+				}
+				logger.Log(ctx, requestLevel(route, recorder.status), "http request", args...)
+			}()
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+		})
+	}
+}
+
+func requestLevel(route string, status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	case route == "/healthz" || route == "/readyz" || route == "/metrics":
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
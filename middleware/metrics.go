@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"warehouse-service/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// Metrics counts the requests and observes their latency by route template,
+// so paths with ids fall into one series. Requests matching no route are
+// recorded as "unmatched".
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		// deferred so a handler aborting a stream is still recorded
+		defer func() {
+			route := routeTemplate(r)
+			status := strconv.Itoa(recorder.status)
+			metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// Unmatched answers the requests matching no route with status. The router
+// runs its middleware on matched routes only, so middlewares are applied here
+// for those requests to be traced, logged and counted as "unmatched" too.
+func Unmatched(status int, middlewares ...mux.MiddlewareFunc) http.Handler {
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(status), status)
+	})
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(p)
+}
+
+// Flush keeps streamed exports flushing through the recorder.
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"warehouse-service/metrics"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(Metrics)
+	router.NotFoundHandler = Unmatched(http.StatusNotFound, Metrics)
+	router.MethodNotAllowedHandler = Unmatched(http.StatusMethodNotAllowed, Metrics)
+	router.HandleFunc("/warehouse/utilization/{shop_id}", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	return router
+}
+
+func serve(router *mux.Router, method, target string) int {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder.Code
+}
+
+func TestMetrics_RecordsRouteTemplate(t *testing.T) {
+	counter := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/warehouse/utilization/{shop_id}", "200")
+	before := testutil.ToFloat64(counter)
+
+	code := serve(newTestRouter(), http.MethodGet, "/warehouse/utilization/7")
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
+
+func TestMetrics_RecordsUnmatchedRequests(t *testing.T) {
+	notFound := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "unmatched", "404")
+	notAllowed := metrics.HTTPRequests.WithLabelValues(http.MethodDelete, "unmatched", "405")
+	notFoundBefore := testutil.ToFloat64(notFound)
+	notAllowedBefore := testutil.ToFloat64(notAllowed)
+
+	notFoundCode := serve(newTestRouter(), http.MethodGet, "/no-such-route")
+	notAllowedCode := serve(newTestRouter(), http.MethodDelete, "/warehouse/utilization/7")
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, notFoundCode)
+	assert.Equal(t, http.StatusMethodNotAllowed, notAllowedCode)
+	assert.Equal(t, notFoundBefore+1, testutil.ToFloat64(notFound))
+	assert.Equal(t, notAllowedBefore+1, testutil.ToFloat64(notAllowed))
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestTimeout sets the deadline of the request context, which cancels
+// the database and broker calls made for the request once it passes. The
+// context is also canceled when the client disconnects.
+func RequestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package bin
+
+type Zone struct {
+	Id          int    `db:"id"`
+	WarehouseId int    `db:"warehouse_id"`
+	Code        string `db:"code"`
+}
+
+type Aisle struct {
+	Id     int    `db:"id"`
+	ZoneId int    `db:"zone_id"`
+	Code   string `db:"code"`
+}
+
+type Bin struct {
+	Id      int    `db:"id"`
+	AisleId int    `db:"aisle_id"`
+	Code    string `db:"code"`
+}
+
+type BinStock struct {
+	Id        int `db:"id"`
+	BinId     int `db:"bin_id"`
+	ProductId int `db:"product_id"`
+	Quantity  int `db:"quantity"`
+}
+
+type ZoneRegisterRequest struct {
+	WarehouseId int    `json:"warehouse_id" validate:"required"`
+	Code        string `json:"code" validate:"required"`
+}
+
+type AisleRegisterRequest struct {
+	ZoneId int    `json:"zone_id" validate:"required"`
+	Code   string `json:"code" validate:"required"`
+}
+
+type BinRegisterRequest struct {
+	AisleId int    `json:"aisle_id" validate:"required"`
+	Code    string `json:"code" validate:"required"`
+}
+
+type PutawayRequest struct {
+	ProductId   int `json:"product_id" validate:"required"`
+	WarehouseId int `json:"warehouse_id" validate:"required"`
+	BinId       int `json:"bin_id" validate:"required"`
+	Quantity    int `json:"quantity" validate:"required,gt=0"`
+}
+
+type MoveRequest struct {
+	ProductId int `json:"product_id" validate:"required"`
+	FromBinId int `json:"from_bin_id" validate:"required"`
+	ToBinId   int `json:"to_bin_id" validate:"required,nefield=FromBinId"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
+}
+
+type BinLocation struct {
+	BinId     int    `db:"bin_id"`
+	ZoneCode  string `db:"zone_code"`
+	AisleCode string `db:"aisle_code"`
+	BinCode   string `db:"bin_code"`
+	Quantity  int    `db:"quantity"`
+}
+
+type PickLocation struct {
+	ProductId   int    `json:"product_id"`
+	WarehouseId int    `json:"warehouse_id"`
+	BinId       int    `json:"bin_id"`
+	Location    string `json:"location"`
+	Quantity    int    `json:"quantity"`
+}
@@ -0,0 +1,25 @@
+package bulk_import
+
+import "warehouse-service/models/valuation"
+
+type OpeningBalanceRequest struct {
+	ProductId   int              `json:"product_id" validate:"required"`
+	WarehouseId int              `json:"warehouse_id" validate:"required"`
+	Quantity    int              `json:"quantity" validate:"gte=0"`
+	UnitCost    *valuation.Money `json:"unit_cost" validate:"omitempty,gte=0"`
+}
+
+type LineError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type Report struct {
+	Kind        string      `json:"kind"`
+	Format      string      `json:"format"`
+	DryRun      bool        `json:"dry_run"`
+	TotalRows   int         `json:"total_rows"`
+	ValidRows   int         `json:"valid_rows"`
+	AppliedRows int         `json:"applied_rows"`
+	Errors      []LineError `json:"errors"`
+}
@@ -0,0 +1,36 @@
+package bundle
+
+type BundleComponent struct {
+	BundleProductId int `db:"bundle_product_id" json:"bundle_product_id"`
+	ProductId       int `db:"product_id" json:"product_id"`
+	Quantity        int `db:"quantity" json:"quantity"`
+}
+
+type RegisterRequest struct {
+	BundleProductId int                `json:"bundle_product_id" validate:"required"`
+	Components      []ComponentRequest `json:"components" validate:"required,min=1,dive"`
+}
+
+type ComponentRequest struct {
+	ProductId int `json:"product_id" validate:"required"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
+}
+
+type ComponentStock struct {
+	ProductId      int `db:"product_id"`
+	WarehouseId    int `db:"warehouse_id"`
+	AvailableStock int `db:"available_stock"`
+}
+
+type WarehouseAvailability struct {
+	WarehouseId    int `json:"warehouse_id"`
+	AvailableStock int `json:"available_stock"`
+}
+
+type Availability struct {
+	BundleProductId int                     `json:"bundle_product_id"`
+	ShopId          int                     `json:"shop_id"`
+	AvailableStock  int                     `json:"available_stock"`
+	Unit            string                  `json:"unit,omitempty"`
+	Warehouses      []WarehouseAvailability `json:"warehouses"`
+}
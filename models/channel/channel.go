@@ -0,0 +1,49 @@
+package channel
+
+type ChannelAllocation struct {
+	Id                  int    `db:"id" json:"id"`
+	ShopId              int    `db:"shop_id" json:"shop_id"`
+	ProductId           int    `db:"product_id" json:"product_id"`
+	Channel             string `db:"channel" json:"channel"`
+	AllocationType      string `db:"allocation_type" json:"allocation_type"`
+	Value               int    `db:"value" json:"value"`
+	AllowSharedFallback bool   `db:"allow_shared_fallback" json:"allow_shared_fallback"`
+}
+
+// ChannelReservation is the stock the open orders of a channel hold in a
+// shop.
+type ChannelReservation struct {
+	ShopId        int    `db:"shop_id"`
+	ProductId     int    `db:"product_id"`
+	Channel       string `db:"channel"`
+	ReservedStock int    `db:"reserved_stock"`
+}
+
+type RegisterRequest struct {
+	ShopId              int    `json:"shop_id" validate:"required"`
+	ProductId           int    `json:"product_id" validate:"required"`
+	Channel             string `json:"channel" validate:"required"`
+	AllocationType      string `json:"allocation_type" validate:"required,oneof=fixed percentage"`
+	Value               int    `json:"value" validate:"required,gt=0"`
+	AllowSharedFallback bool   `json:"allow_shared_fallback"`
+}
+
+type ChannelAvailability struct {
+	Channel        string `json:"channel"`
+	Pool           int    `json:"pool"`
+	AvailableStock int    `json:"available_stock"`
+}
+
+type ProductAvailability struct {
+	ProductId      int                   `json:"product_id"`
+	ShopId         int                   `json:"shop_id"`
+	AvailableStock int                   `json:"available_stock"`
+	SharedStock    int                   `json:"shared_stock"`
+	Channels       []ChannelAvailability `json:"channels"`
+}
+
+type RemoveRequest struct {
+	ShopId    int    `json:"shop_id" validate:"required"`
+	ProductId int    `json:"product_id" validate:"required"`
+	Channel   string `json:"channel" validate:"required"`
+}
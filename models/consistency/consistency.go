@@ -0,0 +1,39 @@
+package consistency
+
+import "time"
+
+type ReservationState struct {
+	ProductId          int `db:"product_id"`
+	WarehouseId        int `db:"warehouse_id"`
+	AvailableStock     int `db:"available_stock"`
+	ReservedStock      int `db:"reserved_stock"`
+	OrderReservedStock int `db:"order_reserved_stock"`
+	SlotStock          int `db:"slot_stock"`
+}
+
+type OrderReservation struct {
+	Id            int    `db:"id"`
+	OrderId       int    `db:"order_id"`
+	ProductId     int    `db:"product_id"`
+	WarehouseId   int    `db:"warehouse_id"`
+	ReservedStock int    `db:"reserved_stock"`
+	Status        string `db:"status"`
+}
+
+type Finding struct {
+	Type             string `json:"type"`
+	ProductId        int    `json:"product_id"`
+	WarehouseId      int    `json:"warehouse_id"`
+	OrderId          int    `json:"order_id,omitempty"`
+	OrderWarehouseId int    `json:"order_warehouse_id,omitempty"`
+	Expected         int    `json:"expected"`
+	Actual           int    `json:"actual"`
+	Detail           string `json:"detail"`
+	Repaired         bool   `json:"repaired"`
+}
+
+type Report struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Repair    bool      `json:"repair"`
+	Findings  []Finding `json:"findings"`
+}
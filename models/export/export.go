@@ -0,0 +1,55 @@
+package export
+
+import "time"
+
+// Filter narrows an export, zero values match all. From and To bound the
+// created_at of movements and reservations and are ignored for stock levels.
+type Filter struct {
+	ShopId      int `validate:"gte=0"`
+	WarehouseId int `validate:"gte=0"`
+	ProductId   int `validate:"gte=0"`
+	From        *time.Time
+	To          *time.Time
+}
+
+// ExportRequest resumes after the row with id Cursor and stops after Limit
+// rows, a zero Limit exports everything. A limited page is held in memory,
+// so Limit is at most 10000.
+type ExportRequest struct {
+	Kind   string `validate:"required"`
+	Format string `validate:"required"`
+	Filter Filter
+	Cursor int `validate:"gte=0"`
+	Limit  int `validate:"gte=0,lte=10000"`
+}
+
+type StockLevel struct {
+	Id             int `db:"id" json:"id"`
+	ShopId         int `db:"shop_id" json:"shop_id"`
+	ProductId      int `db:"product_id" json:"product_id"`
+	WarehouseId    int `db:"warehouse_id" json:"warehouse_id"`
+	AvailableStock int `db:"available_stock" json:"available_stock"`
+	ReservedStock  int `db:"reserved_stock" json:"reserved_stock"`
+	SafetyStock    int `db:"safety_stock" json:"safety_stock"`
+}
+
+type Movement struct {
+	Id             int       `db:"id" json:"id"`
+	ShopId         int       `db:"shop_id" json:"shop_id"`
+	ProductId      int       `db:"product_id" json:"product_id"`
+	WarehouseId    int       `db:"warehouse_id" json:"warehouse_id"`
+	AvailableDelta int       `db:"available_delta" json:"available_delta"`
+	ReservedDelta  int       `db:"reserved_delta" json:"reserved_delta"`
+	Reason         *string   `db:"reason" json:"reason"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
+
+type Reservation struct {
+	Id            int       `db:"id" json:"id"`
+	ShopId        int       `db:"shop_id" json:"shop_id"`
+	OrderId       int       `db:"order_id" json:"order_id"`
+	ProductId     int       `db:"product_id" json:"product_id"`
+	WarehouseId   int       `db:"warehouse_id" json:"warehouse_id"`
+	ReservedStock int       `db:"reserved_stock" json:"reserved_stock"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
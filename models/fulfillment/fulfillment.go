@@ -0,0 +1,67 @@
+package fulfillment
+
+type PickList struct {
+	Id          int            `db:"id" json:"id"`
+	OrderId     int            `db:"order_id" json:"order_id"`
+	WarehouseId int            `db:"warehouse_id" json:"warehouse_id"`
+	Status      string         `db:"status" json:"status"`
+	Items       []PickListItem `db:"-" json:"items"`
+}
+
+type PickListItem struct {
+	Id             int `db:"id" json:"id"`
+	PickListId     int `db:"pick_list_id" json:"pick_list_id"`
+	ProductId      int `db:"product_id" json:"product_id"`
+	Quantity       int `db:"quantity" json:"quantity"`
+	PickedQuantity int `db:"picked_quantity" json:"picked_quantity"`
+}
+
+type PendingPickItem struct {
+	WarehouseId int `db:"warehouse_id"`
+	ProductId   int `db:"product_id"`
+	Quantity    int `db:"quantity"`
+}
+
+type PickConfirmRequest struct {
+	PickListId int          `json:"pick_list_id" validate:"required"`
+	Items      []PickedItem `json:"items" validate:"required,dive"`
+}
+
+type PickedItem struct {
+	ProductId      int `json:"product_id" validate:"required"`
+	PickedQuantity int `json:"picked_quantity" validate:"gte=0"`
+}
+
+type PickListRequest struct {
+	PickListId int `json:"pick_list_id" validate:"required"`
+}
+
+type ShortPick struct {
+	ProductId   int `json:"product_id"`
+	Shortfall   int `json:"shortfall"`
+	Reallocated int `json:"reallocated"`
+}
+
+type PickConfirmation struct {
+	PickListId int         `json:"pick_list_id"`
+	ShortPicks []ShortPick `json:"short_picks"`
+}
+
+type Shipment struct {
+	Id          int `db:"id"`
+	PickListId  int `db:"pick_list_id"`
+	OrderId     int `db:"order_id"`
+	WarehouseId int `db:"warehouse_id"`
+}
+
+type ShipmentItem struct {
+	ProductId int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+type ShippedEvent struct {
+	ShipmentId  int            `json:"shipment_id"`
+	OrderId     int            `json:"order_id"`
+	WarehouseId int            `json:"warehouse_id"`
+	Items       []ShipmentItem `json:"items"`
+}
@@ -0,0 +1,12 @@
+package health
+
+type Check struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Readiness struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
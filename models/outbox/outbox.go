@@ -0,0 +1,16 @@
+package outbox
+
+import "time"
+
+// Event is an event waiting in the outbox table to be published. Payload is
+// the JSON of the event data, TraceContext the propagation headers of the
+// request that stored it.
+type Event struct {
+	Id           int       `db:"id"`
+	MessageId    string    `db:"message_id"`
+	EventType    string    `db:"event_type"`
+	Payload      []byte    `db:"payload"`
+	TraceContext []byte    `db:"trace_context"`
+	Attempts     int       `db:"attempts"`
+	CreatedAt    time.Time `db:"created_at"`
+}
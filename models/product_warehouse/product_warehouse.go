@@ -1,40 +1,93 @@
 package product_warehouse
 
+import (
+	"time"
+	"warehouse-service/models/valuation"
+)
+
 type ProductWarehouse struct {
 	Id             int `db:"id"`
 	ProductId      int `db:"product_id"`
 	WarehouseId    int `db:"warehouse_id"`
 	AvailableStock int `db:"available_stock"`
 	ReservedStock  int `db:"reserved_stock"`
+	SafetyStock    int `db:"safety_stock"`
 }
 
 type RegisterRequest struct {
-	ProductId      int `json:"product_id" validate:"required"`
-	WarehouseId    int `json:"warehouse_id" validate:"required"`
-	AvailableStock int `json:"available_stock"`
+	ProductId      int              `json:"product_id" validate:"required"`
+	WarehouseId    int              `json:"warehouse_id" validate:"required"`
+	AvailableStock int              `json:"available_stock"`
+	SafetyStock    int              `json:"safety_stock" validate:"gte=0"`
+	UnitCost       *valuation.Money `json:"unit_cost,omitempty" validate:"omitempty,gte=0"`
+}
+
+type SafetyStockRequest struct {
+	ProductId   int `json:"product_id" validate:"required"`
+	WarehouseId int `json:"warehouse_id" validate:"required"`
+	SafetyStock int `json:"safety_stock" validate:"gte=0"`
+}
+
+type DimensionRequest struct {
+	ProductId int     `json:"product_id" validate:"required"`
+	Length    float64 `json:"length" validate:"required,gt=0"`
+	Width     float64 `json:"width" validate:"required,gt=0"`
+	Height    float64 `json:"height" validate:"required,gt=0"`
 }
 
 type TransferStockRequest struct {
-	ProductId       int `json:"product_id" validate:"required"`
-	FromWarehouseId int `json:"from_warehouse_id" validate:"required"`
-	ToWarehouseId   int `json:"to_warehouse_id" validate:"required"`
-	Quantity        int `json:"quantity" validate:"required"`
+	ProductId       int        `json:"product_id" validate:"required"`
+	FromWarehouseId int        `json:"from_warehouse_id" validate:"required"`
+	ToWarehouseId   int        `json:"to_warehouse_id" validate:"required,nefield=FromWarehouseId"`
+	Quantity        int        `json:"quantity" validate:"required"`
+	Unit            string     `json:"unit,omitempty"`
+	ExpectedAt      *time.Time `json:"expected_at,omitempty"`
+	InboundId       int        `json:"inbound_id,omitempty"`
 }
 
 type StockOperationRequest struct {
-	ProductId   int `json:"product_id" validate:"required"`
-	WarehouseId int `json:"warehouse_id" validate:"required"`
-	Quantity    int `json:"quantity" validate:"required"`
+	ProductId   int              `json:"product_id" validate:"required"`
+	WarehouseId int              `json:"warehouse_id" validate:"required"`
+	Quantity    int              `json:"quantity" validate:"required"`
+	Unit        string           `json:"unit,omitempty"`
+	UnitCost    *valuation.Money `json:"unit_cost,omitempty" validate:"omitempty,gte=0"`
+}
+
+// BundleOperation is a bundle line of an order with the components of one
+// bundle. Each bundle is allocated with all its components from one warehouse.
+type BundleOperation struct {
+	BundleProductId int
+	Quantity        int
+	Components      []StockOperationRequest
 }
 
 type StockOperationOrderRequest struct {
 	OrderId         int                     `json:"order_id"`
+	ShopId          int                     `json:"shop_id"`
 	StockOperations []StockOperationRequest `json:"stock_operations" validate:"required"`
+	Channel         string                  `json:"channel"`
+	AllocationMode  string                  `json:"allocation_mode" validate:"omitempty,oneof=sequential nearest"`
+	Destination     *Coordinate             `json:"destination" validate:"required_if=AllocationMode nearest,omitempty"`
+}
+
+type Coordinate struct {
+	Latitude  float64 `json:"latitude" validate:"latitude"`
+	Longitude float64 `json:"longitude" validate:"longitude"`
+}
+
+type WarehouseStockLocation struct {
+	ProductId      int      `db:"product_id"`
+	WarehouseId    int      `db:"warehouse_id"`
+	AvailableStock int      `db:"available_stock"`
+	Latitude       *float64 `db:"latitude"`
+	Longitude      *float64 `db:"longitude"`
 }
 
 type ProductShop struct {
-	ProductId int `json:"product_id" validate:"required"`
-	ShopId    int `json:"shop_id" validate:"required"`
+	ProductId int    `json:"product_id" validate:"required"`
+	ShopId    int    `json:"shop_id" validate:"required"`
+	Channel   string `json:"channel,omitempty"`
+	Unit      string `json:"unit,omitempty"`
 }
 
 type UpdateStatusRequest struct {
@@ -47,14 +100,93 @@ type StockOperationProductRequest struct {
 	Quantity  int `json:"quantity" validate:"required"`
 }
 
+// OrderWarehouse is the stock an order holds in a warehouse. Stock taken
+// from a channel pool keeps the shop and channel, so the pool runs down
+// until the order ships or is returned.
 type OrderWarehouse struct {
-	Id            int `db:"id"`
-	OrderId       int `db:"order_id"`
-	ProductId     int `db:"product_id"`
-	WarehouseId   int `db:"warehouse_id"`
-	ReservedStock int `db:"reserved_stock"`
+	Id            int    `db:"id"`
+	OrderId       int    `db:"order_id"`
+	ProductId     int    `db:"product_id"`
+	WarehouseId   int    `db:"warehouse_id"`
+	ReservedStock int    `db:"reserved_stock"`
+	ShopId        int    `db:"shop_id"`
+	Channel       string `db:"channel"`
 }
 
 type Order struct {
 	OrderId int `json:"order_id" validate:"required"`
 }
+
+type InboundStock struct {
+	Id              int              `db:"id" json:"id"`
+	ProductId       int              `db:"product_id" json:"product_id"`
+	WarehouseId     int              `db:"warehouse_id" json:"warehouse_id"`
+	FromWarehouseId *int             `db:"from_warehouse_id" json:"from_warehouse_id,omitempty"`
+	Quantity        int              `db:"quantity" json:"quantity"`
+	UnitCost        *valuation.Money `db:"unit_cost" json:"unit_cost"`
+	Source          string           `db:"source" json:"source"`
+	ExpectedAt      time.Time        `db:"expected_at" json:"expected_at"`
+	Status          string           `db:"status" json:"status"`
+}
+
+type InboundRegisterRequest struct {
+	ProductId   int              `json:"product_id" validate:"required"`
+	WarehouseId int              `json:"warehouse_id" validate:"required"`
+	Quantity    int              `json:"quantity" validate:"required,gt=0"`
+	Unit        string           `json:"unit,omitempty"`
+	UnitCost    *valuation.Money `json:"unit_cost,omitempty" validate:"omitempty,gte=0"`
+	Source      string           `json:"source" validate:"required,oneof=transfer purchase"`
+	ExpectedAt  time.Time        `json:"expected_at" validate:"required"`
+}
+
+// StockSummary sums a product's stock in a shop. SlotStock is the flash sale
+// stock carved out of available into reserved and not sold yet.
+type StockSummary struct {
+	AvailableStock int `db:"available_stock"`
+	ReservedStock  int `db:"reserved_stock"`
+	SafetyStock    int `db:"safety_stock"`
+	SlotStock      int `db:"slot_stock"`
+}
+
+type AvailableToPromiseRequest struct {
+	ProductId   int    `json:"product_id" validate:"required"`
+	ShopId      int    `json:"shop_id" validate:"required"`
+	HorizonDays int    `json:"horizon_days" validate:"required,gt=0,lte=365"`
+	Quantity    int    `json:"quantity" validate:"gte=0"`
+	Unit        string `json:"unit,omitempty"`
+}
+
+type AvailableToPromisePoint struct {
+	Date      string `json:"date"`
+	Inbound   int    `json:"inbound"`
+	Available int    `json:"available"`
+}
+
+type AvailableToPromise struct {
+	ProductId           int                       `json:"product_id"`
+	ShopId              int                       `json:"shop_id"`
+	OnHand              int                       `json:"on_hand"`
+	Reserved            int                       `json:"reserved"`
+	SafetyStock         int                       `json:"safety_stock"`
+	Inbound             int                       `json:"inbound"`
+	Unit                string                    `json:"unit,omitempty"`
+	Curve               []AvailableToPromisePoint `json:"curve"`
+	EarliestPromiseDate *string                   `json:"earliest_promise_date"`
+}
+
+type FlashSaleRequest struct {
+	ProductId int `json:"product_id" validate:"required"`
+	Slots     int `json:"slots" validate:"required,gt=0,lte=64"`
+}
+
+type FlashSaleProductRequest struct {
+	ProductId int `json:"product_id" validate:"required"`
+}
+
+type StockSlot struct {
+	Id          int `db:"id"`
+	ProductId   int `db:"product_id"`
+	WarehouseId int `db:"warehouse_id"`
+	Slot        int `db:"slot"`
+	Stock       int `db:"stock"`
+}
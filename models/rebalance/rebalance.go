@@ -0,0 +1,34 @@
+package rebalance
+
+import "warehouse-service/models/product_warehouse"
+
+type PlanRequest struct {
+	ShopId           int  `json:"shop_id" validate:"required"`
+	ProductId        int  `json:"product_id"`
+	DemandDays       int  `json:"demand_days" validate:"required,gt=0"`
+	CoverageDays     int  `json:"coverage_days" validate:"required,gt=0"`
+	MaxTransferUnits int  `json:"max_transfer_units" validate:"required,gt=0"`
+	DryRun           bool `json:"dry_run"`
+}
+
+type WarehouseStock struct {
+	ProductId      int `db:"product_id"`
+	WarehouseId    int `db:"warehouse_id"`
+	AvailableStock int `db:"available_stock"`
+	SafetyStock    int `db:"safety_stock"`
+	Demand         int `db:"demand"`
+}
+
+type WarehouseTarget struct {
+	ProductId      int `json:"product_id"`
+	WarehouseId    int `json:"warehouse_id"`
+	AvailableStock int `json:"available_stock"`
+	TargetStock    int `json:"target_stock"`
+}
+
+type Plan struct {
+	DryRun     bool                                     `json:"dry_run"`
+	TotalUnits int                                      `json:"total_units"`
+	Targets    []WarehouseTarget                        `json:"targets"`
+	Transfers  []product_warehouse.TransferStockRequest `json:"transfers"`
+}
@@ -0,0 +1,55 @@
+package stock_history
+
+import "time"
+
+type Snapshot struct {
+	Id      int       `db:"id" json:"id"`
+	TakenAt time.Time `db:"taken_at" json:"taken_at"`
+}
+
+type StockLevel struct {
+	ProductId      int `db:"product_id" json:"product_id"`
+	WarehouseId    int `db:"warehouse_id" json:"warehouse_id"`
+	AvailableStock int `db:"available_stock" json:"available_stock"`
+	ReservedStock  int `db:"reserved_stock" json:"reserved_stock"`
+}
+
+// Filter narrows a query to one warehouse and/or product, zero matches all.
+type Filter struct {
+	WarehouseId int `json:"warehouse_id" validate:"gte=0"`
+	ProductId   int `json:"product_id" validate:"gte=0"`
+}
+
+type AsOfRequest struct {
+	Filter
+	At time.Time `json:"at" validate:"required"`
+}
+
+type AsOf struct {
+	At         time.Time    `json:"at"`
+	SnapshotId *int         `json:"snapshot_id"`
+	Stocks     []StockLevel `json:"stocks"`
+}
+
+type DiffRequest struct {
+	Filter
+	From time.Time `json:"from" validate:"required"`
+	To   time.Time `json:"to" validate:"required,gtfield=From"`
+}
+
+type StockDiff struct {
+	ProductId          int `json:"product_id"`
+	WarehouseId        int `json:"warehouse_id"`
+	FromAvailableStock int `json:"from_available_stock"`
+	ToAvailableStock   int `json:"to_available_stock"`
+	AvailableDelta     int `json:"available_delta"`
+	FromReservedStock  int `json:"from_reserved_stock"`
+	ToReservedStock    int `json:"to_reserved_stock"`
+	ReservedDelta      int `json:"reserved_delta"`
+}
+
+type Diff struct {
+	From  time.Time   `json:"from"`
+	To    time.Time   `json:"to"`
+	Diffs []StockDiff `json:"diffs"`
+}
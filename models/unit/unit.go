@@ -0,0 +1,13 @@
+package unit
+
+type ProductUnit struct {
+	ProductId int    `db:"product_id" json:"product_id"`
+	Unit      string `db:"unit" json:"unit"`
+	Factor    int    `db:"factor" json:"factor"`
+}
+
+type RegisterRequest struct {
+	ProductId int    `json:"product_id" validate:"required"`
+	Unit      string `json:"unit" validate:"required,ne=each"`
+	Factor    int    `json:"factor" validate:"required,gt=0"`
+}
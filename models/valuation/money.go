@@ -0,0 +1,112 @@
+package valuation
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in ten-thousandths of the currency unit. Costs are kept
+// as integers so layers and movements add up exactly, and four decimal places
+// leave room for the cost of one unit out of a pack. It is read and written
+// as decimal text in JSON, CSV and DECIMAL columns.
+type Money int64
+
+const moneyScale = 10000
+
+// ParseMoney parses a decimal amount with at most four decimal places.
+func ParseMoney(text string) (Money, error) {
+	digits := strings.TrimPrefix(text, "-")
+	negative := digits != text
+	whole, fraction, _ := strings.Cut(digits, ".")
+	fraction = strings.TrimRight(fraction, "0")
+	if whole == "" || len(fraction) > 4 || strings.HasPrefix(whole, "+") {
+		return 0, fmt.Errorf("%q is not an amount with at most four decimal places", text)
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an amount with at most four decimal places", text)
+	}
+	var fractionUnits int64
+	if fraction != "" {
+		fractionUnits, err = strconv.ParseInt(fraction+strings.Repeat("0", 4-len(fraction)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an amount with at most four decimal places", text)
+		}
+	}
+
+	amount := Money(units*moneyScale + fractionUnits)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func (m Money) String() string {
+	sign := ""
+	amount := int64(m)
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%04d", sign, amount/moneyScale, amount%moneyScale)
+}
+
+// Times returns the amount of quantity units.
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Per divides the amount by quantity, rounding half away from zero.
+func (m Money) Per(quantity int) Money {
+	if quantity == 0 {
+		return 0
+	}
+	divisor := Money(quantity)
+	if (m < 0) != (divisor < 0) {
+		return (m - divisor/2) / divisor
+	}
+	return (m + divisor/2) / divisor
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	return m.UnmarshalText([]byte(strings.Trim(string(data), `"`)))
+}
+
+func (m *Money) UnmarshalText(text []byte) error {
+	amount, err := ParseMoney(string(text))
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		return m.UnmarshalText(value)
+	case string:
+		return m.UnmarshalText([]byte(value))
+	case int64:
+		*m = Money(value * moneyScale)
+	case float64:
+		*m = Money(math.Round(value * moneyScale))
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
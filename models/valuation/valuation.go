@@ -0,0 +1,55 @@
+package valuation
+
+import "time"
+
+type CostLayer struct {
+	Id                int       `db:"id"`
+	ProductId         int       `db:"product_id"`
+	WarehouseId       int       `db:"warehouse_id"`
+	RemainingQuantity int       `db:"remaining_quantity"`
+	UnitCost          Money     `db:"unit_cost"`
+	ReceivedAt        time.Time `db:"received_at"`
+}
+
+type CostMovement struct {
+	ProductId    int       `db:"product_id"`
+	WarehouseId  int       `db:"warehouse_id"`
+	MovementType string    `db:"movement_type"`
+	Quantity     int       `db:"quantity"`
+	Value        Money     `db:"value"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+type ProductValueRow struct {
+	WarehouseId int   `db:"warehouse_id"`
+	ProductId   int   `db:"product_id"`
+	Quantity    int   `db:"quantity"`
+	Value       Money `db:"value"`
+}
+
+// InventoryValueRequest asks for the value at the end of AsOf, a UTC date.
+type InventoryValueRequest struct {
+	ShopId int    `json:"shop_id" validate:"required"`
+	AsOf   string `json:"as_of" validate:"required,datetime=2006-01-02"`
+}
+
+type ProductValue struct {
+	ProductId int   `json:"product_id"`
+	Quantity  int   `json:"quantity"`
+	Value     Money `json:"value"`
+}
+
+type WarehouseValue struct {
+	WarehouseId int            `json:"warehouse_id"`
+	Quantity    int            `json:"quantity"`
+	Value       Money          `json:"value"`
+	Products    []ProductValue `json:"products"`
+}
+
+type InventoryValue struct {
+	ShopId     int              `json:"shop_id"`
+	AsOf       string           `json:"as_of"`
+	Quantity   int              `json:"quantity"`
+	Value      Money            `json:"value"`
+	Warehouses []WarehouseValue `json:"warehouses"`
+}
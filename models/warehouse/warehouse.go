@@ -1,20 +1,59 @@
 package warehouse
 
 type Warehouse struct {
-	Id      int    `db:"id"`
-	Name    string `db:"name"`
-	Address string `db:"address"`
-	ShopId  int    `db:"shop_id"`
+	Id             int      `db:"id"`
+	Name           string   `db:"name"`
+	Address        string   `db:"address"`
+	ShopId         int      `db:"shop_id"`
+	Latitude       *float64 `db:"latitude"`
+	Longitude      *float64 `db:"longitude"`
+	CapacityUnits  *int     `db:"capacity_units"`
+	CapacityVolume *float64 `db:"capacity_volume"`
+	CostingMethod  string   `db:"costing_method"`
 }
 
 type RegisterRequest struct {
-	Name    string `json:"name" validate:"required"`
-	Address string `json:"address" validate:"required"`
-	ShopId  int    `json:"shop_id" validate:"required"`
-	Status  string `json:"status" validate:"required"`
+	Name           string   `json:"name" validate:"required"`
+	Address        string   `json:"address" validate:"required"`
+	ShopId         int      `json:"shop_id" validate:"required"`
+	Status         string   `json:"status" validate:"required"`
+	Latitude       *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,latitude"`
+	Longitude      *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,longitude"`
+	CapacityUnits  *int     `json:"capacity_units" validate:"omitempty,gt=0"`
+	CapacityVolume *float64 `json:"capacity_volume" validate:"omitempty,gt=0"`
+	CostingMethod  string   `json:"costing_method" validate:"omitempty,oneof=fifo weighted_average"`
 }
 
 type UpdateStatusRequest struct {
 	Id     int
 	Status string `json:"status" validate:"required"`
 }
+
+type UpdateLocationRequest struct {
+	Id        int
+	Latitude  *float64 `json:"latitude" validate:"required,latitude"`
+	Longitude *float64 `json:"longitude" validate:"required,longitude"`
+}
+
+type UpdateCapacityRequest struct {
+	Id             int
+	CapacityUnits  *int     `json:"capacity_units" validate:"omitempty,gt=0"`
+	CapacityVolume *float64 `json:"capacity_volume" validate:"omitempty,gt=0"`
+}
+
+type UpdateCostingMethodRequest struct {
+	Id            int
+	CostingMethod string `json:"costing_method" validate:"required,oneof=fifo weighted_average"`
+}
+
+type Utilization struct {
+	WarehouseId       int      `db:"warehouse_id" json:"warehouse_id"`
+	ShopId            int      `db:"shop_id" json:"shop_id"`
+	Name              string   `db:"name" json:"name"`
+	CapacityUnits     *int     `db:"capacity_units" json:"capacity_units"`
+	CapacityVolume    *float64 `db:"capacity_volume" json:"capacity_volume"`
+	UsedUnits         int      `db:"used_units" json:"used_units"`
+	UsedVolume        float64  `db:"used_volume" json:"used_volume"`
+	UnitsUsedPercent  *float64 `db:"-" json:"units_used_percent"`
+	VolumeUsedPercent *float64 `db:"-" json:"volume_used_percent"`
+}
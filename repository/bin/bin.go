@@ -0,0 +1,160 @@
+package bin
+
+import (
+	"context"
+	"warehouse-service/models/bin"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type BinRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewBinRepository(mysql *sqlx.DB) *BinRepository {
+	return &BinRepository{
+		mysql: mysql,
+	}
+}
+
+func (b *BinRepository) InsertZone(ctx context.Context, zone *bin.ZoneRegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.InsertZone")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = b.mysql.ExecContext(ctx, "INSERT INTO warehouse_zones (warehouse_id,code) VALUES (?,?)", zone.WarehouseId, zone.Code)
+	return err
+}
+
+func (b *BinRepository) InsertAisle(ctx context.Context, aisle *bin.AisleRegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.InsertAisle")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = b.mysql.ExecContext(ctx, "INSERT INTO warehouse_aisles (zone_id,code) VALUES (?,?)", aisle.ZoneId, aisle.Code)
+	return err
+}
+
+func (b *BinRepository) InsertBin(ctx context.Context, binRegister *bin.BinRegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.InsertBin")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = b.mysql.ExecContext(ctx, "INSERT INTO warehouse_bins (aisle_id,code) VALUES (?,?)", binRegister.AisleId, binRegister.Code)
+	return err
+}
+
+func (b *BinRepository) GetWarehouseIdByBinId(ctx context.Context, binId int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.GetWarehouseIdByBinId")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT z.warehouse_id
+		FROM warehouse_bins b
+		JOIN warehouse_aisles a ON b.aisle_id = a.id
+		JOIN warehouse_zones z ON a.zone_id = z.id
+		WHERE b.id = ?
+	`
+
+	var warehouseId int
+	err = b.mysql.GetContext(ctx, &warehouseId, query, binId)
+	return warehouseId, err
+}
+
+// LockProductWarehouse locks the stock of the product in the warehouse until
+// tx ends.
+func (b *BinRepository) LockProductWarehouse(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.LockProductWarehouse")
+	defer func() { tracing.End(span, err) }()
+
+	var id int
+	return tx.GetContext(ctx, &id, "SELECT id FROM product_warehouses WHERE product_id=? and warehouse_id=? FOR UPDATE", productId, warehouseId)
+}
+
+func (b *BinRepository) GetUnbinnedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.GetUnbinnedStock")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT pw.available_stock + pw.reserved_stock - COALESCE((
+			SELECT SUM(bs.quantity)
+			FROM bin_stocks bs
+			JOIN warehouse_bins b ON bs.bin_id = b.id
+			JOIN warehouse_aisles a ON b.aisle_id = a.id
+			JOIN warehouse_zones z ON a.zone_id = z.id
+			WHERE bs.product_id = pw.product_id AND z.warehouse_id = pw.warehouse_id
+		), 0)
+		FROM product_warehouses pw
+		WHERE pw.product_id = ? AND pw.warehouse_id = ?
+	`
+
+	var unbinnedStock int
+	err = tx.GetContext(ctx, &unbinnedStock, query, productId, warehouseId)
+	return unbinnedStock, err
+}
+
+func (b *BinRepository) AddBinStock(ctx context.Context, tx *sqlx.Tx, binId int, productId int, quantity int) (err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.AddBinStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO bin_stocks (bin_id,product_id,quantity) VALUES (?,?,?) ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity)", binId, productId, quantity)
+	return err
+}
+
+func (b *BinRepository) SubstractBinStock(ctx context.Context, tx *sqlx.Tx, binId int, productId int, quantity int) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.SubstractBinStock")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := tx.ExecContext(ctx, "UPDATE bin_stocks SET quantity = quantity - ? WHERE bin_id=? and product_id=? and quantity >= ?", quantity, binId, productId, quantity)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (b *BinRepository) GetBinLocations(ctx context.Context, productId int, warehouseId int) (_ []bin.BinLocation, err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.GetBinLocations")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT b.id AS bin_id, z.code AS zone_code, a.code AS aisle_code, b.code AS bin_code, bs.quantity
+		FROM bin_stocks bs
+		JOIN warehouse_bins b ON bs.bin_id = b.id
+		JOIN warehouse_aisles a ON b.aisle_id = a.id
+		JOIN warehouse_zones z ON a.zone_id = z.id
+		WHERE bs.product_id = ? AND z.warehouse_id = ? AND bs.quantity > 0
+		ORDER BY z.code asc, a.code asc, b.code asc
+	`
+
+	var binLocations []bin.BinLocation
+	err = b.mysql.SelectContext(ctx, &binLocations, query, productId, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	return binLocations, nil
+}
+
+func (b *BinRepository) GetBinLocationsForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (_ []bin.BinLocation, err error) {
+	ctx, span := tracing.Start(ctx, "BinRepository.GetBinLocationsForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT b.id AS bin_id, z.code AS zone_code, a.code AS aisle_code, b.code AS bin_code, bs.quantity
+		FROM bin_stocks bs
+		JOIN warehouse_bins b ON bs.bin_id = b.id
+		JOIN warehouse_aisles a ON b.aisle_id = a.id
+		JOIN warehouse_zones z ON a.zone_id = z.id
+		WHERE bs.product_id = ? AND z.warehouse_id = ? AND bs.quantity > 0
+		ORDER BY z.code asc, a.code asc, b.code asc
+		FOR UPDATE OF bs
+	`
+
+	var binLocations []bin.BinLocation
+	err = tx.SelectContext(ctx, &binLocations, query, productId, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	return binLocations, nil
+}
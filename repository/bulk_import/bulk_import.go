@@ -0,0 +1,130 @@
+package bulk_import
+
+import (
+	"context"
+	"database/sql"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type BulkImportRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewBulkImportRepository(mysql *sqlx.DB) *BulkImportRepository {
+	return &BulkImportRepository{
+		mysql: mysql,
+	}
+}
+
+// UpsertWarehouse matches warehouses by shop and name. warehouses has no
+// unique key on them, so the warehouse is looked up under lock and updated
+// when found, otherwise inserted. When names repeat within a shop the oldest
+// warehouse is updated.
+func (b *BulkImportRepository) UpsertWarehouse(ctx context.Context, tx *sqlx.Tx, warehouse *warehouse.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BulkImportRepository.UpsertWarehouse")
+	defer func() { tracing.End(span, err) }()
+
+	var id int
+	err = tx.GetContext(ctx, &id, "SELECT id FROM warehouses WHERE shop_id=? AND name=? ORDER BY id LIMIT 1 FOR UPDATE", warehouse.ShopId, warehouse.Name)
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO warehouses (name,address,shop_id,status,latitude,longitude,capacity_units,capacity_volume,costing_method)
+			VALUES (?,?,?,?,?,?,?,?,?)
+		`, warehouse.Name, warehouse.Address, warehouse.ShopId, warehouse.Status, warehouse.Latitude, warehouse.Longitude, warehouse.CapacityUnits, warehouse.CapacityVolume, warehouse.CostingMethod)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE warehouses SET address=?, status=?, latitude=?, longitude=?, capacity_units=?, capacity_volume=?, costing_method=?
+		WHERE id=?
+	`, warehouse.Address, warehouse.Status, warehouse.Latitude, warehouse.Longitude, warehouse.CapacityUnits, warehouse.CapacityVolume, warehouse.CostingMethod, id)
+	return err
+}
+
+// UpsertProductWarehouse registers the product in the warehouse, or updates
+// its safety stock when it is registered already, and reports whether it was
+// registered. Stock of an existing registration is left to opening balances.
+func (b *BulkImportRepository) UpsertProductWarehouse(ctx context.Context, tx *sqlx.Tx, productWarehouse *product_warehouse.RegisterRequest) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "BulkImportRepository.UpsertProductWarehouse")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO product_warehouses (product_id,warehouse_id,available_stock,safety_stock) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE safety_stock=VALUES(safety_stock)", productWarehouse.ProductId, productWarehouse.WarehouseId, productWarehouse.AvailableStock, productWarehouse.SafetyStock)
+	if err != nil {
+		return false, err
+	}
+	// 1 for an insert, 2 for an update and 0 for an unchanged row
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected != 1 {
+		return false, nil
+	}
+	if productWarehouse.AvailableStock == 0 {
+		return true, nil
+	}
+	return true, insertMovement(ctx, tx, productWarehouse.ProductId, productWarehouse.WarehouseId, productWarehouse.AvailableStock)
+}
+
+// LockWarehouse locks the warehouse row until tx ends.
+func (b *BulkImportRepository) LockWarehouse(ctx context.Context, tx *sqlx.Tx, warehouseId int) (err error) {
+	ctx, span := tracing.Start(ctx, "BulkImportRepository.LockWarehouse")
+	defer func() { tracing.End(span, err) }()
+
+	var id int
+	return tx.GetContext(ctx, &id, "SELECT id FROM warehouses WHERE id=? FOR UPDATE", warehouseId)
+}
+
+// GetAvailableStockForUpdate returns the available stock of a
+// product-warehouse and whether the product is registered in the warehouse,
+// locking the row until tx ends.
+func (b *BulkImportRepository) GetAvailableStockForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (_ int, _ bool, err error) {
+	ctx, span := tracing.Start(ctx, "BulkImportRepository.GetAvailableStockForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	var availableStock int
+	err = tx.GetContext(ctx, &availableStock, "SELECT available_stock FROM product_warehouses WHERE product_id=? and warehouse_id=? FOR UPDATE", productId, warehouseId)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return availableStock, true, nil
+}
+
+// SetOpeningBalance sets the available stock of a product-warehouse,
+// registering it when needed, and returns the change.
+func (b *BulkImportRepository) SetOpeningBalance(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "BulkImportRepository.SetOpeningBalance")
+	defer func() { tracing.End(span, err) }()
+
+	var availableStock int
+	err = tx.GetContext(ctx, &availableStock, "SELECT available_stock FROM product_warehouses WHERE product_id=? and warehouse_id=? FOR UPDATE", productId, warehouseId)
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx, "INSERT INTO product_warehouses (product_id,warehouse_id,available_stock) VALUES (?,?,?)", productId, warehouseId, quantity)
+	} else if err == nil {
+		_, err = tx.ExecContext(ctx, "UPDATE product_warehouses SET available_stock=? WHERE product_id=? and warehouse_id=?", quantity, productId, warehouseId)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	delta := quantity - availableStock
+	if delta == 0 {
+		return 0, nil
+	}
+	return delta, insertMovement(ctx, tx, productId, warehouseId, delta)
+}
+
+func insertMovement(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, availableDelta int) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO stock_movements (product_id,warehouse_id,available_delta,reserved_delta,reason,created_at) VALUES (?,?,?,0,?,NOW(6))", productId, warehouseId, availableDelta, entity.StockMovementOpeningBalance)
+	return err
+}
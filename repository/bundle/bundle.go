@@ -0,0 +1,86 @@
+package bundle
+
+import (
+	"context"
+	"warehouse-service/entity"
+	"warehouse-service/models/bundle"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type BundleRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewBundleRepository(mysql *sqlx.DB) *BundleRepository {
+	return &BundleRepository{
+		mysql: mysql,
+	}
+}
+
+func (b *BundleRepository) DeleteComponents(ctx context.Context, tx *sqlx.Tx, bundleProductId int) (err error) {
+	ctx, span := tracing.Start(ctx, "BundleRepository.DeleteComponents")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM bundle_components WHERE bundle_product_id=?", bundleProductId)
+	return err
+}
+
+func (b *BundleRepository) InsertComponent(ctx context.Context, tx *sqlx.Tx, component *bundle.BundleComponent) (err error) {
+	ctx, span := tracing.Start(ctx, "BundleRepository.InsertComponent")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO bundle_components (bundle_product_id,product_id,quantity) VALUES (?,?,?)", component.BundleProductId, component.ProductId, component.Quantity)
+	return err
+}
+
+func (b *BundleRepository) GetComponentsByBundleIds(ctx context.Context, bundleProductIds []int) (_ []bundle.BundleComponent, err error) {
+	ctx, span := tracing.Start(ctx, "BundleRepository.GetComponentsByBundleIds")
+	defer func() { tracing.End(span, err) }()
+
+	query, args, err := sqlx.In(`
+		SELECT bundle_product_id, product_id, quantity
+		FROM bundle_components
+		WHERE bundle_product_id IN (?)
+		ORDER BY bundle_product_id asc, product_id asc
+	`, bundleProductIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var components []bundle.BundleComponent
+	err = b.mysql.SelectContext(ctx, &components, b.mysql.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+func (b *BundleRepository) GetComponentStocks(ctx context.Context, productIds []int, shopId int) (_ []bundle.ComponentStock, err error) {
+	ctx, span := tracing.Start(ctx, "BundleRepository.GetComponentStocks")
+	defer func() { tracing.End(span, err) }()
+
+	query, args, err := sqlx.In(`
+		SELECT pw.product_id, pw.warehouse_id,
+			pw.available_stock + (
+				SELECT COALESCE(SUM(ss.stock), 0)
+				FROM stock_slots ss
+				WHERE ss.product_id = pw.product_id AND ss.warehouse_id = pw.warehouse_id
+			) AS available_stock
+		FROM product_warehouses pw
+		JOIN warehouses w ON pw.warehouse_id = w.id
+		WHERE pw.product_id IN (?) AND w.shop_id = ? AND w.status = ?
+		ORDER BY pw.warehouse_id asc
+	`, productIds, shopId, entity.WarehouseActive)
+	if err != nil {
+		return nil, err
+	}
+
+	var componentStocks []bundle.ComponentStock
+	err = b.mysql.SelectContext(ctx, &componentStocks, b.mysql.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return componentStocks, nil
+}
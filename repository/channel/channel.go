@@ -0,0 +1,79 @@
+package channel
+
+import (
+	"context"
+	"warehouse-service/models/channel"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ChannelRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewChannelRepository(mysql *sqlx.DB) *ChannelRepository {
+	return &ChannelRepository{
+		mysql: mysql,
+	}
+}
+
+func (c *ChannelRepository) Upsert(ctx context.Context, allocation *channel.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ChannelRepository.Upsert")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = c.mysql.ExecContext(ctx, "INSERT INTO channel_allocations (shop_id,product_id,channel,allocation_type,value,allow_shared_fallback) VALUES (?,?,?,?,?,?) ON DUPLICATE KEY UPDATE allocation_type=VALUES(allocation_type), value=VALUES(value), allow_shared_fallback=VALUES(allow_shared_fallback)", allocation.ShopId, allocation.ProductId, allocation.Channel, allocation.AllocationType, allocation.Value, allocation.AllowSharedFallback)
+	return err
+}
+
+func (c *ChannelRepository) Delete(ctx context.Context, shopId int, productId int, channelName string) (err error) {
+	ctx, span := tracing.Start(ctx, "ChannelRepository.Delete")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = c.mysql.ExecContext(ctx, "DELETE FROM channel_allocations WHERE shop_id=? and product_id=? and channel=?", shopId, productId, channelName)
+	return err
+}
+
+func (c *ChannelRepository) GetByProductIds(ctx context.Context, productIds []int) (_ []channel.ChannelAllocation, err error) {
+	ctx, span := tracing.Start(ctx, "ChannelRepository.GetByProductIds")
+	defer func() { tracing.End(span, err) }()
+
+	query, args, err := sqlx.In(`
+		SELECT id, shop_id, product_id, channel, allocation_type, value, allow_shared_fallback
+		FROM channel_allocations
+		WHERE product_id IN (?)
+		ORDER BY shop_id asc, product_id asc, channel asc
+	`, productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var allocations []channel.ChannelAllocation
+	err = c.mysql.SelectContext(ctx, &allocations, c.mysql.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+func (c *ChannelRepository) GetReservedByProductIds(ctx context.Context, productIds []int) (_ []channel.ChannelReservation, err error) {
+	ctx, span := tracing.Start(ctx, "ChannelRepository.GetReservedByProductIds")
+	defer func() { tracing.End(span, err) }()
+
+	query, args, err := sqlx.In(`
+		SELECT shop_id, product_id, channel, COALESCE(SUM(reserved_stock), 0) AS reserved_stock
+		FROM order_warehouses
+		WHERE product_id IN (?) AND channel <> ''
+		GROUP BY shop_id, product_id, channel
+	`, productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var reservations []channel.ChannelReservation
+	err = c.mysql.SelectContext(ctx, &reservations, c.mysql.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
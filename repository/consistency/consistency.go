@@ -0,0 +1,108 @@
+package consistency
+
+import (
+	"context"
+	"warehouse-service/entity"
+	"warehouse-service/models/consistency"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ConsistencyRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewConsistencyRepository(mysql *sqlx.DB) *ConsistencyRepository {
+	return &ConsistencyRepository{
+		mysql: mysql,
+	}
+}
+
+const reservationStateQuery = `
+	SELECT pw.product_id, pw.warehouse_id, pw.available_stock, pw.reserved_stock,
+		(SELECT COALESCE(SUM(ow.reserved_stock), 0) FROM order_warehouses ow WHERE ow.product_id = pw.product_id AND ow.warehouse_id = pw.warehouse_id) AS order_reserved_stock,
+		(SELECT COALESCE(SUM(ss.stock), 0) FROM stock_slots ss WHERE ss.product_id = pw.product_id AND ss.warehouse_id = pw.warehouse_id) AS slot_stock
+	FROM product_warehouses pw
+	ORDER BY pw.product_id, pw.warehouse_id
+`
+
+// GetReservationStates returns every product-warehouse with the reservations
+// its reserved_stock should add up to.
+func (c *ConsistencyRepository) GetReservationStates(ctx context.Context) (_ []consistency.ReservationState, err error) {
+	ctx, span := tracing.Start(ctx, "ConsistencyRepository.GetReservationStates")
+	defer func() { tracing.End(span, err) }()
+
+	reservationStates := []consistency.ReservationState{}
+	err = c.mysql.SelectContext(ctx, &reservationStates, reservationStateQuery)
+	return reservationStates, err
+}
+
+// GetReservationStateForUpdate locks the product-warehouse first and sums the
+// reservations with locking reads afterwards, so the sums see every writer
+// that held the row before us.
+func (c *ConsistencyRepository) GetReservationStateForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (_ *consistency.ReservationState, err error) {
+	ctx, span := tracing.Start(ctx, "ConsistencyRepository.GetReservationStateForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	reservationState := consistency.ReservationState{}
+	err = tx.GetContext(ctx, &reservationState, "SELECT product_id, warehouse_id, available_stock, reserved_stock FROM product_warehouses WHERE product_id=? AND warehouse_id=? FOR UPDATE", productId, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.GetContext(ctx, &reservationState.OrderReservedStock, "SELECT COALESCE(SUM(reserved_stock), 0) FROM order_warehouses WHERE product_id=? AND warehouse_id=? LOCK IN SHARE MODE", productId, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.GetContext(ctx, &reservationState.SlotStock, "SELECT COALESCE(SUM(stock), 0) FROM stock_slots WHERE product_id=? AND warehouse_id=? LOCK IN SHARE MODE", productId, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	return &reservationState, nil
+}
+
+func (c *ConsistencyRepository) GetNegativeOrderReservations(ctx context.Context) (_ []consistency.OrderReservation, err error) {
+	ctx, span := tracing.Start(ctx, "ConsistencyRepository.GetNegativeOrderReservations")
+	defer func() { tracing.End(span, err) }()
+
+	orderReservations := []consistency.OrderReservation{}
+	err = c.mysql.SelectContext(ctx, &orderReservations, `
+		SELECT ow.id, ow.order_id, ow.product_id, ow.warehouse_id, ow.reserved_stock, w.status
+		FROM order_warehouses ow
+		JOIN warehouses w ON ow.warehouse_id = w.id
+		WHERE ow.reserved_stock < 0
+		ORDER BY ow.id
+	`)
+	return orderReservations, err
+}
+
+// GetOrphanedReservations returns the reservations still held by warehouses
+// that are no longer active, which can never be picked.
+func (c *ConsistencyRepository) GetOrphanedReservations(ctx context.Context) (_ []consistency.OrderReservation, err error) {
+	ctx, span := tracing.Start(ctx, "ConsistencyRepository.GetOrphanedReservations")
+	defer func() { tracing.End(span, err) }()
+
+	orderReservations := []consistency.OrderReservation{}
+	err = c.mysql.SelectContext(ctx, &orderReservations, `
+		SELECT ow.id, ow.order_id, ow.product_id, ow.warehouse_id, ow.reserved_stock, w.status
+		FROM order_warehouses ow
+		JOIN warehouses w ON ow.warehouse_id = w.id
+		WHERE ow.reserved_stock > 0 AND w.status <> ?
+		ORDER BY ow.id
+	`, entity.WarehouseActive)
+	return orderReservations, err
+}
+
+// CorrectStock adjusts the stock columns of a product-warehouse and records
+// the correction in the stock ledger.
+func (c *ConsistencyRepository) CorrectStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, availableDelta int, reservedDelta int) (err error) {
+	ctx, span := tracing.Start(ctx, "ConsistencyRepository.CorrectStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_warehouses SET available_stock = available_stock + ?, reserved_stock = reserved_stock + ? WHERE product_id=? and warehouse_id=?", availableDelta, reservedDelta, productId, warehouseId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO stock_movements (product_id,warehouse_id,available_delta,reserved_delta,reason,created_at) VALUES (?,?,?,?,?,NOW(6))", productId, warehouseId, availableDelta, reservedDelta, entity.StockMovementRepair)
+	return err
+}
@@ -0,0 +1,100 @@
+package export
+
+import (
+	"context"
+	"warehouse-service/models/export"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ExportRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewExportRepository(mysql *sqlx.DB) *ExportRepository {
+	return &ExportRepository{
+		mysql: mysql,
+	}
+}
+
+// The Get functions return the next page of at most limit rows with an id
+// greater than cursor, in id order.
+
+func (e *ExportRepository) GetStockLevels(ctx context.Context, filter export.Filter, cursor int, limit int) (_ []export.StockLevel, err error) {
+	ctx, span := tracing.Start(ctx, "ExportRepository.GetStockLevels")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT pw.id, w.shop_id, pw.product_id, pw.warehouse_id, pw.available_stock, pw.reserved_stock, pw.safety_stock
+		FROM product_warehouses pw
+		JOIN warehouses w ON pw.warehouse_id = w.id
+		WHERE pw.id > ?`
+	query, args := applyFilter(query, []interface{}{cursor}, "pw", filter)
+
+	stockLevels := []export.StockLevel{}
+	err = e.mysql.SelectContext(ctx, &stockLevels, query+" ORDER BY pw.id LIMIT ?", append(args, limit)...)
+	return stockLevels, err
+}
+
+func (e *ExportRepository) GetMovements(ctx context.Context, filter export.Filter, cursor int, limit int) (_ []export.Movement, err error) {
+	ctx, span := tracing.Start(ctx, "ExportRepository.GetMovements")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT sm.id, w.shop_id, sm.product_id, sm.warehouse_id, sm.available_delta, sm.reserved_delta, sm.reason, sm.created_at
+		FROM stock_movements sm
+		JOIN warehouses w ON sm.warehouse_id = w.id
+		WHERE sm.id > ?`
+	query, args := applyFilter(query, []interface{}{cursor}, "sm", filter)
+	query, args = applyPeriod(query, args, "sm", filter)
+
+	movements := []export.Movement{}
+	err = e.mysql.SelectContext(ctx, &movements, query+" ORDER BY sm.id LIMIT ?", append(args, limit)...)
+	return movements, err
+}
+
+func (e *ExportRepository) GetReservations(ctx context.Context, filter export.Filter, cursor int, limit int) (_ []export.Reservation, err error) {
+	ctx, span := tracing.Start(ctx, "ExportRepository.GetReservations")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT ow.id, w.shop_id, ow.order_id, ow.product_id, ow.warehouse_id, ow.reserved_stock, ow.created_at
+		FROM order_warehouses ow
+		JOIN warehouses w ON ow.warehouse_id = w.id
+		WHERE ow.id > ?`
+	query, args := applyFilter(query, []interface{}{cursor}, "ow", filter)
+	query, args = applyPeriod(query, args, "ow", filter)
+
+	reservations := []export.Reservation{}
+	err = e.mysql.SelectContext(ctx, &reservations, query+" ORDER BY ow.id LIMIT ?", append(args, limit)...)
+	return reservations, err
+}
+
+func applyFilter(query string, args []interface{}, table string, filter export.Filter) (string, []interface{}) {
+	if filter.ShopId != 0 {
+		query += " AND w.shop_id = ?"
+		args = append(args, filter.ShopId)
+	}
+	if filter.WarehouseId != 0 {
+		query += " AND " + table + ".warehouse_id = ?"
+		args = append(args, filter.WarehouseId)
+	}
+	if filter.ProductId != 0 {
+		query += " AND " + table + ".product_id = ?"
+		args = append(args, filter.ProductId)
+	}
+	return query, args
+}
+
+func applyPeriod(query string, args []interface{}, table string, filter export.Filter) (string, []interface{}) {
+	if filter.From != nil {
+		query += " AND " + table + ".created_at >= ?"
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		query += " AND " + table + ".created_at < ?"
+		args = append(args, *filter.To)
+	}
+	return query, args
+}
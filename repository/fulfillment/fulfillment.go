@@ -0,0 +1,151 @@
+package fulfillment
+
+import (
+	"context"
+	"warehouse-service/entity"
+	"warehouse-service/models/fulfillment"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type FulfillmentRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewFulfillmentRepository(mysql *sqlx.DB) *FulfillmentRepository {
+	return &FulfillmentRepository{
+		mysql: mysql,
+	}
+}
+
+func (f *FulfillmentRepository) InsertPickList(ctx context.Context, tx *sqlx.Tx, pickList *fulfillment.PickList) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.InsertPickList")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO pick_lists (order_id,warehouse_id,status) VALUES (?,?,?)", pickList.OrderId, pickList.WarehouseId, pickList.Status)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (f *FulfillmentRepository) InsertPickListItem(ctx context.Context, tx *sqlx.Tx, item *fulfillment.PickListItem) (err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.InsertPickListItem")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO pick_list_items (pick_list_id,product_id,quantity,picked_quantity) VALUES (?,?,?,?)", item.PickListId, item.ProductId, item.Quantity, item.PickedQuantity)
+	return err
+}
+
+func (f *FulfillmentRepository) GetPickListById(ctx context.Context, id int) (_ *fulfillment.PickList, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.GetPickListById")
+	defer func() { tracing.End(span, err) }()
+
+	data := fulfillment.PickList{}
+	err = f.mysql.GetContext(ctx, &data, "SELECT id,order_id,warehouse_id,status FROM pick_lists WHERE id=?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.mysql.SelectContext(ctx, &data.Items, "SELECT id,pick_list_id,product_id,quantity,picked_quantity FROM pick_list_items WHERE pick_list_id=? ORDER BY id asc", id)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (f *FulfillmentRepository) GetPickListsByOrderId(ctx context.Context, orderId int) (_ []fulfillment.PickList, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.GetPickListsByOrderId")
+	defer func() { tracing.End(span, err) }()
+
+	var pickLists []fulfillment.PickList
+	err = f.mysql.SelectContext(ctx, &pickLists, "SELECT id,order_id,warehouse_id,status FROM pick_lists WHERE order_id=? ORDER BY id asc", orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pickLists {
+		err = f.mysql.SelectContext(ctx, &pickLists[i].Items, "SELECT id,pick_list_id,product_id,quantity,picked_quantity FROM pick_list_items WHERE pick_list_id=? ORDER BY id asc", pickLists[i].Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pickLists, nil
+}
+
+// GetPendingPickItems returns the quantity already covered by pick lists that
+// are not shipped or cancelled. Once a list is picked only the picked quantity
+// is still pending, because short picks are re-allocated.
+func (f *FulfillmentRepository) GetPendingPickItems(ctx context.Context, tx *sqlx.Tx, orderId int) (_ []fulfillment.PendingPickItem, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.GetPendingPickItems")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT pl.warehouse_id, i.product_id,
+			SUM(CASE WHEN pl.status = ? THEN i.quantity ELSE i.picked_quantity END) AS quantity
+		FROM pick_lists pl
+		JOIN pick_list_items i ON i.pick_list_id = pl.id
+		WHERE pl.order_id = ? AND pl.status IN (?, ?, ?)
+		GROUP BY pl.warehouse_id, i.product_id
+	`
+
+	var pendingPickItems []fulfillment.PendingPickItem
+	err = tx.SelectContext(ctx, &pendingPickItems, query, entity.PickListOpen, orderId, entity.PickListOpen, entity.PickListPicked, entity.PickListPacked)
+	if err != nil {
+		return nil, err
+	}
+	return pendingPickItems, nil
+}
+
+func (f *FulfillmentRepository) UpdatePickListStatus(ctx context.Context, tx *sqlx.Tx, id int, fromStatus string, toStatus string) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.UpdatePickListStatus")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := tx.ExecContext(ctx, "UPDATE pick_lists SET status=? WHERE id=? and status=?", toStatus, id, fromStatus)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (f *FulfillmentRepository) UpdatePickedQuantity(ctx context.Context, tx *sqlx.Tx, itemId int, pickedQuantity int) (err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.UpdatePickedQuantity")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE pick_list_items SET picked_quantity=? WHERE id=?", pickedQuantity, itemId)
+	return err
+}
+
+func (f *FulfillmentRepository) CancelPickLists(ctx context.Context, tx *sqlx.Tx, orderId int) (err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.CancelPickLists")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE pick_lists SET status=? WHERE order_id=? AND status IN (?, ?, ?)", entity.PickListCancelled, orderId, entity.PickListOpen, entity.PickListPicked, entity.PickListPacked)
+	return err
+}
+
+func (f *FulfillmentRepository) InsertShipment(ctx context.Context, tx *sqlx.Tx, shipment *fulfillment.Shipment) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.InsertShipment")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO shipments (pick_list_id,order_id,warehouse_id) VALUES (?,?,?)", shipment.PickListId, shipment.OrderId, shipment.WarehouseId)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (f *FulfillmentRepository) SubstractOrderWarehouseReservedStock(ctx context.Context, tx *sqlx.Tx, id int, substractedReservedStock int) (err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentRepository.SubstractOrderWarehouseReservedStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE order_warehouses SET reserved_stock = reserved_stock - ? WHERE id=?", substractedReservedStock, id)
+	return err
+}
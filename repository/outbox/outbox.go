@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"context"
+	"time"
+	"warehouse-service/models/outbox"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OutboxRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewOutboxRepository(mysql *sqlx.DB) *OutboxRepository {
+	return &OutboxRepository{
+		mysql: mysql,
+	}
+}
+
+func (o *OutboxRepository) Insert(ctx context.Context, tx *sqlx.Tx, event *outbox.Event) (err error) {
+	ctx, span := tracing.Start(ctx, "OutboxRepository.Insert")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox_events (message_id,event_type,payload,trace_context,attempts,next_attempt_at,created_at) VALUES (?,?,?,?,0,NOW(),NOW())", event.MessageId, event.EventType, event.Payload, event.TraceContext)
+	return err
+}
+
+// GetPendingForUpdate locks the oldest events due for publishing. Rows locked
+// by another relay are skipped, so replicas publish different events.
+func (o *OutboxRepository) GetPendingForUpdate(ctx context.Context, tx *sqlx.Tx, limit int) (_ []outbox.Event, err error) {
+	ctx, span := tracing.Start(ctx, "OutboxRepository.GetPendingForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT id, message_id, event_type, payload, trace_context, attempts, created_at
+		FROM outbox_events
+		WHERE published_at IS NULL AND next_attempt_at <= NOW()
+		ORDER BY id asc
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED
+	`
+
+	var events []outbox.Event
+	err = tx.SelectContext(ctx, &events, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (o *OutboxRepository) MarkPublished(ctx context.Context, tx *sqlx.Tx, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "OutboxRepository.MarkPublished")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE outbox_events SET published_at=NOW(), attempts=attempts+1 WHERE id=?", id)
+	return err
+}
+
+// MarkFailed records the failed attempt and holds the event back for retryIn.
+func (o *OutboxRepository) MarkFailed(ctx context.Context, tx *sqlx.Tx, id int, lastError string, retryIn time.Duration) (err error) {
+	ctx, span := tracing.Start(ctx, "OutboxRepository.MarkFailed")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE outbox_events SET attempts=attempts+1, last_error=?, next_attempt_at=DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE id=?", lastError, int(retryIn.Seconds()), id)
+	return err
+}
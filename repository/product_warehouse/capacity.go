@@ -0,0 +1,61 @@
+package product_warehouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (p *ProductWarehouseRepository) UpsertDimension(ctx context.Context, dimension *product_warehouse.DimensionRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.UpsertDimension")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = p.mysql.ExecContext(ctx, "INSERT INTO product_dimensions (product_id,length,width,height) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE length=VALUES(length), width=VALUES(width), height=VALUES(height)", dimension.ProductId, dimension.Length, dimension.Width, dimension.Height)
+	return err
+}
+
+func (p *ProductWarehouseRepository) GetUnitVolume(ctx context.Context, productId int) (_ float64, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetUnitVolume")
+	defer func() { tracing.End(span, err) }()
+
+	var unitVolume float64
+	err = p.mysql.GetContext(ctx, &unitVolume, "SELECT length * width * height FROM product_dimensions WHERE product_id=?", productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return unitVolume, err
+}
+
+// LockWarehouse locks the warehouse row until tx ends.
+func (p *ProductWarehouseRepository) LockWarehouse(ctx context.Context, tx *sqlx.Tx, warehouseId int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.LockWarehouse")
+	defer func() { tracing.End(span, err) }()
+
+	var id int
+	return tx.GetContext(ctx, &id, "SELECT id FROM warehouses WHERE id=? FOR UPDATE", warehouseId)
+}
+
+func (p *ProductWarehouseRepository) GetWarehouseUtilization(ctx context.Context, tx *sqlx.Tx, warehouseId int) (_ *warehouse.Utilization, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetWarehouseUtilization")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT w.id AS warehouse_id, w.shop_id, w.name, w.capacity_units, w.capacity_volume,
+			COALESCE(SUM(pw.available_stock + pw.reserved_stock), 0) AS used_units,
+			COALESCE(SUM((pw.available_stock + pw.reserved_stock) * COALESCE(pd.length * pd.width * pd.height, 0)), 0) AS used_volume
+		FROM warehouses w
+		LEFT JOIN product_warehouses pw ON pw.warehouse_id = w.id
+		LEFT JOIN product_dimensions pd ON pd.product_id = pw.product_id
+		WHERE w.id = ?
+		GROUP BY w.id, w.shop_id, w.name, w.capacity_units, w.capacity_volume
+	`
+
+	data := warehouse.Utilization{}
+	err = tx.GetContext(ctx, &data, query, warehouseId)
+	return &data, err
+}
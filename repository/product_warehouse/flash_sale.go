@@ -0,0 +1,175 @@
+package product_warehouse
+
+import (
+	"context"
+	"database/sql"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (p *ProductWarehouseRepository) UpsertFlashSale(ctx context.Context, productId int, slots int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.UpsertFlashSale")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = p.mysql.ExecContext(ctx, "INSERT INTO flash_sale_products (product_id,slots) VALUES (?,?) ON DUPLICATE KEY UPDATE slots=VALUES(slots)", productId, slots)
+	return err
+}
+
+func (p *ProductWarehouseRepository) DeleteFlashSale(ctx context.Context, productId int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.DeleteFlashSale")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = p.mysql.ExecContext(ctx, "DELETE FROM flash_sale_products WHERE product_id=?", productId)
+	return err
+}
+
+func (p *ProductWarehouseRepository) GetFlashSaleProductIds(ctx context.Context) (_ []int, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetFlashSaleProductIds")
+	defer func() { tracing.End(span, err) }()
+
+	productIds := []int{}
+	err = p.mysql.SelectContext(ctx, &productIds, "SELECT product_id FROM flash_sale_products ORDER BY product_id")
+	return productIds, err
+}
+
+// GetFlashSaleSlots returns the slot count of every flash sale product among
+// productIds, products not on flash sale are left out of the map.
+func (p *ProductWarehouseRepository) GetFlashSaleSlots(ctx context.Context, productIds []int) (_ map[int]int, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetFlashSaleSlots")
+	defer func() { tracing.End(span, err) }()
+
+	flashSaleSlots := make(map[int]int)
+	if len(productIds) == 0 {
+		return flashSaleSlots, nil
+	}
+
+	query, args, err := sqlx.In("SELECT product_id, slots FROM flash_sale_products WHERE product_id IN (?)", productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.mysql.QueryxContext(ctx, p.mysql.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productId, slots int
+		if err := rows.Scan(&productId, &slots); err != nil {
+			return nil, err
+		}
+		flashSaleSlots[productId] = slots
+	}
+	return flashSaleSlots, rows.Err()
+}
+
+func (p *ProductWarehouseRepository) GetAllByProductIdForUpdate(ctx context.Context, tx *sqlx.Tx, productId int) (_ []product_warehouse.ProductWarehouse, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetAllByProductIdForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	productWarehouses := []product_warehouse.ProductWarehouse{}
+	err = tx.SelectContext(ctx, &productWarehouses, "SELECT id,product_id,warehouse_id,available_stock,reserved_stock,safety_stock FROM product_warehouses WHERE product_id=? ORDER BY warehouse_id FOR UPDATE", productId)
+	return productWarehouses, err
+}
+
+func (p *ProductWarehouseRepository) InsertStockSlot(ctx context.Context, tx *sqlx.Tx, stockSlot *product_warehouse.StockSlot) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.InsertStockSlot")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO stock_slots (product_id,warehouse_id,slot,stock) VALUES (?,?,?,?)", stockSlot.ProductId, stockSlot.WarehouseId, stockSlot.Slot, stockSlot.Stock)
+	return err
+}
+
+// TakeSlotStock reserves quantity from a single row of the given slot. Only
+// that row is locked, which is what keeps concurrent flash sale reservations
+// from queueing behind each other. It reports false when no row of the slot
+// holds the whole quantity.
+func (p *ProductWarehouseRepository) TakeSlotStock(ctx context.Context, tx *sqlx.Tx, productId int, slot int, quantity int) (_ int, _ bool, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.TakeSlotStock")
+	defer func() { tracing.End(span, err) }()
+
+	stockSlot := product_warehouse.StockSlot{}
+	err = tx.GetContext(ctx, &stockSlot, "SELECT id,product_id,warehouse_id,slot,stock FROM stock_slots WHERE product_id=? AND slot=? AND stock>=? ORDER BY warehouse_id LIMIT 1 FOR UPDATE", productId, slot, quantity)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE stock_slots SET stock = stock - ? WHERE id=?", quantity, stockSlot.Id)
+	if err != nil {
+		return 0, false, err
+	}
+	return stockSlot.WarehouseId, true, nil
+}
+
+func (p *ProductWarehouseRepository) GetStockSlotsForUpdate(ctx context.Context, tx *sqlx.Tx, productId int) (_ []product_warehouse.StockSlot, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetStockSlotsForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	stockSlots := []product_warehouse.StockSlot{}
+	err = tx.SelectContext(ctx, &stockSlots, "SELECT id,product_id,warehouse_id,slot,stock FROM stock_slots WHERE product_id=? ORDER BY slot, warehouse_id FOR UPDATE", productId)
+	return stockSlots, err
+}
+
+func (p *ProductWarehouseRepository) SubstractSlotStock(ctx context.Context, tx *sqlx.Tx, id int, substractedStock int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.SubstractSlotStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE stock_slots SET stock = stock - ? WHERE id=?", substractedStock, id)
+	return err
+}
+
+func (p *ProductWarehouseRepository) DeleteStockSlots(ctx context.Context, tx *sqlx.Tx, productId int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.DeleteStockSlots")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM stock_slots WHERE product_id=?", productId)
+	return err
+}
+
+// GetSlotStockBulk sums the stock still held in flash sale slots, which is
+// carved out of available_stock and would otherwise not be sold.
+func (p *ProductWarehouseRepository) GetSlotStockBulk(ctx context.Context, availableStockRequest []product_warehouse.ProductShop) (_ map[int]int, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetSlotStockBulk")
+	defer func() { tracing.End(span, err) }()
+
+	productIds := []int{}
+	shopIds := []int{}
+	for _, productShopMap := range availableStockRequest {
+		productIds = append(productIds, productShopMap.ProductId)
+		shopIds = append(shopIds, productShopMap.ShopId)
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT ss.product_id, COALESCE(SUM(ss.stock), 0) AS total_stock
+		FROM stock_slots ss
+		JOIN warehouses w ON ss.warehouse_id = w.id
+		WHERE ss.product_id IN (?) AND w.shop_id IN (?) AND w.status = ?
+		GROUP BY ss.product_id
+	`, productIds, shopIds, entity.WarehouseActive)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.mysql.QueryxContext(ctx, p.mysql.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slotStocks := make(map[int]int)
+	for rows.Next() {
+		var productId, totalStock int
+		if err := rows.Scan(&productId, &totalStock); err != nil {
+			return nil, err
+		}
+		slotStocks[productId] = totalStock
+	}
+	return slotStocks, rows.Err()
+}
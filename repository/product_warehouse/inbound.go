@@ -0,0 +1,94 @@
+package product_warehouse
+
+import (
+	"context"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (p *ProductWarehouseRepository) InsertInbound(ctx context.Context, tx *sqlx.Tx, inbound *product_warehouse.InboundStock) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.InsertInbound")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO inbound_stocks (product_id,warehouse_id,from_warehouse_id,quantity,unit_cost,source,expected_at,status) VALUES (?,?,?,?,?,?,?,?)", inbound.ProductId, inbound.WarehouseId, inbound.FromWarehouseId, inbound.Quantity, inbound.UnitCost, inbound.Source, inbound.ExpectedAt, entity.InboundPending)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (p *ProductWarehouseRepository) GetInboundById(ctx context.Context, id int) (_ *product_warehouse.InboundStock, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetInboundById")
+	defer func() { tracing.End(span, err) }()
+
+	data := product_warehouse.InboundStock{}
+	err = p.mysql.GetContext(ctx, &data, "SELECT id,product_id,warehouse_id,from_warehouse_id,quantity,unit_cost,source,expected_at,status FROM inbound_stocks WHERE id=?", id)
+	return &data, err
+}
+
+func (p *ProductWarehouseRepository) UpdateInboundStatus(ctx context.Context, tx *sqlx.Tx, id int, fromStatus string, toStatus string) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.UpdateInboundStatus")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := tx.ExecContext(ctx, "UPDATE inbound_stocks SET status=? WHERE id=? and status=?", toStatus, id, fromStatus)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (p *ProductWarehouseRepository) GetPendingInbound(ctx context.Context, productId int, shopId int, until time.Time) (_ []product_warehouse.InboundStock, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetPendingInbound")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT i.id, i.product_id, i.warehouse_id, i.from_warehouse_id, i.quantity, i.source, i.expected_at, i.status
+		FROM inbound_stocks i
+		JOIN warehouses w ON i.warehouse_id = w.id
+		WHERE i.product_id = ? AND w.shop_id = ? AND w.status = ? AND i.status = ? AND i.expected_at < ?
+		ORDER BY i.expected_at asc
+	`
+
+	var inboundStocks []product_warehouse.InboundStock
+	err = p.mysql.SelectContext(ctx, &inboundStocks, query, productId, shopId, entity.WarehouseActive, entity.InboundPending, until)
+	if err != nil {
+		return nil, err
+	}
+	return inboundStocks, nil
+}
+
+func (p *ProductWarehouseRepository) GetStockSummary(ctx context.Context, productId int, shopId int) (_ *product_warehouse.StockSummary, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetStockSummary")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT COALESCE(SUM(pw.available_stock), 0) AS available_stock,
+			COALESCE(SUM(pw.reserved_stock), 0) AS reserved_stock,
+			COALESCE(SUM(pw.safety_stock), 0) AS safety_stock,
+			(
+				SELECT COALESCE(SUM(ss.stock), 0)
+				FROM stock_slots ss
+				JOIN warehouses sw ON ss.warehouse_id = sw.id
+				WHERE ss.product_id = ? AND sw.shop_id = ? AND sw.status = ?
+			) AS slot_stock
+		FROM product_warehouses pw
+		JOIN warehouses w ON pw.warehouse_id = w.id
+		WHERE pw.product_id = ? AND w.shop_id = ? AND w.status = ?
+	`
+
+	data := product_warehouse.StockSummary{}
+	err = p.mysql.GetContext(ctx, &data, query, productId, shopId, entity.WarehouseActive, productId, shopId, entity.WarehouseActive)
+	return &data, err
+}
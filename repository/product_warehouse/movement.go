@@ -0,0 +1,16 @@
+package product_warehouse
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// insertMovement appends a change of the stock columns of a
+// product_warehouses row to the stock ledger. Every statement that updates
+// available_stock or reserved_stock writes one in the same transaction, which
+// is what lets past stock be reconstructed from a snapshot.
+func (p *ProductWarehouseRepository) insertMovement(ctx context.Context, execer sqlx.ExecerContext, productId int, warehouseId int, availableDelta int, reservedDelta int) error {
+	_, err := execer.ExecContext(ctx, "INSERT INTO stock_movements (product_id,warehouse_id,available_delta,reserved_delta,created_at) VALUES (?,?,?,?,NOW(6))", productId, warehouseId, availableDelta, reservedDelta)
+	return err
+}
@@ -1,8 +1,10 @@
 package product_warehouse
 
 import (
+	"context"
 	"warehouse-service/entity"
 	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -17,43 +19,93 @@ func NewProductWarehouseRepository(mysql *sqlx.DB) *ProductWarehouseRepository {
 	}
 }
 
-func (p *ProductWarehouseRepository) Insert(productWarehouse *product_warehouse.RegisterRequest) error {
-	_, err := p.mysql.Exec("INSERT INTO product_warehouses (product_id,warehouse_id,available_stock) VALUES (?,?,?)", productWarehouse.ProductId, productWarehouse.WarehouseId, productWarehouse.AvailableStock)
-	return err
+func (p *ProductWarehouseRepository) Insert(ctx context.Context, tx *sqlx.Tx, productWarehouse *product_warehouse.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.Insert")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO product_warehouses (product_id,warehouse_id,available_stock,safety_stock) VALUES (?,?,?,?)", productWarehouse.ProductId, productWarehouse.WarehouseId, productWarehouse.AvailableStock, productWarehouse.SafetyStock)
+	if err != nil {
+		return err
+	}
+	return p.insertMovement(ctx, tx, productWarehouse.ProductId, productWarehouse.WarehouseId, productWarehouse.AvailableStock, 0)
 }
 
-func (p *ProductWarehouseRepository) AddAvailableStock(tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int) error {
-	_, err := tx.Exec("UPDATE product_warehouses SET available_stock = available_stock + ? WHERE product_id=? and warehouse_id=?", addedAvailableStock, productId, warehouseId)
+func (p *ProductWarehouseRepository) UpdateSafetyStock(ctx context.Context, productId int, warehouseId int, safetyStock int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.UpdateSafetyStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = p.mysql.ExecContext(ctx, "UPDATE product_warehouses SET safety_stock=? WHERE product_id=? and warehouse_id=?", safetyStock, productId, warehouseId)
 	return err
 }
 
-func (p *ProductWarehouseRepository) SubstractAvailableStock(tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int) error {
-	_, err := tx.Exec("UPDATE product_warehouses SET available_stock = available_stock - ? WHERE product_id=? and warehouse_id=?", substractedAvailableStock, productId, warehouseId)
-	return err
+func (p *ProductWarehouseRepository) AddAvailableStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.AddAvailableStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_warehouses SET available_stock = available_stock + ? WHERE product_id=? and warehouse_id=?", addedAvailableStock, productId, warehouseId)
+	if err != nil {
+		return err
+	}
+	return p.insertMovement(ctx, tx, productId, warehouseId, addedAvailableStock, 0)
 }
 
-func (p *ProductWarehouseRepository) AddAvailableStockSubsReservedStock(tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int, substractedReservedStock int) error {
-	_, err := tx.Exec("UPDATE product_warehouses SET available_stock = available_stock + ?, reserved_stock = reserved_stock - ? WHERE product_id=? and warehouse_id=?", addedAvailableStock, substractedReservedStock, productId, warehouseId)
-	return err
+func (p *ProductWarehouseRepository) SubstractAvailableStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.SubstractAvailableStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_warehouses SET available_stock = available_stock - ? WHERE product_id=? and warehouse_id=?", substractedAvailableStock, productId, warehouseId)
+	if err != nil {
+		return err
+	}
+	return p.insertMovement(ctx, tx, productId, warehouseId, -substractedAvailableStock, 0)
 }
 
-func (p *ProductWarehouseRepository) SubsAvailableStockAddReservedStock(tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int, addedReservedStock int) error {
-	_, err := tx.Exec("UPDATE product_warehouses SET available_stock = available_stock - ?, reserved_stock = reserved_stock + ? WHERE product_id=? and warehouse_id=?", substractedAvailableStock, addedReservedStock, productId, warehouseId)
-	return err
+func (p *ProductWarehouseRepository) AddAvailableStockSubsReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int, substractedReservedStock int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.AddAvailableStockSubsReservedStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_warehouses SET available_stock = available_stock + ?, reserved_stock = reserved_stock - ? WHERE product_id=? and warehouse_id=?", addedAvailableStock, substractedReservedStock, productId, warehouseId)
+	if err != nil {
+		return err
+	}
+	return p.insertMovement(ctx, tx, productId, warehouseId, addedAvailableStock, -substractedReservedStock)
 }
 
-func (p *ProductWarehouseRepository) SubstractReservedStock(tx *sqlx.Tx, productId int, warehouseId int, substractedReservedStock int) error {
-	_, err := p.mysql.Exec("UPDATE product_warehouses SET reserved_stock = reserved_stock - ? WHERE product_id=? and warehouse_id=?", substractedReservedStock, productId, warehouseId)
-	return err
+func (p *ProductWarehouseRepository) SubsAvailableStockAddReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int, addedReservedStock int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.SubsAvailableStockAddReservedStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_warehouses SET available_stock = available_stock - ?, reserved_stock = reserved_stock + ? WHERE product_id=? and warehouse_id=?", substractedAvailableStock, addedReservedStock, productId, warehouseId)
+	if err != nil {
+		return err
+	}
+	return p.insertMovement(ctx, tx, productId, warehouseId, -substractedAvailableStock, addedReservedStock)
+}
+
+func (p *ProductWarehouseRepository) SubstractReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedReservedStock int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.SubstractReservedStock")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_warehouses SET reserved_stock = reserved_stock - ? WHERE product_id=? and warehouse_id=?", substractedReservedStock, productId, warehouseId)
+	if err != nil {
+		return err
+	}
+	return p.insertMovement(ctx, tx, productId, warehouseId, 0, -substractedReservedStock)
 }
 
-func (p *ProductWarehouseRepository) GetByProductAndWarehouseId(productId int, wareHouseId int) (*product_warehouse.ProductWarehouse, error) {
+func (p *ProductWarehouseRepository) GetByProductAndWarehouseIdForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, wareHouseId int) (_ *product_warehouse.ProductWarehouse, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetByProductAndWarehouseIdForUpdate")
+	defer func() { tracing.End(span, err) }()
+
 	data := product_warehouse.ProductWarehouse{}
-	err := p.mysql.Get(&data, "SELECT id,product_id,warehouse_id,available_stock,reserved_stock FROM product_warehouses WHERE product_id=? and warehouse_id=?", productId, wareHouseId)
+	err = tx.GetContext(ctx, &data, "SELECT id,product_id,warehouse_id,available_stock,reserved_stock,safety_stock FROM product_warehouses WHERE product_id=? and warehouse_id=? FOR UPDATE", productId, wareHouseId)
 	return &data, err
 }
 
-func (p *ProductWarehouseRepository) GetAvailableStockBulk(availableStockRequest []product_warehouse.ProductShop) (map[int]int, error) {
+func (p *ProductWarehouseRepository) GetAvailableStockBulk(ctx context.Context, availableStockRequest []product_warehouse.ProductShop) (_ map[int]int, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetAvailableStockBulk")
+	defer func() { tracing.End(span, err) }()
+
 	productIds := []int{}
 	shopIds := []int{}
 	for _, productShopMap := range availableStockRequest {
@@ -73,7 +125,7 @@ func (p *ProductWarehouseRepository) GetAvailableStockBulk(availableStockRequest
 	}
 
 	query = p.mysql.Rebind(query)
-	rows, err := p.mysql.Queryx(query, args...)
+	rows, err := p.mysql.QueryxContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -91,9 +143,12 @@ func (p *ProductWarehouseRepository) GetAvailableStockBulk(availableStockRequest
 	return stockMap, nil
 }
 
-func (p *ProductWarehouseRepository) GetAllByProductId(productId int) ([]product_warehouse.ProductWarehouse, error) {
+func (p *ProductWarehouseRepository) GetAllByProductId(ctx context.Context, productId int) (_ []product_warehouse.ProductWarehouse, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetAllByProductId")
+	defer func() { tracing.End(span, err) }()
+
 	query := `
-		SELECT pw.id, pw.product_id, pw.warehouse_id, pw.available_stock, pw.reserved_stock
+		SELECT pw.id, pw.product_id, pw.warehouse_id, pw.available_stock, pw.reserved_stock, pw.safety_stock
 		FROM product_warehouses pw
 		JOIN warehouses w ON pw.warehouse_id = w.id
 		WHERE pw.product_id = ? AND w.status = ?
@@ -101,14 +156,17 @@ func (p *ProductWarehouseRepository) GetAllByProductId(productId int) ([]product
 	`
 
 	var productWarehouses []product_warehouse.ProductWarehouse
-	err := p.mysql.Select(&productWarehouses, query, productId, entity.WarehouseActive)
+	err = p.mysql.SelectContext(ctx, &productWarehouses, query, productId, entity.WarehouseActive)
 	if err != nil {
 		return nil, err
 	}
 	return productWarehouses, nil
 }
 
-func (p *ProductWarehouseRepository) GetAvailableStock(productId int) (int, error) {
+func (p *ProductWarehouseRepository) GetAvailableStock(ctx context.Context, productId int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetAvailableStock")
+	defer func() { tracing.End(span, err) }()
+
 	query := `
 		SELECT COALESCE(SUM(pw.available_stock), 0)
 		FROM product_warehouses pw
@@ -117,30 +175,124 @@ func (p *ProductWarehouseRepository) GetAvailableStock(productId int) (int, erro
 	`
 
 	var availableStock int
-	err := p.mysql.Get(&availableStock, query, productId, entity.WarehouseActive)
+	err = p.mysql.GetContext(ctx, &availableStock, query, productId, entity.WarehouseActive)
 	if err != nil {
 		return 0, err
 	}
 	return availableStock, nil
 }
 
-func (p *ProductWarehouseRepository) InsertOrderWarehouse(tx *sqlx.Tx, orderWarehouse *product_warehouse.OrderWarehouse) error {
-	_, err := tx.Exec("INSERT INTO order_warehouses (order_id,product_id,warehouse_id,reserved_stock) VALUES (?,?,?,?)", orderWarehouse.OrderId, orderWarehouse.ProductId, orderWarehouse.WarehouseId, orderWarehouse.ReservedStock)
+func (p *ProductWarehouseRepository) InsertOrderWarehouse(ctx context.Context, tx *sqlx.Tx, orderWarehouse *product_warehouse.OrderWarehouse) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.InsertOrderWarehouse")
+	defer func() { tracing.End(span, err) }()
+
+	// ordered_stock keeps the quantity reserved at insert, reserved_stock runs
+	// down as the order is shipped or released
+	_, err = tx.ExecContext(ctx, "INSERT INTO order_warehouses (order_id,product_id,warehouse_id,reserved_stock,ordered_stock,shop_id,channel) VALUES (?,?,?,?,?,?,?)", orderWarehouse.OrderId, orderWarehouse.ProductId, orderWarehouse.WarehouseId, orderWarehouse.ReservedStock, orderWarehouse.ReservedStock, orderWarehouse.ShopId, orderWarehouse.Channel)
 	return err
 }
 
-func (p *ProductWarehouseRepository) GetOrderWarehouseByOrderId(orderId int) ([]product_warehouse.OrderWarehouse, error) {
+// ClearOrderWarehouse zeroes the reservation row and returns what it still
+// held, so a reservation is only ever returned once.
+func (p *ProductWarehouseRepository) ClearOrderWarehouse(ctx context.Context, tx *sqlx.Tx, id int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.ClearOrderWarehouse")
+	defer func() { tracing.End(span, err) }()
+
+	var reservedStock int
+	err = tx.GetContext(ctx, &reservedStock, "SELECT reserved_stock FROM order_warehouses WHERE id=? FOR UPDATE", id)
+	if err != nil {
+		return 0, err
+	}
+	if reservedStock <= 0 {
+		return 0, nil
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE order_warehouses SET reserved_stock=0 WHERE id=?", id)
+	if err != nil {
+		return 0, err
+	}
+	return reservedStock, nil
+}
+
+func (p *ProductWarehouseRepository) GetOrderWarehouseByOrderId(ctx context.Context, orderId int) (_ []product_warehouse.OrderWarehouse, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetOrderWarehouseByOrderId")
+	defer func() { tracing.End(span, err) }()
+
 	query := `
-		SELECT id, order_id, product_id, warehouse_id, reserved_stock
+		SELECT id, order_id, product_id, warehouse_id, reserved_stock, shop_id, channel
 		FROM order_warehouses
 		WHERE order_id = ?
 	`
 
 	var orderWarehouses []product_warehouse.OrderWarehouse
-	err := p.mysql.Select(&orderWarehouses, query, orderId)
+	err = p.mysql.SelectContext(ctx, &orderWarehouses, query, orderId)
 	if err != nil {
 		return nil, err
 	}
 
 	return orderWarehouses, nil
 }
+
+// GetOrderWarehouseByOrderIdForUpdate is GetOrderWarehouseByOrderId locking
+// the reservations of the order until tx ends.
+func (p *ProductWarehouseRepository) GetOrderWarehouseByOrderIdForUpdate(ctx context.Context, tx *sqlx.Tx, orderId int) (_ []product_warehouse.OrderWarehouse, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetOrderWarehouseByOrderIdForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT id, order_id, product_id, warehouse_id, reserved_stock, shop_id, channel
+		FROM order_warehouses
+		WHERE order_id = ?
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	orderWarehouses := []product_warehouse.OrderWarehouse{}
+	err = tx.SelectContext(ctx, &orderWarehouses, query, orderId)
+	return orderWarehouses, err
+}
+
+// GetActiveByProductIdForUpdate is GetAllByProductId locking the rows until tx
+// ends.
+func (p *ProductWarehouseRepository) GetActiveByProductIdForUpdate(ctx context.Context, tx *sqlx.Tx, productId int) (_ []product_warehouse.ProductWarehouse, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetActiveByProductIdForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT pw.id, pw.product_id, pw.warehouse_id, pw.available_stock, pw.reserved_stock, pw.safety_stock
+		FROM product_warehouses pw
+		JOIN warehouses w ON pw.warehouse_id = w.id
+		WHERE pw.product_id = ? AND w.status = ?
+		ORDER BY pw.id asc
+		FOR UPDATE OF pw
+	`
+
+	var productWarehouses []product_warehouse.ProductWarehouse
+	err = tx.SelectContext(ctx, &productWarehouses, query, productId, entity.WarehouseActive)
+	if err != nil {
+		return nil, err
+	}
+	return productWarehouses, nil
+}
+
+func (p *ProductWarehouseRepository) GetAllByProductIdsWithLocation(ctx context.Context, productIds []int) (_ []product_warehouse.WarehouseStockLocation, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseRepository.GetAllByProductIdsWithLocation")
+	defer func() { tracing.End(span, err) }()
+
+	query, args, err := sqlx.In(`
+		SELECT pw.product_id, pw.warehouse_id, pw.available_stock, w.latitude, w.longitude
+		FROM product_warehouses pw
+		JOIN warehouses w ON pw.warehouse_id = w.id
+		WHERE pw.product_id IN (?) AND w.status = ? AND pw.available_stock > 0
+		ORDER BY pw.id asc
+	`, productIds, entity.WarehouseActive)
+	if err != nil {
+		return nil, err
+	}
+
+	var warehouseStocks []product_warehouse.WarehouseStockLocation
+	err = p.mysql.SelectContext(ctx, &warehouseStocks, p.mysql.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	return warehouseStocks, nil
+}
@@ -0,0 +1,49 @@
+package rebalance
+
+import (
+	"context"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/rebalance"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type RebalanceRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewRebalanceRepository(mysql *sqlx.DB) *RebalanceRepository {
+	return &RebalanceRepository{
+		mysql: mysql,
+	}
+}
+
+func (r *RebalanceRepository) GetWarehouseStocks(ctx context.Context, shopId int, productId int, demandSince time.Time) (_ []rebalance.WarehouseStock, err error) {
+	ctx, span := tracing.Start(ctx, "RebalanceRepository.GetWarehouseStocks")
+	defer func() { tracing.End(span, err) }()
+
+	// demand sums ordered_stock, reserved_stock drops to zero once an order
+	// ships and would hide the warehouses that sell the most
+	query := `
+		SELECT pw.product_id, pw.warehouse_id, pw.available_stock, pw.safety_stock, COALESCE(d.demand, 0) AS demand
+		FROM product_warehouses pw
+		JOIN warehouses w ON pw.warehouse_id = w.id
+		LEFT JOIN (
+			SELECT product_id, warehouse_id, SUM(ordered_stock) AS demand
+			FROM order_warehouses
+			WHERE created_at >= ?
+			GROUP BY product_id, warehouse_id
+		) d ON d.product_id = pw.product_id AND d.warehouse_id = pw.warehouse_id
+		WHERE w.shop_id = ? AND w.status = ? AND (? = 0 OR pw.product_id = ?)
+		ORDER BY pw.product_id asc, pw.warehouse_id asc
+	`
+
+	var warehouseStocks []rebalance.WarehouseStock
+	err = r.mysql.SelectContext(ctx, &warehouseStocks, query, demandSince, shopId, entity.WarehouseActive, productId, productId)
+	if err != nil {
+		return nil, err
+	}
+	return warehouseStocks, nil
+}
@@ -0,0 +1,193 @@
+package stock_history
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"warehouse-service/models/stock_history"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type StockHistoryRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewStockHistoryRepository(mysql *sqlx.DB) *StockHistoryRepository {
+	return &StockHistoryRepository{
+		mysql: mysql,
+	}
+}
+
+// snapshotBatchSize is how many product_warehouses rows a snapshot locks at a
+// time.
+const snapshotBatchSize = 500
+
+// TakeSnapshot copies product_warehouses as of taken_at. The rows are read in
+// batches so writers only ever wait on a batch, and the snapshot is written in
+// one transaction so a partial snapshot is never read.
+func (s *StockHistoryRepository) TakeSnapshot(ctx context.Context) (_ *stock_history.Snapshot, err error) {
+	ctx, span := tracing.Start(ctx, "StockHistoryRepository.TakeSnapshot")
+	defer func() { tracing.End(span, err) }()
+
+	snapshot := stock_history.Snapshot{}
+	err = s.mysql.GetContext(ctx, &snapshot.TakenAt, "SELECT NOW(6)")
+	if err != nil {
+		return nil, err
+	}
+
+	stockLevels := []stock_history.StockLevel{}
+	after := stock_history.StockLevel{}
+	for {
+		batch, err := s.snapshotBatch(ctx, snapshot.TakenAt, after)
+		if err != nil {
+			return nil, err
+		}
+		stockLevels = append(stockLevels, batch...)
+		if len(batch) < snapshotBatchSize {
+			break
+		}
+		after = batch[len(batch)-1]
+	}
+
+	tx, err := s.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, "INSERT INTO stock_snapshots (taken_at) VALUES (?)", snapshot.TakenAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	snapshot.Id = int(id)
+
+	for start := 0; start < len(stockLevels); start += snapshotBatchSize {
+		batch := stockLevels[start:min(start+snapshotBatchSize, len(stockLevels))]
+		query := "INSERT INTO stock_snapshot_items (snapshot_id,product_id,warehouse_id,available_stock,reserved_stock) VALUES " + strings.TrimSuffix(strings.Repeat("(?,?,?,?,?),", len(batch)), ",")
+		args := []interface{}{}
+		for _, stockLevel := range batch {
+			args = append(args, snapshot.Id, stockLevel.ProductId, stockLevel.WarehouseId, stockLevel.AvailableStock, stockLevel.ReservedStock)
+		}
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return &snapshot, tx.Commit()
+}
+
+// snapshotBatch reads the next batch of rows after the given one as they
+// stood at takenAt. The shared lock waits for writers in flight on the batch
+// to commit and is held only while the batch is read, then the movements the
+// batch received after takenAt are taken back out.
+func (s *StockHistoryRepository) snapshotBatch(ctx context.Context, takenAt time.Time, after stock_history.StockLevel) ([]stock_history.StockLevel, error) {
+	tx, err := s.mysql.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stockLevels := []stock_history.StockLevel{}
+	err = tx.SelectContext(ctx, &stockLevels, `
+		SELECT product_id, warehouse_id, available_stock, reserved_stock FROM product_warehouses
+		WHERE (product_id, warehouse_id) > (?, ?)
+		ORDER BY product_id, warehouse_id
+		LIMIT ?
+		LOCK IN SHARE MODE
+	`, after.ProductId, after.WarehouseId, snapshotBatchSize)
+	if err != nil || len(stockLevels) == 0 {
+		return stockLevels, err
+	}
+
+	last := stockLevels[len(stockLevels)-1]
+	movementTotals := []stock_history.StockLevel{}
+	err = tx.SelectContext(ctx, &movementTotals, `
+		SELECT product_id, warehouse_id, SUM(available_delta) AS available_stock, SUM(reserved_delta) AS reserved_stock FROM stock_movements
+		WHERE created_at > ? AND (product_id, warehouse_id) > (?, ?) AND (product_id, warehouse_id) <= (?, ?)
+		GROUP BY product_id, warehouse_id
+	`, takenAt, after.ProductId, after.WarehouseId, last.ProductId, last.WarehouseId)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ productId, warehouseId int }
+	movements := map[key]stock_history.StockLevel{}
+	for _, movementTotal := range movementTotals {
+		movements[key{movementTotal.ProductId, movementTotal.WarehouseId}] = movementTotal
+	}
+	for i, stockLevel := range stockLevels {
+		movement := movements[key{stockLevel.ProductId, stockLevel.WarehouseId}]
+		stockLevels[i].AvailableStock -= movement.AvailableStock
+		stockLevels[i].ReservedStock -= movement.ReservedStock
+	}
+	return stockLevels, tx.Commit()
+}
+
+// GetLatestSnapshot returns the last snapshot taken at or before at, or nil
+// when there is none.
+func (s *StockHistoryRepository) GetLatestSnapshot(ctx context.Context, at time.Time) (_ *stock_history.Snapshot, err error) {
+	ctx, span := tracing.Start(ctx, "StockHistoryRepository.GetLatestSnapshot")
+	defer func() { tracing.End(span, err) }()
+
+	snapshot := stock_history.Snapshot{}
+	err = s.mysql.GetContext(ctx, &snapshot, "SELECT id,taken_at FROM stock_snapshots WHERE taken_at <= ? ORDER BY taken_at DESC LIMIT 1", at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (s *StockHistoryRepository) GetSnapshotItems(ctx context.Context, snapshotId int, filter stock_history.Filter) (_ []stock_history.StockLevel, err error) {
+	ctx, span := tracing.Start(ctx, "StockHistoryRepository.GetSnapshotItems")
+	defer func() { tracing.End(span, err) }()
+
+	query := "SELECT product_id,warehouse_id,available_stock,reserved_stock FROM stock_snapshot_items WHERE snapshot_id = ?"
+	args := []interface{}{snapshotId}
+	query, args = applyFilter(query, args, filter)
+
+	stockLevels := []stock_history.StockLevel{}
+	err = s.mysql.SelectContext(ctx, &stockLevels, query, args...)
+	return stockLevels, err
+}
+
+// GetMovementTotals sums the ledger after from (from the start when nil) up
+// to and including to.
+func (s *StockHistoryRepository) GetMovementTotals(ctx context.Context, from *time.Time, to time.Time, filter stock_history.Filter) (_ []stock_history.StockLevel, err error) {
+	ctx, span := tracing.Start(ctx, "StockHistoryRepository.GetMovementTotals")
+	defer func() { tracing.End(span, err) }()
+
+	query := "SELECT product_id, warehouse_id, COALESCE(SUM(available_delta), 0) AS available_stock, COALESCE(SUM(reserved_delta), 0) AS reserved_stock FROM stock_movements WHERE created_at <= ?"
+	args := []interface{}{to}
+	if from != nil {
+		query += " AND created_at > ?"
+		args = append(args, *from)
+	}
+	query, args = applyFilter(query, args, filter)
+
+	stockLevels := []stock_history.StockLevel{}
+	err = s.mysql.SelectContext(ctx, &stockLevels, query+" GROUP BY product_id, warehouse_id", args...)
+	return stockLevels, err
+}
+
+func applyFilter(query string, args []interface{}, filter stock_history.Filter) (string, []interface{}) {
+	if filter.WarehouseId != 0 {
+		query += " AND warehouse_id = ?"
+		args = append(args, filter.WarehouseId)
+	}
+	if filter.ProductId != 0 {
+		query += " AND product_id = ?"
+		args = append(args, filter.ProductId)
+	}
+	return query, args
+}
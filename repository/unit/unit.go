@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"context"
+	"warehouse-service/models/unit"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type UnitRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewUnitRepository(mysql *sqlx.DB) *UnitRepository {
+	return &UnitRepository{
+		mysql: mysql,
+	}
+}
+
+func (u *UnitRepository) Upsert(ctx context.Context, productUnit *unit.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UnitRepository.Upsert")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = u.mysql.ExecContext(ctx, "INSERT INTO product_units (product_id,unit,factor) VALUES (?,?,?) ON DUPLICATE KEY UPDATE factor=VALUES(factor)", productUnit.ProductId, productUnit.Unit, productUnit.Factor)
+	return err
+}
+
+func (u *UnitRepository) GetByProductId(ctx context.Context, productId int) (_ []unit.ProductUnit, err error) {
+	ctx, span := tracing.Start(ctx, "UnitRepository.GetByProductId")
+	defer func() { tracing.End(span, err) }()
+
+	var productUnits []unit.ProductUnit
+	err = u.mysql.SelectContext(ctx, &productUnits, "SELECT product_id,unit,factor FROM product_units WHERE product_id=? ORDER BY factor asc", productId)
+	if err != nil {
+		return nil, err
+	}
+	return productUnits, nil
+}
+
+func (u *UnitRepository) GetFactor(ctx context.Context, productId int, unitName string) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "UnitRepository.GetFactor")
+	defer func() { tracing.End(span, err) }()
+
+	var factor int
+	err = u.mysql.GetContext(ctx, &factor, "SELECT factor FROM product_units WHERE product_id=? and unit=?", productId, unitName)
+	return factor, err
+}
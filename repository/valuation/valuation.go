@@ -0,0 +1,89 @@
+package valuation
+
+import (
+	"context"
+	"time"
+	"warehouse-service/models/valuation"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ValuationRepository struct {
+	mysql *sqlx.DB
+}
+
+func NewValuationRepository(mysql *sqlx.DB) *ValuationRepository {
+	return &ValuationRepository{
+		mysql: mysql,
+	}
+}
+
+func (v *ValuationRepository) GetCostingMethod(ctx context.Context, tx *sqlx.Tx, warehouseId int) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "ValuationRepository.GetCostingMethod")
+	defer func() { tracing.End(span, err) }()
+
+	var costingMethod string
+	err = tx.GetContext(ctx, &costingMethod, "SELECT costing_method FROM warehouses WHERE id=?", warehouseId)
+	return costingMethod, err
+}
+
+// GetOpenLayersForUpdate returns the layers with stock left, oldest first.
+func (v *ValuationRepository) GetOpenLayersForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (_ []valuation.CostLayer, err error) {
+	ctx, span := tracing.Start(ctx, "ValuationRepository.GetOpenLayersForUpdate")
+	defer func() { tracing.End(span, err) }()
+
+	costLayers := []valuation.CostLayer{}
+	err = tx.SelectContext(ctx, &costLayers, "SELECT id,product_id,warehouse_id,remaining_quantity,unit_cost,received_at FROM cost_layers WHERE product_id=? AND warehouse_id=? AND remaining_quantity>0 ORDER BY received_at, id FOR UPDATE", productId, warehouseId)
+	return costLayers, err
+}
+
+func (v *ValuationRepository) InsertLayer(ctx context.Context, tx *sqlx.Tx, costLayer *valuation.CostLayer) (err error) {
+	ctx, span := tracing.Start(ctx, "ValuationRepository.InsertLayer")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO cost_layers (product_id,warehouse_id,received_quantity,remaining_quantity,unit_cost,received_at) VALUES (?,?,?,?,?,?)", costLayer.ProductId, costLayer.WarehouseId, costLayer.RemainingQuantity, costLayer.RemainingQuantity, costLayer.UnitCost, costLayer.ReceivedAt)
+	return err
+}
+
+func (v *ValuationRepository) SubstractLayer(ctx context.Context, tx *sqlx.Tx, id int, substractedQuantity int) (err error) {
+	ctx, span := tracing.Start(ctx, "ValuationRepository.SubstractLayer")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE cost_layers SET remaining_quantity = remaining_quantity - ? WHERE id=?", substractedQuantity, id)
+	return err
+}
+
+func (v *ValuationRepository) CloseLayers(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (err error) {
+	ctx, span := tracing.Start(ctx, "ValuationRepository.CloseLayers")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "UPDATE cost_layers SET remaining_quantity=0 WHERE product_id=? AND warehouse_id=? AND remaining_quantity>0", productId, warehouseId)
+	return err
+}
+
+func (v *ValuationRepository) InsertMovement(ctx context.Context, tx *sqlx.Tx, costMovement *valuation.CostMovement) (err error) {
+	ctx, span := tracing.Start(ctx, "ValuationRepository.InsertMovement")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO cost_movements (product_id,warehouse_id,movement_type,quantity,value,created_at) VALUES (?,?,?,?,?,?)", costMovement.ProductId, costMovement.WarehouseId, costMovement.MovementType, costMovement.Quantity, costMovement.Value, costMovement.CreatedAt)
+	return err
+}
+
+// GetProductValues sums the cost movements of the shop's warehouses recorded
+// before asOf. Movements are recorded in UTC, so asOf must be in UTC too.
+func (v *ValuationRepository) GetProductValues(ctx context.Context, shopId int, asOf time.Time) (_ []valuation.ProductValueRow, err error) {
+	ctx, span := tracing.Start(ctx, "ValuationRepository.GetProductValues")
+	defer func() { tracing.End(span, err) }()
+
+	productValues := []valuation.ProductValueRow{}
+	err = v.mysql.SelectContext(ctx, &productValues, `
+		SELECT cm.warehouse_id, cm.product_id, COALESCE(SUM(cm.quantity), 0) AS quantity, COALESCE(SUM(cm.value), 0) AS value
+		FROM cost_movements cm
+		JOIN warehouses w ON cm.warehouse_id = w.id
+		WHERE w.shop_id = ? AND cm.created_at < ?
+		GROUP BY cm.warehouse_id, cm.product_id
+		ORDER BY cm.warehouse_id, cm.product_id
+	`, shopId, asOf.UTC())
+	return productValues, err
+}
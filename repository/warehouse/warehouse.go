@@ -1,7 +1,9 @@
 package warehouse
 
 import (
+	"context"
 	"warehouse-service/models/warehouse"
+	"warehouse-service/tracing"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -16,12 +18,66 @@ func NewWarehouseRepository(mysql *sqlx.DB) *WarehouseRepository {
 	}
 }
 
-func (w *WarehouseRepository) Insert(warehouse *warehouse.RegisterRequest) error {
-	_, err := w.mysql.Exec("INSERT INTO warehouses (name,address,shop_id,status) VALUES (?,?,?,?)", warehouse.Name, warehouse.Address, warehouse.ShopId, warehouse.Status)
+func (w *WarehouseRepository) Insert(ctx context.Context, warehouse *warehouse.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseRepository.Insert")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = w.mysql.ExecContext(ctx, "INSERT INTO warehouses (name,address,shop_id,status,latitude,longitude,capacity_units,capacity_volume,costing_method) VALUES (?,?,?,?,?,?,?,?,?)", warehouse.Name, warehouse.Address, warehouse.ShopId, warehouse.Status, warehouse.Latitude, warehouse.Longitude, warehouse.CapacityUnits, warehouse.CapacityVolume, warehouse.CostingMethod)
+	return err
+}
+
+func (w *WarehouseRepository) UpdateStatus(ctx context.Context, id int, status string) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseRepository.UpdateStatus")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = w.mysql.ExecContext(ctx, "UPDATE warehouses SET status=? WHERE id=?", status, id)
 	return err
 }
 
-func (w *WarehouseRepository) UpdateStatus(id int, status string) error {
-	_, err := w.mysql.Exec("UPDATE warehouses SET status=? WHERE id=?", status, id)
+func (w *WarehouseRepository) UpdateLocation(ctx context.Context, id int, latitude float64, longitude float64) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseRepository.UpdateLocation")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = w.mysql.ExecContext(ctx, "UPDATE warehouses SET latitude=?, longitude=? WHERE id=?", latitude, longitude, id)
+	return err
+}
+
+func (w *WarehouseRepository) UpdateCapacity(ctx context.Context, id int, capacityUnits *int, capacityVolume *float64) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseRepository.UpdateCapacity")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = w.mysql.ExecContext(ctx, "UPDATE warehouses SET capacity_units=?, capacity_volume=? WHERE id=?", capacityUnits, capacityVolume, id)
 	return err
 }
+
+func (w *WarehouseRepository) UpdateCostingMethod(ctx context.Context, id int, costingMethod string) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseRepository.UpdateCostingMethod")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = w.mysql.ExecContext(ctx, "UPDATE warehouses SET costing_method=? WHERE id=?", costingMethod, id)
+	return err
+}
+
+func (w *WarehouseRepository) GetUtilizationByShop(ctx context.Context, shopId int) (_ []warehouse.Utilization, err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseRepository.GetUtilizationByShop")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		SELECT w.id AS warehouse_id, w.shop_id, w.name, w.capacity_units, w.capacity_volume,
+			COALESCE(SUM(pw.available_stock + pw.reserved_stock), 0) AS used_units,
+			COALESCE(SUM((pw.available_stock + pw.reserved_stock) * COALESCE(pd.length * pd.width * pd.height, 0)), 0) AS used_volume
+		FROM warehouses w
+		LEFT JOIN product_warehouses pw ON pw.warehouse_id = w.id
+		LEFT JOIN product_dimensions pd ON pd.product_id = pw.product_id
+		WHERE w.shop_id = ?
+		GROUP BY w.id, w.shop_id, w.name, w.capacity_units, w.capacity_volume
+		ORDER BY w.id asc
+	`
+
+	var utilizations []warehouse.Utilization
+	err = w.mysql.SelectContext(ctx, &utilizations, query, shopId)
+	if err != nil {
+		return nil, err
+	}
+	return utilizations, nil
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"warehouse-service/config"
+	"warehouse-service/entity"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "warehouse-service"
+
+// tracer delegates to the provider installed by Setup, until then its spans
+// only carry the trace context along.
+var tracer = otel.Tracer(instrumentationName)
+
+// Setup installs the W3C trace context propagator and, unless the exporter
+// is "none", a tracer provider exporting the sampled spans. The returned
+// shutdown flushes the spans still buffered.
+func Setup(tracingConfig config.Tracing) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if tracingConfig.Exporter == entity.TracingExporterNone {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	exporter, closer, err := newExporter(tracingConfig)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", tracingConfig.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tracingConfig.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+func newExporter(tracingConfig config.Tracing) (sdktrace.SpanExporter, io.Closer, error) {
+	switch tracingConfig.Exporter {
+	case entity.TracingExporterStdout:
+		if tracingConfig.File == "" {
+			exporter, err := stdouttrace.New()
+			return exporter, nil, err
+		}
+		file, err := os.OpenFile(tracingConfig.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	case entity.TracingExporterOTLP:
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(tracingConfig.OTLPEndpoint)}
+		if tracingConfig.OTLPInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), options...)
+		return exporter, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown tracing exporter %q", tracingConfig.Exporter)
+	}
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, options...)
+}
+
+// End marks the span failed when err is not nil, then ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"warehouse-service/config"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetup_StdoutFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(config.Tracing{Exporter: "stdout", File: path, ServiceName: "warehouse-service", SampleRatio: 1})
+	assert.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "UnitUsecase.Register")
+	_, child := Start(ctx, "UnitRepository.Upsert")
+	End(child, errors.New("duplicate entry"))
+	End(parent, nil)
+	err = shutdown(context.Background())
+	content, _ := os.ReadFile(path)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"Name":"UnitUsecase.Register"`)
+	assert.Contains(t, string(content), `"Name":"UnitRepository.Upsert"`)
+	assert.Contains(t, string(content), "duplicate entry")
+	assert.Contains(t, string(content), parent.SpanContext().TraceID().String())
+}
+
+func TestSetup_NonePropagates(t *testing.T) {
+	_, err := Setup(config.Tracing{Exporter: "none", ServiceName: "warehouse-service", SampleRatio: 1})
+	assert.NoError(t, err)
+	incoming := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), incoming)
+	ctx, span := Start(ctx, "ProductWarehouseUsecase.TransferStock")
+	outgoing := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, outgoing)
+	span.End()
+
+	// Assertions
+	assert.Contains(t, outgoing["traceparent"], "4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+// TestEnd_DeferredInMethodsReturningErrors keeps new methods from ending
+// their span with a plain span.End(), which would leave failed spans
+// unmarked. Methods returning an error end it with End and a named err.
+func TestEnd_DeferredInMethodsReturningErrors(t *testing.T) {
+	fset := token.NewFileSet()
+	unmarked := []string{}
+	err := filepath.WalkDir("..", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		for _, decl := range file.Decls {
+			function, ok := decl.(*ast.FuncDecl)
+			if !ok || function.Body == nil || !returnsError(function.Type) {
+				continue
+			}
+			for _, stmt := range function.Body.List {
+				if deferStmt, ok := stmt.(*ast.DeferStmt); ok && isSpanEnd(deferStmt.Call) {
+					unmarked = append(unmarked, fset.Position(deferStmt.Pos()).String())
+				}
+			}
+		}
+		return nil
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, unmarked)
+}
+
+func returnsError(function *ast.FuncType) bool {
+	if function.Results == nil {
+		return false
+	}
+	last, ok := function.Results.List[len(function.Results.List)-1].Type.(*ast.Ident)
+	return ok && last.Name == "error"
+}
+
+func isSpanEnd(call *ast.CallExpr) bool {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != "End" {
+		return false
+	}
+	receiver, ok := selector.X.(*ast.Ident)
+	return ok && receiver.Name == "span"
+}
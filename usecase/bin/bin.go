@@ -0,0 +1,239 @@
+package bin
+
+import (
+	"context"
+	"fmt"
+	"warehouse-service/entity"
+	"warehouse-service/models/bin"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type BinRepository interface {
+	InsertZone(ctx context.Context, zone *bin.ZoneRegisterRequest) error
+	InsertAisle(ctx context.Context, aisle *bin.AisleRegisterRequest) error
+	InsertBin(ctx context.Context, binRegister *bin.BinRegisterRequest) error
+	GetWarehouseIdByBinId(ctx context.Context, binId int) (int, error)
+	LockProductWarehouse(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) error
+	GetUnbinnedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (int, error)
+	AddBinStock(ctx context.Context, tx *sqlx.Tx, binId int, productId int, quantity int) error
+	SubstractBinStock(ctx context.Context, tx *sqlx.Tx, binId int, productId int, quantity int) (bool, error)
+	GetBinLocations(ctx context.Context, productId int, warehouseId int) ([]bin.BinLocation, error)
+	GetBinLocationsForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) ([]bin.BinLocation, error)
+}
+
+type OrderWarehouseRepository interface {
+	GetOrderWarehouseByOrderId(ctx context.Context, orderId int) ([]product_warehouse.OrderWarehouse, error)
+}
+
+type BinUsecase struct {
+	binRepo            BinRepository
+	orderWarehouseRepo OrderWarehouseRepository
+	mysql              *sqlx.DB
+}
+
+func NewBinUsecase(binRepo BinRepository, orderWarehouseRepo OrderWarehouseRepository, mysql *sqlx.DB) *BinUsecase {
+	return &BinUsecase{
+		binRepo:            binRepo,
+		orderWarehouseRepo: orderWarehouseRepo,
+		mysql:              mysql,
+	}
+}
+
+func (b *BinUsecase) RegisterZone(ctx context.Context, zone *bin.ZoneRegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BinUsecase.RegisterZone")
+	defer func() { tracing.End(span, err) }()
+
+	return b.binRepo.InsertZone(ctx, zone)
+}
+
+func (b *BinUsecase) RegisterAisle(ctx context.Context, aisle *bin.AisleRegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BinUsecase.RegisterAisle")
+	defer func() { tracing.End(span, err) }()
+
+	return b.binRepo.InsertAisle(ctx, aisle)
+}
+
+func (b *BinUsecase) RegisterBin(ctx context.Context, binRegister *bin.BinRegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BinUsecase.RegisterBin")
+	defer func() { tracing.End(span, err) }()
+
+	return b.binRepo.InsertBin(ctx, binRegister)
+}
+
+// Putaway places stock that is counted on the product warehouse but not yet
+// stored in any bin into the given bin.
+func (b *BinUsecase) Putaway(ctx context.Context, putaway *bin.PutawayRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BinUsecase.Putaway")
+	defer func() { tracing.End(span, err) }()
+
+	warehouseId, err := b.binRepo.GetWarehouseIdByBinId(ctx, putaway.BinId)
+	if err != nil {
+		return err
+	}
+	if warehouseId != putaway.WarehouseId {
+		return entity.ErrorBinNotInWarehouse
+	}
+
+	tx, err := b.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// stock leaving the warehouse takes the same lock before its bins
+	err = b.binRepo.LockProductWarehouse(ctx, tx, putaway.ProductId, putaway.WarehouseId)
+	if err != nil {
+		return err
+	}
+	unbinnedStock, err := b.binRepo.GetUnbinnedStock(ctx, tx, putaway.ProductId, putaway.WarehouseId)
+	if err != nil {
+		return err
+	}
+	if unbinnedStock < putaway.Quantity {
+		err = entity.ErrorInsufficientStock
+		return err
+	}
+
+	err = b.binRepo.AddBinStock(ctx, tx, putaway.BinId, putaway.ProductId, putaway.Quantity)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *BinUsecase) Move(ctx context.Context, move *bin.MoveRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BinUsecase.Move")
+	defer func() { tracing.End(span, err) }()
+
+	fromWarehouseId, err := b.binRepo.GetWarehouseIdByBinId(ctx, move.FromBinId)
+	if err != nil {
+		return err
+	}
+	toWarehouseId, err := b.binRepo.GetWarehouseIdByBinId(ctx, move.ToBinId)
+	if err != nil {
+		return err
+	}
+	if fromWarehouseId != toWarehouseId {
+		return entity.ErrorBinNotInWarehouse
+	}
+
+	tx, err := b.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	moved, err := b.binRepo.SubstractBinStock(ctx, tx, move.FromBinId, move.ProductId, move.Quantity)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		err = entity.ErrorInsufficientStock
+		return err
+	}
+
+	err = b.binRepo.AddBinStock(ctx, tx, move.ToBinId, move.ProductId, move.Quantity)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPickLocations lists the bins to pick each reserved line of an order from.
+// Reserved quantity that is not in any bin yet is returned without a bin.
+func (b *BinUsecase) GetPickLocations(ctx context.Context, orderId int) (_ []bin.PickLocation, err error) {
+	ctx, span := tracing.Start(ctx, "BinUsecase.GetPickLocations")
+	defer func() { tracing.End(span, err) }()
+
+	orderWarehouses, err := b.orderWarehouseRepo.GetOrderWarehouseByOrderId(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	pickLocations := []bin.PickLocation{}
+	for _, orderWarehouse := range orderWarehouses {
+		if orderWarehouse.ReservedStock <= 0 {
+			continue
+		}
+		binLocations, err := b.binRepo.GetBinLocations(ctx, orderWarehouse.ProductId, orderWarehouse.WarehouseId)
+		if err != nil {
+			return nil, err
+		}
+		pickLocations = append(pickLocations, buildPickLocations(orderWarehouse, binLocations)...)
+	}
+	return pickLocations, nil
+}
+
+// TakeStock removes quantity units leaving the warehouse from its bins, in
+// the order they are picked, so the bins never hold more than the warehouse.
+// Units beyond what the bins hold were never put away. Callers have already
+// taken the units off the product warehouse in tx.
+func (b *BinUsecase) TakeStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) (err error) {
+	ctx, span := tracing.Start(ctx, "BinUsecase.TakeStock")
+	defer func() { tracing.End(span, err) }()
+
+	binLocations, err := b.binRepo.GetBinLocationsForUpdate(ctx, tx, productId, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	orderWarehouse := product_warehouse.OrderWarehouse{ProductId: productId, WarehouseId: warehouseId, ReservedStock: quantity}
+	for _, pickLocation := range buildPickLocations(orderWarehouse, binLocations) {
+		if pickLocation.BinId == 0 {
+			continue
+		}
+		taken, err := b.binRepo.SubstractBinStock(ctx, tx, pickLocation.BinId, productId, pickLocation.Quantity)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			return entity.ErrorInsufficientStock
+		}
+	}
+	return nil
+}
+
+func buildPickLocations(orderWarehouse product_warehouse.OrderWarehouse, binLocations []bin.BinLocation) []bin.PickLocation {
+	pickLocations := []bin.PickLocation{}
+	remaining := orderWarehouse.ReservedStock
+	for _, binLocation := range binLocations {
+		if remaining == 0 {
+			break
+		}
+		quantity := min(binLocation.Quantity, remaining)
+		pickLocations = append(pickLocations, bin.PickLocation{
+			ProductId:   orderWarehouse.ProductId,
+			WarehouseId: orderWarehouse.WarehouseId,
+			BinId:       binLocation.BinId,
+			Location:    fmt.Sprintf("%s-%s-%s", binLocation.ZoneCode, binLocation.AisleCode, binLocation.BinCode),
+			Quantity:    quantity,
+		})
+		remaining -= quantity
+	}
+	if remaining > 0 {
+		pickLocations = append(pickLocations, bin.PickLocation{
+			ProductId:   orderWarehouse.ProductId,
+			WarehouseId: orderWarehouse.WarehouseId,
+			Quantity:    remaining,
+		})
+	}
+	return pickLocations
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,155 @@
+package bin
+
+import (
+	"context"
+	"testing"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/entity"
+	"warehouse-service/models/bin"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockBinRepository struct {
+	BinRepository
+	mock.Mock
+}
+
+func (m *MockBinRepository) GetWarehouseIdByBinId(ctx context.Context, binId int) (int, error) {
+	args := m.Called(binId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockBinRepository) LockProductWarehouse(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) error {
+	args := m.Called(productId, warehouseId)
+	return args.Error(0)
+}
+
+func (m *MockBinRepository) GetUnbinnedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (int, error) {
+	args := m.Called(productId, warehouseId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockBinRepository) AddBinStock(ctx context.Context, tx *sqlx.Tx, binId int, productId int, quantity int) error {
+	args := m.Called(binId, productId, quantity)
+	return args.Error(0)
+}
+
+func (m *MockBinRepository) SubstractBinStock(ctx context.Context, tx *sqlx.Tx, binId int, productId int, quantity int) (bool, error) {
+	args := m.Called(binId, productId, quantity)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBinRepository) GetBinLocationsForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) ([]bin.BinLocation, error) {
+	args := m.Called(productId, warehouseId)
+	return args.Get(0).([]bin.BinLocation), args.Error(1)
+}
+
+func TestBuildPickLocations(t *testing.T) {
+	orderWarehouse := product_warehouse.OrderWarehouse{ProductId: 7, WarehouseId: 2, ReservedStock: 10}
+	binLocations := []bin.BinLocation{
+		{BinId: 1, ZoneCode: "A", AisleCode: "01", BinCode: "01", Quantity: 4},
+		{BinId: 2, ZoneCode: "A", AisleCode: "01", BinCode: "02", Quantity: 3},
+	}
+
+	pickLocations := buildPickLocations(orderWarehouse, binLocations)
+
+	// Assertions
+	assert.Equal(t, []bin.PickLocation{
+		{ProductId: 7, WarehouseId: 2, BinId: 1, Location: "A-01-01", Quantity: 4},
+		{ProductId: 7, WarehouseId: 2, BinId: 2, Location: "A-01-02", Quantity: 3},
+		{ProductId: 7, WarehouseId: 2, Quantity: 3},
+	}, pickLocations)
+}
+
+func TestBuildPickLocations_StopsWhenCovered(t *testing.T) {
+	orderWarehouse := product_warehouse.OrderWarehouse{ProductId: 7, WarehouseId: 2, ReservedStock: 5}
+	binLocations := []bin.BinLocation{
+		{BinId: 1, ZoneCode: "A", AisleCode: "01", BinCode: "01", Quantity: 4},
+		{BinId: 2, ZoneCode: "A", AisleCode: "01", BinCode: "02", Quantity: 3},
+		{BinId: 3, ZoneCode: "B", AisleCode: "01", BinCode: "01", Quantity: 9},
+	}
+
+	pickLocations := buildPickLocations(orderWarehouse, binLocations)
+
+	// Assertions
+	assert.Equal(t, []bin.PickLocation{
+		{ProductId: 7, WarehouseId: 2, BinId: 1, Location: "A-01-01", Quantity: 4},
+		{ProductId: 7, WarehouseId: 2, BinId: 2, Location: "A-01-02", Quantity: 1},
+	}, pickLocations)
+}
+
+func TestTakeStock_InPickOrder(t *testing.T) {
+	mockRepo := new(MockBinRepository)
+	db := mysqltest.NewDB()
+	binUsecase := NewBinUsecase(mockRepo, nil, db.DB)
+
+	mockRepo.On("GetBinLocationsForUpdate", 7, 2).Return([]bin.BinLocation{
+		{BinId: 1, Quantity: 4},
+		{BinId: 2, Quantity: 3},
+	}, nil)
+	mockRepo.On("SubstractBinStock", 1, 7, 4).Return(true, nil)
+	mockRepo.On("SubstractBinStock", 2, 7, 3).Return(true, nil)
+
+	tx, _ := db.BeginTxx(context.Background(), nil)
+	defer tx.Rollback()
+	err := binUsecase.TakeStock(context.Background(), tx, 7, 2, 9)
+
+	// Assertions
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNumberOfCalls(t, "SubstractBinStock", 2)
+}
+
+func TestPutaway(t *testing.T) {
+	mockRepo := new(MockBinRepository)
+	db := mysqltest.NewDB()
+	binUsecase := NewBinUsecase(mockRepo, nil, db.DB)
+
+	mockRepo.On("GetWarehouseIdByBinId", 1).Return(2, nil)
+	mockRepo.On("LockProductWarehouse", 7, 2).Return(nil)
+	mockRepo.On("GetUnbinnedStock", 7, 2).Return(5, nil)
+	mockRepo.On("AddBinStock", 1, 7, 5).Return(nil)
+
+	err := binUsecase.Putaway(context.Background(), &bin.PutawayRequest{ProductId: 7, WarehouseId: 2, BinId: 1, Quantity: 5})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, db.Commits())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPutaway_MoreThanUnbinned(t *testing.T) {
+	mockRepo := new(MockBinRepository)
+	db := mysqltest.NewDB()
+	binUsecase := NewBinUsecase(mockRepo, nil, db.DB)
+
+	mockRepo.On("GetWarehouseIdByBinId", 1).Return(2, nil)
+	mockRepo.On("LockProductWarehouse", 7, 2).Return(nil)
+	mockRepo.On("GetUnbinnedStock", 7, 2).Return(4, nil)
+
+	err := binUsecase.Putaway(context.Background(), &bin.PutawayRequest{ProductId: 7, WarehouseId: 2, BinId: 1, Quantity: 5})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+	assert.Equal(t, 1, db.Rollbacks())
+	mockRepo.AssertNotCalled(t, "AddBinStock", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPutaway_BinInOtherWarehouse(t *testing.T) {
+	mockRepo := new(MockBinRepository)
+	db := mysqltest.NewDB()
+	binUsecase := NewBinUsecase(mockRepo, nil, db.DB)
+
+	mockRepo.On("GetWarehouseIdByBinId", 1).Return(3, nil)
+
+	err := binUsecase.Putaway(context.Background(), &bin.PutawayRequest{ProductId: 7, WarehouseId: 2, BinId: 1, Quantity: 5})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorBinNotInWarehouse)
+	assert.Equal(t, 0, db.Commits())
+}
@@ -0,0 +1,272 @@
+package bulk_import
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"warehouse-service/entity"
+	"warehouse-service/handler/httperror"
+	"warehouse-service/models/bulk_import"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/valuation"
+	"warehouse-service/models/warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// batchSize is the number of rows applied per transaction.
+const batchSize = 500
+
+type BulkImportRepository interface {
+	UpsertWarehouse(ctx context.Context, tx *sqlx.Tx, warehouse *warehouse.RegisterRequest) error
+	UpsertProductWarehouse(ctx context.Context, tx *sqlx.Tx, productWarehouse *product_warehouse.RegisterRequest) (bool, error)
+	LockWarehouse(ctx context.Context, tx *sqlx.Tx, warehouseId int) error
+	GetAvailableStockForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (int, bool, error)
+	SetOpeningBalance(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) (int, error)
+}
+
+type Capacity interface {
+	Check(ctx context.Context, tx *sqlx.Tx, warehouseId int, productId int, quantity int) error
+}
+
+type Bins interface {
+	TakeStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) error
+}
+
+type Valuation interface {
+	Receive(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int, unitCost *valuation.Money) error
+	Consume(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int) error
+}
+
+type BulkImportUsecase struct {
+	bulkImportRepo BulkImportRepository
+	capacity       Capacity
+	bins           Bins
+	valuation      Valuation
+	mysql          *sqlx.DB
+}
+
+// validate reports rows like the handlers report request bodies.
+var validate = httperror.NewValidator()
+
+func NewBulkImportUsecase(bulkImportRepo BulkImportRepository, capacity Capacity, bins Bins, valuation Valuation, mysql *sqlx.DB) *BulkImportUsecase {
+	return &BulkImportUsecase{
+		bulkImportRepo: bulkImportRepo,
+		capacity:       capacity,
+		bins:           bins,
+		valuation:      valuation,
+		mysql:          mysql,
+	}
+}
+
+// Import validates every row of the file with the validator tags of the
+// register requests and, unless dryRun, upserts the valid rows in batches as
+// the file is read. A batch is applied in one transaction, so a row failing
+// in the database rolls back the rows of its batch only. Batches applied
+// before the file fails to read stay applied, every import is an upsert so
+// the file can be sent again.
+func (b *BulkImportUsecase) Import(ctx context.Context, kind string, format string, reader io.Reader, dryRun bool) (_ *bulk_import.Report, err error) {
+	ctx, span := tracing.Start(ctx, "BulkImportUsecase.Import")
+	defer func() { tracing.End(span, err) }()
+
+	newValue, apply, err := b.importKind(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &bulk_import.Report{
+		Kind:   kind,
+		Format: format,
+		DryRun: dryRun,
+	}
+	lineErrors := make(map[int]string)
+	batch := []row{}
+	applyPending := func() error {
+		applied, err := b.applyBatch(ctx, batch, apply, lineErrors)
+		if err != nil {
+			return err
+		}
+		report.AppliedRows += applied
+		batch = batch[:0]
+		return nil
+	}
+	handle := func(row row) error {
+		report.TotalRows++
+		if err := validate.Struct(row.value); err != nil {
+			lineErrors[row.line] = httperror.Validation(err).Error()
+			return nil
+		}
+		report.ValidRows++
+		if dryRun {
+			return nil
+		}
+		batch = append(batch, row)
+		if len(batch) < batchSize {
+			return nil
+		}
+		return applyPending()
+	}
+
+	var parseErrors map[int]string
+	switch format {
+	case entity.FormatCSV:
+		parseErrors, err = parseCSV(reader, newValue, handle)
+	case entity.FormatNDJSON:
+		parseErrors, err = parseNDJSON(reader, newValue, handle)
+	default:
+		return nil, entity.ErrorUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(batch) > 0 {
+		err = applyPending()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report.TotalRows += len(parseErrors)
+	for line, message := range parseErrors {
+		lineErrors[line] = message
+	}
+	report.Errors = sortLineErrors(lineErrors)
+	return report, nil
+}
+
+// applyBatch returns the number of rows applied. Row failures are recorded in
+// lineErrors, only failing to reach the database is returned as an error.
+// Rows read committed data so each capacity check sees the stock committed
+// before it took the warehouse lock, not the snapshot of the batch's first
+// read.
+func (b *BulkImportUsecase) applyBatch(ctx context.Context, batch []row, apply func(ctx context.Context, tx *sqlx.Tx, value interface{}) error, lineErrors map[int]string) (int, error) {
+	tx, err := b.mysql.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, row := range batch {
+		err = apply(ctx, tx, row.value)
+		if err == nil {
+			continue
+		}
+		tx.Rollback()
+		for _, batchRow := range batch {
+			lineErrors[batchRow.line] = fmt.Sprintf("not applied, batch rolled back on line %d", row.line)
+		}
+		lineErrors[row.line] = err.Error()
+		return 0, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+func (b *BulkImportUsecase) importKind(kind string) (func() interface{}, func(ctx context.Context, tx *sqlx.Tx, value interface{}) error, error) {
+	switch kind {
+	case entity.ImportWarehouses:
+		return func() interface{} { return &warehouse.RegisterRequest{} }, b.applyWarehouse, nil
+	case entity.ImportProductWarehouses:
+		return func() interface{} { return &product_warehouse.RegisterRequest{} }, b.applyProductWarehouse, nil
+	case entity.ImportOpeningBalances:
+		return func() interface{} { return &bulk_import.OpeningBalanceRequest{} }, b.applyOpeningBalance, nil
+	}
+	return nil, nil, entity.ErrorUnknownImportKind
+}
+
+func (b *BulkImportUsecase) applyWarehouse(ctx context.Context, tx *sqlx.Tx, value interface{}) error {
+	warehouseRegister := value.(*warehouse.RegisterRequest)
+	if warehouseRegister.CostingMethod == "" {
+		warehouseRegister.CostingMethod = entity.CostingFIFO
+	}
+	return b.bulkImportRepo.UpsertWarehouse(ctx, tx, warehouseRegister)
+}
+
+// applyProductWarehouse values the stock of a new registration like an opening
+// balance, after checking it against the warehouse's capacity. The stock of an
+// existing registration is left to opening balances, so sending the file again
+// is not checked.
+func (b *BulkImportUsecase) applyProductWarehouse(ctx context.Context, tx *sqlx.Tx, value interface{}) error {
+	productWarehouse := value.(*product_warehouse.RegisterRequest)
+	if productWarehouse.AvailableStock > 0 {
+		err := b.bulkImportRepo.LockWarehouse(ctx, tx, productWarehouse.WarehouseId)
+		if err != nil {
+			return err
+		}
+		_, registered, err := b.bulkImportRepo.GetAvailableStockForUpdate(ctx, tx, productWarehouse.ProductId, productWarehouse.WarehouseId)
+		if err != nil {
+			return err
+		}
+		if !registered {
+			err = b.capacity.Check(ctx, tx, productWarehouse.WarehouseId, productWarehouse.ProductId, productWarehouse.AvailableStock)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	registered, err := b.bulkImportRepo.UpsertProductWarehouse(ctx, tx, productWarehouse)
+	if err != nil {
+		return err
+	}
+	if !registered || productWarehouse.AvailableStock <= 0 {
+		return nil
+	}
+	return b.valuation.Receive(ctx, tx, entity.CostMovementReceipt, productWarehouse.ProductId, productWarehouse.WarehouseId, productWarehouse.AvailableStock, productWarehouse.UnitCost)
+}
+
+// applyOpeningBalance values the stock it adds at the given unit cost, or at
+// the current average cost when there is none. Stock it adds must fit the
+// warehouse's capacity like any other receipt, stock it removes is taken from
+// the bins like any other deduction. The warehouse is locked before the stock
+// is read, in the order every path adding stock takes the locks.
+func (b *BulkImportUsecase) applyOpeningBalance(ctx context.Context, tx *sqlx.Tx, value interface{}) error {
+	openingBalance := value.(*bulk_import.OpeningBalanceRequest)
+	err := b.bulkImportRepo.LockWarehouse(ctx, tx, openingBalance.WarehouseId)
+	if err != nil {
+		return err
+	}
+	availableStock, _, err := b.bulkImportRepo.GetAvailableStockForUpdate(ctx, tx, openingBalance.ProductId, openingBalance.WarehouseId)
+	if err != nil {
+		return err
+	}
+	if openingBalance.Quantity > availableStock {
+		err = b.capacity.Check(ctx, tx, openingBalance.WarehouseId, openingBalance.ProductId, openingBalance.Quantity-availableStock)
+		if err != nil {
+			return err
+		}
+	}
+
+	delta, err := b.bulkImportRepo.SetOpeningBalance(ctx, tx, openingBalance.ProductId, openingBalance.WarehouseId, openingBalance.Quantity)
+	if err != nil {
+		return err
+	}
+	if delta > 0 {
+		return b.valuation.Receive(ctx, tx, entity.CostMovementReceipt, openingBalance.ProductId, openingBalance.WarehouseId, delta, openingBalance.UnitCost)
+	}
+	if delta < 0 {
+		err = b.bins.TakeStock(ctx, tx, openingBalance.ProductId, openingBalance.WarehouseId, -delta)
+		if err != nil {
+			return err
+		}
+		return b.valuation.Consume(ctx, tx, entity.CostMovementDeduction, openingBalance.ProductId, openingBalance.WarehouseId, -delta)
+	}
+	return nil
+}
+
+func sortLineErrors(lineErrors map[int]string) []bulk_import.LineError {
+	sorted := []bulk_import.LineError{}
+	for line, message := range lineErrors {
+		sorted = append(sorted, bulk_import.LineError{Line: line, Message: message})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Line < sorted[j].Line
+	})
+	return sorted
+}
@@ -0,0 +1,271 @@
+package bulk_import
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/entity"
+	"warehouse-service/models/bulk_import"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/valuation"
+	"warehouse-service/models/warehouse"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockBulkImportRepository struct {
+	BulkImportRepository
+	mock.Mock
+}
+
+func (m *MockBulkImportRepository) UpsertProductWarehouse(ctx context.Context, tx *sqlx.Tx, productWarehouse *product_warehouse.RegisterRequest) (bool, error) {
+	args := m.Called(productWarehouse)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBulkImportRepository) LockWarehouse(ctx context.Context, tx *sqlx.Tx, warehouseId int) error {
+	args := m.Called(warehouseId)
+	return args.Error(0)
+}
+
+func (m *MockBulkImportRepository) GetAvailableStockForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (int, bool, error) {
+	args := m.Called(productId, warehouseId)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockBulkImportRepository) SetOpeningBalance(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) (int, error) {
+	args := m.Called(productId, warehouseId, quantity)
+	return args.Int(0), args.Error(1)
+}
+
+type MockCapacity struct {
+	mock.Mock
+}
+
+func (m *MockCapacity) Check(ctx context.Context, tx *sqlx.Tx, warehouseId int, productId int, quantity int) error {
+	args := m.Called(warehouseId, productId, quantity)
+	return args.Error(0)
+}
+
+type MockBins struct {
+	mock.Mock
+}
+
+func (m *MockBins) TakeStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) error {
+	args := m.Called(productId, warehouseId, quantity)
+	return args.Error(0)
+}
+
+type MockValuation struct {
+	mock.Mock
+}
+
+func (m *MockValuation) Receive(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int, unitCost *valuation.Money) error {
+	args := m.Called(movementType, productId, warehouseId, quantity, unitCost)
+	return args.Error(0)
+}
+
+func (m *MockValuation) Consume(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int) error {
+	args := m.Called(movementType, productId, warehouseId, quantity)
+	return args.Error(0)
+}
+
+// collect parses file with parse and returns the rows it handled.
+func collect(parse func(io.Reader, func() interface{}, func(row) error) (map[int]string, error), file string, newValue func() interface{}) ([]row, map[int]string, error) {
+	rows := []row{}
+	lineErrors, err := parse(strings.NewReader(file), newValue, func(row row) error {
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, lineErrors, err
+}
+
+func TestParseCSV(t *testing.T) {
+	file := "name,address,shop_id,status,latitude,longitude\n" +
+		"North,Jl. Utara 1,1,active,-6.2,106.8\n" +
+		"South,Jl. Selatan 2,abc,active,,\n"
+
+	rows, lineErrors, err := collect(parseCSV, file, func() interface{} { return &warehouse.RegisterRequest{} })
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, 2, rows[0].line)
+	north := rows[0].value.(*warehouse.RegisterRequest)
+	assert.Equal(t, "North", north.Name)
+	assert.Equal(t, 1, north.ShopId)
+	assert.Equal(t, -6.2, *north.Latitude)
+	assert.Contains(t, lineErrors, 3)
+}
+
+func TestParseCSV_UnitCost(t *testing.T) {
+	file := "product_id,warehouse_id,quantity,unit_cost\n" +
+		"1,2,10,12.345\n" +
+		"1,2,10,0.00001\n"
+
+	rows, lineErrors, err := collect(parseCSV, file, func() interface{} { return &bulk_import.OpeningBalanceRequest{} })
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, valuation.Money(123450), *rows[0].value.(*bulk_import.OpeningBalanceRequest).UnitCost)
+	assert.Contains(t, lineErrors, 3)
+}
+
+func TestParseNDJSON(t *testing.T) {
+	file := "{\"product_id\":1,\"warehouse_id\":2,\"available_stock\":10}\n" +
+		"\n" +
+		"{\"product_id\":\"x\"}\n"
+
+	rows, lineErrors, err := collect(parseNDJSON, file, func() interface{} { return &product_warehouse.RegisterRequest{} })
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, &product_warehouse.RegisterRequest{ProductId: 1, WarehouseId: 2, AvailableStock: 10}, rows[0].value)
+	assert.Contains(t, lineErrors, 3)
+}
+
+func TestImport_DryRun(t *testing.T) {
+	bulkImportUsecase := NewBulkImportUsecase(nil, nil, nil, nil, nil)
+	file := "product_id,warehouse_id,quantity,unit_cost\n" +
+		"1,2,10,1500\n" +
+		"1,0,5,\n" +
+		"2,2,-1,\n"
+
+	report, err := bulkImportUsecase.Import(context.Background(), entity.ImportOpeningBalances, entity.FormatCSV, strings.NewReader(file), true)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.TotalRows)
+	assert.Equal(t, 1, report.ValidRows)
+	assert.Equal(t, 0, report.AppliedRows)
+	assert.Equal(t, []bulk_import.LineError{
+		{Line: 3, Message: "warehouse_id is required"},
+		{Line: 4, Message: "quantity must be at least 0"},
+	}, report.Errors)
+}
+
+func TestImport_UnknownKind(t *testing.T) {
+	bulkImportUsecase := NewBulkImportUsecase(nil, nil, nil, nil, nil)
+
+	report, err := bulkImportUsecase.Import(context.Background(), "customers", entity.FormatCSV, strings.NewReader(""), true)
+
+	// Assertions
+	assert.Nil(t, report)
+	assert.ErrorIs(t, err, entity.ErrorUnknownImportKind)
+}
+
+func TestSortLineErrors(t *testing.T) {
+	sorted := sortLineErrors(map[int]string{5: "b", 2: "a"})
+
+	// Assertions
+	assert.Equal(t, []bulk_import.LineError{{Line: 2, Message: "a"}, {Line: 5, Message: "b"}}, sorted)
+}
+
+func TestImport_OpeningBalanceOverCapacity(t *testing.T) {
+	mockRepo := new(MockBulkImportRepository)
+	mockCapacity := new(MockCapacity)
+	db := mysqltest.NewDB()
+	bulkImportUsecase := NewBulkImportUsecase(mockRepo, mockCapacity, nil, nil, db.DB)
+	file := "product_id,warehouse_id,quantity\n" +
+		"1,2,50\n"
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetAvailableStockForUpdate", 1, 2).Return(10, true, nil)
+	mockCapacity.On("Check", 2, 1, 40).Return(entity.ErrorOverCapacity)
+
+	report, err := bulkImportUsecase.Import(context.Background(), entity.ImportOpeningBalances, entity.FormatCSV, strings.NewReader(file), false)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.AppliedRows)
+	assert.Equal(t, []bulk_import.LineError{{Line: 2, Message: entity.ErrorOverCapacity.Error()}}, report.Errors)
+	assert.Equal(t, 1, db.Rollbacks())
+	mockRepo.AssertNotCalled(t, "SetOpeningBalance", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImport_OpeningBalanceDecreaseTakesBinStock(t *testing.T) {
+	mockRepo := new(MockBulkImportRepository)
+	mockBins := new(MockBins)
+	mockValuation := new(MockValuation)
+	db := mysqltest.NewDB()
+	bulkImportUsecase := NewBulkImportUsecase(mockRepo, new(MockCapacity), mockBins, mockValuation, db.DB)
+	file := "product_id,warehouse_id,quantity\n" +
+		"1,2,4\n"
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetAvailableStockForUpdate", 1, 2).Return(10, true, nil)
+	mockRepo.On("SetOpeningBalance", 1, 2, 4).Return(-6, nil)
+	mockBins.On("TakeStock", 1, 2, 6).Return(nil)
+	mockValuation.On("Consume", entity.CostMovementDeduction, 1, 2, 6).Return(nil)
+
+	report, err := bulkImportUsecase.Import(context.Background(), entity.ImportOpeningBalances, entity.FormatCSV, strings.NewReader(file), false)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.AppliedRows)
+	assert.Equal(t, 1, db.Commits())
+	mockBins.AssertExpectations(t)
+	mockValuation.AssertExpectations(t)
+}
+
+func TestImport_ProductWarehouseOverCapacity(t *testing.T) {
+	mockRepo := new(MockBulkImportRepository)
+	mockCapacity := new(MockCapacity)
+	db := mysqltest.NewDB()
+	bulkImportUsecase := NewBulkImportUsecase(mockRepo, mockCapacity, nil, nil, db.DB)
+	file := "product_id,warehouse_id,available_stock\n" +
+		"1,2,50\n"
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetAvailableStockForUpdate", 1, 2).Return(0, false, nil)
+	mockCapacity.On("Check", 2, 1, 50).Return(entity.ErrorOverCapacity)
+
+	report, err := bulkImportUsecase.Import(context.Background(), entity.ImportProductWarehouses, entity.FormatCSV, strings.NewReader(file), false)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.AppliedRows)
+	assert.Equal(t, []bulk_import.LineError{{Line: 2, Message: entity.ErrorOverCapacity.Error()}}, report.Errors)
+	mockRepo.AssertNotCalled(t, "UpsertProductWarehouse", mock.Anything)
+}
+
+func TestImport_ProductWarehouseRegisteredIsNotChecked(t *testing.T) {
+	mockRepo := new(MockBulkImportRepository)
+	mockCapacity := new(MockCapacity)
+	db := mysqltest.NewDB()
+	bulkImportUsecase := NewBulkImportUsecase(mockRepo, mockCapacity, nil, nil, db.DB)
+	file := "product_id,warehouse_id,available_stock,safety_stock\n" +
+		"1,2,50,5\n"
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetAvailableStockForUpdate", 1, 2).Return(50, true, nil)
+	mockRepo.On("UpsertProductWarehouse", &product_warehouse.RegisterRequest{ProductId: 1, WarehouseId: 2, AvailableStock: 50, SafetyStock: 5}).Return(false, nil)
+
+	report, err := bulkImportUsecase.Import(context.Background(), entity.ImportProductWarehouses, entity.FormatCSV, strings.NewReader(file), false)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.AppliedRows)
+	mockCapacity.AssertNotCalled(t, "Check", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImport_ReadErrorIsReturned(t *testing.T) {
+	bulkImportUsecase := NewBulkImportUsecase(nil, nil, nil, nil, nil)
+	errRead := errors.New("body too large")
+	reader := io.MultiReader(strings.NewReader("product_id,warehouse_id,quantity\n1,2,50\n"), iotest.ErrReader(errRead))
+
+	report, err := bulkImportUsecase.Import(context.Background(), entity.ImportOpeningBalances, entity.FormatCSV, reader, true)
+
+	// Assertions
+	assert.Nil(t, report)
+	assert.ErrorIs(t, err, errRead)
+}
@@ -0,0 +1,162 @@
+package bulk_import
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// row is a decoded record and the line of the file it came from.
+type row struct {
+	line  int
+	value interface{}
+}
+
+// parseCSV decodes every record after the header into a new value from
+// newValue and hands it to handle as soon as it is read, so the file is never
+// held in memory. Columns are matched to the json tags of the value. The lines
+// that could not be decoded are returned with their error.
+func parseCSV(reader io.Reader, newValue func() interface{}, handle func(row row) error) (map[int]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	header = append([]string{}, header...)
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	lineErrors := make(map[int]string)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				lineErrors[parseErr.Line] = parseErr.Err.Error()
+				continue
+			}
+			return nil, err
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		value := newValue()
+		err = decodeRecord(header, record, value)
+		if err != nil {
+			lineErrors[line] = err.Error()
+			continue
+		}
+		err = handle(row{line: line, value: value})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lineErrors, nil
+}
+
+// parseNDJSON decodes one JSON object per line like parseCSV, blank lines are
+// skipped.
+func parseNDJSON(reader io.Reader, newValue func() interface{}, handle func(row row) error) (map[int]string, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineErrors := make(map[int]string)
+	line := 0
+	for scanner.Scan() {
+		line++
+		content := bytes.TrimSpace(scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+
+		value := newValue()
+		err := json.Unmarshal(content, value)
+		if err != nil {
+			lineErrors[line] = err.Error()
+			continue
+		}
+		err = handle(row{line: line, value: value})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lineErrors, scanner.Err()
+}
+
+// decodeRecord sets the fields of value from the CSV columns of the same json
+// name. Empty cells leave the field at its zero value.
+func decodeRecord(header []string, record []string, value interface{}) error {
+	fields := make(map[string]reflect.Value)
+	structValue := reflect.ValueOf(value).Elem()
+	for i := 0; i < structValue.NumField(); i++ {
+		name := strings.Split(structValue.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = structValue.Field(i)
+		}
+	}
+
+	for i, cell := range record {
+		if i >= len(header) {
+			return fmt.Errorf("row has more columns than the header")
+		}
+		field, ok := fields[header[i]]
+		cell = strings.TrimSpace(cell)
+		if !ok || cell == "" {
+			continue
+		}
+		err := setField(field, cell)
+		if err != nil {
+			return fmt.Errorf("%s: %v", header[i], err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, cell string) error {
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(cell))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(cell)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(cell)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", cell)
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", cell)
+		}
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(cell)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", cell)
+		}
+		field.SetBool(parsed)
+	default:
+		return fmt.Errorf("unsupported column type %s", field.Kind())
+	}
+	return nil
+}
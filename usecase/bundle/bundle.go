@@ -0,0 +1,281 @@
+package bundle
+
+import (
+	"context"
+	"warehouse-service/entity"
+	"warehouse-service/models/bundle"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type BundleRepository interface {
+	DeleteComponents(ctx context.Context, tx *sqlx.Tx, bundleProductId int) error
+	InsertComponent(ctx context.Context, tx *sqlx.Tx, component *bundle.BundleComponent) error
+	GetComponentsByBundleIds(ctx context.Context, bundleProductIds []int) ([]bundle.BundleComponent, error)
+	GetComponentStocks(ctx context.Context, productIds []int, shopId int) ([]bundle.ComponentStock, error)
+}
+
+type UnitConverter interface {
+	FromBase(ctx context.Context, productId int, unit string, quantity int) (int, error)
+}
+
+type BundleUsecase struct {
+	bundleRepo    BundleRepository
+	unitConverter UnitConverter
+	mysql         *sqlx.DB
+}
+
+func NewBundleUsecase(bundleRepo BundleRepository, unitConverter UnitConverter, mysql *sqlx.DB) *BundleUsecase {
+	return &BundleUsecase{
+		bundleRepo:    bundleRepo,
+		unitConverter: unitConverter,
+		mysql:         mysql,
+	}
+}
+
+// Register replaces the components of a bundle. Components must be plain
+// products, bundles of bundles are not supported.
+func (b *BundleUsecase) Register(ctx context.Context, bundleRegister *bundle.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "BundleUsecase.Register")
+	defer func() { tracing.End(span, err) }()
+
+	componentIds := []int{}
+	for _, component := range bundleRegister.Components {
+		if component.ProductId == bundleRegister.BundleProductId {
+			return entity.ErrorInvalidBundle
+		}
+		componentIds = append(componentIds, component.ProductId)
+	}
+	nestedComponents, err := b.bundleRepo.GetComponentsByBundleIds(ctx, componentIds)
+	if err != nil {
+		return err
+	}
+	if len(nestedComponents) > 0 {
+		return entity.ErrorInvalidBundle
+	}
+
+	tx, err := b.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = b.bundleRepo.DeleteComponents(ctx, tx, bundleRegister.BundleProductId)
+	if err != nil {
+		return err
+	}
+	for _, component := range bundleRegister.Components {
+		err = b.bundleRepo.InsertComponent(ctx, tx, &bundle.BundleComponent{
+			BundleProductId: bundleRegister.BundleProductId,
+			ProductId:       component.ProductId,
+			Quantity:        component.Quantity,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *BundleUsecase) GetComponents(ctx context.Context, bundleProductId int) (_ []bundle.BundleComponent, err error) {
+	ctx, span := tracing.Start(ctx, "BundleUsecase.GetComponents")
+	defer func() { tracing.End(span, err) }()
+
+	return b.bundleRepo.GetComponentsByBundleIds(ctx, []int{bundleProductId})
+}
+
+// ExplodeBundles replaces bundle lines with their component lines and merges
+// lines of the same product, so availability is checked on the total demand.
+// The bundle lines are returned as well, so each bundle can be allocated with
+// all its components from one warehouse.
+func (b *BundleUsecase) ExplodeBundles(ctx context.Context, operations []product_warehouse.StockOperationRequest) (_ []product_warehouse.StockOperationRequest, _ []product_warehouse.BundleOperation, err error) {
+	ctx, span := tracing.Start(ctx, "BundleUsecase.ExplodeBundles")
+	defer func() { tracing.End(span, err) }()
+
+	productIds := []int{}
+	for _, operation := range operations {
+		productIds = append(productIds, operation.ProductId)
+	}
+	components, err := b.bundleRepo.GetComponentsByBundleIds(ctx, productIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(components) == 0 {
+		return operations, nil, nil
+	}
+
+	componentsByBundle := groupComponents(components)
+	exploded := []product_warehouse.StockOperationRequest{}
+	bundleOperations := []product_warehouse.BundleOperation{}
+	index := make(map[int]int)
+	addLine := func(operation product_warehouse.StockOperationRequest) {
+		if i, ok := index[operation.ProductId]; ok {
+			exploded[i].Quantity += operation.Quantity
+			return
+		}
+		index[operation.ProductId] = len(exploded)
+		exploded = append(exploded, operation)
+	}
+
+	for _, operation := range operations {
+		bundleComponents, ok := componentsByBundle[operation.ProductId]
+		if !ok {
+			addLine(operation)
+			continue
+		}
+		bundleOperation := product_warehouse.BundleOperation{
+			BundleProductId: operation.ProductId,
+			Quantity:        operation.Quantity,
+		}
+		for _, component := range bundleComponents {
+			addLine(product_warehouse.StockOperationRequest{
+				ProductId:   component.ProductId,
+				WarehouseId: operation.WarehouseId,
+				Quantity:    operation.Quantity * component.Quantity,
+			})
+			bundleOperation.Components = append(bundleOperation.Components, product_warehouse.StockOperationRequest{
+				ProductId: component.ProductId,
+				Quantity:  component.Quantity,
+			})
+		}
+		bundleOperations = append(bundleOperations, bundleOperation)
+	}
+	return exploded, bundleOperations, nil
+}
+
+// GetAvailableStockBulk returns the available stock of the requested products
+// that are bundles. Products that are not bundles are left out.
+func (b *BundleUsecase) GetAvailableStockBulk(ctx context.Context, productShops []product_warehouse.ProductShop) (_ map[int]int, err error) {
+	ctx, span := tracing.Start(ctx, "BundleUsecase.GetAvailableStockBulk")
+	defer func() { tracing.End(span, err) }()
+
+	productIds := []int{}
+	for _, productShop := range productShops {
+		productIds = append(productIds, productShop.ProductId)
+	}
+	components, err := b.bundleRepo.GetComponentsByBundleIds(ctx, productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	stockMap := make(map[int]int)
+	componentsByBundle := groupComponents(components)
+	for _, productShop := range productShops {
+		bundleComponents, ok := componentsByBundle[productShop.ProductId]
+		if !ok {
+			continue
+		}
+		availability, err := b.getAvailability(ctx, productShop, bundleComponents)
+		if err != nil {
+			return nil, err
+		}
+		stockMap[productShop.ProductId] = availability.AvailableStock
+	}
+	return stockMap, nil
+}
+
+func (b *BundleUsecase) GetAvailability(ctx context.Context, productShop *product_warehouse.ProductShop) (_ *bundle.Availability, err error) {
+	ctx, span := tracing.Start(ctx, "BundleUsecase.GetAvailability")
+	defer func() { tracing.End(span, err) }()
+
+	components, err := b.bundleRepo.GetComponentsByBundleIds(ctx, []int{productShop.ProductId})
+	if err != nil {
+		return nil, err
+	}
+	if len(components) == 0 {
+		return nil, entity.ErrorInvalidBundle
+	}
+	availability, err := b.getAvailability(ctx, *productShop, components)
+	if err != nil {
+		return nil, err
+	}
+	if productShop.Unit == "" {
+		return availability, nil
+	}
+
+	// warehouses are converted on their own, so they may not add up to the total
+	availability.AvailableStock, err = b.unitConverter.FromBase(ctx, productShop.ProductId, productShop.Unit, availability.AvailableStock)
+	if err != nil {
+		return nil, err
+	}
+	for i := range availability.Warehouses {
+		availability.Warehouses[i].AvailableStock, err = b.unitConverter.FromBase(ctx, productShop.ProductId, productShop.Unit, availability.Warehouses[i].AvailableStock)
+		if err != nil {
+			return nil, err
+		}
+	}
+	availability.Unit = productShop.Unit
+	return availability, nil
+}
+
+func (b *BundleUsecase) getAvailability(ctx context.Context, productShop product_warehouse.ProductShop, components []bundle.BundleComponent) (*bundle.Availability, error) {
+	componentIds := []int{}
+	for _, component := range components {
+		componentIds = append(componentIds, component.ProductId)
+	}
+	componentStocks, err := b.bundleRepo.GetComponentStocks(ctx, componentIds, productShop.ShopId)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := &bundle.Availability{
+		BundleProductId: productShop.ProductId,
+		ShopId:          productShop.ShopId,
+		Warehouses:      bundleStockPerWarehouse(components, componentStocks),
+	}
+	for _, warehouse := range availability.Warehouses {
+		availability.AvailableStock += warehouse.AvailableStock
+	}
+	return availability, nil
+}
+
+// bundleStockPerWarehouse computes how many complete bundles each warehouse
+// can ship, which is limited by the scarcest component in that warehouse.
+func bundleStockPerWarehouse(components []bundle.BundleComponent, componentStocks []bundle.ComponentStock) []bundle.WarehouseAvailability {
+	warehouseIds := []int{}
+	stocks := make(map[int]map[int]int)
+	for _, componentStock := range componentStocks {
+		if _, ok := stocks[componentStock.WarehouseId]; !ok {
+			warehouseIds = append(warehouseIds, componentStock.WarehouseId)
+			stocks[componentStock.WarehouseId] = make(map[int]int)
+		}
+		stocks[componentStock.WarehouseId][componentStock.ProductId] += componentStock.AvailableStock
+	}
+
+	warehouses := []bundle.WarehouseAvailability{}
+	for _, warehouseId := range warehouseIds {
+		bundleStock := -1
+		for _, component := range components {
+			componentBundles := stocks[warehouseId][component.ProductId] / component.Quantity
+			if bundleStock == -1 || componentBundles < bundleStock {
+				bundleStock = componentBundles
+			}
+		}
+		warehouses = append(warehouses, bundle.WarehouseAvailability{
+			WarehouseId:    warehouseId,
+			AvailableStock: max(bundleStock, 0),
+		})
+	}
+	return warehouses
+}
+
+func groupComponents(components []bundle.BundleComponent) map[int][]bundle.BundleComponent {
+	componentsByBundle := make(map[int][]bundle.BundleComponent)
+	for _, component := range components {
+		componentsByBundle[component.BundleProductId] = append(componentsByBundle[component.BundleProductId], component)
+	}
+	return componentsByBundle
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
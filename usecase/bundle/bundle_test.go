@@ -0,0 +1,136 @@
+package bundle
+
+import (
+	"context"
+	"testing"
+	"warehouse-service/models/bundle"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockBundleRepository struct {
+	mock.Mock
+}
+
+func (m *MockBundleRepository) DeleteComponents(ctx context.Context, tx *sqlx.Tx, bundleProductId int) error {
+	args := m.Called(tx, bundleProductId)
+	return args.Error(0)
+}
+
+func (m *MockBundleRepository) InsertComponent(ctx context.Context, tx *sqlx.Tx, component *bundle.BundleComponent) error {
+	args := m.Called(tx, component)
+	return args.Error(0)
+}
+
+func (m *MockBundleRepository) GetComponentsByBundleIds(ctx context.Context, bundleProductIds []int) ([]bundle.BundleComponent, error) {
+	args := m.Called(bundleProductIds)
+	return args.Get(0).([]bundle.BundleComponent), args.Error(1)
+}
+
+func (m *MockBundleRepository) GetComponentStocks(ctx context.Context, productIds []int, shopId int) ([]bundle.ComponentStock, error) {
+	args := m.Called(productIds, shopId)
+	return args.Get(0).([]bundle.ComponentStock), args.Error(1)
+}
+
+// gift set = 2x product 1 + 1x product 2
+var giftSet = []bundle.BundleComponent{
+	{BundleProductId: 100, ProductId: 1, Quantity: 2},
+	{BundleProductId: 100, ProductId: 2, Quantity: 1},
+}
+
+func TestExplodeBundles(t *testing.T) {
+	mockRepo := new(MockBundleRepository)
+	bundleUsecase := NewBundleUsecase(mockRepo, nil, nil)
+
+	mockRepo.On("GetComponentsByBundleIds", []int{100, 1}).Return(giftSet, nil)
+
+	operations, bundleOperations, err := bundleUsecase.ExplodeBundles(context.Background(), []product_warehouse.StockOperationRequest{
+		{ProductId: 100, Quantity: 3},
+		{ProductId: 1, Quantity: 1},
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 7},
+		{ProductId: 2, Quantity: 3},
+	}, operations)
+	assert.Equal(t, []product_warehouse.BundleOperation{
+		{BundleProductId: 100, Quantity: 3, Components: []product_warehouse.StockOperationRequest{
+			{ProductId: 1, Quantity: 2},
+			{ProductId: 2, Quantity: 1},
+		}},
+	}, bundleOperations)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExplodeBundles_NoBundle(t *testing.T) {
+	mockRepo := new(MockBundleRepository)
+	bundleUsecase := NewBundleUsecase(mockRepo, nil, nil)
+
+	request := []product_warehouse.StockOperationRequest{{ProductId: 1, Quantity: 1}}
+	mockRepo.On("GetComponentsByBundleIds", []int{1}).Return([]bundle.BundleComponent{}, nil)
+
+	operations, bundleOperations, err := bundleUsecase.ExplodeBundles(context.Background(), request)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, request, operations)
+	assert.Empty(t, bundleOperations)
+}
+
+func TestGetAvailability(t *testing.T) {
+	mockRepo := new(MockBundleRepository)
+	bundleUsecase := NewBundleUsecase(mockRepo, nil, nil)
+
+	mockRepo.On("GetComponentsByBundleIds", []int{100}).Return(giftSet, nil)
+	mockRepo.On("GetComponentStocks", []int{1, 2}, 9).Return([]bundle.ComponentStock{
+		{ProductId: 1, WarehouseId: 10, AvailableStock: 7},
+		{ProductId: 2, WarehouseId: 10, AvailableStock: 5},
+		{ProductId: 1, WarehouseId: 20, AvailableStock: 10},
+		{ProductId: 2, WarehouseId: 20, AvailableStock: 1},
+		{ProductId: 1, WarehouseId: 30, AvailableStock: 50},
+	}, nil)
+
+	availability, err := bundleUsecase.GetAvailability(context.Background(), &product_warehouse.ProductShop{ProductId: 100, ShopId: 9})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []bundle.WarehouseAvailability{
+		{WarehouseId: 10, AvailableStock: 3},
+		{WarehouseId: 20, AvailableStock: 1},
+		{WarehouseId: 30, AvailableStock: 0},
+	}, availability.Warehouses)
+	assert.Equal(t, 4, availability.AvailableStock)
+	mockRepo.AssertExpectations(t)
+}
+
+// caseUnit converts between bundles and cases of 2
+type caseUnit struct{}
+
+func (caseUnit) FromBase(ctx context.Context, productId int, unit string, quantity int) (int, error) {
+	return quantity / 2, nil
+}
+
+func TestGetAvailability_InUnit(t *testing.T) {
+	mockRepo := new(MockBundleRepository)
+	bundleUsecase := NewBundleUsecase(mockRepo, caseUnit{}, nil)
+
+	mockRepo.On("GetComponentsByBundleIds", []int{100}).Return(giftSet, nil)
+	mockRepo.On("GetComponentStocks", []int{1, 2}, 9).Return([]bundle.ComponentStock{
+		{ProductId: 1, WarehouseId: 10, AvailableStock: 10},
+		{ProductId: 2, WarehouseId: 10, AvailableStock: 5},
+	}, nil)
+
+	availability, err := bundleUsecase.GetAvailability(context.Background(), &product_warehouse.ProductShop{ProductId: 100, ShopId: 9, Unit: "case"})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "case", availability.Unit)
+	assert.Equal(t, 2, availability.AvailableStock)
+	assert.Equal(t, []bundle.WarehouseAvailability{{WarehouseId: 10, AvailableStock: 2}}, availability.Warehouses)
+}
@@ -0,0 +1,202 @@
+package channel
+
+import (
+	"context"
+	"warehouse-service/entity"
+	"warehouse-service/models/channel"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
+)
+
+type ChannelRepository interface {
+	Upsert(ctx context.Context, allocation *channel.RegisterRequest) error
+	Delete(ctx context.Context, shopId int, productId int, channelName string) error
+	GetByProductIds(ctx context.Context, productIds []int) ([]channel.ChannelAllocation, error)
+	GetReservedByProductIds(ctx context.Context, productIds []int) ([]channel.ChannelReservation, error)
+}
+
+type StockRepository interface {
+	GetAvailableStockBulk(ctx context.Context, availableStockRequest []product_warehouse.ProductShop) (map[int]int, error)
+}
+
+type ChannelUsecase struct {
+	channelRepo ChannelRepository
+	stockRepo   StockRepository
+}
+
+func NewChannelUsecase(channelRepo ChannelRepository, stockRepo StockRepository) *ChannelUsecase {
+	return &ChannelUsecase{
+		channelRepo: channelRepo,
+		stockRepo:   stockRepo,
+	}
+}
+
+func (c *ChannelUsecase) Register(ctx context.Context, allocation *channel.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ChannelUsecase.Register")
+	defer func() { tracing.End(span, err) }()
+
+	if allocation.AllocationType == entity.ChannelAllocationPercentage && allocation.Value > 100 {
+		return entity.ErrorInvalidAllocation
+	}
+	return c.channelRepo.Upsert(ctx, allocation)
+}
+
+func (c *ChannelUsecase) Remove(ctx context.Context, allocation *channel.RemoveRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ChannelUsecase.Remove")
+	defer func() { tracing.End(span, err) }()
+
+	return c.channelRepo.Delete(ctx, allocation.ShopId, allocation.ProductId, allocation.Channel)
+}
+
+// AvailableForChannel narrows the shop's available stock of a product down to
+// what the channel may sell.
+func (c *ChannelUsecase) AvailableForChannel(ctx context.Context, productId int, shopId int, channelName string, availableStock int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "ChannelUsecase.AvailableForChannel")
+	defer func() { tracing.End(span, err) }()
+
+	allocations, err := c.channelRepo.GetByProductIds(ctx, []int{productId})
+	if err != nil {
+		return 0, err
+	}
+	allocations = filterAllocations(allocations, productId, shopId)
+	if len(allocations) == 0 {
+		return availableStock, nil
+	}
+	reservations, err := c.channelRepo.GetReservedByProductIds(ctx, []int{productId})
+	if err != nil {
+		return 0, err
+	}
+
+	pools, sharedStock := splitPools(allocations, reservedByChannel(reservations, productId, shopId), availableStock)
+	for _, allocation := range allocations {
+		if allocation.Channel != channelName {
+			continue
+		}
+		if allocation.AllowSharedFallback {
+			return pools[channelName] + sharedStock, nil
+		}
+		return pools[channelName], nil
+	}
+	return sharedStock, nil
+}
+
+func (c *ChannelUsecase) GetAvailabilityBulk(ctx context.Context, productShops []product_warehouse.ProductShop) (_ []channel.ProductAvailability, err error) {
+	ctx, span := tracing.Start(ctx, "ChannelUsecase.GetAvailabilityBulk")
+	defer func() { tracing.End(span, err) }()
+
+	stockMap, err := c.stockRepo.GetAvailableStockBulk(ctx, productShops)
+	if err != nil {
+		return nil, err
+	}
+
+	productIds := []int{}
+	for _, productShop := range productShops {
+		productIds = append(productIds, productShop.ProductId)
+	}
+	allAllocations, err := c.channelRepo.GetByProductIds(ctx, productIds)
+	if err != nil {
+		return nil, err
+	}
+	allReservations, err := c.channelRepo.GetReservedByProductIds(ctx, productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	productAvailabilities := []channel.ProductAvailability{}
+	for _, productShop := range productShops {
+		availableStock := stockMap[productShop.ProductId]
+		allocations := filterAllocations(allAllocations, productShop.ProductId, productShop.ShopId)
+		reserved := reservedByChannel(allReservations, productShop.ProductId, productShop.ShopId)
+		pools, sharedStock := splitPools(allocations, reserved, availableStock)
+
+		productAvailability := channel.ProductAvailability{
+			ProductId:      productShop.ProductId,
+			ShopId:         productShop.ShopId,
+			AvailableStock: availableStock,
+			SharedStock:    sharedStock,
+			Channels:       []channel.ChannelAvailability{},
+		}
+		for _, allocation := range allocations {
+			channelAvailability := channel.ChannelAvailability{
+				Channel:        allocation.Channel,
+				Pool:           pools[allocation.Channel],
+				AvailableStock: pools[allocation.Channel],
+			}
+			if allocation.AllowSharedFallback {
+				channelAvailability.AvailableStock += sharedStock
+			}
+			productAvailability.Channels = append(productAvailability.Channels, channelAvailability)
+		}
+		productAvailabilities = append(productAvailabilities, productAvailability)
+	}
+	return productAvailabilities, nil
+}
+
+// splitPools carves the channel pools out of the stock in channel order and
+// returns what is left of each pool and of the shared pool. The pools are
+// sized on the available stock plus what the channels' open orders hold, and
+// those orders run their own pool down, so a pool refills only when its
+// orders ship or are returned. Fixed pools take up to their quantity,
+// percentage pools take their share, and whatever available stock is not
+// left in a pool is the shared pool.
+func splitPools(allocations []channel.ChannelAllocation, reserved map[string]int, availableStock int) (map[string]int, int) {
+	availableStock = max(availableStock, 0)
+	stock := availableStock
+	for _, allocation := range allocations {
+		stock += reserved[allocation.Channel]
+	}
+
+	pools := make(map[string]int)
+	unallocated := stock
+	sharedStock := availableStock
+	for _, allocation := range allocations {
+		pool := allocation.Value
+		if allocation.AllocationType == entity.ChannelAllocationPercentage {
+			pool = stock * allocation.Value / 100
+		}
+		pool = min(pool, unallocated)
+		unallocated -= pool
+
+		// a pool never holds more than the stock still available
+		left := min(max(pool-reserved[allocation.Channel], 0), sharedStock)
+		pools[allocation.Channel] = left
+		sharedStock -= left
+	}
+	return pools, sharedStock
+}
+
+// reservedByChannel sums the open channel reservations of a product in a shop.
+func reservedByChannel(reservations []channel.ChannelReservation, productId int, shopId int) map[string]int {
+	reserved := make(map[string]int)
+	for _, reservation := range reservations {
+		if reservation.ProductId == productId && reservation.ShopId == shopId {
+			reserved[reservation.Channel] += reservation.ReservedStock
+		}
+	}
+	return reserved
+}
+
+func filterAllocations(allocations []channel.ChannelAllocation, productId int, shopId int) []channel.ChannelAllocation {
+	filtered := []channel.ChannelAllocation{}
+	for _, allocation := range allocations {
+		if allocation.ProductId != productId || allocation.ShopId != shopId {
+			continue
+		}
+		filtered = append(filtered, allocation)
+	}
+	return filtered
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,142 @@
+package channel
+
+import (
+	"context"
+	"testing"
+	"warehouse-service/entity"
+	"warehouse-service/models/channel"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockChannelRepository struct {
+	mock.Mock
+}
+
+func (m *MockChannelRepository) Upsert(ctx context.Context, allocation *channel.RegisterRequest) error {
+	args := m.Called(allocation)
+	return args.Error(0)
+}
+
+func (m *MockChannelRepository) Delete(ctx context.Context, shopId int, productId int, channelName string) error {
+	args := m.Called(shopId, productId, channelName)
+	return args.Error(0)
+}
+
+func (m *MockChannelRepository) GetByProductIds(ctx context.Context, productIds []int) ([]channel.ChannelAllocation, error) {
+	args := m.Called(productIds)
+	return args.Get(0).([]channel.ChannelAllocation), args.Error(1)
+}
+
+func (m *MockChannelRepository) GetReservedByProductIds(ctx context.Context, productIds []int) ([]channel.ChannelReservation, error) {
+	args := m.Called(productIds)
+	return args.Get(0).([]channel.ChannelReservation), args.Error(1)
+}
+
+type MockStockRepository struct {
+	mock.Mock
+}
+
+func (m *MockStockRepository) GetAvailableStockBulk(ctx context.Context, availableStockRequest []product_warehouse.ProductShop) (map[int]int, error) {
+	args := m.Called(availableStockRequest)
+	return args.Get(0).(map[int]int), args.Error(1)
+}
+
+var allocations = []channel.ChannelAllocation{
+	{ShopId: 1, ProductId: 1, Channel: "marketplace", AllocationType: entity.ChannelAllocationFixed, Value: 30},
+	{ShopId: 1, ProductId: 1, Channel: "web", AllocationType: entity.ChannelAllocationPercentage, Value: 20, AllowSharedFallback: true},
+}
+
+func TestAvailableForChannel(t *testing.T) {
+	mockRepo := new(MockChannelRepository)
+	channelUsecase := NewChannelUsecase(mockRepo, new(MockStockRepository))
+
+	mockRepo.On("GetByProductIds", []int{1}).Return(allocations, nil)
+	mockRepo.On("GetReservedByProductIds", []int{1}).Return([]channel.ChannelReservation{}, nil)
+
+	marketplace, err := channelUsecase.AvailableForChannel(context.Background(), 1, 1, "marketplace", 100)
+	assert.NoError(t, err)
+	web, err := channelUsecase.AvailableForChannel(context.Background(), 1, 1, "web", 100)
+	assert.NoError(t, err)
+	pos, err := channelUsecase.AvailableForChannel(context.Background(), 1, 1, "pos", 100)
+	assert.NoError(t, err)
+
+	// Assertions
+	assert.Equal(t, 30, marketplace)
+	assert.Equal(t, 70, web)
+	assert.Equal(t, 50, pos)
+}
+
+func TestAvailableForChannel_NoAllocation(t *testing.T) {
+	mockRepo := new(MockChannelRepository)
+	channelUsecase := NewChannelUsecase(mockRepo, new(MockStockRepository))
+
+	mockRepo.On("GetByProductIds", []int{2}).Return(allocations, nil)
+
+	availableStock, err := channelUsecase.AvailableForChannel(context.Background(), 2, 1, "web", 100)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 100, availableStock)
+}
+
+func TestSplitPools_FixedExceedsStock(t *testing.T) {
+	pools, sharedStock := splitPools(allocations, nil, 20)
+
+	// Assertions
+	assert.Equal(t, 20, pools["marketplace"])
+	assert.Equal(t, 0, pools["web"])
+	assert.Equal(t, 0, sharedStock)
+}
+
+func TestAvailableForChannel_PoolRunsDown(t *testing.T) {
+	mockRepo := new(MockChannelRepository)
+	channelUsecase := NewChannelUsecase(mockRepo, new(MockStockRepository))
+
+	// marketplace orders hold 25 of its 30, web orders took 40 past their pool
+	mockRepo.On("GetByProductIds", []int{1}).Return(allocations, nil)
+	mockRepo.On("GetReservedByProductIds", []int{1}).Return([]channel.ChannelReservation{
+		{ShopId: 1, ProductId: 1, Channel: "marketplace", ReservedStock: 25},
+		{ShopId: 1, ProductId: 1, Channel: "web", ReservedStock: 40},
+		{ShopId: 2, ProductId: 1, Channel: "marketplace", ReservedStock: 500},
+	}, nil)
+
+	marketplace, err := channelUsecase.AvailableForChannel(context.Background(), 1, 1, "marketplace", 35)
+	assert.NoError(t, err)
+	web, err := channelUsecase.AvailableForChannel(context.Background(), 1, 1, "web", 35)
+	assert.NoError(t, err)
+	pos, err := channelUsecase.AvailableForChannel(context.Background(), 1, 1, "pos", 35)
+	assert.NoError(t, err)
+
+	// Assertions
+	assert.Equal(t, 5, marketplace)
+	assert.Equal(t, 30, web)
+	assert.Equal(t, 30, pos)
+}
+
+func TestAvailableForChannel_OtherShop(t *testing.T) {
+	mockRepo := new(MockChannelRepository)
+	channelUsecase := NewChannelUsecase(mockRepo, new(MockStockRepository))
+
+	mockRepo.On("GetByProductIds", []int{1}).Return(allocations, nil)
+
+	availableStock, err := channelUsecase.AvailableForChannel(context.Background(), 1, 2, "pos", 100)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 100, availableStock)
+}
+
+func TestRegister_PercentageOver100(t *testing.T) {
+	mockRepo := new(MockChannelRepository)
+	channelUsecase := NewChannelUsecase(mockRepo, new(MockStockRepository))
+
+	err := channelUsecase.Register(context.Background(), &channel.RegisterRequest{ShopId: 1, ProductId: 1, Channel: "web", AllocationType: entity.ChannelAllocationPercentage, Value: 120})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInvalidAllocation)
+	mockRepo.AssertNotCalled(t, "Upsert", mock.Anything)
+}
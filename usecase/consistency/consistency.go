@@ -0,0 +1,242 @@
+package consistency
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/consistency"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ConsistencyRepository interface {
+	GetReservationStates(ctx context.Context) ([]consistency.ReservationState, error)
+	GetReservationStateForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) (*consistency.ReservationState, error)
+	GetNegativeOrderReservations(ctx context.Context) ([]consistency.OrderReservation, error)
+	GetOrphanedReservations(ctx context.Context) ([]consistency.OrderReservation, error)
+	CorrectStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, availableDelta int, reservedDelta int) error
+}
+
+type ProductWarehouseRepository interface {
+	ClearOrderWarehouse(ctx context.Context, tx *sqlx.Tx, id int) (int, error)
+}
+
+type ConsistencyUsecase struct {
+	consistencyRepo      ConsistencyRepository
+	productWarehouseRepo ProductWarehouseRepository
+	mysql                *sqlx.DB
+	logger               *slog.Logger
+}
+
+func NewConsistencyUsecase(consistencyRepo ConsistencyRepository, productWarehouseRepo ProductWarehouseRepository, mysql *sqlx.DB, logger *slog.Logger) *ConsistencyUsecase {
+	return &ConsistencyUsecase{
+		consistencyRepo:      consistencyRepo,
+		productWarehouseRepo: productWarehouseRepo,
+		mysql:                mysql,
+		logger:               logger,
+	}
+}
+
+// Check compares reserved_stock with the reservations behind it and looks for
+// negative counters and reservations stuck in inactive warehouses. With
+// repair, orphaned reservations are released and reserved_stock is corrected
+// to match its reservations, each through a ledger entry. Negative counters
+// are only reported, there is no stock to correct them with.
+func (c *ConsistencyUsecase) Check(ctx context.Context, repair bool) (_ *consistency.Report, err error) {
+	ctx, span := tracing.Start(ctx, "ConsistencyUsecase.Check")
+	defer func() { tracing.End(span, err) }()
+
+	report := &consistency.Report{
+		CheckedAt: time.Now(),
+		Repair:    repair,
+		Findings:  []consistency.Finding{},
+	}
+
+	orphanedReservations, err := c.consistencyRepo.GetOrphanedReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orphanedFindings := orderReservationFindings(entity.FindingOrphanedReservation, orphanedReservations)
+	for i := range orphanedFindings {
+		if !repair {
+			continue
+		}
+		err = c.releaseOrphanedReservation(ctx, &orphanedFindings[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	report.Findings = append(report.Findings, orphanedFindings...)
+
+	// read after the orphans are released, they change reserved_stock
+	reservationStates, err := c.consistencyRepo.GetReservationStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stateFindings := checkReservationStates(reservationStates)
+	for i := range stateFindings {
+		if !repair || stateFindings[i].Type != entity.FindingReservedMismatch {
+			continue
+		}
+		err = c.repairReservedMismatch(ctx, &stateFindings[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	report.Findings = append(report.Findings, stateFindings...)
+
+	negativeReservations, err := c.consistencyRepo.GetNegativeOrderReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Findings = append(report.Findings, orderReservationFindings(entity.FindingNegativeOrderReservation, negativeReservations)...)
+	return report, nil
+}
+
+// RunJob checks on every tick until ctx is done and logs the findings, it
+// never repairs.
+func (c *ConsistencyUsecase) RunJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		report, err := c.Check(ctx, false)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "failed to check stock consistency", "error", err)
+			continue
+		}
+		for _, finding := range report.Findings {
+			c.logger.WarnContext(ctx, "stock consistency finding", "finding", finding)
+		}
+	}
+}
+
+func (c *ConsistencyUsecase) releaseOrphanedReservation(ctx context.Context, finding *consistency.Finding) error {
+	tx, err := c.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	reservedStock, err := c.productWarehouseRepo.ClearOrderWarehouse(ctx, tx, finding.OrderWarehouseId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if reservedStock > 0 {
+		err = c.consistencyRepo.CorrectStock(ctx, tx, finding.ProductId, finding.WarehouseId, reservedStock, -reservedStock)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	finding.Repaired = true
+	return nil
+}
+
+// repairReservedMismatch checks the product-warehouse again under lock, since
+// it may have changed after the report was read, and moves the difference
+// between reserved and available stock.
+func (c *ConsistencyUsecase) repairReservedMismatch(ctx context.Context, finding *consistency.Finding) error {
+	tx, err := c.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	reservationState, err := c.consistencyRepo.GetReservationStateForUpdate(ctx, tx, finding.ProductId, finding.WarehouseId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	excess := reservationState.ReservedStock - expectedReservedStock(*reservationState)
+	if excess != 0 {
+		err = c.consistencyRepo.CorrectStock(ctx, tx, finding.ProductId, finding.WarehouseId, excess, -excess)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	finding.Repaired = true
+	return nil
+}
+
+// expectedReservedStock is what reserved_stock should hold: the order
+// reservations plus the flash sale slots carved out of available stock.
+func expectedReservedStock(reservationState consistency.ReservationState) int {
+	return reservationState.OrderReservedStock + reservationState.SlotStock
+}
+
+func checkReservationStates(reservationStates []consistency.ReservationState) []consistency.Finding {
+	findings := []consistency.Finding{}
+	for _, reservationState := range reservationStates {
+		expected := expectedReservedStock(reservationState)
+		if reservationState.ReservedStock != expected {
+			findings = append(findings, consistency.Finding{
+				Type:        entity.FindingReservedMismatch,
+				ProductId:   reservationState.ProductId,
+				WarehouseId: reservationState.WarehouseId,
+				Expected:    expected,
+				Actual:      reservationState.ReservedStock,
+				Detail:      fmt.Sprintf("reserved_stock is %d, order reservations hold %d and flash sale slots %d", reservationState.ReservedStock, reservationState.OrderReservedStock, reservationState.SlotStock),
+			})
+		}
+		if reservationState.AvailableStock < 0 {
+			findings = append(findings, consistency.Finding{
+				Type:        entity.FindingNegativeAvailableStock,
+				ProductId:   reservationState.ProductId,
+				WarehouseId: reservationState.WarehouseId,
+				Actual:      reservationState.AvailableStock,
+				Detail:      "available_stock is negative",
+			})
+		}
+		if reservationState.ReservedStock < 0 {
+			findings = append(findings, consistency.Finding{
+				Type:        entity.FindingNegativeReservedStock,
+				ProductId:   reservationState.ProductId,
+				WarehouseId: reservationState.WarehouseId,
+				Expected:    expected,
+				Actual:      reservationState.ReservedStock,
+				Detail:      "reserved_stock is negative",
+			})
+		}
+	}
+	return findings
+}
+
+func orderReservationFindings(findingType string, orderReservations []consistency.OrderReservation) []consistency.Finding {
+	findings := []consistency.Finding{}
+	for _, orderReservation := range orderReservations {
+		finding := consistency.Finding{
+			Type:             findingType,
+			ProductId:        orderReservation.ProductId,
+			WarehouseId:      orderReservation.WarehouseId,
+			OrderId:          orderReservation.OrderId,
+			OrderWarehouseId: orderReservation.Id,
+			Actual:           orderReservation.ReservedStock,
+		}
+		if findingType == entity.FindingOrphanedReservation {
+			finding.Detail = fmt.Sprintf("warehouse is %s", orderReservation.Status)
+		} else {
+			finding.Detail = "order reservation is negative"
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
@@ -0,0 +1,115 @@
+package consistency
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/entity"
+	"warehouse-service/models/consistency"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockConsistencyRepository struct {
+	ConsistencyRepository
+	mock.Mock
+}
+
+func (m *MockConsistencyRepository) GetReservationStates(ctx context.Context) ([]consistency.ReservationState, error) {
+	args := m.Called()
+	return args.Get(0).([]consistency.ReservationState), args.Error(1)
+}
+
+func (m *MockConsistencyRepository) GetNegativeOrderReservations(ctx context.Context) ([]consistency.OrderReservation, error) {
+	args := m.Called()
+	return args.Get(0).([]consistency.OrderReservation), args.Error(1)
+}
+
+func (m *MockConsistencyRepository) GetOrphanedReservations(ctx context.Context) ([]consistency.OrderReservation, error) {
+	args := m.Called()
+	return args.Get(0).([]consistency.OrderReservation), args.Error(1)
+}
+
+func (m *MockConsistencyRepository) CorrectStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, availableDelta int, reservedDelta int) error {
+	args := m.Called(productId, warehouseId, availableDelta, reservedDelta)
+	return args.Error(0)
+}
+
+type MockProductWarehouseRepository struct {
+	mock.Mock
+}
+
+func (m *MockProductWarehouseRepository) ClearOrderWarehouse(ctx context.Context, tx *sqlx.Tx, id int) (int, error) {
+	args := m.Called(id)
+	return args.Int(0), args.Error(1)
+}
+
+func TestCheckReservationStates(t *testing.T) {
+	reservationStates := []consistency.ReservationState{
+		{ProductId: 1, WarehouseId: 1, AvailableStock: 10, ReservedStock: 5, OrderReservedStock: 3, SlotStock: 2},
+		{ProductId: 2, WarehouseId: 1, AvailableStock: 10, ReservedStock: 8, OrderReservedStock: 4},
+		{ProductId: 3, WarehouseId: 1, AvailableStock: -2, ReservedStock: -1},
+	}
+
+	findings := checkReservationStates(reservationStates)
+
+	// Assertions
+	assert.Len(t, findings, 4)
+	assert.Equal(t, entity.FindingReservedMismatch, findings[0].Type)
+	assert.Equal(t, 2, findings[0].ProductId)
+	assert.Equal(t, 4, findings[0].Expected)
+	assert.Equal(t, 8, findings[0].Actual)
+	assert.Equal(t, entity.FindingReservedMismatch, findings[1].Type)
+	assert.Equal(t, 3, findings[1].ProductId)
+	assert.Equal(t, entity.FindingNegativeAvailableStock, findings[2].Type)
+	assert.Equal(t, -2, findings[2].Actual)
+	assert.Equal(t, entity.FindingNegativeReservedStock, findings[3].Type)
+}
+
+func TestOrderReservationFindings_Orphaned(t *testing.T) {
+	orderReservations := []consistency.OrderReservation{
+		{Id: 7, OrderId: 100, ProductId: 1, WarehouseId: 2, ReservedStock: 4, Status: entity.WarehouseInactive},
+	}
+
+	findings := orderReservationFindings(entity.FindingOrphanedReservation, orderReservations)
+
+	// Assertions
+	assert.Equal(t, []consistency.Finding{{
+		Type:             entity.FindingOrphanedReservation,
+		ProductId:        1,
+		WarehouseId:      2,
+		OrderId:          100,
+		OrderWarehouseId: 7,
+		Actual:           4,
+		Detail:           "warehouse is inactive",
+	}}, findings)
+}
+
+func TestCheck_RepairReleasesOrphanedReservation(t *testing.T) {
+	mockRepo := new(MockConsistencyRepository)
+	mockProductWarehouseRepo := new(MockProductWarehouseRepository)
+	db := mysqltest.NewDB()
+	consistencyUsecase := NewConsistencyUsecase(mockRepo, mockProductWarehouseRepo, db.DB, slog.Default())
+
+	mockRepo.On("GetOrphanedReservations").Return([]consistency.OrderReservation{
+		{Id: 7, OrderId: 100, ProductId: 1, WarehouseId: 2, ReservedStock: 4, Status: entity.WarehouseInactive},
+	}, nil)
+	mockProductWarehouseRepo.On("ClearOrderWarehouse", 7).Return(4, nil)
+	mockRepo.On("CorrectStock", 1, 2, 4, -4).Return(nil)
+	mockRepo.On("GetReservationStates").Return([]consistency.ReservationState{}, nil)
+	mockRepo.On("GetNegativeOrderReservations").Return([]consistency.OrderReservation{}, nil)
+
+	report, err := consistencyUsecase.Check(context.Background(), true)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, report.Findings, 1)
+	assert.True(t, report.Findings[0].Repaired)
+	assert.Equal(t, 1, db.Commits())
+	mockRepo.AssertExpectations(t)
+	mockProductWarehouseRepo.AssertExpectations(t)
+}
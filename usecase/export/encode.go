@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+	"warehouse-service/entity"
+)
+
+// rowWriter buffers encoded rows until Flush.
+type rowWriter interface {
+	Write(value interface{}) error
+	Flush() error
+}
+
+func newRowWriter(format string, writer io.Writer, sample interface{}) (rowWriter, error) {
+	switch format {
+	case entity.FormatCSV:
+		csvWriter := csv.NewWriter(writer)
+		err := csvWriter.Write(csvHeader(sample))
+		if err != nil {
+			return nil, err
+		}
+		return &csvRowWriter{writer: csvWriter}, nil
+	case entity.FormatNDJSON:
+		buffer := bufio.NewWriter(writer)
+		return &ndjsonRowWriter{buffer: buffer, encoder: json.NewEncoder(buffer)}, nil
+	}
+	return nil, entity.ErrorUnknownFormat
+}
+
+type csvRowWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvRowWriter) Write(value interface{}) error {
+	return c.writer.Write(csvRecord(value))
+}
+
+func (c *csvRowWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type ndjsonRowWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (n *ndjsonRowWriter) Write(value interface{}) error {
+	return n.encoder.Encode(value)
+}
+
+func (n *ndjsonRowWriter) Flush() error {
+	return n.buffer.Flush()
+}
+
+// csvHeader names the columns after the json tags of the struct, the same
+// names the NDJSON rows use.
+func csvHeader(value interface{}) []string {
+	structType := reflect.TypeOf(value)
+	header := make([]string, structType.NumField())
+	for i := range header {
+		header[i] = strings.Split(structType.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return header
+}
+
+// csvRecord formats the fields of the struct, nil pointers as empty cells and
+// times as RFC 3339.
+func csvRecord(value interface{}) []string {
+	structValue := reflect.ValueOf(value)
+	record := make([]string, structValue.NumField())
+	for i := range record {
+		field := structValue.Field(i)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+		if at, ok := field.Interface().(time.Time); ok {
+			record[i] = at.Format(time.RFC3339Nano)
+			continue
+		}
+		record[i] = fmt.Sprint(field.Interface())
+	}
+	return record
+}
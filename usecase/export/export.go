@@ -0,0 +1,125 @@
+package export
+
+import (
+	"context"
+	"io"
+	"warehouse-service/entity"
+	"warehouse-service/models/export"
+	"warehouse-service/tracing"
+)
+
+// pageSize is the number of rows read per query, so an export holds at most
+// one page in memory.
+const pageSize = 1000
+
+type ExportRepository interface {
+	GetStockLevels(ctx context.Context, filter export.Filter, cursor int, limit int) ([]export.StockLevel, error)
+	GetMovements(ctx context.Context, filter export.Filter, cursor int, limit int) ([]export.Movement, error)
+	GetReservations(ctx context.Context, filter export.Filter, cursor int, limit int) ([]export.Reservation, error)
+}
+
+type ExportUsecase struct {
+	exportRepo ExportRepository
+}
+
+func NewExportUsecase(exportRepo ExportRepository) *ExportUsecase {
+	return &ExportUsecase{
+		exportRepo: exportRepo,
+	}
+}
+
+// fetchPage returns the rows of the page after cursor and the id of its last
+// row.
+type fetchPage func(cursor int, limit int) ([]interface{}, int, error)
+
+// Export writes the rows after request.Cursor page by page, flushing the
+// writer after every page. It returns the cursor to resume from when
+// request.Limit stopped the export, or zero once the end is reached.
+func (e *ExportUsecase) Export(ctx context.Context, request *export.ExportRequest, writer io.Writer) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "ExportUsecase.Export")
+	defer func() { tracing.End(span, err) }()
+
+	sample, fetch, err := e.exportKind(ctx, request.Kind, request.Filter)
+	if err != nil {
+		return 0, err
+	}
+	rowWriter, err := newRowWriter(request.Format, writer, sample)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor := request.Cursor
+	written := 0
+	for {
+		limit := pageSize
+		if request.Limit > 0 && request.Limit-written < limit {
+			limit = request.Limit - written
+		}
+
+		rows, lastId, err := fetch(cursor, limit)
+		if err != nil {
+			return 0, err
+		}
+		for _, row := range rows {
+			err = rowWriter.Write(row)
+			if err != nil {
+				return 0, err
+			}
+		}
+		err = rowWriter.Flush()
+		if err != nil {
+			return 0, err
+		}
+
+		written += len(rows)
+		if len(rows) < limit {
+			return 0, nil
+		}
+		cursor = lastId
+		if request.Limit > 0 && written >= request.Limit {
+			return cursor, nil
+		}
+	}
+}
+
+func (e *ExportUsecase) exportKind(ctx context.Context, kind string, filter export.Filter) (interface{}, fetchPage, error) {
+	switch kind {
+	case entity.ExportStockLevels:
+		return export.StockLevel{}, func(cursor int, limit int) ([]interface{}, int, error) {
+			stockLevels, err := e.exportRepo.GetStockLevels(ctx, filter, cursor, limit)
+			if err != nil || len(stockLevels) == 0 {
+				return nil, 0, err
+			}
+			rows := make([]interface{}, len(stockLevels))
+			for i := range stockLevels {
+				rows[i] = stockLevels[i]
+			}
+			return rows, stockLevels[len(stockLevels)-1].Id, nil
+		}, nil
+	case entity.ExportMovements:
+		return export.Movement{}, func(cursor int, limit int) ([]interface{}, int, error) {
+			movements, err := e.exportRepo.GetMovements(ctx, filter, cursor, limit)
+			if err != nil || len(movements) == 0 {
+				return nil, 0, err
+			}
+			rows := make([]interface{}, len(movements))
+			for i := range movements {
+				rows[i] = movements[i]
+			}
+			return rows, movements[len(movements)-1].Id, nil
+		}, nil
+	case entity.ExportReservations:
+		return export.Reservation{}, func(cursor int, limit int) ([]interface{}, int, error) {
+			reservations, err := e.exportRepo.GetReservations(ctx, filter, cursor, limit)
+			if err != nil || len(reservations) == 0 {
+				return nil, 0, err
+			}
+			rows := make([]interface{}, len(reservations))
+			for i := range reservations {
+				rows[i] = reservations[i]
+			}
+			return rows, reservations[len(reservations)-1].Id, nil
+		}, nil
+	}
+	return nil, nil, entity.ErrorUnknownExportKind
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/export"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockExportRepository struct {
+	mock.Mock
+}
+
+func (m *MockExportRepository) GetStockLevels(ctx context.Context, filter export.Filter, cursor int, limit int) ([]export.StockLevel, error) {
+	args := m.Called(filter, cursor, limit)
+	return args.Get(0).([]export.StockLevel), args.Error(1)
+}
+
+func (m *MockExportRepository) GetMovements(ctx context.Context, filter export.Filter, cursor int, limit int) ([]export.Movement, error) {
+	args := m.Called(filter, cursor, limit)
+	return args.Get(0).([]export.Movement), args.Error(1)
+}
+
+func (m *MockExportRepository) GetReservations(ctx context.Context, filter export.Filter, cursor int, limit int) ([]export.Reservation, error) {
+	args := m.Called(filter, cursor, limit)
+	return args.Get(0).([]export.Reservation), args.Error(1)
+}
+
+func TestExport_StockLevelsCSV(t *testing.T) {
+	mockRepo := new(MockExportRepository)
+	exportUsecase := NewExportUsecase(mockRepo)
+
+	filter := export.Filter{ShopId: 1}
+	mockRepo.On("GetStockLevels", filter, 0, pageSize).Return([]export.StockLevel{
+		{Id: 4, ShopId: 1, ProductId: 2, WarehouseId: 3, AvailableStock: 10, ReservedStock: 1},
+	}, nil)
+
+	var buffer bytes.Buffer
+	nextCursor, err := exportUsecase.Export(context.Background(), &export.ExportRequest{Kind: entity.ExportStockLevels, Format: entity.FormatCSV, Filter: filter}, &buffer)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 0, nextCursor)
+	assert.Equal(t, "id,shop_id,product_id,warehouse_id,available_stock,reserved_stock,safety_stock\n4,1,2,3,10,1,0\n", buffer.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExport_MovementsNDJSONWithLimit(t *testing.T) {
+	mockRepo := new(MockExportRepository)
+	exportUsecase := NewExportUsecase(mockRepo)
+
+	reason := entity.StockMovementOpeningBalance
+	createdAt := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	mockRepo.On("GetMovements", export.Filter{}, 7, 2).Return([]export.Movement{
+		{Id: 8, ShopId: 1, ProductId: 2, WarehouseId: 3, AvailableDelta: 5, Reason: &reason, CreatedAt: createdAt},
+		{Id: 9, ShopId: 1, ProductId: 2, WarehouseId: 3, AvailableDelta: -1, ReservedDelta: 1, CreatedAt: createdAt},
+	}, nil)
+
+	var buffer bytes.Buffer
+	nextCursor, err := exportUsecase.Export(context.Background(), &export.ExportRequest{Kind: entity.ExportMovements, Format: entity.FormatNDJSON, Cursor: 7, Limit: 2}, &buffer)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 9, nextCursor)
+	assert.Equal(t, `{"id":8,"shop_id":1,"product_id":2,"warehouse_id":3,"available_delta":5,"reserved_delta":0,"reason":"opening_balance","created_at":"2026-10-01T08:00:00Z"}
+{"id":9,"shop_id":1,"product_id":2,"warehouse_id":3,"available_delta":-1,"reserved_delta":1,"reason":null,"created_at":"2026-10-01T08:00:00Z"}
+`, buffer.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExport_Pages(t *testing.T) {
+	mockRepo := new(MockExportRepository)
+	exportUsecase := NewExportUsecase(mockRepo)
+
+	firstPage := make([]export.Reservation, pageSize)
+	for i := range firstPage {
+		firstPage[i] = export.Reservation{Id: i + 1}
+	}
+	mockRepo.On("GetReservations", export.Filter{}, 0, pageSize).Return(firstPage, nil)
+	mockRepo.On("GetReservations", export.Filter{}, pageSize, pageSize).Return([]export.Reservation{{Id: pageSize + 1}}, nil)
+
+	var buffer bytes.Buffer
+	nextCursor, err := exportUsecase.Export(context.Background(), &export.ExportRequest{Kind: entity.ExportReservations, Format: entity.FormatCSV}, &buffer)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 0, nextCursor)
+	assert.Equal(t, pageSize+2, bytes.Count(buffer.Bytes(), []byte("\n")))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCSVRecord(t *testing.T) {
+	reason := "restock"
+	record := csvRecord(export.Movement{Id: 1, Reason: &reason, CreatedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)})
+
+	// Assertions
+	assert.Equal(t, []string{"1", "0", "0", "0", "0", "0", "restock", "2026-10-01T00:00:00Z"}, record)
+}
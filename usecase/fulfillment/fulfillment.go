@@ -0,0 +1,467 @@
+package fulfillment
+
+import (
+	"context"
+	"sort"
+	"warehouse-service/entity"
+	"warehouse-service/models/fulfillment"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type FulfillmentRepository interface {
+	InsertPickList(ctx context.Context, tx *sqlx.Tx, pickList *fulfillment.PickList) (int, error)
+	InsertPickListItem(ctx context.Context, tx *sqlx.Tx, item *fulfillment.PickListItem) error
+	GetPickListById(ctx context.Context, id int) (*fulfillment.PickList, error)
+	GetPickListsByOrderId(ctx context.Context, orderId int) ([]fulfillment.PickList, error)
+	GetPendingPickItems(ctx context.Context, tx *sqlx.Tx, orderId int) ([]fulfillment.PendingPickItem, error)
+	UpdatePickListStatus(ctx context.Context, tx *sqlx.Tx, id int, fromStatus string, toStatus string) (bool, error)
+	UpdatePickedQuantity(ctx context.Context, tx *sqlx.Tx, itemId int, pickedQuantity int) error
+	CancelPickLists(ctx context.Context, tx *sqlx.Tx, orderId int) error
+	InsertShipment(ctx context.Context, tx *sqlx.Tx, shipment *fulfillment.Shipment) (int, error)
+	SubstractOrderWarehouseReservedStock(ctx context.Context, tx *sqlx.Tx, id int, substractedReservedStock int) error
+}
+
+type ProductWarehouseRepository interface {
+	GetOrderWarehouseByOrderId(ctx context.Context, orderId int) ([]product_warehouse.OrderWarehouse, error)
+	GetOrderWarehouseByOrderIdForUpdate(ctx context.Context, tx *sqlx.Tx, orderId int) ([]product_warehouse.OrderWarehouse, error)
+	GetActiveByProductIdForUpdate(ctx context.Context, tx *sqlx.Tx, productId int) ([]product_warehouse.ProductWarehouse, error)
+	SubsAvailableStockAddReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int, addedReservedStock int) error
+	SubstractReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedReservedStock int) error
+	InsertOrderWarehouse(ctx context.Context, tx *sqlx.Tx, orderWarehouse *product_warehouse.OrderWarehouse) error
+}
+
+type Valuation interface {
+	Consume(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int) error
+}
+
+type Bins interface {
+	TakeStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) error
+}
+
+type Outbox interface {
+	Add(ctx context.Context, tx *sqlx.Tx, eventType string, data interface{}) error
+}
+
+type FulfillmentUsecase struct {
+	fulfillmentRepo      FulfillmentRepository
+	productWarehouseRepo ProductWarehouseRepository
+	bins                 Bins
+	outbox               Outbox
+	valuation            Valuation
+	mysql                *sqlx.DB
+}
+
+func NewFulfillmentUsecase(fulfillmentRepo FulfillmentRepository, productWarehouseRepo ProductWarehouseRepository, bins Bins, outbox Outbox, valuation Valuation, mysql *sqlx.DB) *FulfillmentUsecase {
+	return &FulfillmentUsecase{
+		fulfillmentRepo:      fulfillmentRepo,
+		productWarehouseRepo: productWarehouseRepo,
+		bins:                 bins,
+		outbox:               outbox,
+		valuation:            valuation,
+		mysql:                mysql,
+	}
+}
+
+type warehouseProduct struct {
+	warehouseId int
+	productId   int
+}
+
+// GeneratePickLists creates one pick list per warehouse for the reserved stock
+// of the order that is not covered by a pending pick list yet, so it is safe
+// to call again after a short pick re-allocated stock. The reservations of the
+// order stay locked until the pick lists are written, so concurrent calls
+// cannot list the same stock twice.
+func (f *FulfillmentUsecase) GeneratePickLists(ctx context.Context, order *product_warehouse.Order) (err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentUsecase.GeneratePickLists")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := f.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	orderWarehouses, err := f.productWarehouseRepo.GetOrderWarehouseByOrderIdForUpdate(ctx, tx, order.OrderId)
+	if err != nil {
+		return err
+	}
+	pendingPickItems, err := f.fulfillmentRepo.GetPendingPickItems(ctx, tx, order.OrderId)
+	if err != nil {
+		return err
+	}
+
+	unlisted := make(map[warehouseProduct]int)
+	for _, orderWarehouse := range orderWarehouses {
+		unlisted[warehouseProduct{orderWarehouse.WarehouseId, orderWarehouse.ProductId}] += orderWarehouse.ReservedStock
+	}
+	for _, pendingPickItem := range pendingPickItems {
+		unlisted[warehouseProduct{pendingPickItem.WarehouseId, pendingPickItem.ProductId}] -= pendingPickItem.Quantity
+	}
+
+	keys := []warehouseProduct{}
+	for key, quantity := range unlisted {
+		if quantity > 0 {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return tx.Commit()
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].warehouseId != keys[j].warehouseId {
+			return keys[i].warehouseId < keys[j].warehouseId
+		}
+		return keys[i].productId < keys[j].productId
+	})
+
+	pickListId := 0
+	for i, key := range keys {
+		if i == 0 || keys[i-1].warehouseId != key.warehouseId {
+			pickListId, err = f.fulfillmentRepo.InsertPickList(ctx, tx, &fulfillment.PickList{
+				OrderId:     order.OrderId,
+				WarehouseId: key.warehouseId,
+				Status:      entity.PickListOpen,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = f.fulfillmentRepo.InsertPickListItem(ctx, tx, &fulfillment.PickListItem{
+			PickListId: pickListId,
+			ProductId:  key.productId,
+			Quantity:   unlisted[key],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (f *FulfillmentUsecase) GetPickLists(ctx context.Context, orderId int) (_ []fulfillment.PickList, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentUsecase.GetPickLists")
+	defer func() { tracing.End(span, err) }()
+
+	return f.fulfillmentRepo.GetPickListsByOrderId(ctx, orderId)
+}
+
+// CancelPickLists cancels the pick lists of the order that are not shipped, in
+// the transaction returning its reserved stock.
+func (f *FulfillmentUsecase) CancelPickLists(ctx context.Context, tx *sqlx.Tx, order *product_warehouse.Order) (err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentUsecase.CancelPickLists")
+	defer func() { tracing.End(span, err) }()
+
+	return f.fulfillmentRepo.CancelPickLists(ctx, tx, order.OrderId)
+}
+
+// ConfirmPick records the picked quantities. Units that could not be found are
+// written off from the warehouse reservation and re-reserved from the other
+// warehouses, which then get their own pick lists.
+func (f *FulfillmentUsecase) ConfirmPick(ctx context.Context, pickConfirm *fulfillment.PickConfirmRequest) (_ *fulfillment.PickConfirmation, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentUsecase.ConfirmPick")
+	defer func() { tracing.End(span, err) }()
+
+	pickList, err := f.fulfillmentRepo.GetPickListById(ctx, pickConfirm.PickListId)
+	if err != nil {
+		return nil, err
+	}
+	if pickList.Status != entity.PickListOpen {
+		return nil, entity.ErrorInvalidPickList
+	}
+
+	pickedQuantities := make(map[int]int)
+	for _, pickedItem := range pickConfirm.Items {
+		pickedQuantities[pickedItem.ProductId] += pickedItem.PickedQuantity
+	}
+	if len(pickedQuantities) != len(pickList.Items) {
+		return nil, entity.ErrorInvalidPickedItem
+	}
+	for _, item := range pickList.Items {
+		pickedQuantity, ok := pickedQuantities[item.ProductId]
+		if !ok || pickedQuantity > item.Quantity {
+			return nil, entity.ErrorInvalidPickedItem
+		}
+	}
+
+	orderWarehouses, err := f.productWarehouseRepo.GetOrderWarehouseByOrderId(ctx, pickList.OrderId)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := f.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	updated, err := f.fulfillmentRepo.UpdatePickListStatus(ctx, tx, pickList.Id, entity.PickListOpen, entity.PickListPicked)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		err = entity.ErrorInvalidPickList
+		return nil, err
+	}
+
+	pickConfirmation := &fulfillment.PickConfirmation{
+		PickListId: pickList.Id,
+		ShortPicks: []fulfillment.ShortPick{},
+	}
+	for _, item := range pickList.Items {
+		pickedQuantity := pickedQuantities[item.ProductId]
+		err = f.fulfillmentRepo.UpdatePickedQuantity(ctx, tx, item.Id, pickedQuantity)
+		if err != nil {
+			return nil, err
+		}
+
+		shortfall := item.Quantity - pickedQuantity
+		if shortfall == 0 {
+			continue
+		}
+
+		err = f.consumeReservation(ctx, tx, entity.CostMovementWriteOff, orderWarehouses, item.ProductId, pickList.WarehouseId, shortfall)
+		if err != nil {
+			return nil, err
+		}
+
+		var reallocated int
+		reallocated, err = f.reallocate(ctx, tx, reservationOf(orderWarehouses, pickList.OrderId, item.ProductId, pickList.WarehouseId), shortfall)
+		if err != nil {
+			return nil, err
+		}
+		pickConfirmation.ShortPicks = append(pickConfirmation.ShortPicks, fulfillment.ShortPick{
+			ProductId:   item.ProductId,
+			Shortfall:   shortfall,
+			Reallocated: reallocated,
+		})
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pickConfirmation.ShortPicks) > 0 {
+		err = f.GeneratePickLists(ctx, &product_warehouse.Order{OrderId: pickList.OrderId})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pickConfirmation, nil
+}
+
+func (f *FulfillmentUsecase) ConfirmPack(ctx context.Context, pickListRequest *fulfillment.PickListRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentUsecase.ConfirmPack")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := f.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	updated, err := f.fulfillmentRepo.UpdatePickListStatus(ctx, tx, pickListRequest.PickListId, entity.PickListPicked, entity.PickListPacked)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		err = entity.ErrorInvalidPickList
+		return err
+	}
+	return tx.Commit()
+}
+
+// Ship creates the shipment for a packed pick list. This is the only place
+// reserved stock leaves the warehouse.
+func (f *FulfillmentUsecase) Ship(ctx context.Context, pickListRequest *fulfillment.PickListRequest) (_ *fulfillment.ShippedEvent, err error) {
+	ctx, span := tracing.Start(ctx, "FulfillmentUsecase.Ship")
+	defer func() { tracing.End(span, err) }()
+
+	pickList, err := f.fulfillmentRepo.GetPickListById(ctx, pickListRequest.PickListId)
+	if err != nil {
+		return nil, err
+	}
+	if pickList.Status != entity.PickListPacked {
+		return nil, entity.ErrorInvalidPickList
+	}
+
+	orderWarehouses, err := f.productWarehouseRepo.GetOrderWarehouseByOrderId(ctx, pickList.OrderId)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := f.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	updated, err := f.fulfillmentRepo.UpdatePickListStatus(ctx, tx, pickList.Id, entity.PickListPacked, entity.PickListShipped)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		err = entity.ErrorInvalidPickList
+		return nil, err
+	}
+
+	shipmentId, err := f.fulfillmentRepo.InsertShipment(ctx, tx, &fulfillment.Shipment{
+		PickListId:  pickList.Id,
+		OrderId:     pickList.OrderId,
+		WarehouseId: pickList.WarehouseId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shippedEvent := &fulfillment.ShippedEvent{
+		ShipmentId:  shipmentId,
+		OrderId:     pickList.OrderId,
+		WarehouseId: pickList.WarehouseId,
+		Items:       []fulfillment.ShipmentItem{},
+	}
+	for _, item := range pickList.Items {
+		if item.PickedQuantity == 0 {
+			continue
+		}
+		err = f.consumeReservation(ctx, tx, entity.CostMovementShipment, orderWarehouses, item.ProductId, pickList.WarehouseId, item.PickedQuantity)
+		if err != nil {
+			return nil, err
+		}
+		shippedEvent.Items = append(shippedEvent.Items, fulfillment.ShipmentItem{
+			ProductId: item.ProductId,
+			Quantity:  item.PickedQuantity,
+		})
+	}
+	err = f.outbox.Add(ctx, tx, entity.StockShippedEvent, shippedEvent)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return shippedEvent, nil
+}
+
+// consumeReservation removes quantity from the reserved stock of the warehouse,
+// its bins and the order's reservation rows for that warehouse, along with
+// its cost.
+func (f *FulfillmentUsecase) consumeReservation(ctx context.Context, tx *sqlx.Tx, movementType string, orderWarehouses []product_warehouse.OrderWarehouse, productId int, warehouseId int, quantity int) error {
+	err := f.productWarehouseRepo.SubstractReservedStock(ctx, tx, productId, warehouseId, quantity)
+	if err != nil {
+		return err
+	}
+
+	err = f.bins.TakeStock(ctx, tx, productId, warehouseId, quantity)
+	if err != nil {
+		return err
+	}
+
+	err = f.valuation.Consume(ctx, tx, movementType, productId, warehouseId, quantity)
+	if err != nil {
+		return err
+	}
+
+	remaining := quantity
+	for i := range orderWarehouses {
+		orderWarehouse := &orderWarehouses[i]
+		if remaining == 0 {
+			break
+		}
+		if orderWarehouse.ProductId != productId || orderWarehouse.WarehouseId != warehouseId || orderWarehouse.ReservedStock <= 0 {
+			continue
+		}
+		consumed := min(orderWarehouse.ReservedStock, remaining)
+		err = f.fulfillmentRepo.SubstractOrderWarehouseReservedStock(ctx, tx, orderWarehouse.Id, consumed)
+		if err != nil {
+			return err
+		}
+		orderWarehouse.ReservedStock -= consumed
+		remaining -= consumed
+	}
+	if remaining > 0 {
+		return entity.ErrorInsufficientStock
+	}
+	return nil
+}
+
+// reservationOf returns the order's reservation of the product in the
+// warehouse, or a bare one when the order holds none there.
+func reservationOf(orderWarehouses []product_warehouse.OrderWarehouse, orderId int, productId int, warehouseId int) product_warehouse.OrderWarehouse {
+	for _, orderWarehouse := range orderWarehouses {
+		if orderWarehouse.ProductId == productId && orderWarehouse.WarehouseId == warehouseId {
+			return orderWarehouse
+		}
+	}
+	return product_warehouse.OrderWarehouse{OrderId: orderId, ProductId: productId, WarehouseId: warehouseId}
+}
+
+// reallocate reserves up to quantity of the short reservation from the active
+// warehouses other than the one that came up short and returns how much it
+// could reserve. The new reservations keep the shop and channel of the short
+// one. The warehouse rows stay locked until tx ends, so concurrent
+// reservations cannot take the same stock.
+func (f *FulfillmentUsecase) reallocate(ctx context.Context, tx *sqlx.Tx, short product_warehouse.OrderWarehouse, quantity int) (int, error) {
+	productId := short.ProductId
+	productWarehouses, err := f.productWarehouseRepo.GetActiveByProductIdForUpdate(ctx, tx, productId)
+	if err != nil {
+		return 0, err
+	}
+
+	reserved := 0
+	for _, productWarehouse := range productWarehouses {
+		if reserved == quantity {
+			break
+		}
+		if productWarehouse.WarehouseId == short.WarehouseId || productWarehouse.AvailableStock <= 0 {
+			continue
+		}
+		queryQuantity := min(productWarehouse.AvailableStock, quantity-reserved)
+
+		err = f.productWarehouseRepo.SubsAvailableStockAddReservedStock(ctx, tx, productId, productWarehouse.WarehouseId, queryQuantity, queryQuantity)
+		if err != nil {
+			return 0, err
+		}
+		err = f.productWarehouseRepo.InsertOrderWarehouse(ctx, tx, &product_warehouse.OrderWarehouse{
+			OrderId:       short.OrderId,
+			ProductId:     productId,
+			WarehouseId:   productWarehouse.WarehouseId,
+			ReservedStock: queryQuantity,
+			ShopId:        short.ShopId,
+			Channel:       short.Channel,
+		})
+		if err != nil {
+			return 0, err
+		}
+		reserved += queryQuantity
+	}
+	return reserved, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,394 @@
+package fulfillment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/entity"
+	"warehouse-service/models/fulfillment"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockFulfillmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockFulfillmentRepository) InsertPickList(ctx context.Context, tx *sqlx.Tx, pickList *fulfillment.PickList) (int, error) {
+	args := m.Called(pickList)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockFulfillmentRepository) InsertPickListItem(ctx context.Context, tx *sqlx.Tx, item *fulfillment.PickListItem) error {
+	args := m.Called(item)
+	return args.Error(0)
+}
+
+func (m *MockFulfillmentRepository) GetPickListById(ctx context.Context, id int) (*fulfillment.PickList, error) {
+	args := m.Called(id)
+	return args.Get(0).(*fulfillment.PickList), args.Error(1)
+}
+
+func (m *MockFulfillmentRepository) GetPickListsByOrderId(ctx context.Context, orderId int) ([]fulfillment.PickList, error) {
+	args := m.Called(orderId)
+	return args.Get(0).([]fulfillment.PickList), args.Error(1)
+}
+
+func (m *MockFulfillmentRepository) GetPendingPickItems(ctx context.Context, tx *sqlx.Tx, orderId int) ([]fulfillment.PendingPickItem, error) {
+	args := m.Called(orderId)
+	return args.Get(0).([]fulfillment.PendingPickItem), args.Error(1)
+}
+
+func (m *MockFulfillmentRepository) UpdatePickListStatus(ctx context.Context, tx *sqlx.Tx, id int, fromStatus string, toStatus string) (bool, error) {
+	args := m.Called(id, fromStatus, toStatus)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockFulfillmentRepository) UpdatePickedQuantity(ctx context.Context, tx *sqlx.Tx, itemId int, pickedQuantity int) error {
+	args := m.Called(itemId, pickedQuantity)
+	return args.Error(0)
+}
+
+func (m *MockFulfillmentRepository) CancelPickLists(ctx context.Context, tx *sqlx.Tx, orderId int) error {
+	args := m.Called(orderId)
+	return args.Error(0)
+}
+
+func (m *MockFulfillmentRepository) InsertShipment(ctx context.Context, tx *sqlx.Tx, shipment *fulfillment.Shipment) (int, error) {
+	args := m.Called(shipment)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockFulfillmentRepository) SubstractOrderWarehouseReservedStock(ctx context.Context, tx *sqlx.Tx, id int, substractedReservedStock int) error {
+	args := m.Called(id, substractedReservedStock)
+	return args.Error(0)
+}
+
+type MockProductWarehouseRepository struct {
+	mock.Mock
+}
+
+func (m *MockProductWarehouseRepository) GetOrderWarehouseByOrderId(ctx context.Context, orderId int) ([]product_warehouse.OrderWarehouse, error) {
+	args := m.Called(orderId)
+	return args.Get(0).([]product_warehouse.OrderWarehouse), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetOrderWarehouseByOrderIdForUpdate(ctx context.Context, tx *sqlx.Tx, orderId int) ([]product_warehouse.OrderWarehouse, error) {
+	args := m.Called(orderId)
+	return args.Get(0).([]product_warehouse.OrderWarehouse), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetActiveByProductIdForUpdate(ctx context.Context, tx *sqlx.Tx, productId int) ([]product_warehouse.ProductWarehouse, error) {
+	args := m.Called(productId)
+	return args.Get(0).([]product_warehouse.ProductWarehouse), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) SubsAvailableStockAddReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int, addedReservedStock int) error {
+	args := m.Called(productId, warehouseId, substractedAvailableStock, addedReservedStock)
+	return args.Error(0)
+}
+
+func (m *MockProductWarehouseRepository) SubstractReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedReservedStock int) error {
+	args := m.Called(productId, warehouseId, substractedReservedStock)
+	return args.Error(0)
+}
+
+func (m *MockProductWarehouseRepository) InsertOrderWarehouse(ctx context.Context, tx *sqlx.Tx, orderWarehouse *product_warehouse.OrderWarehouse) error {
+	args := m.Called(orderWarehouse)
+	return args.Error(0)
+}
+
+type MockBins struct {
+	mock.Mock
+}
+
+func (m *MockBins) TakeStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) error {
+	args := m.Called(productId, warehouseId, quantity)
+	return args.Error(0)
+}
+
+type MockOutbox struct {
+	mock.Mock
+}
+
+func (m *MockOutbox) Add(ctx context.Context, tx *sqlx.Tx, eventType string, data interface{}) error {
+	args := m.Called(eventType, data)
+	return args.Error(0)
+}
+
+type MockValuation struct {
+	mock.Mock
+}
+
+func (m *MockValuation) Consume(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int) error {
+	args := m.Called(movementType, productId, warehouseId, quantity)
+	return args.Error(0)
+}
+
+type mocks struct {
+	fulfillmentRepo      *MockFulfillmentRepository
+	productWarehouseRepo *MockProductWarehouseRepository
+	bins                 *MockBins
+	outbox               *MockOutbox
+	valuation            *MockValuation
+	db                   *mysqltest.DB
+}
+
+func newTestUsecase() (*FulfillmentUsecase, *mocks) {
+	m := &mocks{
+		fulfillmentRepo:      new(MockFulfillmentRepository),
+		productWarehouseRepo: new(MockProductWarehouseRepository),
+		bins:                 new(MockBins),
+		outbox:               new(MockOutbox),
+		valuation:            new(MockValuation),
+		db:                   mysqltest.NewDB(),
+	}
+	return NewFulfillmentUsecase(m.fulfillmentRepo, m.productWarehouseRepo, m.bins, m.outbox, m.valuation, m.db.DB), m
+}
+
+func TestGeneratePickLists_OnePerWarehouse(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.productWarehouseRepo.On("GetOrderWarehouseByOrderIdForUpdate", 1).Return([]product_warehouse.OrderWarehouse{
+		{Id: 1, OrderId: 1, ProductId: 7, WarehouseId: 1, ReservedStock: 5},
+		{Id: 2, OrderId: 1, ProductId: 8, WarehouseId: 1, ReservedStock: 2},
+		{Id: 3, OrderId: 1, ProductId: 7, WarehouseId: 2, ReservedStock: 3},
+	}, nil)
+	m.fulfillmentRepo.On("GetPendingPickItems", 1).Return([]fulfillment.PendingPickItem{}, nil)
+	m.fulfillmentRepo.On("InsertPickList", &fulfillment.PickList{OrderId: 1, WarehouseId: 1, Status: entity.PickListOpen}).Return(10, nil)
+	m.fulfillmentRepo.On("InsertPickList", &fulfillment.PickList{OrderId: 1, WarehouseId: 2, Status: entity.PickListOpen}).Return(11, nil)
+	m.fulfillmentRepo.On("InsertPickListItem", &fulfillment.PickListItem{PickListId: 10, ProductId: 7, Quantity: 5}).Return(nil)
+	m.fulfillmentRepo.On("InsertPickListItem", &fulfillment.PickListItem{PickListId: 10, ProductId: 8, Quantity: 2}).Return(nil)
+	m.fulfillmentRepo.On("InsertPickListItem", &fulfillment.PickListItem{PickListId: 11, ProductId: 7, Quantity: 3}).Return(nil)
+
+	err := fulfillmentUsecase.GeneratePickLists(context.Background(), &product_warehouse.Order{OrderId: 1})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, m.db.Commits())
+	m.fulfillmentRepo.AssertExpectations(t)
+}
+
+func TestGeneratePickLists_AlreadyListed(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.productWarehouseRepo.On("GetOrderWarehouseByOrderIdForUpdate", 1).Return([]product_warehouse.OrderWarehouse{
+		{Id: 1, OrderId: 1, ProductId: 7, WarehouseId: 1, ReservedStock: 5},
+	}, nil)
+	m.fulfillmentRepo.On("GetPendingPickItems", 1).Return([]fulfillment.PendingPickItem{
+		{WarehouseId: 1, ProductId: 7, Quantity: 5},
+	}, nil)
+
+	err := fulfillmentUsecase.GeneratePickLists(context.Background(), &product_warehouse.Order{OrderId: 1})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, m.db.Commits())
+	m.fulfillmentRepo.AssertNotCalled(t, "InsertPickList", mock.Anything)
+}
+
+func TestConfirmPick_NotOpen(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.fulfillmentRepo.On("GetPickListById", 10).Return(&fulfillment.PickList{Id: 10, OrderId: 1, WarehouseId: 1, Status: entity.PickListPacked}, nil)
+
+	pickConfirmation, err := fulfillmentUsecase.ConfirmPick(context.Background(), &fulfillment.PickConfirmRequest{
+		PickListId: 10,
+		Items:      []fulfillment.PickedItem{{ProductId: 7, PickedQuantity: 5}},
+	})
+
+	// Assertions
+	assert.Nil(t, pickConfirmation)
+	assert.ErrorIs(t, err, entity.ErrorInvalidPickList)
+	assert.Equal(t, 0, m.db.Commits())
+}
+
+func TestConfirmPick_PickedConcurrently(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.fulfillmentRepo.On("GetPickListById", 10).Return(&fulfillment.PickList{
+		Id: 10, OrderId: 1, WarehouseId: 1, Status: entity.PickListOpen,
+		Items: []fulfillment.PickListItem{{Id: 100, ProductId: 7, Quantity: 5}},
+	}, nil)
+	m.productWarehouseRepo.On("GetOrderWarehouseByOrderId", 1).Return([]product_warehouse.OrderWarehouse{}, nil)
+	m.fulfillmentRepo.On("UpdatePickListStatus", 10, entity.PickListOpen, entity.PickListPicked).Return(false, nil)
+
+	_, err := fulfillmentUsecase.ConfirmPick(context.Background(), &fulfillment.PickConfirmRequest{
+		PickListId: 10,
+		Items:      []fulfillment.PickedItem{{ProductId: 7, PickedQuantity: 5}},
+	})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInvalidPickList)
+	assert.Equal(t, 1, m.db.Rollbacks())
+	m.fulfillmentRepo.AssertNotCalled(t, "UpdatePickedQuantity", mock.Anything, mock.Anything)
+}
+
+func TestConfirmPick_ItemsDoNotMatch(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.fulfillmentRepo.On("GetPickListById", 10).Return(&fulfillment.PickList{
+		Id: 10, OrderId: 1, WarehouseId: 1, Status: entity.PickListOpen,
+		Items: []fulfillment.PickListItem{{Id: 100, ProductId: 7, Quantity: 5}},
+	}, nil)
+
+	_, err := fulfillmentUsecase.ConfirmPick(context.Background(), &fulfillment.PickConfirmRequest{
+		PickListId: 10,
+		Items:      []fulfillment.PickedItem{{ProductId: 7, PickedQuantity: 6}},
+	})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInvalidPickedItem)
+}
+
+func TestConfirmPick_ShortPickReallocates(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.fulfillmentRepo.On("GetPickListById", 10).Return(&fulfillment.PickList{
+		Id: 10, OrderId: 1, WarehouseId: 1, Status: entity.PickListOpen,
+		Items: []fulfillment.PickListItem{{Id: 100, ProductId: 7, Quantity: 5}},
+	}, nil)
+	m.productWarehouseRepo.On("GetOrderWarehouseByOrderId", 1).Return([]product_warehouse.OrderWarehouse{
+		{Id: 1, OrderId: 1, ProductId: 7, WarehouseId: 1, ReservedStock: 5, ShopId: 9, Channel: "web"},
+	}, nil).Once()
+	m.fulfillmentRepo.On("UpdatePickListStatus", 10, entity.PickListOpen, entity.PickListPicked).Return(true, nil)
+	m.fulfillmentRepo.On("UpdatePickedQuantity", 100, 2).Return(nil)
+
+	// the 3 units that were not found are written off warehouse 1
+	m.productWarehouseRepo.On("SubstractReservedStock", 7, 1, 3).Return(nil)
+	m.bins.On("TakeStock", 7, 1, 3).Return(nil)
+	m.valuation.On("Consume", entity.CostMovementWriteOff, 7, 1, 3).Return(nil)
+	m.fulfillmentRepo.On("SubstractOrderWarehouseReservedStock", 1, 3).Return(nil)
+
+	// and reserved from the other warehouses in their order, in the same channel pool
+	m.productWarehouseRepo.On("GetActiveByProductIdForUpdate", 7).Return([]product_warehouse.ProductWarehouse{
+		{ProductId: 7, WarehouseId: 1, AvailableStock: 10},
+		{ProductId: 7, WarehouseId: 2, AvailableStock: 2},
+		{ProductId: 7, WarehouseId: 3, AvailableStock: 0},
+		{ProductId: 7, WarehouseId: 4, AvailableStock: 5},
+	}, nil)
+	m.productWarehouseRepo.On("SubsAvailableStockAddReservedStock", 7, 2, 2, 2).Return(nil)
+	m.productWarehouseRepo.On("SubsAvailableStockAddReservedStock", 7, 4, 1, 1).Return(nil)
+	m.productWarehouseRepo.On("InsertOrderWarehouse", &product_warehouse.OrderWarehouse{OrderId: 1, ProductId: 7, WarehouseId: 2, ReservedStock: 2, ShopId: 9, Channel: "web"}).Return(nil)
+	m.productWarehouseRepo.On("InsertOrderWarehouse", &product_warehouse.OrderWarehouse{OrderId: 1, ProductId: 7, WarehouseId: 4, ReservedStock: 1, ShopId: 9, Channel: "web"}).Return(nil)
+
+	// the new reservations get their own pick lists
+	m.productWarehouseRepo.On("GetOrderWarehouseByOrderIdForUpdate", 1).Return([]product_warehouse.OrderWarehouse{
+		{Id: 1, OrderId: 1, ProductId: 7, WarehouseId: 1, ReservedStock: 2},
+		{Id: 2, OrderId: 1, ProductId: 7, WarehouseId: 2, ReservedStock: 2},
+		{Id: 3, OrderId: 1, ProductId: 7, WarehouseId: 4, ReservedStock: 1},
+	}, nil).Once()
+	m.fulfillmentRepo.On("GetPendingPickItems", 1).Return([]fulfillment.PendingPickItem{
+		{WarehouseId: 1, ProductId: 7, Quantity: 2},
+	}, nil)
+	m.fulfillmentRepo.On("InsertPickList", &fulfillment.PickList{OrderId: 1, WarehouseId: 2, Status: entity.PickListOpen}).Return(11, nil)
+	m.fulfillmentRepo.On("InsertPickList", &fulfillment.PickList{OrderId: 1, WarehouseId: 4, Status: entity.PickListOpen}).Return(12, nil)
+	m.fulfillmentRepo.On("InsertPickListItem", &fulfillment.PickListItem{PickListId: 11, ProductId: 7, Quantity: 2}).Return(nil)
+	m.fulfillmentRepo.On("InsertPickListItem", &fulfillment.PickListItem{PickListId: 12, ProductId: 7, Quantity: 1}).Return(nil)
+
+	pickConfirmation, err := fulfillmentUsecase.ConfirmPick(context.Background(), &fulfillment.PickConfirmRequest{
+		PickListId: 10,
+		Items:      []fulfillment.PickedItem{{ProductId: 7, PickedQuantity: 2}},
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []fulfillment.ShortPick{{ProductId: 7, Shortfall: 3, Reallocated: 3}}, pickConfirmation.ShortPicks)
+	assert.Equal(t, 2, m.db.Commits())
+	m.fulfillmentRepo.AssertExpectations(t)
+	m.productWarehouseRepo.AssertExpectations(t)
+	m.bins.AssertExpectations(t)
+	m.valuation.AssertExpectations(t)
+}
+
+func TestConfirmPack_NotPicked(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.fulfillmentRepo.On("UpdatePickListStatus", 10, entity.PickListPicked, entity.PickListPacked).Return(false, nil)
+
+	err := fulfillmentUsecase.ConfirmPack(context.Background(), &fulfillment.PickListRequest{PickListId: 10})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInvalidPickList)
+	assert.Equal(t, 1, m.db.Rollbacks())
+}
+
+func TestShip(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.fulfillmentRepo.On("GetPickListById", 10).Return(&fulfillment.PickList{
+		Id: 10, OrderId: 1, WarehouseId: 1, Status: entity.PickListPacked,
+		Items: []fulfillment.PickListItem{
+			{Id: 100, ProductId: 7, Quantity: 5, PickedQuantity: 5},
+			{Id: 101, ProductId: 8, Quantity: 1, PickedQuantity: 0},
+		},
+	}, nil)
+	m.productWarehouseRepo.On("GetOrderWarehouseByOrderId", 1).Return([]product_warehouse.OrderWarehouse{
+		{Id: 1, OrderId: 1, ProductId: 7, WarehouseId: 1, ReservedStock: 5},
+	}, nil)
+	m.fulfillmentRepo.On("UpdatePickListStatus", 10, entity.PickListPacked, entity.PickListShipped).Return(true, nil)
+	m.fulfillmentRepo.On("InsertShipment", &fulfillment.Shipment{PickListId: 10, OrderId: 1, WarehouseId: 1}).Return(20, nil)
+	m.productWarehouseRepo.On("SubstractReservedStock", 7, 1, 5).Return(nil)
+	m.bins.On("TakeStock", 7, 1, 5).Return(nil)
+	m.valuation.On("Consume", entity.CostMovementShipment, 7, 1, 5).Return(nil)
+	m.fulfillmentRepo.On("SubstractOrderWarehouseReservedStock", 1, 5).Return(nil)
+	m.outbox.On("Add", entity.StockShippedEvent, mock.Anything).Return(nil)
+
+	shippedEvent, err := fulfillmentUsecase.Ship(context.Background(), &fulfillment.PickListRequest{PickListId: 10})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, &fulfillment.ShippedEvent{
+		ShipmentId:  20,
+		OrderId:     1,
+		WarehouseId: 1,
+		Items:       []fulfillment.ShipmentItem{{ProductId: 7, Quantity: 5}},
+	}, shippedEvent)
+	assert.Equal(t, 1, m.db.Commits())
+	m.bins.AssertExpectations(t)
+	m.outbox.AssertExpectations(t)
+}
+
+func TestShip_CommitFails(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.fulfillmentRepo.On("GetPickListById", 10).Return(&fulfillment.PickList{
+		Id: 10, OrderId: 1, WarehouseId: 1, Status: entity.PickListPacked,
+		Items: []fulfillment.PickListItem{{Id: 100, ProductId: 7, Quantity: 5, PickedQuantity: 5}},
+	}, nil)
+	m.productWarehouseRepo.On("GetOrderWarehouseByOrderId", 1).Return([]product_warehouse.OrderWarehouse{
+		{Id: 1, OrderId: 1, ProductId: 7, WarehouseId: 1, ReservedStock: 5},
+	}, nil)
+	m.fulfillmentRepo.On("UpdatePickListStatus", 10, entity.PickListPacked, entity.PickListShipped).Return(true, nil)
+	m.fulfillmentRepo.On("InsertShipment", &fulfillment.Shipment{PickListId: 10, OrderId: 1, WarehouseId: 1}).Return(20, nil)
+	m.productWarehouseRepo.On("SubstractReservedStock", 7, 1, 5).Return(nil)
+	m.bins.On("TakeStock", 7, 1, 5).Return(nil)
+	m.valuation.On("Consume", entity.CostMovementShipment, 7, 1, 5).Return(nil)
+	m.fulfillmentRepo.On("SubstractOrderWarehouseReservedStock", 1, 5).Return(nil)
+	m.outbox.On("Add", entity.StockShippedEvent, mock.Anything).Return(nil)
+	m.db.FailCommits(errors.New("invalid connection"))
+
+	shippedEvent, err := fulfillmentUsecase.Ship(context.Background(), &fulfillment.PickListRequest{PickListId: 10})
+
+	// Assertions
+	assert.Nil(t, shippedEvent)
+	assert.EqualError(t, err, "invalid connection")
+}
+
+func TestShip_NotPacked(t *testing.T) {
+	fulfillmentUsecase, m := newTestUsecase()
+
+	m.fulfillmentRepo.On("GetPickListById", 10).Return(&fulfillment.PickList{Id: 10, OrderId: 1, WarehouseId: 1, Status: entity.PickListPicked}, nil)
+
+	shippedEvent, err := fulfillmentUsecase.Ship(context.Background(), &fulfillment.PickListRequest{PickListId: 10})
+
+	// Assertions
+	assert.Nil(t, shippedEvent)
+	assert.ErrorIs(t, err, entity.ErrorInvalidPickList)
+	m.fulfillmentRepo.AssertNotCalled(t, "InsertShipment", mock.Anything)
+}
@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+	"warehouse-service/logging"
+	"warehouse-service/models/outbox"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// batchSize is the number of events published per relay transaction.
+const batchSize = 100
+
+// maxRetryIn caps the backoff of an event the broker keeps refusing.
+const maxRetryIn = 5 * time.Minute
+
+type OutboxRepository interface {
+	Insert(ctx context.Context, tx *sqlx.Tx, event *outbox.Event) error
+	GetPendingForUpdate(ctx context.Context, tx *sqlx.Tx, limit int) ([]outbox.Event, error)
+	MarkPublished(ctx context.Context, tx *sqlx.Tx, id int) error
+	MarkFailed(ctx context.Context, tx *sqlx.Tx, id int, lastError string, retryIn time.Duration) error
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, messageId string, eventType string, data interface{}) error
+}
+
+// OutboxUsecase stores events in the transaction of the changes they report
+// and relays them to the broker once committed, so an event is published if
+// and only if its changes are, even across a crash or a broker outage.
+// Events are published at least once, a relay stopped between the publish
+// and its commit publishes the event again.
+type OutboxUsecase struct {
+	outboxRepo OutboxRepository
+	publisher  Publisher
+	mysql      *sqlx.DB
+	logger     *slog.Logger
+}
+
+func NewOutboxUsecase(outboxRepo OutboxRepository, publisher Publisher, mysql *sqlx.DB, logger *slog.Logger) *OutboxUsecase {
+	return &OutboxUsecase{
+		outboxRepo: outboxRepo,
+		publisher:  publisher,
+		mysql:      mysql,
+		logger:     logger,
+	}
+}
+
+// Add stores the event in tx. The trace context of ctx is kept with it, so
+// the publish joins the trace of the request.
+func (o *OutboxUsecase) Add(ctx context.Context, tx *sqlx.Tx, eventType string, data interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "OutboxUsecase.Add")
+	defer func() { tracing.End(span, err) }()
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	traceContext, err := json.Marshal(carrier)
+	if err != nil {
+		return err
+	}
+
+	return o.outboxRepo.Insert(ctx, tx, &outbox.Event{
+		MessageId:    logging.NewID(),
+		EventType:    eventType,
+		Payload:      payload,
+		TraceContext: traceContext,
+	})
+}
+
+// RunRelay publishes the pending events on every tick until ctx is done.
+func (o *OutboxUsecase) RunRelay(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if _, err := o.Relay(ctx); err != nil {
+			o.logger.ErrorContext(ctx, "failed to relay outbox events", "error", err)
+		}
+	}
+}
+
+// Drain publishes what is left in the outbox on shutdown, while the broker
+// connection is still open. Events the broker refuses stay in the outbox
+// for the next start.
+func (o *OutboxUsecase) Drain(ctx context.Context) error {
+	_, err := o.Relay(ctx)
+	return err
+}
+
+// Relay publishes the due events in batches until none is left or a publish
+// fails, and returns how many it published.
+func (o *OutboxUsecase) Relay(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		published, due, err := o.relayBatch(ctx)
+		total += published
+		if err != nil {
+			return total, err
+		}
+		if published < due || due < batchSize {
+			return total, nil
+		}
+	}
+	return total, ctx.Err()
+}
+
+// relayBatch publishes one batch in order and stops at the first failure,
+// the broker is most likely down and the rest would fail alike.
+func (o *OutboxUsecase) relayBatch(ctx context.Context) (int, int, error) {
+	tx, err := o.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	events, err := o.outboxRepo.GetPendingForUpdate(ctx, tx, batchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		publishErr := o.publish(ctx, event)
+		if publishErr != nil {
+			retry := retryIn(event.Attempts)
+			o.logger.WarnContext(ctx, "outbox event not published",
+				logging.MessageID, event.MessageId,
+				"event", event.EventType,
+				"attempts", event.Attempts+1,
+				"retry_in", retry.String(),
+				"error", publishErr)
+			err = o.outboxRepo.MarkFailed(ctx, tx, event.Id, publishErr.Error(), retry)
+			if err != nil {
+				return 0, 0, err
+			}
+			break
+		}
+		err = o.outboxRepo.MarkPublished(ctx, tx, event.Id)
+		if err != nil {
+			return 0, 0, err
+		}
+		published++
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, err
+	}
+	return published, len(events), nil
+}
+
+func (o *OutboxUsecase) publish(ctx context.Context, event outbox.Event) error {
+	carrier := propagation.MapCarrier{}
+	if len(event.TraceContext) > 0 {
+		json.Unmarshal(event.TraceContext, &carrier)
+	}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	return o.publisher.Publish(ctx, event.MessageId, event.EventType, json.RawMessage(event.Payload))
+}
+
+// retryIn doubles the wait with every failed attempt, from one second.
+func retryIn(attempts int) time.Duration {
+	if attempts >= 9 {
+		return maxRetryIn
+	}
+	return min(time.Second<<attempts, maxRetryIn)
+}
@@ -0,0 +1,140 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/entity"
+	"warehouse-service/models/outbox"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) Insert(ctx context.Context, tx *sqlx.Tx, event *outbox.Event) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) GetPendingForUpdate(ctx context.Context, tx *sqlx.Tx, limit int) ([]outbox.Event, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]outbox.Event), args.Error(1)
+}
+
+func (m *MockOutboxRepository) MarkPublished(ctx context.Context, tx *sqlx.Tx, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkFailed(ctx context.Context, tx *sqlx.Tx, id int, lastError string, retryIn time.Duration) error {
+	args := m.Called(id, lastError, retryIn)
+	return args.Error(0)
+}
+
+// Mock publisher
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(ctx context.Context, messageId string, eventType string, data interface{}) error {
+	args := m.Called(messageId, eventType, data)
+	return args.Error(0)
+}
+
+func TestAdd(t *testing.T) {
+	mockRepo := new(MockOutboxRepository)
+	db := mysqltest.NewDB()
+	outboxUsecase := NewOutboxUsecase(mockRepo, new(MockPublisher), db.DB, slog.Default())
+
+	mockRepo.On("Insert", mock.MatchedBy(func(event *outbox.Event) bool {
+		return event.EventType == entity.StockShippedEvent && string(event.Payload) == `{"order_id":7}` && event.MessageId != ""
+	})).Return(nil)
+
+	tx, _ := db.Beginx()
+	err := outboxUsecase.Add(context.Background(), tx, entity.StockShippedEvent, map[string]int{"order_id": 7})
+
+	// Assertions
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRelay_PublishesInOrder(t *testing.T) {
+	mockRepo := new(MockOutboxRepository)
+	mockPublisher := new(MockPublisher)
+	db := mysqltest.NewDB()
+	outboxUsecase := NewOutboxUsecase(mockRepo, mockPublisher, db.DB, slog.Default())
+
+	mockRepo.On("GetPendingForUpdate", batchSize).Return([]outbox.Event{
+		{Id: 1, MessageId: "a", EventType: entity.StockShippedEvent, Payload: []byte(`{"order_id":1}`)},
+		{Id: 2, MessageId: "b", EventType: entity.OrderUpdateStatusEvent, Payload: []byte(`{"id":2}`)},
+	}, nil)
+	mockPublisher.On("Publish", "a", entity.StockShippedEvent, json.RawMessage(`{"order_id":1}`)).Return(nil)
+	mockPublisher.On("Publish", "b", entity.OrderUpdateStatusEvent, json.RawMessage(`{"id":2}`)).Return(nil)
+	mockRepo.On("MarkPublished", 1).Return(nil)
+	mockRepo.On("MarkPublished", 2).Return(nil)
+
+	published, err := outboxUsecase.Relay(context.Background())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, 1, db.Commits())
+	mockPublisher.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRelay_KeepsFailedEvents(t *testing.T) {
+	mockRepo := new(MockOutboxRepository)
+	mockPublisher := new(MockPublisher)
+	db := mysqltest.NewDB()
+	outboxUsecase := NewOutboxUsecase(mockRepo, mockPublisher, db.DB, slog.Default())
+
+	mockRepo.On("GetPendingForUpdate", batchSize).Return([]outbox.Event{
+		{Id: 1, MessageId: "a", EventType: entity.StockShippedEvent, Payload: []byte(`{}`), Attempts: 2},
+		{Id: 2, MessageId: "b", EventType: entity.StockShippedEvent, Payload: []byte(`{}`)},
+	}, nil)
+	mockPublisher.On("Publish", "a", entity.StockShippedEvent, mock.Anything).Return(entity.ErrorBrokerNotConnected)
+	mockRepo.On("MarkFailed", 1, entity.ErrorBrokerNotConnected.Error(), 4*time.Second).Return(nil)
+
+	published, err := outboxUsecase.Relay(context.Background())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published)
+	// the failed attempt is committed, the second event waits for the next relay
+	assert.Equal(t, 1, db.Commits())
+	mockPublisher.AssertNotCalled(t, "Publish", "b", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "MarkPublished", mock.Anything)
+}
+
+func TestRelay_RollsBackOnRepositoryError(t *testing.T) {
+	mockRepo := new(MockOutboxRepository)
+	db := mysqltest.NewDB()
+	outboxUsecase := NewOutboxUsecase(mockRepo, new(MockPublisher), db.DB, slog.Default())
+
+	mockRepo.On("GetPendingForUpdate", batchSize).Return([]outbox.Event(nil), errors.New("lock wait timeout"))
+
+	_, err := outboxUsecase.Relay(context.Background())
+
+	// Assertions
+	assert.EqualError(t, err, "lock wait timeout")
+	assert.Equal(t, 1, db.Rollbacks())
+}
+
+func TestRetryIn(t *testing.T) {
+	// Assertions
+	assert.Equal(t, time.Second, retryIn(0))
+	assert.Equal(t, 8*time.Second, retryIn(3))
+	assert.Equal(t, maxRetryIn, retryIn(9))
+	assert.Equal(t, maxRetryIn, retryIn(60))
+}
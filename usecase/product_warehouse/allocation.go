@@ -0,0 +1,367 @@
+package product_warehouse
+
+import (
+	"context"
+	"math"
+	"sort"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+)
+
+const earthRadiusKm = 6371.0
+
+type allocation struct {
+	productId   int
+	warehouseId int
+	quantity    int
+}
+
+func (p *ProductWarehouseUsecase) allocate(ctx context.Context, operations []product_warehouse.StockOperationRequest, bundleOperations []product_warehouse.BundleOperation, allocationMode string, destination *product_warehouse.Coordinate) ([]allocation, error) {
+	bundleAllocations := []allocation{}
+	if len(bundleOperations) > 0 {
+		var err error
+		bundleAllocations, operations, err = p.allocateBundles(ctx, operations, bundleOperations, allocationMode, destination)
+		if err != nil {
+			return nil, err
+		}
+	}
+	taken := takenStock(bundleAllocations)
+
+	if allocationMode != entity.AllocationNearest {
+		allocations, err := p.allocateSequential(ctx, operations, taken)
+		if err != nil {
+			return nil, err
+		}
+		return mergeAllocations(append(bundleAllocations, allocations...)), nil
+	}
+
+	productIds := []int{}
+	for _, operation := range operations {
+		productIds = append(productIds, operation.ProductId)
+	}
+	warehouseStocks, err := p.productWarehouseRepo.GetAllByProductIdsWithLocation(ctx, productIds)
+	if err != nil {
+		return nil, err
+	}
+	for i := range warehouseStocks {
+		warehouseStocks[i].AvailableStock -= taken[warehouseStocks[i].WarehouseId][warehouseStocks[i].ProductId]
+	}
+	allocations, err := allocateNearest(operations, warehouseStocks, *destination)
+	if err != nil {
+		return nil, err
+	}
+	return mergeAllocations(append(bundleAllocations, allocations...)), nil
+}
+
+// allocateSequential fills each line from warehouses in registration order,
+// leaving out the stock already taken by bundles. A line the warehouses cannot
+// fill fails the order with entity.ErrorInsufficientStock.
+func (p *ProductWarehouseUsecase) allocateSequential(ctx context.Context, operations []product_warehouse.StockOperationRequest, taken map[int]map[int]int) ([]allocation, error) {
+	allocations := []allocation{}
+	for _, operation := range operations {
+		productWarehouses, err := p.productWarehouseRepo.GetAllByProductId(ctx, operation.ProductId)
+		if err != nil {
+			return nil, err
+		}
+
+		reservedStock := operation.Quantity
+
+		for i := 0; i < len(productWarehouses) && reservedStock > 0; i++ {
+			warehouse := productWarehouses[i]
+
+			queryQuantity := min(warehouse.AvailableStock-taken[warehouse.WarehouseId][operation.ProductId], reservedStock)
+			if queryQuantity <= 0 {
+				continue
+			}
+
+			allocations = append(allocations, allocation{
+				productId:   operation.ProductId,
+				warehouseId: warehouse.WarehouseId,
+				quantity:    queryQuantity,
+			})
+
+			reservedStock -= queryQuantity
+		}
+		if reservedStock > 0 {
+			return nil, entity.ErrorInsufficientStock
+		}
+	}
+	return allocations, nil
+}
+
+// allocateBundles allocates the bundles before the other lines. Warehouses are
+// tried in registration order, or by distance for nearest allocation, and the
+// operations are returned without the component stock the bundles took.
+func (p *ProductWarehouseUsecase) allocateBundles(ctx context.Context, operations []product_warehouse.StockOperationRequest, bundleOperations []product_warehouse.BundleOperation, allocationMode string, destination *product_warehouse.Coordinate) ([]allocation, []product_warehouse.StockOperationRequest, error) {
+	productIds := []int{}
+	for _, operation := range operations {
+		productIds = append(productIds, operation.ProductId)
+	}
+	if len(productIds) == 0 {
+		return []allocation{}, operations, nil
+	}
+	warehouseStocks, err := p.productWarehouseRepo.GetAllByProductIdsWithLocation(ctx, productIds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warehouseIds []int
+	if allocationMode == entity.AllocationNearest {
+		warehouseIds = warehousesByDistance(warehouseStocks, *destination)
+	} else {
+		seen := make(map[int]bool)
+		for _, warehouseStock := range warehouseStocks {
+			if !seen[warehouseStock.WarehouseId] {
+				seen[warehouseStock.WarehouseId] = true
+				warehouseIds = append(warehouseIds, warehouseStock.WarehouseId)
+			}
+		}
+	}
+	return allocateBundleUnits(operations, bundleOperations, warehouseStocks, warehouseIds)
+}
+
+// allocateBundleUnits takes every bundle with all its components from one
+// warehouse. A bundle line is split across warehouses in whole bundles only.
+// Components missing from the operations, such as flash sale products, are
+// reserved on their own.
+func allocateBundleUnits(operations []product_warehouse.StockOperationRequest, bundleOperations []product_warehouse.BundleOperation, warehouseStocks []product_warehouse.WarehouseStockLocation, warehouseIds []int) ([]allocation, []product_warehouse.StockOperationRequest, error) {
+	demand := make(map[int]int)
+	for _, operation := range operations {
+		demand[operation.ProductId] += operation.Quantity
+	}
+	stocks := make(map[int]map[int]int)
+	for _, warehouseStock := range warehouseStocks {
+		if _, ok := stocks[warehouseStock.WarehouseId]; !ok {
+			stocks[warehouseStock.WarehouseId] = make(map[int]int)
+		}
+		stocks[warehouseStock.WarehouseId][warehouseStock.ProductId] += warehouseStock.AvailableStock
+	}
+
+	allocations := []allocation{}
+	for _, bundleOperation := range bundleOperations {
+		components := []product_warehouse.StockOperationRequest{}
+		for _, component := range bundleOperation.Components {
+			if _, ok := demand[component.ProductId]; ok {
+				components = append(components, component)
+			}
+		}
+		if len(components) == 0 {
+			continue
+		}
+
+		remaining := bundleOperation.Quantity
+		for _, warehouseId := range warehouseIds {
+			if remaining == 0 {
+				break
+			}
+			bundles := remaining
+			for _, component := range components {
+				bundles = min(bundles, stocks[warehouseId][component.ProductId]/component.Quantity)
+			}
+			if bundles <= 0 {
+				continue
+			}
+			for _, component := range components {
+				quantity := bundles * component.Quantity
+				allocations = append(allocations, allocation{
+					productId:   component.ProductId,
+					warehouseId: warehouseId,
+					quantity:    quantity,
+				})
+				stocks[warehouseId][component.ProductId] -= quantity
+				demand[component.ProductId] -= quantity
+			}
+			remaining -= bundles
+		}
+		if remaining > 0 {
+			return nil, nil, entity.ErrorInsufficientStock
+		}
+	}
+
+	rest := []product_warehouse.StockOperationRequest{}
+	for _, operation := range operations {
+		operation.Quantity = min(operation.Quantity, demand[operation.ProductId])
+		if operation.Quantity > 0 {
+			rest = append(rest, operation)
+		}
+		demand[operation.ProductId] -= operation.Quantity
+	}
+	return allocations, rest, nil
+}
+
+// totalByProduct adds up the lines of each product, a product can be ordered
+// on several lines and as a bundle component. Products keep the order of
+// their first line.
+func totalByProduct(operations []product_warehouse.StockOperationRequest) []product_warehouse.StockOperationRequest {
+	totals := []product_warehouse.StockOperationRequest{}
+	index := make(map[int]int)
+	for _, operation := range operations {
+		if i, ok := index[operation.ProductId]; ok {
+			totals[i].Quantity += operation.Quantity
+			continue
+		}
+		index[operation.ProductId] = len(totals)
+		totals = append(totals, operation)
+	}
+	return totals
+}
+
+func takenStock(allocations []allocation) map[int]map[int]int {
+	taken := make(map[int]map[int]int)
+	for _, allocation := range allocations {
+		if _, ok := taken[allocation.warehouseId]; !ok {
+			taken[allocation.warehouseId] = make(map[int]int)
+		}
+		taken[allocation.warehouseId][allocation.productId] += allocation.quantity
+	}
+	return taken
+}
+
+// mergeAllocations keeps one allocation per product and warehouse, so an order
+// has a single reservation row for each.
+func mergeAllocations(allocations []allocation) []allocation {
+	merged := []allocation{}
+	index := make(map[[2]int]int)
+	for _, allocation := range allocations {
+		key := [2]int{allocation.productId, allocation.warehouseId}
+		if i, ok := index[key]; ok {
+			merged[i].quantity += allocation.quantity
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, allocation)
+	}
+	return merged
+}
+
+// allocateNearest ranks warehouses by distance to the destination and keeps the
+// number of shipments low: the nearest warehouse that can ship the whole order
+// wins, otherwise each line prefers a warehouse already shipping part of the
+// order, then the nearest warehouse that can ship the whole line, and only then
+// splits the line across warehouses in distance order.
+func allocateNearest(operations []product_warehouse.StockOperationRequest, warehouseStocks []product_warehouse.WarehouseStockLocation, destination product_warehouse.Coordinate) ([]allocation, error) {
+	warehouseIds := warehousesByDistance(warehouseStocks, destination)
+	stocks := make(map[int]map[int]int)
+	for _, warehouseStock := range warehouseStocks {
+		if _, ok := stocks[warehouseStock.WarehouseId]; !ok {
+			stocks[warehouseStock.WarehouseId] = make(map[int]int)
+		}
+		stocks[warehouseStock.WarehouseId][warehouseStock.ProductId] += warehouseStock.AvailableStock
+	}
+
+	demand := make(map[int]int)
+	for _, operation := range operations {
+		demand[operation.ProductId] += operation.Quantity
+	}
+	for _, warehouseId := range warehouseIds {
+		if canFulfill(stocks[warehouseId], demand) {
+			allocations := []allocation{}
+			for _, operation := range operations {
+				allocations = append(allocations, allocation{
+					productId:   operation.ProductId,
+					warehouseId: warehouseId,
+					quantity:    operation.Quantity,
+				})
+			}
+			return allocations, nil
+		}
+	}
+
+	allocations := []allocation{}
+	used := make(map[int]bool)
+	for _, operation := range operations {
+		candidates := make([]int, 0, len(warehouseIds))
+		for _, warehouseId := range warehouseIds {
+			if used[warehouseId] {
+				candidates = append(candidates, warehouseId)
+			}
+		}
+		for _, warehouseId := range warehouseIds {
+			if !used[warehouseId] {
+				candidates = append(candidates, warehouseId)
+			}
+		}
+
+		remaining := operation.Quantity
+		for _, warehouseId := range candidates {
+			if stocks[warehouseId][operation.ProductId] >= remaining {
+				candidates = []int{warehouseId}
+				break
+			}
+		}
+
+		for _, warehouseId := range candidates {
+			if remaining == 0 {
+				break
+			}
+			quantity := min(stocks[warehouseId][operation.ProductId], remaining)
+			if quantity <= 0 {
+				continue
+			}
+			allocations = append(allocations, allocation{
+				productId:   operation.ProductId,
+				warehouseId: warehouseId,
+				quantity:    quantity,
+			})
+			stocks[warehouseId][operation.ProductId] -= quantity
+			used[warehouseId] = true
+			remaining -= quantity
+		}
+		if remaining > 0 {
+			return nil, entity.ErrorInsufficientStock
+		}
+	}
+	return allocations, nil
+}
+
+// warehousesByDistance returns the warehouses nearest first. Warehouses
+// without a location come last.
+func warehousesByDistance(warehouseStocks []product_warehouse.WarehouseStockLocation, destination product_warehouse.Coordinate) []int {
+	distances := make(map[int]float64)
+	for _, warehouseStock := range warehouseStocks {
+		if _, ok := distances[warehouseStock.WarehouseId]; ok {
+			continue
+		}
+		distances[warehouseStock.WarehouseId] = math.Inf(1)
+		if warehouseStock.Latitude != nil && warehouseStock.Longitude != nil {
+			distances[warehouseStock.WarehouseId] = distanceKm(destination, product_warehouse.Coordinate{
+				Latitude:  *warehouseStock.Latitude,
+				Longitude: *warehouseStock.Longitude,
+			})
+		}
+	}
+
+	warehouseIds := make([]int, 0, len(distances))
+	for warehouseId := range distances {
+		warehouseIds = append(warehouseIds, warehouseId)
+	}
+	sort.Slice(warehouseIds, func(i, j int) bool {
+		if distances[warehouseIds[i]] != distances[warehouseIds[j]] {
+			return distances[warehouseIds[i]] < distances[warehouseIds[j]]
+		}
+		return warehouseIds[i] < warehouseIds[j]
+	})
+	return warehouseIds
+}
+
+func canFulfill(stock map[int]int, demand map[int]int) bool {
+	for productId, quantity := range demand {
+		if stock[productId] < quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// distanceKm returns the great-circle distance between two coordinates using
+// the haversine formula.
+func distanceKm(from product_warehouse.Coordinate, to product_warehouse.Coordinate) float64 {
+	fromLatitude := from.Latitude * math.Pi / 180
+	toLatitude := to.Latitude * math.Pi / 180
+	deltaLatitude := (to.Latitude - from.Latitude) * math.Pi / 180
+	deltaLongitude := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(fromLatitude)*math.Cos(toLatitude)*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
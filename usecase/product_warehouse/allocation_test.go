@@ -0,0 +1,205 @@
+package product_warehouse
+
+import (
+	"testing"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	jakarta  = product_warehouse.Coordinate{Latitude: -6.2088, Longitude: 106.8456}
+	bandung  = product_warehouse.Coordinate{Latitude: -6.9175, Longitude: 107.6191}
+	surabaya = product_warehouse.Coordinate{Latitude: -7.2575, Longitude: 112.7521}
+)
+
+func warehouseStock(productId int, warehouseId int, availableStock int, location *product_warehouse.Coordinate) product_warehouse.WarehouseStockLocation {
+	warehouseStock := product_warehouse.WarehouseStockLocation{
+		ProductId:      productId,
+		WarehouseId:    warehouseId,
+		AvailableStock: availableStock,
+	}
+	if location != nil {
+		warehouseStock.Latitude = &location.Latitude
+		warehouseStock.Longitude = &location.Longitude
+	}
+	return warehouseStock
+}
+
+func TestDistanceKm(t *testing.T) {
+	assert.InDelta(t, 0, distanceKm(jakarta, jakarta), 1e-9)
+	assert.InDelta(t, 116.24, distanceKm(jakarta, bandung), 0.01)
+	assert.InDelta(t, 662.57, distanceKm(jakarta, surabaya), 0.01)
+	assert.InDelta(t, distanceKm(jakarta, surabaya), distanceKm(surabaya, jakarta), 1e-9)
+	// half of the earth's circumference
+	assert.InDelta(t, 20015.09, distanceKm(product_warehouse.Coordinate{}, product_warehouse.Coordinate{Longitude: 180}), 0.01)
+}
+
+func TestAllocateNearest_SingleWarehouse(t *testing.T) {
+	operations := []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 5},
+		{ProductId: 2, Quantity: 5},
+	}
+	// Bandung is closest but only Surabaya holds both products.
+	warehouseStocks := []product_warehouse.WarehouseStockLocation{
+		warehouseStock(1, 10, 100, &bandung),
+		warehouseStock(1, 20, 10, &surabaya),
+		warehouseStock(2, 20, 10, &surabaya),
+	}
+
+	allocations, err := allocateNearest(operations, warehouseStocks, jakarta)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []allocation{
+		{productId: 1, warehouseId: 20, quantity: 5},
+		{productId: 2, warehouseId: 20, quantity: 5},
+	}, allocations)
+}
+
+func TestAllocateNearest_MinimizesSplits(t *testing.T) {
+	operations := []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 8},
+		{ProductId: 2, Quantity: 4},
+	}
+	warehouseStocks := []product_warehouse.WarehouseStockLocation{
+		warehouseStock(1, 10, 5, &jakarta),
+		warehouseStock(1, 20, 10, &surabaya),
+		warehouseStock(2, 10, 4, &jakarta),
+		warehouseStock(2, 30, 4, nil),
+	}
+
+	allocations, err := allocateNearest(operations, warehouseStocks, bandung)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []allocation{
+		{productId: 1, warehouseId: 20, quantity: 8},
+		{productId: 2, warehouseId: 10, quantity: 4},
+	}, allocations)
+}
+
+func TestAllocateNearest_SplitsLineByDistance(t *testing.T) {
+	operations := []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 12},
+	}
+	warehouseStocks := []product_warehouse.WarehouseStockLocation{
+		warehouseStock(1, 10, 5, nil),
+		warehouseStock(1, 20, 5, &surabaya),
+		warehouseStock(1, 30, 5, &bandung),
+	}
+
+	allocations, err := allocateNearest(operations, warehouseStocks, jakarta)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []allocation{
+		{productId: 1, warehouseId: 30, quantity: 5},
+		{productId: 1, warehouseId: 20, quantity: 5},
+		{productId: 1, warehouseId: 10, quantity: 2},
+	}, allocations)
+}
+
+func TestAllocateNearest_InsufficientStock(t *testing.T) {
+	operations := []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 6},
+	}
+	warehouseStocks := []product_warehouse.WarehouseStockLocation{
+		warehouseStock(1, 10, 5, &bandung),
+	}
+
+	allocations, err := allocateNearest(operations, warehouseStocks, jakarta)
+
+	// Assertions
+	assert.Nil(t, allocations)
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+}
+
+// gift set = 2x product 1 + 1x product 2
+var giftSet = product_warehouse.BundleOperation{
+	BundleProductId: 100,
+	Components: []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 2},
+		{ProductId: 2, Quantity: 1},
+	},
+}
+
+func TestAllocateBundleUnits_OneWarehousePerBundle(t *testing.T) {
+	bundleOperation := giftSet
+	bundleOperation.Quantity = 3
+	operations := []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 7},
+		{ProductId: 2, Quantity: 3},
+	}
+	// warehouse 1 has most of product 1 but only one product 2
+	warehouseStocks := []product_warehouse.WarehouseStockLocation{
+		warehouseStock(1, 1, 10, nil),
+		warehouseStock(2, 1, 1, nil),
+		warehouseStock(1, 2, 4, nil),
+		warehouseStock(2, 2, 5, nil),
+	}
+
+	allocations, rest, err := allocateBundleUnits(operations, []product_warehouse.BundleOperation{bundleOperation}, warehouseStocks, []int{1, 2})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []allocation{
+		{productId: 1, warehouseId: 1, quantity: 2},
+		{productId: 2, warehouseId: 1, quantity: 1},
+		{productId: 1, warehouseId: 2, quantity: 4},
+		{productId: 2, warehouseId: 2, quantity: 2},
+	}, allocations)
+	assert.Equal(t, []product_warehouse.StockOperationRequest{{ProductId: 1, Quantity: 1}}, rest)
+}
+
+func TestAllocateBundleUnits_ComponentsNotColocated(t *testing.T) {
+	bundleOperation := giftSet
+	bundleOperation.Quantity = 1
+	operations := []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 2},
+		{ProductId: 2, Quantity: 1},
+	}
+	warehouseStocks := []product_warehouse.WarehouseStockLocation{
+		warehouseStock(1, 1, 10, nil),
+		warehouseStock(2, 2, 5, nil),
+	}
+
+	_, _, err := allocateBundleUnits(operations, []product_warehouse.BundleOperation{bundleOperation}, warehouseStocks, []int{1, 2})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+}
+
+func TestAllocateBundleUnits_FlashSaleComponentSkipped(t *testing.T) {
+	bundleOperation := giftSet
+	bundleOperation.Quantity = 2
+	// product 2 is on flash sale and reserved from its slots
+	operations := []product_warehouse.StockOperationRequest{
+		{ProductId: 1, Quantity: 4},
+	}
+	warehouseStocks := []product_warehouse.WarehouseStockLocation{
+		warehouseStock(1, 1, 4, nil),
+	}
+
+	allocations, rest, err := allocateBundleUnits(operations, []product_warehouse.BundleOperation{bundleOperation}, warehouseStocks, []int{1})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []allocation{{productId: 1, warehouseId: 1, quantity: 4}}, allocations)
+	assert.Empty(t, rest)
+}
+
+func TestMergeAllocations(t *testing.T) {
+	allocations := mergeAllocations([]allocation{
+		{productId: 1, warehouseId: 1, quantity: 2},
+		{productId: 2, warehouseId: 1, quantity: 1},
+		{productId: 1, warehouseId: 1, quantity: 3},
+	})
+
+	// Assertions
+	assert.Equal(t, []allocation{
+		{productId: 1, warehouseId: 1, quantity: 5},
+		{productId: 2, warehouseId: 1, quantity: 1},
+	}, allocations)
+}
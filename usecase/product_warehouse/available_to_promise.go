@@ -0,0 +1,189 @@
+package product_warehouse
+
+import (
+	"context"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
+)
+
+func (p *ProductWarehouseUsecase) RegisterInbound(ctx context.Context, inbound *product_warehouse.InboundRegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.RegisterInbound")
+	defer func() { tracing.End(span, err) }()
+
+	unitCost, err := p.toBaseUnitCost(ctx, inbound.ProductId, &inbound.Unit, &inbound.Quantity, inbound.UnitCost)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = p.productWarehouseRepo.InsertInbound(ctx, tx, &product_warehouse.InboundStock{
+		ProductId:   inbound.ProductId,
+		WarehouseId: inbound.WarehouseId,
+		Quantity:    inbound.Quantity,
+		UnitCost:    unitCost,
+		Source:      inbound.Source,
+		ExpectedAt:  inbound.ExpectedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *ProductWarehouseUsecase) ReceiveInbound(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.ReceiveInbound")
+	defer func() { tracing.End(span, err) }()
+
+	inbound, err := p.productWarehouseRepo.GetInboundById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inbound.Status != entity.InboundPending {
+		return entity.ErrorInboundNotPending
+	}
+
+	tx, err := p.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = p.capacity.Check(ctx, tx, inbound.WarehouseId, inbound.ProductId, inbound.Quantity)
+	if err != nil {
+		return err
+	}
+
+	updated, err := p.productWarehouseRepo.UpdateInboundStatus(ctx, tx, inbound.Id, entity.InboundPending, entity.InboundReceived)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		// received concurrently by another request
+		err = entity.ErrorInboundNotPending
+		return err
+	}
+
+	err = p.productWarehouseRepo.AddAvailableStock(ctx, tx, inbound.ProductId, inbound.WarehouseId, inbound.Quantity)
+	if err != nil {
+		return err
+	}
+
+	if inbound.FromWarehouseId != nil {
+		// a transfer brings the cost layers of its source warehouse
+		err = p.valuation.Transfer(ctx, tx, inbound.ProductId, *inbound.FromWarehouseId, inbound.WarehouseId, inbound.Quantity)
+	} else {
+		err = p.valuation.Receive(ctx, tx, entity.CostMovementReceipt, inbound.ProductId, inbound.WarehouseId, inbound.Quantity, inbound.UnitCost)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *ProductWarehouseUsecase) GetAvailableToPromise(ctx context.Context, atpRequest *product_warehouse.AvailableToPromiseRequest) (_ *product_warehouse.AvailableToPromise, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.GetAvailableToPromise")
+	defer func() { tracing.End(span, err) }()
+
+	unit := atpRequest.Unit
+	err = p.toBaseUnit(ctx, atpRequest.ProductId, &atpRequest.Unit, &atpRequest.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	stockSummary, err := p.productWarehouseRepo.GetStockSummary(ctx, atpRequest.ProductId, atpRequest.ShopId)
+	if err != nil {
+		return nil, err
+	}
+
+	today := startOfDay(time.Now())
+	inboundStocks, err := p.productWarehouseRepo.GetPendingInbound(ctx, atpRequest.ProductId, atpRequest.ShopId, today.AddDate(0, 0, atpRequest.HorizonDays))
+	if err != nil {
+		return nil, err
+	}
+
+	atp := buildAvailableToPromise(atpRequest, stockSummary, inboundStocks, today)
+	if unit == "" {
+		return atp, nil
+	}
+	err = p.availableToPromiseFromBase(ctx, atp, unit)
+	if err != nil {
+		return nil, err
+	}
+	return atp, nil
+}
+
+// startOfDay returns the UTC midnight of now's UTC day. Expected dates come
+// back from MySQL in UTC, so the day buckets and the horizon are UTC days
+// whatever the zone of the server.
+func startOfDay(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// buildAvailableToPromise produces one point per day of the horizon. Available
+// stock already excludes reservations, so the curve starts at available stock
+// minus safety stock and grows with each day's inbound. Flash sale slot stock
+// is counted as available rather than reserved. Overdue inbound is counted on
+// the first day.
+func buildAvailableToPromise(atpRequest *product_warehouse.AvailableToPromiseRequest, stockSummary *product_warehouse.StockSummary, inboundStocks []product_warehouse.InboundStock, today time.Time) *product_warehouse.AvailableToPromise {
+	inboundByDay := make([]int, atpRequest.HorizonDays)
+	totalInbound := 0
+	for _, inbound := range inboundStocks {
+		day := 0
+		if inbound.ExpectedAt.After(today) {
+			day = int(inbound.ExpectedAt.Sub(today).Hours() / 24)
+		}
+		if day >= atpRequest.HorizonDays {
+			continue
+		}
+		inboundByDay[day] += inbound.Quantity
+		totalInbound += inbound.Quantity
+	}
+
+	atp := &product_warehouse.AvailableToPromise{
+		ProductId:   atpRequest.ProductId,
+		ShopId:      atpRequest.ShopId,
+		OnHand:      stockSummary.AvailableStock + stockSummary.ReservedStock,
+		Reserved:    stockSummary.ReservedStock - stockSummary.SlotStock,
+		SafetyStock: stockSummary.SafetyStock,
+		Inbound:     totalInbound,
+		Curve:       make([]product_warehouse.AvailableToPromisePoint, 0, atpRequest.HorizonDays),
+	}
+
+	available := stockSummary.AvailableStock + stockSummary.SlotStock - stockSummary.SafetyStock
+	for day := 0; day < atpRequest.HorizonDays; day++ {
+		available += inboundByDay[day]
+		date := today.AddDate(0, 0, day).Format(time.DateOnly)
+		atp.Curve = append(atp.Curve, product_warehouse.AvailableToPromisePoint{
+			Date:      date,
+			Inbound:   inboundByDay[day],
+			Available: max(available, 0),
+		})
+		if atp.EarliestPromiseDate == nil && atpRequest.Quantity > 0 && available >= atpRequest.Quantity {
+			atp.EarliestPromiseDate = &date
+		}
+	}
+	return atp
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,126 @@
+package product_warehouse
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildAvailableToPromise(t *testing.T) {
+	today := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	request := &product_warehouse.AvailableToPromiseRequest{
+		ProductId:   1,
+		ShopId:      2,
+		HorizonDays: 4,
+		Quantity:    40,
+	}
+	stockSummary := &product_warehouse.StockSummary{AvailableStock: 20, ReservedStock: 5, SafetyStock: 10}
+	inboundStocks := []product_warehouse.InboundStock{
+		{Quantity: 5, ExpectedAt: today.AddDate(0, 0, -2)},
+		{Quantity: 15, ExpectedAt: today.Add(36 * time.Hour)},
+		{Quantity: 10, ExpectedAt: today.AddDate(0, 0, 2)},
+	}
+
+	atp := buildAvailableToPromise(request, stockSummary, inboundStocks, today)
+
+	// Assertions
+	assert.Equal(t, 25, atp.OnHand)
+	assert.Equal(t, 5, atp.Reserved)
+	assert.Equal(t, 30, atp.Inbound)
+	assert.Equal(t, []product_warehouse.AvailableToPromisePoint{
+		{Date: "2024-03-01", Inbound: 5, Available: 15},
+		{Date: "2024-03-02", Inbound: 15, Available: 30},
+		{Date: "2024-03-03", Inbound: 10, Available: 40},
+		{Date: "2024-03-04", Inbound: 0, Available: 40},
+	}, atp.Curve)
+	assert.Equal(t, "2024-03-03", *atp.EarliestPromiseDate)
+}
+
+func TestBuildAvailableToPromise_CannotPromise(t *testing.T) {
+	today := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	request := &product_warehouse.AvailableToPromiseRequest{
+		ProductId:   1,
+		ShopId:      2,
+		HorizonDays: 2,
+		Quantity:    100,
+	}
+	stockSummary := &product_warehouse.StockSummary{AvailableStock: 3, SafetyStock: 10}
+
+	atp := buildAvailableToPromise(request, stockSummary, nil, today)
+
+	// Assertions
+	assert.Equal(t, 0, atp.Curve[0].Available)
+	assert.Nil(t, atp.EarliestPromiseDate)
+}
+
+func TestBuildAvailableToPromise_SlotStockIsAvailable(t *testing.T) {
+	today := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	request := &product_warehouse.AvailableToPromiseRequest{
+		ProductId:   1,
+		ShopId:      2,
+		HorizonDays: 1,
+		Quantity:    30,
+	}
+	// 25 of the 30 reserved are flash sale slots nobody has bought yet
+	stockSummary := &product_warehouse.StockSummary{AvailableStock: 10, ReservedStock: 30, SlotStock: 25}
+
+	atp := buildAvailableToPromise(request, stockSummary, nil, today)
+
+	// Assertions
+	assert.Equal(t, 40, atp.OnHand)
+	assert.Equal(t, 5, atp.Reserved)
+	assert.Equal(t, 35, atp.Curve[0].Available)
+	assert.Equal(t, "2024-03-01", *atp.EarliestPromiseDate)
+}
+
+// caseUnit converts between eaches and cases of 12
+type caseUnit struct{}
+
+func (caseUnit) ToBase(ctx context.Context, productId int, unit string, quantity int) (int, error) {
+	return quantity * 12, nil
+}
+
+func (caseUnit) FromBase(ctx context.Context, productId int, unit string, quantity int) (int, error) {
+	return quantity / 12, nil
+}
+
+func TestGetAvailableToPromise_InUnit(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	productWarehouseUsecase := NewProductWarehouseUsecase(mockRepo, fakePublisher{}, nil, nil, fakeBins{}, fakeBundle{}, caseUnit{}, fakeChannelAllocator{}, nil, mysqltest.NewDB().DB, slog.Default())
+
+	mockRepo.On("GetStockSummary", 1, 2).Return(&product_warehouse.StockSummary{AvailableStock: 30, ReservedStock: 6, SafetyStock: 6}, nil)
+	mockRepo.On("GetPendingInbound", 1, 2).Return([]product_warehouse.InboundStock{
+		{Quantity: 24, ExpectedAt: time.Now().AddDate(0, 0, -1)},
+	}, nil)
+
+	atp, err := productWarehouseUsecase.GetAvailableToPromise(context.Background(), &product_warehouse.AvailableToPromiseRequest{
+		ProductId:   1,
+		ShopId:      2,
+		HorizonDays: 1,
+		Quantity:    4,
+		Unit:        "case",
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "case", atp.Unit)
+	assert.Equal(t, 3, atp.OnHand)
+	assert.Equal(t, 0, atp.Reserved)
+	assert.Equal(t, 2, atp.Inbound)
+	// 30 - 6 + 24 = 48 eaches, exactly 4 cases
+	assert.Equal(t, 4, atp.Curve[0].Available)
+	assert.NotNil(t, atp.EarliestPromiseDate)
+}
+
+func TestStartOfDay_UTC(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	// Assertions
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), startOfDay(time.Date(2024, 3, 2, 1, 30, 0, 0, jakarta)))
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), startOfDay(time.Date(2024, 3, 2, 8, 0, 0, 0, jakarta)))
+}
@@ -0,0 +1,71 @@
+package product_warehouse
+
+import (
+	"context"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (p *ProductWarehouseUsecase) UpdateDimension(ctx context.Context, dimension *product_warehouse.DimensionRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.UpdateDimension")
+	defer func() { tracing.End(span, err) }()
+
+	return p.productWarehouseRepo.UpsertDimension(ctx, dimension)
+}
+
+type CapacityRepository interface {
+	LockWarehouse(ctx context.Context, tx *sqlx.Tx, warehouseId int) error
+	GetWarehouseUtilization(ctx context.Context, tx *sqlx.Tx, warehouseId int) (*warehouse.Utilization, error)
+	GetUnitVolume(ctx context.Context, productId int) (float64, error)
+}
+
+// CapacityChecker checks stock coming into a warehouse against its capacity,
+// for every path that adds stock.
+type CapacityChecker struct {
+	capacityRepo CapacityRepository
+}
+
+func NewCapacityChecker(capacityRepo CapacityRepository) *CapacityChecker {
+	return &CapacityChecker{
+		capacityRepo: capacityRepo,
+	}
+}
+
+// Check makes sure the warehouse can hold quantity more units of the product.
+// Warehouses without a capacity accept any quantity. The warehouse stays
+// locked until tx ends, so stock added concurrently is checked against this
+// stock. Under repeatable read it must be the first read of tx, later reads
+// would see the snapshot of the first one.
+func (c *CapacityChecker) Check(ctx context.Context, tx *sqlx.Tx, warehouseId int, productId int, quantity int) (err error) {
+	ctx, span := tracing.Start(ctx, "CapacityChecker.Check")
+	defer func() { tracing.End(span, err) }()
+
+	err = c.capacityRepo.LockWarehouse(ctx, tx, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	utilization, err := c.capacityRepo.GetWarehouseUtilization(ctx, tx, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	if utilization.CapacityUnits != nil && utilization.UsedUnits+quantity > *utilization.CapacityUnits {
+		return entity.ErrorOverCapacity
+	}
+
+	if utilization.CapacityVolume != nil {
+		unitVolume, err := c.capacityRepo.GetUnitVolume(ctx, productId)
+		if err != nil {
+			return err
+		}
+		if utilization.UsedVolume+unitVolume*float64(quantity) > *utilization.CapacityVolume {
+			return entity.ErrorOverCapacity
+		}
+	}
+	return nil
+}
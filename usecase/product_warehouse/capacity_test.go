@@ -0,0 +1,77 @@
+package product_warehouse
+
+import (
+	"context"
+	"testing"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/warehouse"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func addStock(quantity int) *product_warehouse.StockOperationRequest {
+	return &product_warehouse.StockOperationRequest{ProductId: 7, WarehouseId: 2, Quantity: quantity}
+}
+
+func TestAddStock_AtCapacity(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	mockValuation := new(MockValuation)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), mockValuation, db)
+	capacityUnits := 100
+	capacityVolume := 50.0
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetWarehouseUtilization", 2).Return(&warehouse.Utilization{
+		CapacityUnits: &capacityUnits, UsedUnits: 90, CapacityVolume: &capacityVolume, UsedVolume: 45,
+	}, nil)
+	mockRepo.On("GetUnitVolume", 7).Return(0.5, nil)
+	mockRepo.On("AddAvailableStock", 7, 2, 10).Return(nil)
+	mockValuation.On("Receive", entity.CostMovementReceipt, 7, 2, 10, mock.Anything).Return(nil)
+
+	err := productWarehouseUsecase.AddStock(context.Background(), addStock(10))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, db.Commits())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAddStock_OverCapacityUnits(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), new(MockValuation), db)
+	capacityUnits := 100
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetWarehouseUtilization", 2).Return(&warehouse.Utilization{CapacityUnits: &capacityUnits, UsedUnits: 90}, nil)
+
+	err := productWarehouseUsecase.AddStock(context.Background(), addStock(11))
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorOverCapacity)
+	assert.Equal(t, 0, db.Commits())
+	assert.Equal(t, 1, db.Rollbacks())
+	mockRepo.AssertNotCalled(t, "AddAvailableStock", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAddStock_OverCapacityVolume(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), new(MockValuation), db)
+	capacityVolume := 50.0
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetWarehouseUtilization", 2).Return(&warehouse.Utilization{CapacityVolume: &capacityVolume, UsedVolume: 45}, nil)
+	mockRepo.On("GetUnitVolume", 7).Return(0.5, nil)
+
+	err := productWarehouseUsecase.AddStock(context.Background(), addStock(11))
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorOverCapacity)
+	assert.Equal(t, 1, db.Rollbacks())
+	mockRepo.AssertNotCalled(t, "AddAvailableStock", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,252 @@
+package product_warehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Flash sale products keep their available stock carved into slots. Carved
+// stock is moved to reserved_stock so the regular path never sees it, and a
+// reservation only has to lock one slot row instead of the product_warehouses
+// row every other order for the product is waiting on. Slot stock that was
+// not sold is folded back by ReconcileFlashSale.
+
+func (p *ProductWarehouseUsecase) EnableFlashSale(ctx context.Context, flashSale *product_warehouse.FlashSaleRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.EnableFlashSale")
+	defer func() { tracing.End(span, err) }()
+
+	err = p.productWarehouseRepo.UpsertFlashSale(ctx, flashSale.ProductId, flashSale.Slots)
+	if err != nil {
+		return err
+	}
+	return p.ReconcileFlashSale(ctx, flashSale.ProductId)
+}
+
+func (p *ProductWarehouseUsecase) DisableFlashSale(ctx context.Context, flashSale *product_warehouse.FlashSaleProductRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.DisableFlashSale")
+	defer func() { tracing.End(span, err) }()
+
+	err = p.productWarehouseRepo.DeleteFlashSale(ctx, flashSale.ProductId)
+	if err != nil {
+		return err
+	}
+	return p.ReconcileFlashSale(ctx, flashSale.ProductId)
+}
+
+// ReconcileFlashSale folds the slots of a product back into its
+// product_warehouses rows and, while the product is still on flash sale,
+// carves the current available stock into evenly filled slots again.
+func (p *ProductWarehouseUsecase) ReconcileFlashSale(ctx context.Context, productId int) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.ReconcileFlashSale")
+	defer func() { tracing.End(span, err) }()
+
+	flashSaleSlots, err := p.productWarehouseRepo.GetFlashSaleSlots(ctx, []int{productId})
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// lock the product rows first, in the same order as the regular path
+	productWarehouses, err := p.productWarehouseRepo.GetAllByProductIdForUpdate(ctx, tx, productId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stockSlots, err := p.productWarehouseRepo.GetStockSlotsForUpdate(ctx, tx, productId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	slotStocks := make(map[int]int)
+	for _, stockSlot := range stockSlots {
+		slotStocks[stockSlot.WarehouseId] += stockSlot.Stock
+	}
+	err = p.productWarehouseRepo.DeleteStockSlots(ctx, tx, productId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	slots := flashSaleSlots[productId]
+	for _, productWarehouse := range productWarehouses {
+		slotStock := slotStocks[productWarehouse.WarehouseId]
+		availableStock := productWarehouse.AvailableStock + slotStock
+		carvedStock := 0
+		if slots > 0 {
+			carvedStock = max(availableStock, 0)
+		}
+
+		// net change of available_stock is slotStock - carvedStock
+		if slotStock != carvedStock {
+			err = p.productWarehouseRepo.AddAvailableStockSubsReservedStock(ctx, tx, productId, productWarehouse.WarehouseId, slotStock-carvedStock, slotStock-carvedStock)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		for slot, stock := range splitSlots(carvedStock, slots) {
+			if stock == 0 {
+				continue
+			}
+			stockSlot := product_warehouse.StockSlot{
+				ProductId:   productId,
+				WarehouseId: productWarehouse.WarehouseId,
+				Slot:        slot,
+				Stock:       stock,
+			}
+			err = p.productWarehouseRepo.InsertStockSlot(ctx, tx, &stockSlot)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReconcileFlashSales rebalances every flash sale product. Reservations drain
+// slots unevenly, so without it orders start missing a slot with stock left
+// in the others. A product that fails does not hold up the others, its error
+// is returned along with theirs.
+func (p *ProductWarehouseUsecase) ReconcileFlashSales(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.ReconcileFlashSales")
+	defer func() { tracing.End(span, err) }()
+
+	productIds, err := p.productWarehouseRepo.GetFlashSaleProductIds(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, productId := range productIds {
+		err = p.ReconcileFlashSale(ctx, productId)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("product %d: %w", productId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunFlashSaleReconciler reconciles on every tick until ctx is done.
+func (p *ProductWarehouseUsecase) RunFlashSaleReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if err := p.ReconcileFlashSales(ctx); err != nil {
+			p.logger.ErrorContext(ctx, "failed to reconcile flash sales", "error", err)
+		}
+	}
+}
+
+// splitFlashSale separates the operations of flash sale products from the
+// ones going through the regular path.
+func (p *ProductWarehouseUsecase) splitFlashSale(ctx context.Context, operations []product_warehouse.StockOperationRequest) ([]product_warehouse.StockOperationRequest, []product_warehouse.StockOperationRequest, map[int]int, error) {
+	productIds := []int{}
+	for _, operation := range operations {
+		productIds = append(productIds, operation.ProductId)
+	}
+	flashSaleSlots, err := p.productWarehouseRepo.GetFlashSaleSlots(ctx, productIds)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	flashSaleOperations := []product_warehouse.StockOperationRequest{}
+	regularOperations := []product_warehouse.StockOperationRequest{}
+	for _, operation := range operations {
+		if flashSaleSlots[operation.ProductId] > 0 {
+			flashSaleOperations = append(flashSaleOperations, operation)
+		} else {
+			regularOperations = append(regularOperations, operation)
+		}
+	}
+	return flashSaleOperations, regularOperations, flashSaleSlots, nil
+}
+
+// reserveFlashSale takes the operations out of the product slots. Orders
+// start at different slots so concurrent orders lock different rows; an order
+// no single slot can cover locks all of the product slots and takes from
+// each in turn.
+func (p *ProductWarehouseUsecase) reserveFlashSale(ctx context.Context, tx *sqlx.Tx, orderId int, operations []product_warehouse.StockOperationRequest, flashSaleSlots map[int]int) ([]allocation, error) {
+	allocations := []allocation{}
+	for _, operation := range operations {
+		slots := flashSaleSlots[operation.ProductId]
+		reserved := false
+		for i := 0; i < slots && !reserved; i++ {
+			warehouseId, ok, err := p.productWarehouseRepo.TakeSlotStock(ctx, tx, operation.ProductId, (orderId+i)%slots, operation.Quantity)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				allocations = append(allocations, allocation{productId: operation.ProductId, warehouseId: warehouseId, quantity: operation.Quantity})
+				reserved = true
+			}
+		}
+		if reserved {
+			continue
+		}
+
+		stockSlots, err := p.productWarehouseRepo.GetStockSlotsForUpdate(ctx, tx, operation.ProductId)
+		if err != nil {
+			return nil, err
+		}
+		remaining := operation.Quantity
+		warehouseQuantities := make(map[int]int)
+		warehouseIds := []int{}
+		for _, stockSlot := range stockSlots {
+			if remaining == 0 {
+				break
+			}
+			quantity := min(stockSlot.Stock, remaining)
+			if quantity <= 0 {
+				continue
+			}
+			err = p.productWarehouseRepo.SubstractSlotStock(ctx, tx, stockSlot.Id, quantity)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := warehouseQuantities[stockSlot.WarehouseId]; !ok {
+				warehouseIds = append(warehouseIds, stockSlot.WarehouseId)
+			}
+			warehouseQuantities[stockSlot.WarehouseId] += quantity
+			remaining -= quantity
+		}
+		if remaining > 0 {
+			return nil, entity.ErrorInsufficientStock
+		}
+		for _, warehouseId := range warehouseIds {
+			allocations = append(allocations, allocation{productId: operation.ProductId, warehouseId: warehouseId, quantity: warehouseQuantities[warehouseId]})
+		}
+	}
+	return allocations, nil
+}
+
+// splitSlots spreads stock over slots as evenly as possible.
+func splitSlots(stock int, slots int) []int {
+	slotStocks := make([]int, slots)
+	for slot := range slotStocks {
+		slotStocks[slot] = stock / slots
+		if slot < stock%slots {
+			slotStocks[slot]++
+		}
+	}
+	return slotStocks
+}
@@ -0,0 +1,349 @@
+package product_warehouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTrip is the simulated latency of a single statement.
+const roundTrip = 100 * time.Microsecond
+
+// rowLockDriver is a database/sql driver that executes nothing but keeps the
+// locking behaviour that matters for contention: "LOCK <key>" blocks until no
+// other transaction holds key, and the lock is held until commit or rollback.
+// Like InnoDB row locks they are reentrant within a transaction.
+type rowLockDriver struct{}
+
+var rowLocks sync.Map
+
+func (rowLockDriver) Open(name string) (driver.Conn, error) {
+	return &rowLockConn{held: make(map[interface{}]*sync.Mutex)}, nil
+}
+
+type rowLockConn struct {
+	held map[interface{}]*sync.Mutex
+}
+
+func (c *rowLockConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *rowLockConn) Close() error {
+	return nil
+}
+
+func (c *rowLockConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *rowLockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if query == "LOCK" {
+		c.lock(args[0].Value)
+	}
+	time.Sleep(roundTrip)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *rowLockConn) lock(key interface{}) {
+	if _, ok := c.held[key]; ok {
+		return
+	}
+	lock, _ := rowLocks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	c.held[key] = lock.(*sync.Mutex)
+}
+
+func (c *rowLockConn) Commit() error {
+	time.Sleep(roundTrip)
+	c.release()
+	return nil
+}
+
+func (c *rowLockConn) Rollback() error {
+	c.release()
+	return nil
+}
+
+func (c *rowLockConn) release() {
+	for key, lock := range c.held {
+		lock.Unlock()
+		delete(c.held, key)
+	}
+}
+
+func init() {
+	sql.Register("rowlock", rowLockDriver{})
+}
+
+type fakeSlot struct {
+	id          int
+	warehouseId int
+	slot        int
+	stock       int
+}
+
+// fakeRepository keeps a single product in memory. Row locks go through the
+// transaction so they are held until commit like they would be in MySQL.
+type fakeRepository struct {
+	ProductWarehouseRepository
+	mu             sync.Mutex
+	availableStock map[int]int
+	reservedStock  map[int]int
+	slots          []*fakeSlot
+	flashSaleSlots int
+}
+
+func newFakeRepository(availableStock map[int]int) *fakeRepository {
+	return &fakeRepository{
+		availableStock: availableStock,
+		reservedStock:  make(map[int]int),
+	}
+}
+
+// carve fills slots the same way ReconcileFlashSale does.
+func (f *fakeRepository) carve(slots int) {
+	f.flashSaleSlots = slots
+	for _, warehouseId := range []int{10, 20} {
+		for slot, stock := range splitSlots(f.availableStock[warehouseId], slots) {
+			f.slots = append(f.slots, &fakeSlot{id: len(f.slots) + 1, warehouseId: warehouseId, slot: slot, stock: stock})
+		}
+		f.reservedStock[warehouseId] += f.availableStock[warehouseId]
+		f.availableStock[warehouseId] = 0
+	}
+}
+
+func lockRow(tx *sqlx.Tx, format string, args ...interface{}) error {
+	_, err := tx.Exec("LOCK", fmt.Sprintf(format, args...))
+	return err
+}
+
+func (f *fakeRepository) GetFlashSaleSlots(ctx context.Context, productIds []int) (map[int]int, error) {
+	time.Sleep(roundTrip)
+	flashSaleSlots := make(map[int]int)
+	if f.flashSaleSlots > 0 {
+		flashSaleSlots[1] = f.flashSaleSlots
+	}
+	return flashSaleSlots, nil
+}
+
+func (f *fakeRepository) GetAvailableStock(ctx context.Context, productId int) (int, error) {
+	time.Sleep(roundTrip)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.availableStock[10] + f.availableStock[20], nil
+}
+
+func (f *fakeRepository) GetAllByProductId(ctx context.Context, productId int) ([]product_warehouse.ProductWarehouse, error) {
+	time.Sleep(roundTrip)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	productWarehouses := []product_warehouse.ProductWarehouse{}
+	for _, warehouseId := range []int{10, 20} {
+		productWarehouses = append(productWarehouses, product_warehouse.ProductWarehouse{ProductId: productId, WarehouseId: warehouseId, AvailableStock: f.availableStock[warehouseId]})
+	}
+	return productWarehouses, nil
+}
+
+func (f *fakeRepository) SubsAvailableStockAddReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int, addedReservedStock int) error {
+	if err := lockRow(tx, "product_warehouse:%d:%d", productId, warehouseId); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.availableStock[warehouseId] -= substractedAvailableStock
+	f.reservedStock[warehouseId] += addedReservedStock
+	return nil
+}
+
+func (f *fakeRepository) InsertOrderWarehouse(ctx context.Context, tx *sqlx.Tx, orderWarehouse *product_warehouse.OrderWarehouse) error {
+	_, err := tx.Exec("INSERT")
+	return err
+}
+
+func (f *fakeRepository) TakeSlotStock(ctx context.Context, tx *sqlx.Tx, productId int, slot int, quantity int) (int, bool, error) {
+	if err := lockRow(tx, "stock_slot:%d:%d", productId, slot); err != nil {
+		return 0, false, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, fakeSlot := range f.slots {
+		if fakeSlot.slot == slot && fakeSlot.stock >= quantity {
+			fakeSlot.stock -= quantity
+			return fakeSlot.warehouseId, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (f *fakeRepository) GetStockSlotsForUpdate(ctx context.Context, tx *sqlx.Tx, productId int) ([]product_warehouse.StockSlot, error) {
+	for slot := 0; slot < f.flashSaleSlots; slot++ {
+		if err := lockRow(tx, "stock_slot:%d:%d", productId, slot); err != nil {
+			return nil, err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stockSlots := []product_warehouse.StockSlot{}
+	for _, fakeSlot := range f.slots {
+		stockSlots = append(stockSlots, product_warehouse.StockSlot{Id: fakeSlot.id, ProductId: productId, WarehouseId: fakeSlot.warehouseId, Slot: fakeSlot.slot, Stock: fakeSlot.stock})
+	}
+	return stockSlots, nil
+}
+
+func (f *fakeRepository) SubstractSlotStock(ctx context.Context, tx *sqlx.Tx, id int, substractedStock int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.slots[id-1].stock -= substractedStock
+	return nil
+}
+
+func (f *fakeRepository) slotStock() int {
+	stock := 0
+	for _, fakeSlot := range f.slots {
+		stock += fakeSlot.stock
+	}
+	return stock
+}
+
+type fakeBundle struct {
+	Bundle
+}
+
+func (fakeBundle) ExplodeBundles(ctx context.Context, operations []product_warehouse.StockOperationRequest) ([]product_warehouse.StockOperationRequest, []product_warehouse.BundleOperation, error) {
+	return operations, nil, nil
+}
+
+type fakeChannelAllocator struct{}
+
+func (fakeChannelAllocator) AvailableForChannel(ctx context.Context, productId int, shopId int, channel string, availableStock int) (int, error) {
+	time.Sleep(roundTrip)
+	return availableStock, nil
+}
+
+type fakePublisher struct{}
+
+func (fakePublisher) PublishEvent(ctx context.Context, eventType string, data interface{}) error {
+	return nil
+}
+
+type fakeBins struct{}
+
+func (fakeBins) TakeStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) error {
+	return nil
+}
+
+type fakeOutbox struct{}
+
+func (fakeOutbox) Add(ctx context.Context, tx *sqlx.Tx, eventType string, data interface{}) error {
+	return nil
+}
+
+func newFlashSaleUsecase(repo *fakeRepository) *ProductWarehouseUsecase {
+	db := sqlx.NewDb(sql.OpenDB(connector{}), "mysql")
+	return NewProductWarehouseUsecase(repo, fakePublisher{}, fakeOutbox{}, nil, fakeBins{}, fakeBundle{}, nil, fakeChannelAllocator{}, nil, db, slog.Default())
+}
+
+type connector struct{}
+
+func (connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return rowLockDriver{}.Open("")
+}
+
+func (connector) Driver() driver.Driver {
+	return rowLockDriver{}
+}
+
+func reserveOne(orderId int, quantity int) *product_warehouse.StockOperationOrderRequest {
+	return &product_warehouse.StockOperationOrderRequest{
+		OrderId:         orderId,
+		StockOperations: []product_warehouse.StockOperationRequest{{ProductId: 1, Quantity: quantity}},
+	}
+}
+
+func TestSplitSlots(t *testing.T) {
+	assert.Equal(t, []int{4, 3, 3}, splitSlots(10, 3))
+	assert.Equal(t, []int{1, 1, 0, 0}, splitSlots(2, 4))
+	assert.Equal(t, []int{0, 0}, splitSlots(0, 2))
+}
+
+func TestReserveStock_FlashSaleSlot(t *testing.T) {
+	repo := newFakeRepository(map[int]int{10: 8, 20: 0})
+	repo.carve(4)
+	productWarehouseUsecase := newFlashSaleUsecase(repo)
+
+	// order 6 starts at slot 2
+	err := productWarehouseUsecase.ReserveStock(context.Background(), reserveOne(6, 2))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 0, repo.slots[2].stock)
+	assert.Equal(t, 6, repo.slotStock())
+	assert.Equal(t, 8, repo.reservedStock[10])
+}
+
+func TestReserveStock_FlashSaleAcrossSlots(t *testing.T) {
+	repo := newFakeRepository(map[int]int{10: 4, 20: 4})
+	repo.carve(4)
+	productWarehouseUsecase := newFlashSaleUsecase(repo)
+
+	// no single slot holds 5, so the order is taken from several
+	err := productWarehouseUsecase.ReserveStock(context.Background(), reserveOne(1, 5))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 3, repo.slotStock())
+}
+
+func TestReserveStock_FlashSaleInsufficient(t *testing.T) {
+	repo := newFakeRepository(map[int]int{10: 4, 20: 0})
+	repo.carve(2)
+	productWarehouseUsecase := newFlashSaleUsecase(repo)
+
+	err := productWarehouseUsecase.ReserveStock(context.Background(), reserveOne(1, 5))
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+}
+
+// The benchmarks reserve one unit of the same product from many goroutines.
+// The regular path serializes on the product_warehouses row, the flash sale
+// path spreads orders over the slot rows.
+func benchmarkReserveStock(b *testing.B, slots int) {
+	repo := newFakeRepository(map[int]int{10: 1 << 40, 20: 1 << 40})
+	if slots > 0 {
+		repo.carve(slots)
+	}
+	productWarehouseUsecase := newFlashSaleUsecase(repo)
+
+	var orderId int64
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			err := productWarehouseUsecase.ReserveStock(context.Background(), reserveOne(int(atomic.AddInt64(&orderId, 1)), 1))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkReserveStock_Regular(b *testing.B) {
+	benchmarkReserveStock(b, 0)
+}
+
+func BenchmarkReserveStock_FlashSale(b *testing.B) {
+	benchmarkReserveStock(b, 32)
+}
@@ -1,56 +1,267 @@
 package product_warehouse
 
 import (
+	"context"
 	"errors"
+	"log/slog"
+	"time"
 	"warehouse-service/entity"
+	"warehouse-service/logging"
 	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/valuation"
+	"warehouse-service/models/warehouse"
+	"warehouse-service/tracing"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type ProductWarehouseRepository interface {
-	Insert(productWarehouse *product_warehouse.RegisterRequest) error
-	AddAvailableStock(tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int) error
-	SubstractAvailableStock(tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int) error
-	AddAvailableStockSubsReservedStock(tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int, substractedReservedStock int) error
-	SubsAvailableStockAddReservedStock(tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int, addedReservedStock int) error
-	SubstractReservedStock(tx *sqlx.Tx, productId int, warehouseId int, substractedReservedStock int) error
-	GetByProductAndWarehouseId(productId int, wareHouseId int) (*product_warehouse.ProductWarehouse, error)
-	GetAvailableStockBulk(availableStockRequest []product_warehouse.ProductShop) (map[int]int, error)
-	GetAllByProductId(productId int) ([]product_warehouse.ProductWarehouse, error)
-	GetAvailableStock(productId int) (int, error)
-	InsertOrderWarehouse(tx *sqlx.Tx, orderWarehouse *product_warehouse.OrderWarehouse) error
-	GetOrderWarehouseByOrderId(orderId int) ([]product_warehouse.OrderWarehouse, error)
+	Insert(ctx context.Context, tx *sqlx.Tx, productWarehouse *product_warehouse.RegisterRequest) error
+	UpdateSafetyStock(ctx context.Context, productId int, warehouseId int, safetyStock int) error
+	AddAvailableStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int) error
+	SubstractAvailableStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int) error
+	AddAvailableStockSubsReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int, substractedReservedStock int) error
+	SubsAvailableStockAddReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int, addedReservedStock int) error
+	SubstractReservedStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedReservedStock int) error
+	GetByProductAndWarehouseIdForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, wareHouseId int) (*product_warehouse.ProductWarehouse, error)
+	GetAvailableStockBulk(ctx context.Context, availableStockRequest []product_warehouse.ProductShop) (map[int]int, error)
+	GetAllByProductId(ctx context.Context, productId int) ([]product_warehouse.ProductWarehouse, error)
+	GetAllByProductIdsWithLocation(ctx context.Context, productIds []int) ([]product_warehouse.WarehouseStockLocation, error)
+	GetAvailableStock(ctx context.Context, productId int) (int, error)
+	InsertOrderWarehouse(ctx context.Context, tx *sqlx.Tx, orderWarehouse *product_warehouse.OrderWarehouse) error
+	ClearOrderWarehouse(ctx context.Context, tx *sqlx.Tx, id int) (int, error)
+	GetOrderWarehouseByOrderId(ctx context.Context, orderId int) ([]product_warehouse.OrderWarehouse, error)
+	InsertInbound(ctx context.Context, tx *sqlx.Tx, inbound *product_warehouse.InboundStock) (int, error)
+	GetInboundById(ctx context.Context, id int) (*product_warehouse.InboundStock, error)
+	UpdateInboundStatus(ctx context.Context, tx *sqlx.Tx, id int, fromStatus string, toStatus string) (bool, error)
+	GetPendingInbound(ctx context.Context, productId int, shopId int, until time.Time) ([]product_warehouse.InboundStock, error)
+	GetStockSummary(ctx context.Context, productId int, shopId int) (*product_warehouse.StockSummary, error)
+	UpsertDimension(ctx context.Context, dimension *product_warehouse.DimensionRequest) error
+	GetUnitVolume(ctx context.Context, productId int) (float64, error)
+	LockWarehouse(ctx context.Context, tx *sqlx.Tx, warehouseId int) error
+	GetWarehouseUtilization(ctx context.Context, tx *sqlx.Tx, warehouseId int) (*warehouse.Utilization, error)
+	UpsertFlashSale(ctx context.Context, productId int, slots int) error
+	DeleteFlashSale(ctx context.Context, productId int) error
+	GetFlashSaleProductIds(ctx context.Context) ([]int, error)
+	GetFlashSaleSlots(ctx context.Context, productIds []int) (map[int]int, error)
+	GetAllByProductIdForUpdate(ctx context.Context, tx *sqlx.Tx, productId int) ([]product_warehouse.ProductWarehouse, error)
+	InsertStockSlot(ctx context.Context, tx *sqlx.Tx, stockSlot *product_warehouse.StockSlot) error
+	TakeSlotStock(ctx context.Context, tx *sqlx.Tx, productId int, slot int, quantity int) (int, bool, error)
+	GetStockSlotsForUpdate(ctx context.Context, tx *sqlx.Tx, productId int) ([]product_warehouse.StockSlot, error)
+	SubstractSlotStock(ctx context.Context, tx *sqlx.Tx, id int, substractedStock int) error
+	DeleteStockSlots(ctx context.Context, tx *sqlx.Tx, productId int) error
+	GetSlotStockBulk(ctx context.Context, availableStockRequest []product_warehouse.ProductShop) (map[int]int, error)
 }
 
 type Publisher interface {
-	PublishEvent(eventType string, data interface{}) error
+	PublishEvent(ctx context.Context, eventType string, data interface{}) error
+}
+
+type Outbox interface {
+	Add(ctx context.Context, tx *sqlx.Tx, eventType string, data interface{}) error
+}
+
+type Fulfillment interface {
+	GeneratePickLists(ctx context.Context, order *product_warehouse.Order) error
+	CancelPickLists(ctx context.Context, tx *sqlx.Tx, order *product_warehouse.Order) error
+}
+
+type Bins interface {
+	TakeStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, quantity int) error
+}
+
+type Bundle interface {
+	ExplodeBundles(ctx context.Context, operations []product_warehouse.StockOperationRequest) ([]product_warehouse.StockOperationRequest, []product_warehouse.BundleOperation, error)
+	GetAvailableStockBulk(ctx context.Context, productShops []product_warehouse.ProductShop) (map[int]int, error)
+}
+
+type UnitConverter interface {
+	ToBase(ctx context.Context, productId int, unit string, quantity int) (int, error)
+	FromBase(ctx context.Context, productId int, unit string, quantity int) (int, error)
+}
+
+type ChannelAllocator interface {
+	AvailableForChannel(ctx context.Context, productId int, shopId int, channel string, availableStock int) (int, error)
+}
+
+type Valuation interface {
+	Receive(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int, unitCost *valuation.Money) error
+	Consume(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int) error
+	Transfer(ctx context.Context, tx *sqlx.Tx, productId int, fromWarehouseId int, toWarehouseId int, quantity int) error
 }
 
 type ProductWarehouseUsecase struct {
 	productWarehouseRepo ProductWarehouseRepository
 	publisher            Publisher
+	outbox               Outbox
+	fulfillment          Fulfillment
+	bins                 Bins
+	bundle               Bundle
+	unitConverter        UnitConverter
+	channelAllocator     ChannelAllocator
+	valuation            Valuation
+	capacity             *CapacityChecker
 	mysql                *sqlx.DB
+	logger               *slog.Logger
 }
 
-func NewProductWarehouseUsecase(productWarehouseRepo ProductWarehouseRepository, publisher Publisher, mysql *sqlx.DB) *ProductWarehouseUsecase {
+func NewProductWarehouseUsecase(productWarehouseRepo ProductWarehouseRepository, publisher Publisher, outbox Outbox, fulfillment Fulfillment, bins Bins, bundle Bundle, unitConverter UnitConverter, channelAllocator ChannelAllocator, valuation Valuation, mysql *sqlx.DB, logger *slog.Logger) *ProductWarehouseUsecase {
 	return &ProductWarehouseUsecase{
 		productWarehouseRepo: productWarehouseRepo,
 		publisher:            publisher,
+		outbox:               outbox,
+		fulfillment:          fulfillment,
+		bins:                 bins,
+		bundle:               bundle,
+		unitConverter:        unitConverter,
+		channelAllocator:     channelAllocator,
+		valuation:            valuation,
+		capacity:             NewCapacityChecker(productWarehouseRepo),
 		mysql:                mysql,
+		logger:               logger,
 	}
 }
 
-func (p *ProductWarehouseUsecase) Register(productWarehouseRegister *product_warehouse.RegisterRequest) error {
-	return p.productWarehouseRepo.Insert(productWarehouseRegister)
+// Register adds the product to the warehouse. Its opening stock is received
+// into the cost layers at the given unit cost, or at zero cost without one.
+func (p *ProductWarehouseUsecase) Register(ctx context.Context, productWarehouseRegister *product_warehouse.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.Register")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := p.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if productWarehouseRegister.AvailableStock > 0 {
+		err = p.capacity.Check(ctx, tx, productWarehouseRegister.WarehouseId, productWarehouseRegister.ProductId, productWarehouseRegister.AvailableStock)
+		if err != nil {
+			return err
+		}
+	}
+	err = p.productWarehouseRepo.Insert(ctx, tx, productWarehouseRegister)
+	if err != nil {
+		return err
+	}
+	if productWarehouseRegister.AvailableStock > 0 {
+		err = p.valuation.Receive(ctx, tx, entity.CostMovementReceipt, productWarehouseRegister.ProductId, productWarehouseRegister.WarehouseId, productWarehouseRegister.AvailableStock, productWarehouseRegister.UnitCost)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *ProductWarehouseUsecase) UpdateSafetyStock(ctx context.Context, safetyStock *product_warehouse.SafetyStockRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.UpdateSafetyStock")
+	defer func() { tracing.End(span, err) }()
+
+	return p.productWarehouseRepo.UpdateSafetyStock(ctx, safetyStock.ProductId, safetyStock.WarehouseId, safetyStock.SafetyStock)
 }
 
-func (p *ProductWarehouseUsecase) TransferStockRequest(transferStock *product_warehouse.TransferStockRequest) error {
-	return p.publisher.PublishEvent(entity.StockTransferEvent, transferStock)
+func (p *ProductWarehouseUsecase) TransferStockRequest(ctx context.Context, transferStock *product_warehouse.TransferStockRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.TransferStockRequest")
+	defer func() { tracing.End(span, err) }()
+
+	return p.RequestTransfers(ctx, []product_warehouse.TransferStockRequest{*transferStock})
+}
+
+// RequestTransfers takes the stock of the transfers out of their source
+// warehouses and registers it as inbound stock of their destinations, where
+// it is received when the transfer event is consumed. Either all transfers
+// are requested or none is.
+func (p *ProductWarehouseUsecase) RequestTransfers(ctx context.Context, transfers []product_warehouse.TransferStockRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.RequestTransfers")
+	defer func() { tracing.End(span, err) }()
+
+	for i := range transfers {
+		err := p.toBaseUnit(ctx, transfers[i].ProductId, &transfers[i].Unit, &transfers[i].Quantity)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := p.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	for i := range transfers {
+		transfer := &transfers[i]
+		var sourceProduct *product_warehouse.ProductWarehouse
+		sourceProduct, err = p.productWarehouseRepo.GetByProductAndWarehouseIdForUpdate(ctx, tx, transfer.ProductId, transfer.FromWarehouseId)
+		if err != nil {
+			return err
+		}
+		if sourceProduct.AvailableStock < transfer.Quantity {
+			err = entity.ErrorInsufficientStock
+			return err
+		}
+
+		err = p.productWarehouseRepo.SubstractAvailableStock(ctx, tx, transfer.ProductId, transfer.FromWarehouseId, transfer.Quantity)
+		if err != nil {
+			return err
+		}
+		err = p.bins.TakeStock(ctx, tx, transfer.ProductId, transfer.FromWarehouseId, transfer.Quantity)
+		if err != nil {
+			return err
+		}
+
+		expectedAt := now
+		if transfer.ExpectedAt != nil {
+			expectedAt = *transfer.ExpectedAt
+		}
+		transfer.InboundId, err = p.productWarehouseRepo.InsertInbound(ctx, tx, &product_warehouse.InboundStock{
+			ProductId:       transfer.ProductId,
+			WarehouseId:     transfer.ToWarehouseId,
+			FromWarehouseId: &transfer.FromWarehouseId,
+			Quantity:        transfer.Quantity,
+			Source:          entity.InboundSourceTransfer,
+			ExpectedAt:      expectedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = p.outbox.Add(ctx, tx, entity.StockTransferEvent, transfer)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func (p *ProductWarehouseUsecase) TransferStock(transferStock *product_warehouse.TransferStockRequest) error {
-	tx, err := p.mysql.Beginx()
+func (p *ProductWarehouseUsecase) TransferStock(ctx context.Context, transferStock *product_warehouse.TransferStockRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.TransferStock")
+	defer func() { tracing.End(span, err) }()
+
+	if transferStock.InboundId != 0 {
+		// the stock left the source warehouse when the transfer was requested
+		err := p.ReceiveInbound(ctx, transferStock.InboundId)
+		if errors.Is(err, entity.ErrorInboundNotPending) {
+			// redelivered after it was received
+			return nil
+		}
+		return err
+	}
+
+	err = p.toBaseUnit(ctx, transferStock.ProductId, &transferStock.Unit, &transferStock.Quantity)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.mysql.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -61,35 +272,66 @@ func (p *ProductWarehouseUsecase) TransferStock(transferStock *product_warehouse
 		}
 	}()
 
-	sourceProduct, err := p.productWarehouseRepo.GetByProductAndWarehouseId(transferStock.ProductId, transferStock.FromWarehouseId)
+	err = p.capacity.Check(ctx, tx, transferStock.ToWarehouseId, transferStock.ProductId, transferStock.Quantity)
+	if err != nil {
+		return err
+	}
+
+	sourceProduct, err := p.productWarehouseRepo.GetByProductAndWarehouseIdForUpdate(ctx, tx, transferStock.ProductId, transferStock.FromWarehouseId)
 	if err != nil {
 		return err
 	}
 
 	if sourceProduct.AvailableStock < transferStock.Quantity {
 		// TO DO: send notif to user
-		return errors.New(entity.ErrorInsufficientStock)
+		err = entity.ErrorInsufficientStock
+		return err
+	}
+
+	err = p.productWarehouseRepo.AddAvailableStock(ctx, tx, transferStock.ProductId, transferStock.ToWarehouseId, transferStock.Quantity)
+	if err != nil {
+		return err
 	}
 
-	err = p.productWarehouseRepo.AddAvailableStock(tx, transferStock.ProductId, transferStock.ToWarehouseId, transferStock.Quantity)
+	err = p.productWarehouseRepo.SubstractAvailableStock(ctx, tx, transferStock.ProductId, transferStock.FromWarehouseId, transferStock.Quantity)
 	if err != nil {
 		return err
 	}
 
-	err = p.productWarehouseRepo.SubstractAvailableStock(tx, transferStock.ProductId, transferStock.FromWarehouseId, transferStock.Quantity)
+	err = p.bins.TakeStock(ctx, tx, transferStock.ProductId, transferStock.FromWarehouseId, transferStock.Quantity)
 	if err != nil {
 		return err
 	}
-	tx.Commit()
-	return nil
+
+	err = p.valuation.Transfer(ctx, tx, transferStock.ProductId, transferStock.FromWarehouseId, transferStock.ToWarehouseId, transferStock.Quantity)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (p *ProductWarehouseUsecase) AddStockRequest(addStock *product_warehouse.StockOperationRequest) error {
-	return p.publisher.PublishEvent(entity.StockAddEvent, addStock)
+func (p *ProductWarehouseUsecase) AddStockRequest(ctx context.Context, addStock *product_warehouse.StockOperationRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.AddStockRequest")
+	defer func() { tracing.End(span, err) }()
+
+	unitCost, err := p.toBaseUnitCost(ctx, addStock.ProductId, &addStock.Unit, &addStock.Quantity, addStock.UnitCost)
+	if err != nil {
+		return err
+	}
+	addStock.UnitCost = unitCost
+	return p.publisher.PublishEvent(ctx, entity.StockAddEvent, addStock)
 }
 
-func (p *ProductWarehouseUsecase) AddStock(addStock *product_warehouse.StockOperationRequest) error {
-	tx, err := p.mysql.Beginx()
+func (p *ProductWarehouseUsecase) AddStock(ctx context.Context, addStock *product_warehouse.StockOperationRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.AddStock")
+	defer func() { tracing.End(span, err) }()
+
+	unitCost, err := p.toBaseUnitCost(ctx, addStock.ProductId, &addStock.Unit, &addStock.Quantity, addStock.UnitCost)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.mysql.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -98,30 +340,44 @@ func (p *ProductWarehouseUsecase) AddStock(addStock *product_warehouse.StockOper
 			tx.Rollback()
 		}
 	}()
-	err = p.productWarehouseRepo.AddAvailableStock(tx, addStock.ProductId, addStock.WarehouseId, addStock.Quantity)
+
+	err = p.capacity.Check(ctx, tx, addStock.WarehouseId, addStock.ProductId, addStock.Quantity)
+	if err != nil {
+		return err
+	}
+	err = p.productWarehouseRepo.AddAvailableStock(ctx, tx, addStock.ProductId, addStock.WarehouseId, addStock.Quantity)
 	if err != nil {
 		return err
 	}
-	tx.Commit()
-	return nil
-}
 
-func (p *ProductWarehouseUsecase) DeductStockRequest(deductStock *product_warehouse.StockOperationRequest) error {
-	return p.publisher.PublishEvent(entity.StockDeductEvent, deductStock)
+	err = p.valuation.Receive(ctx, tx, entity.CostMovementReceipt, addStock.ProductId, addStock.WarehouseId, addStock.Quantity, unitCost)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (p *ProductWarehouseUsecase) DeductStock(deductStock *product_warehouse.StockOperationRequest) error {
-	sourceProduct, err := p.productWarehouseRepo.GetByProductAndWarehouseId(deductStock.ProductId, deductStock.WarehouseId)
+func (p *ProductWarehouseUsecase) DeductStockRequest(ctx context.Context, deductStock *product_warehouse.StockOperationRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.DeductStockRequest")
+	defer func() { tracing.End(span, err) }()
+
+	err = p.toBaseUnit(ctx, deductStock.ProductId, &deductStock.Unit, &deductStock.Quantity)
 	if err != nil {
 		return err
 	}
+	return p.publisher.PublishEvent(ctx, entity.StockDeductEvent, deductStock)
+}
 
-	if sourceProduct.AvailableStock < deductStock.Quantity {
-		// TO DO: send notif to user
-		return errors.New(entity.ErrorInsufficientStock)
+func (p *ProductWarehouseUsecase) DeductStock(ctx context.Context, deductStock *product_warehouse.StockOperationRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.DeductStock")
+	defer func() { tracing.End(span, err) }()
+
+	err = p.toBaseUnit(ctx, deductStock.ProductId, &deductStock.Unit, &deductStock.Quantity)
+	if err != nil {
+		return err
 	}
 
-	tx, err := p.mysql.Beginx()
+	tx, err := p.mysql.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -130,20 +386,53 @@ func (p *ProductWarehouseUsecase) DeductStock(deductStock *product_warehouse.Sto
 			tx.Rollback()
 		}
 	}()
-	err = p.productWarehouseRepo.SubstractAvailableStock(tx, deductStock.ProductId, deductStock.WarehouseId, deductStock.Quantity)
+
+	sourceProduct, err := p.productWarehouseRepo.GetByProductAndWarehouseIdForUpdate(ctx, tx, deductStock.ProductId, deductStock.WarehouseId)
+	if err != nil {
+		return err
+	}
+
+	if sourceProduct.AvailableStock < deductStock.Quantity {
+		// TO DO: send notif to user
+		err = entity.ErrorInsufficientStock
+		return err
+	}
+
+	err = p.productWarehouseRepo.SubstractAvailableStock(ctx, tx, deductStock.ProductId, deductStock.WarehouseId, deductStock.Quantity)
+	if err != nil {
+		return err
+	}
+
+	err = p.bins.TakeStock(ctx, tx, deductStock.ProductId, deductStock.WarehouseId, deductStock.Quantity)
 	if err != nil {
 		return err
 	}
-	tx.Commit()
-	return nil
+
+	err = p.valuation.Consume(ctx, tx, entity.CostMovementDeduction, deductStock.ProductId, deductStock.WarehouseId, deductStock.Quantity)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReleaseReservedStock hands the order over to fulfillment. The reserved stock
+// stays reserved until the pick lists are shipped.
+func (p *ProductWarehouseUsecase) ReleaseReservedStock(ctx context.Context, order *product_warehouse.Order) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.ReleaseReservedStock")
+	defer func() { tracing.End(span, err) }()
+
+	return p.fulfillment.GeneratePickLists(ctx, order)
 }
 
-func (p *ProductWarehouseUsecase) ReleaseReservedStock(order *product_warehouse.Order) error {
-	orderWarehouses, err := p.productWarehouseRepo.GetOrderWarehouseByOrderId(order.OrderId)
+func (p *ProductWarehouseUsecase) ReturnReservedStock(ctx context.Context, order *product_warehouse.Order) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.ReturnReservedStock")
+	defer func() { tracing.End(span, err) }()
+
+	orderWarehouses, err := p.productWarehouseRepo.GetOrderWarehouseByOrderId(ctx, order.OrderId)
 	if err != nil {
 		return err
 	}
-	tx, err := p.mysql.Beginx()
+	tx, err := p.mysql.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -155,23 +444,35 @@ func (p *ProductWarehouseUsecase) ReleaseReservedStock(order *product_warehouse.
 	}()
 
 	for _, orderWarehouse := range orderWarehouses {
-		err := p.productWarehouseRepo.SubstractReservedStock(tx, orderWarehouse.ProductId, orderWarehouse.WarehouseId, orderWarehouse.ReservedStock)
+		// a redelivered or concurrent return finds the row already cleared
+		var reservedStock int
+		reservedStock, err = p.productWarehouseRepo.ClearOrderWarehouse(ctx, tx, orderWarehouse.Id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if reservedStock == 0 {
+			continue
+		}
+		err = p.productWarehouseRepo.AddAvailableStockSubsReservedStock(ctx, tx, orderWarehouse.ProductId, orderWarehouse.WarehouseId, reservedStock, reservedStock)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
 	}
 
-	tx.Commit()
-	return nil
-}
-
-func (p *ProductWarehouseUsecase) ReturnReservedStock(order *product_warehouse.Order) error {
-	orderWarehouses, err := p.productWarehouseRepo.GetOrderWarehouseByOrderId(order.OrderId)
+	err = p.fulfillment.CancelPickLists(ctx, tx, order)
 	if err != nil {
 		return err
 	}
-	tx, err := p.mysql.Beginx()
+	return tx.Commit()
+}
+
+func (p *ProductWarehouseUsecase) ReserveStock(ctx context.Context, operationStock *product_warehouse.StockOperationOrderRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.ReserveStock")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := p.mysql.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -182,87 +483,198 @@ func (p *ProductWarehouseUsecase) ReturnReservedStock(order *product_warehouse.O
 		}
 	}()
 
-	for _, orderWarehouse := range orderWarehouses {
-		err = p.productWarehouseRepo.AddAvailableStockSubsReservedStock(tx, orderWarehouse.ProductId, orderWarehouse.WarehouseId, orderWarehouse.ReservedStock, orderWarehouse.ReservedStock)
+	for i := range operationStock.StockOperations {
+		operation := &operationStock.StockOperations[i]
+		err = p.toBaseUnit(ctx, operation.ProductId, &operation.Unit, &operation.Quantity)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
 	}
 
-	tx.Commit()
-	return nil
-}
+	// bundles are reserved through their components
+	operations, bundleOperations, err := p.bundle.ExplodeBundles(ctx, operationStock.StockOperations)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
-func (p *ProductWarehouseUsecase) ReserveStock(operationStock *product_warehouse.StockOperationOrderRequest) error {
-	tx, err := p.mysql.Beginx()
+	// flash sale products are reserved from their slots and skip channel pools
+	flashSaleOperations, operations, flashSaleSlots, err := p.splitFlashSale(ctx, operations)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	defer func() {
+	// the stock of a product has to cover all the lines asking for it
+	for _, operation := range totalByProduct(operations) {
+		availableStock, err := p.productWarehouseRepo.GetAvailableStock(ctx, operation.ProductId)
 		if err != nil {
 			tx.Rollback()
+			return err
 		}
-	}()
+		// other channels' pools in the shop are off limits for this order
+		if operationStock.Channel != "" && operationStock.ShopId != 0 {
+			var channelStock int
+			channelStock, err = p.availableForChannel(ctx, operation.ProductId, operationStock.ShopId, operationStock.Channel)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			availableStock = min(availableStock, channelStock)
+		}
+		if availableStock < operation.Quantity {
+			tx.Rollback()
+			p.logger.WarnContext(ctx, "insufficient stock to reserve",
+				logging.OrderID, operationStock.OrderId,
+				logging.ProductID, operation.ProductId,
+				"available", availableStock,
+				"quantity", operation.Quantity)
+			err = p.cancelOrder(ctx, operationStock.OrderId)
+			if err != nil {
+				return err
+			}
+			return entity.ErrorInsufficientStock
+		}
+	}
+
+	flashSaleAllocations, err := p.reserveFlashSale(ctx, tx, operationStock.OrderId, flashSaleOperations, flashSaleSlots)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, entity.ErrorInsufficientStock) {
+			p.logger.WarnContext(ctx, "insufficient flash sale stock to reserve", logging.OrderID, operationStock.OrderId)
+			cancelErr := p.cancelOrder(ctx, operationStock.OrderId)
+			if cancelErr != nil {
+				return cancelErr
+			}
+		}
+		return err
+	}
 
-	for _, operation := range operationStock.StockOperations {
-		availableStock, err := p.productWarehouseRepo.GetAvailableStock(operation.ProductId)
+	allocations, err := p.allocate(ctx, operations, bundleOperations, operationStock.AllocationMode, operationStock.Destination)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, entity.ErrorInsufficientStock) {
+			p.logger.WarnContext(ctx, "insufficient stock to allocate", logging.OrderID, operationStock.OrderId)
+			cancelErr := p.cancelOrder(ctx, operationStock.OrderId)
+			if cancelErr != nil {
+				return cancelErr
+			}
+		}
+		return err
+	}
+
+	for _, allocation := range allocations {
+		err = p.productWarehouseRepo.SubsAvailableStockAddReservedStock(ctx, tx, allocation.productId, allocation.warehouseId, allocation.quantity, allocation.quantity)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
-		if availableStock < operation.Quantity {
+
+		orderWarehouse := product_warehouse.OrderWarehouse{
+			OrderId:       operationStock.OrderId,
+			ProductId:     allocation.productId,
+			WarehouseId:   allocation.warehouseId,
+			ReservedStock: allocation.quantity,
+			ShopId:        operationStock.ShopId,
+			Channel:       operationStock.Channel,
+		}
+
+		err = p.productWarehouseRepo.InsertOrderWarehouse(ctx, tx, &orderWarehouse)
+		if err != nil {
 			tx.Rollback()
-			updateOrderRequest := product_warehouse.UpdateStatusRequest{
-				Id:     operationStock.OrderId,
-				Status: "cancel",
-			}
-			go p.publisher.PublishEvent(entity.OrderUpdateStatusEvent, updateOrderRequest)
-			return errors.New(entity.ErrorInsufficientStock)
+			return err
+		}
+	}
+
+	// slot stock is already counted in reserved_stock
+	for _, allocation := range flashSaleAllocations {
+		orderWarehouse := product_warehouse.OrderWarehouse{
+			OrderId:       operationStock.OrderId,
+			ProductId:     allocation.productId,
+			WarehouseId:   allocation.warehouseId,
+			ReservedStock: allocation.quantity,
 		}
-		productWarehouses, err := p.productWarehouseRepo.GetAllByProductId(operation.ProductId)
+
+		err = p.productWarehouseRepo.InsertOrderWarehouse(ctx, tx, &orderWarehouse)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
+	}
 
-		reservedStock := operation.Quantity
+	return tx.Commit()
+}
 
-		for i := 0; i < len(productWarehouses) && reservedStock > 0; i++ {
-			warehouse := productWarehouses[i]
+// availableForChannel returns how much of the shop's available stock of the
+// product the channel may sell.
+func (p *ProductWarehouseUsecase) availableForChannel(ctx context.Context, productId int, shopId int, channel string) (int, error) {
+	stockMap, err := p.productWarehouseRepo.GetAvailableStockBulk(ctx, []product_warehouse.ProductShop{{ProductId: productId, ShopId: shopId}})
+	if err != nil {
+		return 0, err
+	}
+	return p.channelAllocator.AvailableForChannel(ctx, productId, shopId, channel, stockMap[productId])
+}
 
-			queryQuantity := min(warehouse.AvailableStock, reservedStock)
+// cancelOrder asks the order service to cancel an order that could not be
+// reserved, through the outbox so the request survives a broker outage.
+func (p *ProductWarehouseUsecase) cancelOrder(ctx context.Context, orderId int) error {
+	tx, err := p.mysql.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	updateOrderRequest := product_warehouse.UpdateStatusRequest{
+		Id:     orderId,
+		Status: "cancel",
+	}
+	err = p.outbox.Add(ctx, tx, entity.OrderUpdateStatusEvent, updateOrderRequest)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
 
-			err = p.productWarehouseRepo.SubsAvailableStockAddReservedStock(tx, operation.ProductId, warehouse.WarehouseId, queryQuantity, queryQuantity)
-			if err != nil {
-				tx.Rollback()
-				return err
-			}
+func (p *ProductWarehouseUsecase) GetAvailableStockBulk(ctx context.Context, getAvailableStock []product_warehouse.ProductShop) (_ map[int]int, err error) {
+	ctx, span := tracing.Start(ctx, "ProductWarehouseUsecase.GetAvailableStockBulk")
+	defer func() { tracing.End(span, err) }()
 
-			orderWarehouse := product_warehouse.OrderWarehouse{
-				OrderId:       operationStock.OrderId,
-				ProductId:     operation.ProductId,
-				WarehouseId:   warehouse.WarehouseId,
-				ReservedStock: queryQuantity,
-			}
+	stockMap, err := p.productWarehouseRepo.GetAvailableStockBulk(ctx, getAvailableStock)
+	if err != nil {
+		return nil, err
+	}
 
-			err = p.productWarehouseRepo.InsertOrderWarehouse(tx, &orderWarehouse)
+	slotStockMap, err := p.productWarehouseRepo.GetSlotStockBulk(ctx, getAvailableStock)
+	if err != nil {
+		return nil, err
+	}
+	for productId, slotStock := range slotStockMap {
+		stockMap[productId] += slotStock
+	}
+
+	bundleStockMap, err := p.bundle.GetAvailableStockBulk(ctx, getAvailableStock)
+	if err != nil {
+		return nil, err
+	}
+	for productId, availableStock := range bundleStockMap {
+		stockMap[productId] = availableStock
+	}
+
+	for _, productShop := range getAvailableStock {
+		if productShop.Channel != "" {
+			stockMap[productShop.ProductId], err = p.channelAllocator.AvailableForChannel(ctx, productShop.ProductId, productShop.ShopId, productShop.Channel, stockMap[productShop.ProductId])
 			if err != nil {
-				tx.Rollback()
-				return err
+				return nil, err
+			}
+		}
+		if productShop.Unit != "" {
+			stockMap[productShop.ProductId], err = p.unitConverter.FromBase(ctx, productShop.ProductId, productShop.Unit, stockMap[productShop.ProductId])
+			if err != nil {
+				return nil, err
 			}
-
-			reservedStock -= queryQuantity
 		}
 	}
-
-	tx.Commit()
-	return nil
-}
-
-func (p *ProductWarehouseUsecase) GetAvailableStockBulk(getAvailableStock []product_warehouse.ProductShop) (map[int]int, error) {
-	return p.productWarehouseRepo.GetAvailableStockBulk(getAvailableStock)
+	return stockMap, nil
 }
 
 func min(a, b int) int {
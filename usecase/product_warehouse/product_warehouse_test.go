@@ -0,0 +1,434 @@
+package product_warehouse
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/valuation"
+	"warehouse-service/models/warehouse"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockProductWarehouseRepository struct {
+	ProductWarehouseRepository
+	mock.Mock
+}
+
+func (m *MockProductWarehouseRepository) GetByProductAndWarehouseIdForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, wareHouseId int) (*product_warehouse.ProductWarehouse, error) {
+	args := m.Called(productId, wareHouseId)
+	return args.Get(0).(*product_warehouse.ProductWarehouse), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) Insert(ctx context.Context, tx *sqlx.Tx, productWarehouse *product_warehouse.RegisterRequest) error {
+	args := m.Called(productWarehouse)
+	return args.Error(0)
+}
+
+func (m *MockProductWarehouseRepository) AddAvailableStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, addedAvailableStock int) error {
+	args := m.Called(productId, warehouseId, addedAvailableStock)
+	return args.Error(0)
+}
+
+func (m *MockProductWarehouseRepository) SubstractAvailableStock(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, substractedAvailableStock int) error {
+	args := m.Called(productId, warehouseId, substractedAvailableStock)
+	return args.Error(0)
+}
+
+func (m *MockProductWarehouseRepository) InsertInbound(ctx context.Context, tx *sqlx.Tx, inbound *product_warehouse.InboundStock) (int, error) {
+	args := m.Called(inbound)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetInboundById(ctx context.Context, id int) (*product_warehouse.InboundStock, error) {
+	args := m.Called(id)
+	return args.Get(0).(*product_warehouse.InboundStock), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) UpdateInboundStatus(ctx context.Context, tx *sqlx.Tx, id int, fromStatus string, toStatus string) (bool, error) {
+	args := m.Called(id, fromStatus, toStatus)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) LockWarehouse(ctx context.Context, tx *sqlx.Tx, warehouseId int) error {
+	args := m.Called(warehouseId)
+	return args.Error(0)
+}
+
+func (m *MockProductWarehouseRepository) GetWarehouseUtilization(ctx context.Context, tx *sqlx.Tx, warehouseId int) (*warehouse.Utilization, error) {
+	args := m.Called(warehouseId)
+	return args.Get(0).(*warehouse.Utilization), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetUnitVolume(ctx context.Context, productId int) (float64, error) {
+	args := m.Called(productId)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetPendingInbound(ctx context.Context, productId int, shopId int, until time.Time) ([]product_warehouse.InboundStock, error) {
+	args := m.Called(productId, shopId)
+	return args.Get(0).([]product_warehouse.InboundStock), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetStockSummary(ctx context.Context, productId int, shopId int) (*product_warehouse.StockSummary, error) {
+	args := m.Called(productId, shopId)
+	return args.Get(0).(*product_warehouse.StockSummary), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetFlashSaleSlots(ctx context.Context, productIds []int) (map[int]int, error) {
+	args := m.Called(productIds)
+	return args.Get(0).(map[int]int), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetAllByProductId(ctx context.Context, productId int) ([]product_warehouse.ProductWarehouse, error) {
+	args := m.Called(productId)
+	return args.Get(0).([]product_warehouse.ProductWarehouse), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetAvailableStock(ctx context.Context, productId int) (int, error) {
+	args := m.Called(productId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetAvailableStockBulk(ctx context.Context, availableStockRequest []product_warehouse.ProductShop) (map[int]int, error) {
+	args := m.Called(availableStockRequest)
+	return args.Get(0).(map[int]int), args.Error(1)
+}
+
+func (m *MockProductWarehouseRepository) GetFlashSaleProductIds(ctx context.Context) ([]int, error) {
+	args := m.Called()
+	return args.Get(0).([]int), args.Error(1)
+}
+
+// Mock outbox
+type MockOutbox struct {
+	mock.Mock
+}
+
+func (m *MockOutbox) Add(ctx context.Context, tx *sqlx.Tx, eventType string, data interface{}) error {
+	args := m.Called(eventType, data)
+	return args.Error(0)
+}
+
+// Mock valuation
+type MockValuation struct {
+	mock.Mock
+}
+
+func (m *MockValuation) Receive(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int, unitCost *valuation.Money) error {
+	args := m.Called(movementType, productId, warehouseId, quantity, unitCost)
+	return args.Error(0)
+}
+
+func (m *MockValuation) Consume(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int) error {
+	args := m.Called(movementType, productId, warehouseId, quantity)
+	return args.Error(0)
+}
+
+func (m *MockValuation) Transfer(ctx context.Context, tx *sqlx.Tx, productId int, fromWarehouseId int, toWarehouseId int, quantity int) error {
+	args := m.Called(productId, fromWarehouseId, toWarehouseId, quantity)
+	return args.Error(0)
+}
+
+// Mock channel allocator
+type MockChannelAllocator struct {
+	mock.Mock
+}
+
+func (m *MockChannelAllocator) AvailableForChannel(ctx context.Context, productId int, shopId int, channel string, availableStock int) (int, error) {
+	args := m.Called(productId, shopId, channel, availableStock)
+	return args.Int(0), args.Error(1)
+}
+
+func newTestUsecase(mockRepo *MockProductWarehouseRepository, mockOutbox *MockOutbox, mockValuation *MockValuation, db *mysqltest.DB) *ProductWarehouseUsecase {
+	return NewProductWarehouseUsecase(mockRepo, fakePublisher{}, mockOutbox, nil, fakeBins{}, fakeBundle{}, nil, fakeChannelAllocator{}, mockValuation, db.DB, slog.Default())
+}
+
+func TestRequestTransfers_RegistersInbound(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	mockOutbox := new(MockOutbox)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, mockOutbox, new(MockValuation), db)
+	expectedAt := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	mockRepo.On("GetByProductAndWarehouseIdForUpdate", 7, 1).Return(&product_warehouse.ProductWarehouse{AvailableStock: 30}, nil)
+	mockRepo.On("GetByProductAndWarehouseIdForUpdate", 8, 1).Return(&product_warehouse.ProductWarehouse{AvailableStock: 5}, nil)
+	mockRepo.On("SubstractAvailableStock", 7, 1, 20).Return(nil)
+	mockRepo.On("SubstractAvailableStock", 8, 1, 5).Return(nil)
+	mockRepo.On("InsertInbound", mock.MatchedBy(func(inbound *product_warehouse.InboundStock) bool {
+		return inbound.ProductId == 7 && inbound.WarehouseId == 2 && *inbound.FromWarehouseId == 1 &&
+			inbound.Source == entity.InboundSourceTransfer && inbound.ExpectedAt.Equal(expectedAt)
+	})).Return(11, nil)
+	mockRepo.On("InsertInbound", mock.MatchedBy(func(inbound *product_warehouse.InboundStock) bool {
+		return inbound.ProductId == 8 && inbound.WarehouseId == 3
+	})).Return(12, nil)
+	mockOutbox.On("Add", entity.StockTransferEvent, mock.Anything).Return(nil)
+
+	err := productWarehouseUsecase.RequestTransfers(context.Background(), []product_warehouse.TransferStockRequest{
+		{ProductId: 7, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 20, ExpectedAt: &expectedAt},
+		{ProductId: 8, FromWarehouseId: 1, ToWarehouseId: 3, Quantity: 5},
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, db.Commits())
+	mockRepo.AssertExpectations(t)
+	transfer := mockOutbox.Calls[0].Arguments.Get(1).(*product_warehouse.TransferStockRequest)
+	assert.Equal(t, 11, transfer.InboundId)
+	transfer = mockOutbox.Calls[1].Arguments.Get(1).(*product_warehouse.TransferStockRequest)
+	assert.Equal(t, 12, transfer.InboundId)
+}
+
+func TestRequestTransfers_InsufficientStockRequestsNone(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	mockOutbox := new(MockOutbox)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, mockOutbox, new(MockValuation), db)
+
+	mockRepo.On("GetByProductAndWarehouseIdForUpdate", 7, 1).Return(&product_warehouse.ProductWarehouse{AvailableStock: 30}, nil)
+	mockRepo.On("GetByProductAndWarehouseIdForUpdate", 8, 1).Return(&product_warehouse.ProductWarehouse{AvailableStock: 4}, nil)
+	mockRepo.On("SubstractAvailableStock", 7, 1, 20).Return(nil)
+	mockRepo.On("InsertInbound", mock.Anything).Return(11, nil)
+	mockOutbox.On("Add", entity.StockTransferEvent, mock.Anything).Return(nil)
+
+	err := productWarehouseUsecase.RequestTransfers(context.Background(), []product_warehouse.TransferStockRequest{
+		{ProductId: 7, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 20},
+		{ProductId: 8, FromWarehouseId: 1, ToWarehouseId: 3, Quantity: 5},
+	})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+	assert.Equal(t, 0, db.Commits())
+	assert.Equal(t, 1, db.Rollbacks())
+	mockRepo.AssertNotCalled(t, "SubstractAvailableStock", 8, 1, 5)
+}
+
+func TestTransferStock_ReceivesInbound(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	mockValuation := new(MockValuation)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), mockValuation, db)
+	fromWarehouseId := 1
+
+	mockRepo.On("GetInboundById", 11).Return(&product_warehouse.InboundStock{
+		Id: 11, ProductId: 7, WarehouseId: 2, FromWarehouseId: &fromWarehouseId, Quantity: 20, Status: entity.InboundPending,
+	}, nil)
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetWarehouseUtilization", 2).Return(&warehouse.Utilization{}, nil)
+	mockRepo.On("UpdateInboundStatus", 11, entity.InboundPending, entity.InboundReceived).Return(true, nil)
+	mockRepo.On("AddAvailableStock", 7, 2, 20).Return(nil)
+	mockValuation.On("Transfer", 7, 1, 2, 20).Return(nil)
+
+	err := productWarehouseUsecase.TransferStock(context.Background(), &product_warehouse.TransferStockRequest{
+		ProductId: 7, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 20, InboundId: 11,
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, db.Commits())
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "SubstractAvailableStock", mock.Anything, mock.Anything, mock.Anything)
+	mockValuation.AssertExpectations(t)
+}
+
+func TestTransferStock_RedeliveredInboundIsIgnored(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), new(MockValuation), db)
+
+	mockRepo.On("GetInboundById", 11).Return(&product_warehouse.InboundStock{
+		Id: 11, ProductId: 7, WarehouseId: 2, Quantity: 20, Status: entity.InboundReceived,
+	}, nil)
+
+	err := productWarehouseUsecase.TransferStock(context.Background(), &product_warehouse.TransferStockRequest{
+		ProductId: 7, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 20, InboundId: 11,
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 0, db.Commits())
+	mockRepo.AssertNotCalled(t, "AddAvailableStock", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeductStock_InsufficientUnderLock(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), new(MockValuation), db)
+
+	mockRepo.On("GetByProductAndWarehouseIdForUpdate", 7, 1).Return(&product_warehouse.ProductWarehouse{AvailableStock: 4}, nil)
+
+	err := productWarehouseUsecase.DeductStock(context.Background(), &product_warehouse.StockOperationRequest{ProductId: 7, WarehouseId: 1, Quantity: 5})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+	assert.Equal(t, 1, db.Rollbacks())
+	mockRepo.AssertNotCalled(t, "SubstractAvailableStock", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeductStock_CommitFails(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	mockValuation := new(MockValuation)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), mockValuation, db)
+	errCommit := errors.New("invalid connection")
+
+	mockRepo.On("GetByProductAndWarehouseIdForUpdate", 7, 1).Return(&product_warehouse.ProductWarehouse{AvailableStock: 10}, nil)
+	mockRepo.On("SubstractAvailableStock", 7, 1, 5).Return(nil)
+	mockValuation.On("Consume", entity.CostMovementDeduction, 7, 1, 5).Return(nil)
+	db.FailCommits(errCommit)
+
+	err := productWarehouseUsecase.DeductStock(context.Background(), &product_warehouse.StockOperationRequest{ProductId: 7, WarehouseId: 1, Quantity: 5})
+
+	// Assertions
+	assert.ErrorIs(t, err, errCommit)
+	assert.Equal(t, 0, db.Commits())
+}
+
+func TestReserveStock_ChannelPoolOfTheShop(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	mockOutbox := new(MockOutbox)
+	mockChannelAllocator := new(MockChannelAllocator)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := NewProductWarehouseUsecase(mockRepo, fakePublisher{}, mockOutbox, nil, fakeBins{}, fakeBundle{}, nil, mockChannelAllocator, new(MockValuation), db.DB, slog.Default())
+
+	mockRepo.On("GetFlashSaleSlots", []int{7}).Return(map[int]int{}, nil)
+	mockRepo.On("GetAvailableStock", 7).Return(100, nil)
+	mockRepo.On("GetAvailableStockBulk", []product_warehouse.ProductShop{{ProductId: 7, ShopId: 3}}).Return(map[int]int{7: 60}, nil)
+	mockChannelAllocator.On("AvailableForChannel", 7, 3, "marketplace", 60).Return(4, nil)
+	mockOutbox.On("Add", entity.OrderUpdateStatusEvent, mock.Anything).Return(nil)
+
+	err := productWarehouseUsecase.ReserveStock(context.Background(), &product_warehouse.StockOperationOrderRequest{
+		OrderId:         1,
+		ShopId:          3,
+		Channel:         "marketplace",
+		StockOperations: []product_warehouse.StockOperationRequest{{ProductId: 7, Quantity: 5}},
+	})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+	mockChannelAllocator.AssertExpectations(t)
+	mockOutbox.AssertExpectations(t)
+}
+
+func TestReserveStock_LinesOfAProductAddUp(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	mockOutbox := new(MockOutbox)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := NewProductWarehouseUsecase(mockRepo, fakePublisher{}, mockOutbox, nil, fakeBins{}, fakeBundle{}, nil, new(MockChannelAllocator), new(MockValuation), db.DB, slog.Default())
+
+	mockRepo.On("GetFlashSaleSlots", []int{7, 7}).Return(map[int]int{}, nil)
+	mockRepo.On("GetAvailableStock", 7).Return(5, nil).Once()
+	mockOutbox.On("Add", entity.OrderUpdateStatusEvent, mock.Anything).Return(nil)
+
+	// each line fits the available stock, both together do not
+	err := productWarehouseUsecase.ReserveStock(context.Background(), &product_warehouse.StockOperationOrderRequest{
+		OrderId:         1,
+		StockOperations: []product_warehouse.StockOperationRequest{{ProductId: 7, Quantity: 3}, {ProductId: 7, Quantity: 3}},
+	})
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+	assert.Equal(t, 1, db.Rollbacks())
+	mockRepo.AssertExpectations(t)
+	mockOutbox.AssertExpectations(t)
+}
+
+func TestAllocateSequential_InsufficientStock(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), new(MockValuation), mysqltest.NewDB())
+
+	mockRepo.On("GetAllByProductId", 7).Return([]product_warehouse.ProductWarehouse{
+		{ProductId: 7, WarehouseId: 1, AvailableStock: 2},
+		{ProductId: 7, WarehouseId: 2, AvailableStock: 4},
+	}, nil)
+
+	// warehouse 2 is already taken by a bundle down to 1 unit
+	allocations, err := productWarehouseUsecase.allocateSequential(context.Background(),
+		[]product_warehouse.StockOperationRequest{{ProductId: 7, Quantity: 4}},
+		map[int]map[int]int{2: {7: 3}})
+
+	// Assertions
+	assert.Nil(t, allocations)
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+}
+
+func TestReconcileFlashSales_KeepsGoingAfterAnError(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), new(MockValuation), mysqltest.NewDB())
+	errSlots := errors.New("slots unavailable")
+
+	mockRepo.On("GetFlashSaleProductIds").Return([]int{7, 8}, nil)
+	mockRepo.On("GetFlashSaleSlots", []int{7}).Return(map[int]int{}, errSlots)
+	mockRepo.On("GetFlashSaleSlots", []int{8}).Return(map[int]int{}, errSlots)
+
+	err := productWarehouseUsecase.ReconcileFlashSales(context.Background())
+
+	// Assertions
+	assert.ErrorIs(t, err, errSlots)
+	assert.Contains(t, err.Error(), "product 7")
+	assert.Contains(t, err.Error(), "product 8")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRegister_ReceivesOpeningStock(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	mockValuation := new(MockValuation)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), mockValuation, db)
+	unitCost := valuation.Money(125000)
+	register := &product_warehouse.RegisterRequest{ProductId: 7, WarehouseId: 2, AvailableStock: 10, UnitCost: &unitCost}
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetWarehouseUtilization", 2).Return(&warehouse.Utilization{}, nil)
+	mockRepo.On("Insert", register).Return(nil)
+	mockValuation.On("Receive", entity.CostMovementReceipt, 7, 2, 10, &unitCost).Return(nil)
+
+	err := productWarehouseUsecase.Register(context.Background(), register)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 1, db.Commits())
+	mockRepo.AssertExpectations(t)
+	mockValuation.AssertExpectations(t)
+}
+
+func TestRegister_OpeningStockOverCapacity(t *testing.T) {
+	mockRepo := new(MockProductWarehouseRepository)
+	db := mysqltest.NewDB()
+	productWarehouseUsecase := newTestUsecase(mockRepo, new(MockOutbox), new(MockValuation), db)
+	capacityUnits := 100
+	register := &product_warehouse.RegisterRequest{ProductId: 7, WarehouseId: 2, AvailableStock: 11}
+
+	mockRepo.On("LockWarehouse", 2).Return(nil)
+	mockRepo.On("GetWarehouseUtilization", 2).Return(&warehouse.Utilization{CapacityUnits: &capacityUnits, UsedUnits: 90}, nil)
+
+	err := productWarehouseUsecase.Register(context.Background(), register)
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorOverCapacity)
+	assert.Equal(t, 1, db.Rollbacks())
+	mockRepo.AssertNotCalled(t, "Insert", mock.Anything)
+}
+
+func TestToBaseUnitCost_LeavesTheUnitCost(t *testing.T) {
+	productWarehouseUsecase := NewProductWarehouseUsecase(nil, fakePublisher{}, nil, nil, fakeBins{}, fakeBundle{}, caseUnit{}, fakeChannelAllocator{}, nil, mysqltest.NewDB().DB, slog.Default())
+	unit := "case"
+	quantity := 2
+	unitCost := valuation.Money(1200000)
+
+	baseUnitCost, err := productWarehouseUsecase.toBaseUnitCost(context.Background(), 7, &unit, &quantity, &unitCost)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 24, quantity)
+	assert.Equal(t, valuation.Money(100000), *baseUnitCost)
+	assert.Equal(t, valuation.Money(1200000), unitCost)
+}
@@ -0,0 +1,56 @@
+package product_warehouse
+
+import (
+	"context"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/valuation"
+)
+
+// toBaseUnit converts quantity to the product's base unit in place and clears
+// unit, so converting the same request twice is harmless.
+func (p *ProductWarehouseUsecase) toBaseUnit(ctx context.Context, productId int, unit *string, quantity *int) error {
+	if *unit == "" {
+		return nil
+	}
+
+	baseQuantity, err := p.unitConverter.ToBase(ctx, productId, *unit, *quantity)
+	if err != nil {
+		return err
+	}
+	*quantity = baseQuantity
+	*unit = ""
+	return nil
+}
+
+// toBaseUnitCost is toBaseUnit for operations that carry a unit cost, and
+// returns the cost of one base unit. The given unit cost is left untouched.
+func (p *ProductWarehouseUsecase) toBaseUnitCost(ctx context.Context, productId int, unit *string, quantity *int, unitCost *valuation.Money) (*valuation.Money, error) {
+	unitQuantity := *quantity
+	err := p.toBaseUnit(ctx, productId, unit, quantity)
+	if err != nil {
+		return nil, err
+	}
+	if unitCost == nil || *quantity == unitQuantity || *quantity == 0 {
+		return unitCost, nil
+	}
+	baseUnitCost := unitCost.Times(unitQuantity).Per(*quantity)
+	return &baseUnitCost, nil
+}
+
+// availableToPromiseFromBase converts every quantity of the response to the
+// requested unit, each rounded down to whole units.
+func (p *ProductWarehouseUsecase) availableToPromiseFromBase(ctx context.Context, atp *product_warehouse.AvailableToPromise, unit string) error {
+	quantities := []*int{&atp.OnHand, &atp.Reserved, &atp.SafetyStock, &atp.Inbound}
+	for i := range atp.Curve {
+		quantities = append(quantities, &atp.Curve[i].Inbound, &atp.Curve[i].Available)
+	}
+	for _, quantity := range quantities {
+		converted, err := p.unitConverter.FromBase(ctx, atp.ProductId, unit, *quantity)
+		if err != nil {
+			return err
+		}
+		*quantity = converted
+	}
+	atp.Unit = unit
+	return nil
+}
@@ -0,0 +1,145 @@
+package rebalance
+
+import (
+	"context"
+	"sort"
+	"time"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/rebalance"
+	"warehouse-service/tracing"
+)
+
+type RebalanceRepository interface {
+	GetWarehouseStocks(ctx context.Context, shopId int, productId int, demandSince time.Time) ([]rebalance.WarehouseStock, error)
+}
+
+type Transferer interface {
+	RequestTransfers(ctx context.Context, transfers []product_warehouse.TransferStockRequest) error
+}
+
+type RebalanceUsecase struct {
+	rebalanceRepo RebalanceRepository
+	transferer    Transferer
+}
+
+func NewRebalanceUsecase(rebalanceRepo RebalanceRepository, transferer Transferer) *RebalanceUsecase {
+	return &RebalanceUsecase{
+		rebalanceRepo: rebalanceRepo,
+		transferer:    transferer,
+	}
+}
+
+// Plan proposes transfers that move surplus stock into warehouses that are
+// below their target level. Target level is the safety stock plus the demand
+// expected over the coverage window, based on reservations in the demand window.
+func (r *RebalanceUsecase) Plan(ctx context.Context, planRequest *rebalance.PlanRequest) (_ *rebalance.Plan, err error) {
+	ctx, span := tracing.Start(ctx, "RebalanceUsecase.Plan")
+	defer func() { tracing.End(span, err) }()
+
+	demandSince := time.Now().AddDate(0, 0, -planRequest.DemandDays)
+	warehouseStocks, err := r.rebalanceRepo.GetWarehouseStocks(ctx, planRequest.ShopId, planRequest.ProductId, demandSince)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := buildPlan(warehouseStocks, planRequest)
+	if planRequest.DryRun {
+		return plan, nil
+	}
+
+	// all transfers of the plan are requested, or none when one fails
+	err = r.transferer.RequestTransfers(ctx, plan.Transfers)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+type stockGap struct {
+	warehouseId int
+	quantity    int
+}
+
+func buildPlan(warehouseStocks []rebalance.WarehouseStock, planRequest *rebalance.PlanRequest) *rebalance.Plan {
+	plan := &rebalance.Plan{
+		DryRun:    planRequest.DryRun,
+		Targets:   []rebalance.WarehouseTarget{},
+		Transfers: []product_warehouse.TransferStockRequest{},
+	}
+
+	productIds := []int{}
+	stocksByProduct := make(map[int][]rebalance.WarehouseStock)
+	for _, warehouseStock := range warehouseStocks {
+		if _, ok := stocksByProduct[warehouseStock.ProductId]; !ok {
+			productIds = append(productIds, warehouseStock.ProductId)
+		}
+		stocksByProduct[warehouseStock.ProductId] = append(stocksByProduct[warehouseStock.ProductId], warehouseStock)
+	}
+	sort.Ints(productIds)
+
+	budget := planRequest.MaxTransferUnits
+	for _, productId := range productIds {
+		surpluses := []stockGap{}
+		deficits := []stockGap{}
+		for _, warehouseStock := range stocksByProduct[productId] {
+			target := targetStock(warehouseStock, planRequest.DemandDays, planRequest.CoverageDays)
+			plan.Targets = append(plan.Targets, rebalance.WarehouseTarget{
+				ProductId:      productId,
+				WarehouseId:    warehouseStock.WarehouseId,
+				AvailableStock: warehouseStock.AvailableStock,
+				TargetStock:    target,
+			})
+			gap := warehouseStock.AvailableStock - target
+			if gap > 0 {
+				surpluses = append(surpluses, stockGap{warehouseId: warehouseStock.WarehouseId, quantity: gap})
+			} else if gap < 0 {
+				deficits = append(deficits, stockGap{warehouseId: warehouseStock.WarehouseId, quantity: -gap})
+			}
+		}
+		sortGaps(surpluses)
+		sortGaps(deficits)
+
+		for i, j := 0, 0; i < len(surpluses) && j < len(deficits) && budget > 0; {
+			quantity := min(min(surpluses[i].quantity, deficits[j].quantity), budget)
+			plan.Transfers = append(plan.Transfers, product_warehouse.TransferStockRequest{
+				ProductId:       productId,
+				FromWarehouseId: surpluses[i].warehouseId,
+				ToWarehouseId:   deficits[j].warehouseId,
+				Quantity:        quantity,
+			})
+			plan.TotalUnits += quantity
+			budget -= quantity
+			surpluses[i].quantity -= quantity
+			deficits[j].quantity -= quantity
+			if surpluses[i].quantity == 0 {
+				i++
+			}
+			if deficits[j].quantity == 0 {
+				j++
+			}
+		}
+	}
+	return plan
+}
+
+func targetStock(warehouseStock rebalance.WarehouseStock, demandDays int, coverageDays int) int {
+	expectedDemand := (warehouseStock.Demand*coverageDays + demandDays - 1) / demandDays
+	return warehouseStock.SafetyStock + expectedDemand
+}
+
+// sortGaps puts the largest gaps first so the plan uses as few transfers as possible.
+func sortGaps(gaps []stockGap) {
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].quantity != gaps[j].quantity {
+			return gaps[i].quantity > gaps[j].quantity
+		}
+		return gaps[i].warehouseId < gaps[j].warehouseId
+	})
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,166 @@
+package rebalance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/product_warehouse"
+	"warehouse-service/models/rebalance"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockRebalanceRepository struct {
+	mock.Mock
+}
+
+func (m *MockRebalanceRepository) GetWarehouseStocks(ctx context.Context, shopId int, productId int, demandSince time.Time) ([]rebalance.WarehouseStock, error) {
+	args := m.Called(shopId, productId, demandSince)
+	return args.Get(0).([]rebalance.WarehouseStock), args.Error(1)
+}
+
+// Mock transferer
+type MockTransferer struct {
+	mock.Mock
+}
+
+func (m *MockTransferer) RequestTransfers(ctx context.Context, transfers []product_warehouse.TransferStockRequest) error {
+	args := m.Called(transfers)
+	return args.Error(0)
+}
+
+func TestPlan_DryRun(t *testing.T) {
+	mockRepo := new(MockRebalanceRepository)
+	mockTransferer := new(MockTransferer)
+	rebalanceUsecase := NewRebalanceUsecase(mockRepo, mockTransferer)
+
+	// Warehouse 1 has 100 units against a target of 10 + 20, warehouse 2 has
+	// nothing against a target of 5 + 40, warehouse 3 is exactly on target.
+	mockRepo.On("GetWarehouseStocks", 1, 0, mock.Anything).Return([]rebalance.WarehouseStock{
+		{ProductId: 7, WarehouseId: 1, AvailableStock: 100, SafetyStock: 10, Demand: 20},
+		{ProductId: 7, WarehouseId: 2, AvailableStock: 0, SafetyStock: 5, Demand: 40},
+		{ProductId: 7, WarehouseId: 3, AvailableStock: 5, SafetyStock: 5, Demand: 0},
+	}, nil)
+
+	plan, err := rebalanceUsecase.Plan(context.Background(), &rebalance.PlanRequest{
+		ShopId:           1,
+		DemandDays:       7,
+		CoverageDays:     7,
+		MaxTransferUnits: 1000,
+		DryRun:           true,
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []product_warehouse.TransferStockRequest{
+		{ProductId: 7, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 45},
+	}, plan.Transfers)
+	assert.Equal(t, 45, plan.TotalUnits)
+	mockRepo.AssertExpectations(t)
+	mockTransferer.AssertNotCalled(t, "RequestTransfers", mock.Anything)
+}
+
+func TestPlan_RespectsBudget(t *testing.T) {
+	mockRepo := new(MockRebalanceRepository)
+	mockTransferer := new(MockTransferer)
+	rebalanceUsecase := NewRebalanceUsecase(mockRepo, mockTransferer)
+
+	mockRepo.On("GetWarehouseStocks", 1, 0, mock.Anything).Return([]rebalance.WarehouseStock{
+		{ProductId: 7, WarehouseId: 1, AvailableStock: 50},
+		{ProductId: 7, WarehouseId: 2, SafetyStock: 30},
+		{ProductId: 8, WarehouseId: 1, AvailableStock: 50},
+		{ProductId: 8, WarehouseId: 2, SafetyStock: 30},
+	}, nil)
+
+	plan, err := rebalanceUsecase.Plan(context.Background(), &rebalance.PlanRequest{
+		ShopId:           1,
+		DemandDays:       7,
+		CoverageDays:     7,
+		MaxTransferUnits: 40,
+		DryRun:           true,
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []product_warehouse.TransferStockRequest{
+		{ProductId: 7, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 30},
+		{ProductId: 8, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 10},
+	}, plan.Transfers)
+	assert.Equal(t, 40, plan.TotalUnits)
+}
+
+func TestPlan_RequestsTransfers(t *testing.T) {
+	mockRepo := new(MockRebalanceRepository)
+	mockTransferer := new(MockTransferer)
+	rebalanceUsecase := NewRebalanceUsecase(mockRepo, mockTransferer)
+
+	mockRepo.On("GetWarehouseStocks", 1, 7, mock.Anything).Return([]rebalance.WarehouseStock{
+		{ProductId: 7, WarehouseId: 1, AvailableStock: 50},
+		{ProductId: 7, WarehouseId: 2, SafetyStock: 30},
+	}, nil)
+	mockTransferer.On("RequestTransfers", []product_warehouse.TransferStockRequest{
+		{ProductId: 7, FromWarehouseId: 1, ToWarehouseId: 2, Quantity: 30},
+	}).Return(nil)
+
+	_, err := rebalanceUsecase.Plan(context.Background(), &rebalance.PlanRequest{
+		ShopId:           1,
+		ProductId:        7,
+		DemandDays:       7,
+		CoverageDays:     7,
+		MaxTransferUnits: 100,
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	mockTransferer.AssertExpectations(t)
+}
+
+func TestPlan_TransferError(t *testing.T) {
+	mockRepo := new(MockRebalanceRepository)
+	mockTransferer := new(MockTransferer)
+	rebalanceUsecase := NewRebalanceUsecase(mockRepo, mockTransferer)
+
+	mockRepo.On("GetWarehouseStocks", 1, 0, mock.Anything).Return([]rebalance.WarehouseStock{
+		{ProductId: 7, WarehouseId: 1, AvailableStock: 50},
+		{ProductId: 7, WarehouseId: 2, SafetyStock: 30},
+		{ProductId: 8, WarehouseId: 1, AvailableStock: 50},
+		{ProductId: 8, WarehouseId: 2, SafetyStock: 30},
+	}, nil)
+	mockTransferer.On("RequestTransfers", mock.Anything).Return(entity.ErrorInsufficientStock)
+
+	plan, err := rebalanceUsecase.Plan(context.Background(), &rebalance.PlanRequest{
+		ShopId:           1,
+		DemandDays:       7,
+		CoverageDays:     7,
+		MaxTransferUnits: 100,
+	})
+
+	// Assertions
+	assert.Nil(t, plan)
+	assert.ErrorIs(t, err, entity.ErrorInsufficientStock)
+	mockTransferer.AssertNumberOfCalls(t, "RequestTransfers", 1)
+}
+
+func TestPlan_RepositoryError(t *testing.T) {
+	mockRepo := new(MockRebalanceRepository)
+	mockTransferer := new(MockTransferer)
+	rebalanceUsecase := NewRebalanceUsecase(mockRepo, mockTransferer)
+
+	mockRepo.On("GetWarehouseStocks", 1, 0, mock.Anything).Return([]rebalance.WarehouseStock(nil), errors.New("database error"))
+
+	plan, err := rebalanceUsecase.Plan(context.Background(), &rebalance.PlanRequest{
+		ShopId:           1,
+		DemandDays:       7,
+		CoverageDays:     7,
+		MaxTransferUnits: 100,
+	})
+
+	// Assertions
+	assert.Nil(t, plan)
+	assert.Error(t, err)
+	assert.Equal(t, "database error", err.Error())
+}
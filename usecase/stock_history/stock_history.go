@@ -0,0 +1,193 @@
+package stock_history
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+	"warehouse-service/models/stock_history"
+	"warehouse-service/tracing"
+)
+
+type StockHistoryRepository interface {
+	TakeSnapshot(ctx context.Context) (*stock_history.Snapshot, error)
+	GetLatestSnapshot(ctx context.Context, at time.Time) (*stock_history.Snapshot, error)
+	GetSnapshotItems(ctx context.Context, snapshotId int, filter stock_history.Filter) ([]stock_history.StockLevel, error)
+	GetMovementTotals(ctx context.Context, from *time.Time, to time.Time, filter stock_history.Filter) ([]stock_history.StockLevel, error)
+}
+
+type StockHistoryUsecase struct {
+	stockHistoryRepo StockHistoryRepository
+	logger           *slog.Logger
+}
+
+func NewStockHistoryUsecase(stockHistoryRepo StockHistoryRepository, logger *slog.Logger) *StockHistoryUsecase {
+	return &StockHistoryUsecase{
+		stockHistoryRepo: stockHistoryRepo,
+		logger:           logger,
+	}
+}
+
+type stockKey struct {
+	productId   int
+	warehouseId int
+}
+
+func (s *StockHistoryUsecase) TakeSnapshot(ctx context.Context) (_ *stock_history.Snapshot, err error) {
+	ctx, span := tracing.Start(ctx, "StockHistoryUsecase.TakeSnapshot")
+	defer func() { tracing.End(span, err) }()
+
+	return s.stockHistoryRepo.TakeSnapshot(ctx)
+}
+
+// RunSnapshotJob takes a snapshot on every tick until ctx is done.
+func (s *StockHistoryUsecase) RunSnapshotJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if _, err := s.stockHistoryRepo.TakeSnapshot(ctx); err != nil {
+			s.logger.ErrorContext(ctx, "failed to take stock snapshot", "error", err)
+		}
+	}
+}
+
+func (s *StockHistoryUsecase) GetStockAsOf(ctx context.Context, asOfRequest *stock_history.AsOfRequest) (_ *stock_history.AsOf, err error) {
+	ctx, span := tracing.Start(ctx, "StockHistoryUsecase.GetStockAsOf")
+	defer func() { tracing.End(span, err) }()
+
+	stockLevels, snapshot, err := s.stockAsOf(ctx, asOfRequest.At, asOfRequest.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	asOf := &stock_history.AsOf{
+		At:     asOfRequest.At,
+		Stocks: stockLevels,
+	}
+	if snapshot != nil {
+		asOf.SnapshotId = &snapshot.Id
+	}
+	return asOf, nil
+}
+
+func (s *StockHistoryUsecase) GetStockDiff(ctx context.Context, diffRequest *stock_history.DiffRequest) (_ *stock_history.Diff, err error) {
+	ctx, span := tracing.Start(ctx, "StockHistoryUsecase.GetStockDiff")
+	defer func() { tracing.End(span, err) }()
+
+	fromStockLevels, _, err := s.stockAsOf(ctx, diffRequest.From, diffRequest.Filter)
+	if err != nil {
+		return nil, err
+	}
+	toStockLevels, _, err := s.stockAsOf(ctx, diffRequest.To, diffRequest.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stock_history.Diff{
+		From:  diffRequest.From,
+		To:    diffRequest.To,
+		Diffs: diffStockLevels(fromStockLevels, toStockLevels),
+	}, nil
+}
+
+// stockAsOf starts from the latest snapshot at or before at and replays the
+// ledger recorded after it. Without a snapshot the whole ledger is replayed.
+func (s *StockHistoryUsecase) stockAsOf(ctx context.Context, at time.Time, filter stock_history.Filter) ([]stock_history.StockLevel, *stock_history.Snapshot, error) {
+	snapshot, err := s.stockHistoryRepo.GetLatestSnapshot(ctx, at)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stockLevels := []stock_history.StockLevel{}
+	var from *time.Time
+	if snapshot != nil {
+		stockLevels, err = s.stockHistoryRepo.GetSnapshotItems(ctx, snapshot.Id, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		from = &snapshot.TakenAt
+	}
+
+	movementTotals, err := s.stockHistoryRepo.GetMovementTotals(ctx, from, at, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return applyMovements(stockLevels, movementTotals), snapshot, nil
+}
+
+// applyMovements adds the movement totals to the snapshot stock levels and
+// returns them sorted by product and warehouse.
+func applyMovements(stockLevels []stock_history.StockLevel, movementTotals []stock_history.StockLevel) []stock_history.StockLevel {
+	levels := make(map[stockKey]*stock_history.StockLevel)
+	for i := range stockLevels {
+		levels[stockKey{stockLevels[i].ProductId, stockLevels[i].WarehouseId}] = &stockLevels[i]
+	}
+
+	result := stockLevels
+	for _, movementTotal := range movementTotals {
+		key := stockKey{movementTotal.ProductId, movementTotal.WarehouseId}
+		if level, ok := levels[key]; ok {
+			level.AvailableStock += movementTotal.AvailableStock
+			level.ReservedStock += movementTotal.ReservedStock
+			continue
+		}
+		result = append(result, movementTotal)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ProductId != result[j].ProductId {
+			return result[i].ProductId < result[j].ProductId
+		}
+		return result[i].WarehouseId < result[j].WarehouseId
+	})
+	return result
+}
+
+// diffStockLevels lists the product-warehouses whose stock differs between
+// the two points in time.
+func diffStockLevels(fromStockLevels []stock_history.StockLevel, toStockLevels []stock_history.StockLevel) []stock_history.StockDiff {
+	diffs := make(map[stockKey]*stock_history.StockDiff)
+	keys := []stockKey{}
+	diffFor := func(productId int, warehouseId int) *stock_history.StockDiff {
+		key := stockKey{productId, warehouseId}
+		if _, ok := diffs[key]; !ok {
+			diffs[key] = &stock_history.StockDiff{ProductId: productId, WarehouseId: warehouseId}
+			keys = append(keys, key)
+		}
+		return diffs[key]
+	}
+	for _, stockLevel := range fromStockLevels {
+		diff := diffFor(stockLevel.ProductId, stockLevel.WarehouseId)
+		diff.FromAvailableStock = stockLevel.AvailableStock
+		diff.FromReservedStock = stockLevel.ReservedStock
+	}
+	for _, stockLevel := range toStockLevels {
+		diff := diffFor(stockLevel.ProductId, stockLevel.WarehouseId)
+		diff.ToAvailableStock = stockLevel.AvailableStock
+		diff.ToReservedStock = stockLevel.ReservedStock
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].productId != keys[j].productId {
+			return keys[i].productId < keys[j].productId
+		}
+		return keys[i].warehouseId < keys[j].warehouseId
+	})
+
+	result := []stock_history.StockDiff{}
+	for _, key := range keys {
+		diff := diffs[key]
+		diff.AvailableDelta = diff.ToAvailableStock - diff.FromAvailableStock
+		diff.ReservedDelta = diff.ToReservedStock - diff.FromReservedStock
+		if diff.AvailableDelta == 0 && diff.ReservedDelta == 0 {
+			continue
+		}
+		result = append(result, *diff)
+	}
+	return result
+}
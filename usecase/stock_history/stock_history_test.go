@@ -0,0 +1,107 @@
+package stock_history
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+	"warehouse-service/models/stock_history"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockStockHistoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockStockHistoryRepository) TakeSnapshot(ctx context.Context) (*stock_history.Snapshot, error) {
+	args := m.Called()
+	return args.Get(0).(*stock_history.Snapshot), args.Error(1)
+}
+
+func (m *MockStockHistoryRepository) GetLatestSnapshot(ctx context.Context, at time.Time) (*stock_history.Snapshot, error) {
+	args := m.Called(at)
+	snapshot, _ := args.Get(0).(*stock_history.Snapshot)
+	return snapshot, args.Error(1)
+}
+
+func (m *MockStockHistoryRepository) GetSnapshotItems(ctx context.Context, snapshotId int, filter stock_history.Filter) ([]stock_history.StockLevel, error) {
+	args := m.Called(snapshotId, filter)
+	return args.Get(0).([]stock_history.StockLevel), args.Error(1)
+}
+
+func (m *MockStockHistoryRepository) GetMovementTotals(ctx context.Context, from *time.Time, to time.Time, filter stock_history.Filter) ([]stock_history.StockLevel, error) {
+	args := m.Called(from, to, filter)
+	return args.Get(0).([]stock_history.StockLevel), args.Error(1)
+}
+
+func TestGetStockAsOf_FromSnapshot(t *testing.T) {
+	mockRepo := new(MockStockHistoryRepository)
+	stockHistoryUsecase := NewStockHistoryUsecase(mockRepo, slog.Default())
+
+	takenAt := time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2026, 9, 30, 23, 59, 59, 0, time.UTC)
+	filter := stock_history.Filter{WarehouseId: 3}
+	mockRepo.On("GetLatestSnapshot", at).Return(&stock_history.Snapshot{Id: 9, TakenAt: takenAt}, nil)
+	mockRepo.On("GetSnapshotItems", 9, filter).Return([]stock_history.StockLevel{
+		{ProductId: 2, WarehouseId: 3, AvailableStock: 10, ReservedStock: 2},
+		{ProductId: 1, WarehouseId: 3, AvailableStock: 5},
+	}, nil)
+	mockRepo.On("GetMovementTotals", &takenAt, at, filter).Return([]stock_history.StockLevel{
+		{ProductId: 2, WarehouseId: 3, AvailableStock: -4, ReservedStock: 4},
+		{ProductId: 3, WarehouseId: 3, AvailableStock: 7},
+	}, nil)
+
+	asOf, err := stockHistoryUsecase.GetStockAsOf(context.Background(), &stock_history.AsOfRequest{Filter: filter, At: at})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 9, *asOf.SnapshotId)
+	assert.Equal(t, []stock_history.StockLevel{
+		{ProductId: 1, WarehouseId: 3, AvailableStock: 5},
+		{ProductId: 2, WarehouseId: 3, AvailableStock: 6, ReservedStock: 6},
+		{ProductId: 3, WarehouseId: 3, AvailableStock: 7},
+	}, asOf.Stocks)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetStockAsOf_WithoutSnapshot(t *testing.T) {
+	mockRepo := new(MockStockHistoryRepository)
+	stockHistoryUsecase := NewStockHistoryUsecase(mockRepo, slog.Default())
+
+	at := time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetLatestSnapshot", at).Return(nil, nil)
+	mockRepo.On("GetMovementTotals", (*time.Time)(nil), at, stock_history.Filter{}).Return([]stock_history.StockLevel{
+		{ProductId: 1, WarehouseId: 3, AvailableStock: 5},
+	}, nil)
+
+	asOf, err := stockHistoryUsecase.GetStockAsOf(context.Background(), &stock_history.AsOfRequest{At: at})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Nil(t, asOf.SnapshotId)
+	assert.Equal(t, []stock_history.StockLevel{{ProductId: 1, WarehouseId: 3, AvailableStock: 5}}, asOf.Stocks)
+	mockRepo.AssertNotCalled(t, "GetSnapshotItems", mock.Anything, mock.Anything)
+}
+
+func TestDiffStockLevels(t *testing.T) {
+	fromStockLevels := []stock_history.StockLevel{
+		{ProductId: 1, WarehouseId: 3, AvailableStock: 5},
+		{ProductId: 2, WarehouseId: 3, AvailableStock: 10, ReservedStock: 2},
+	}
+	toStockLevels := []stock_history.StockLevel{
+		{ProductId: 1, WarehouseId: 3, AvailableStock: 5},
+		{ProductId: 2, WarehouseId: 3, AvailableStock: 6, ReservedStock: 1},
+		{ProductId: 2, WarehouseId: 4, AvailableStock: 8},
+	}
+
+	diffs := diffStockLevels(fromStockLevels, toStockLevels)
+
+	// Assertions
+	assert.Equal(t, []stock_history.StockDiff{
+		{ProductId: 2, WarehouseId: 3, FromAvailableStock: 10, ToAvailableStock: 6, AvailableDelta: -4, FromReservedStock: 2, ToReservedStock: 1, ReservedDelta: -1},
+		{ProductId: 2, WarehouseId: 4, ToAvailableStock: 8, AvailableDelta: 8},
+	}, diffs)
+}
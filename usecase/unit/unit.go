@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"warehouse-service/entity"
+	"warehouse-service/models/unit"
+	"warehouse-service/tracing"
+)
+
+type UnitRepository interface {
+	Upsert(ctx context.Context, productUnit *unit.RegisterRequest) error
+	GetByProductId(ctx context.Context, productId int) ([]unit.ProductUnit, error)
+	GetFactor(ctx context.Context, productId int, unitName string) (int, error)
+}
+
+type UnitUsecase struct {
+	unitRepo UnitRepository
+}
+
+func NewUnitUsecase(unitRepo UnitRepository) *UnitUsecase {
+	return &UnitUsecase{
+		unitRepo: unitRepo,
+	}
+}
+
+func (u *UnitUsecase) Register(ctx context.Context, productUnit *unit.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UnitUsecase.Register")
+	defer func() { tracing.End(span, err) }()
+
+	return u.unitRepo.Upsert(ctx, productUnit)
+}
+
+func (u *UnitUsecase) GetUnits(ctx context.Context, productId int) (_ []unit.ProductUnit, err error) {
+	ctx, span := tracing.Start(ctx, "UnitUsecase.GetUnits")
+	defer func() { tracing.End(span, err) }()
+
+	return u.unitRepo.GetByProductId(ctx, productId)
+}
+
+// ToBase converts a quantity in the given unit to the base unit stock is
+// stored in. An empty unit means the quantity is already in the base unit.
+func (u *UnitUsecase) ToBase(ctx context.Context, productId int, unitName string, quantity int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "UnitUsecase.ToBase")
+	defer func() { tracing.End(span, err) }()
+
+	factor, err := u.factor(ctx, productId, unitName)
+	if err != nil {
+		return 0, err
+	}
+	return quantity * factor, nil
+}
+
+// FromBase converts a base unit quantity to the given unit, rounding down to
+// whole units.
+func (u *UnitUsecase) FromBase(ctx context.Context, productId int, unitName string, quantity int) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "UnitUsecase.FromBase")
+	defer func() { tracing.End(span, err) }()
+
+	factor, err := u.factor(ctx, productId, unitName)
+	if err != nil {
+		return 0, err
+	}
+	return quantity / factor, nil
+}
+
+func (u *UnitUsecase) factor(ctx context.Context, productId int, unitName string) (int, error) {
+	if unitName == "" || unitName == entity.BaseUnit {
+		return 1, nil
+	}
+
+	factor, err := u.unitRepo.GetFactor(ctx, productId, unitName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, entity.ErrorUnknownUnit
+	}
+	if err != nil {
+		return 0, err
+	}
+	return factor, nil
+}
@@ -0,0 +1,83 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"warehouse-service/entity"
+	"warehouse-service/models/unit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockUnitRepository struct {
+	mock.Mock
+}
+
+func (m *MockUnitRepository) Upsert(ctx context.Context, productUnit *unit.RegisterRequest) error {
+	args := m.Called(productUnit)
+	return args.Error(0)
+}
+
+func (m *MockUnitRepository) GetByProductId(ctx context.Context, productId int) ([]unit.ProductUnit, error) {
+	args := m.Called(productId)
+	return args.Get(0).([]unit.ProductUnit), args.Error(1)
+}
+
+func (m *MockUnitRepository) GetFactor(ctx context.Context, productId int, unitName string) (int, error) {
+	args := m.Called(productId, unitName)
+	return args.Int(0), args.Error(1)
+}
+
+func TestToBase_Case(t *testing.T) {
+	mockRepo := new(MockUnitRepository)
+	unitUsecase := NewUnitUsecase(mockRepo)
+
+	mockRepo.On("GetFactor", 1, "case").Return(24, nil)
+
+	quantity, err := unitUsecase.ToBase(context.Background(), 1, "case", 3)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 72, quantity)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestToBase_BaseUnit(t *testing.T) {
+	mockRepo := new(MockUnitRepository)
+	unitUsecase := NewUnitUsecase(mockRepo)
+
+	quantity, err := unitUsecase.ToBase(context.Background(), 1, entity.BaseUnit, 3)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 3, quantity)
+	mockRepo.AssertNotCalled(t, "GetFactor", mock.Anything, mock.Anything)
+}
+
+func TestToBase_UnknownUnit(t *testing.T) {
+	mockRepo := new(MockUnitRepository)
+	unitUsecase := NewUnitUsecase(mockRepo)
+
+	mockRepo.On("GetFactor", 1, "pallet").Return(0, sql.ErrNoRows)
+
+	_, err := unitUsecase.ToBase(context.Background(), 1, "pallet", 3)
+
+	// Assertions
+	assert.ErrorIs(t, err, entity.ErrorUnknownUnit)
+}
+
+func TestFromBase_RoundsDown(t *testing.T) {
+	mockRepo := new(MockUnitRepository)
+	unitUsecase := NewUnitUsecase(mockRepo)
+
+	mockRepo.On("GetFactor", 1, "case").Return(24, nil)
+
+	quantity, err := unitUsecase.FromBase(context.Background(), 1, "case", 50)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 2, quantity)
+}
@@ -0,0 +1,330 @@
+package valuation
+
+import (
+	"context"
+	"time"
+	"warehouse-service/entity"
+	"warehouse-service/models/valuation"
+	"warehouse-service/tracing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ValuationRepository interface {
+	GetCostingMethod(ctx context.Context, tx *sqlx.Tx, warehouseId int) (string, error)
+	GetOpenLayersForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) ([]valuation.CostLayer, error)
+	InsertLayer(ctx context.Context, tx *sqlx.Tx, costLayer *valuation.CostLayer) error
+	SubstractLayer(ctx context.Context, tx *sqlx.Tx, id int, substractedQuantity int) error
+	CloseLayers(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) error
+	InsertMovement(ctx context.Context, tx *sqlx.Tx, costMovement *valuation.CostMovement) error
+	GetProductValues(ctx context.Context, shopId int, asOf time.Time) ([]valuation.ProductValueRow, error)
+}
+
+type ValuationUsecase struct {
+	valuationRepo ValuationRepository
+}
+
+func NewValuationUsecase(valuationRepo ValuationRepository) *ValuationUsecase {
+	return &ValuationUsecase{
+		valuationRepo: valuationRepo,
+	}
+}
+
+// costPiece is a quantity that entered or left the layers at one unit cost.
+type costPiece struct {
+	quantity int
+	unitCost valuation.Money
+}
+
+type layerTake struct {
+	layerId  int
+	quantity int
+}
+
+// Receive adds stock to the cost layers of a product-warehouse. Without a
+// unit cost the stock is received at the current average carrying cost.
+func (v *ValuationUsecase) Receive(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int, unitCost *valuation.Money) (err error) {
+	ctx, span := tracing.Start(ctx, "ValuationUsecase.Receive")
+	defer func() { tracing.End(span, err) }()
+
+	costLayers, err := v.valuationRepo.GetOpenLayersForUpdate(ctx, tx, productId, warehouseId)
+	if err != nil {
+		return err
+	}
+	piece := mergePieces(layerPieces(costLayers))
+	if unitCost != nil {
+		piece.unitCost = *unitCost
+	}
+	piece.quantity = quantity
+	return v.receive(ctx, tx, movementType, productId, warehouseId, costLayers, []costPiece{piece})
+}
+
+// Consume takes quantity out of the cost layers, oldest first under FIFO and
+// at the average cost under weighted average. Stock that predates costing has
+// no layer and leaves at zero cost.
+func (v *ValuationUsecase) Consume(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int) (err error) {
+	ctx, span := tracing.Start(ctx, "ValuationUsecase.Consume")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = v.consume(ctx, tx, movementType, productId, warehouseId, quantity)
+	return err
+}
+
+// Transfer moves quantity between warehouses at the cost it leaves the source
+// with, so the transfer itself does not change the shop's inventory value.
+func (v *ValuationUsecase) Transfer(ctx context.Context, tx *sqlx.Tx, productId int, fromWarehouseId int, toWarehouseId int, quantity int) (err error) {
+	ctx, span := tracing.Start(ctx, "ValuationUsecase.Transfer")
+	defer func() { tracing.End(span, err) }()
+
+	pieces, err := v.consume(ctx, tx, entity.CostMovementTransferOut, productId, fromWarehouseId, quantity)
+	if err != nil {
+		return err
+	}
+	costLayers, err := v.valuationRepo.GetOpenLayersForUpdate(ctx, tx, productId, toWarehouseId)
+	if err != nil {
+		return err
+	}
+	return v.receive(ctx, tx, entity.CostMovementTransferIn, productId, toWarehouseId, costLayers, pieces)
+}
+
+func (v *ValuationUsecase) GetInventoryValue(ctx context.Context, inventoryValueRequest *valuation.InventoryValueRequest) (_ *valuation.InventoryValue, err error) {
+	ctx, span := tracing.Start(ctx, "ValuationUsecase.GetInventoryValue")
+	defer func() { tracing.End(span, err) }()
+
+	asOf, err := time.Parse(time.DateOnly, inventoryValueRequest.AsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	// as of the end of the UTC day, movements are recorded in UTC
+	productValues, err := v.valuationRepo.GetProductValues(ctx, inventoryValueRequest.ShopId, asOf.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	return buildInventoryValue(inventoryValueRequest.ShopId, inventoryValueRequest.AsOf, productValues), nil
+}
+
+func (v *ValuationUsecase) receive(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, costLayers []valuation.CostLayer, pieces []costPiece) error {
+	costingMethod, err := v.valuationRepo.GetCostingMethod(ctx, tx, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	received := mergePieces(pieces)
+	err = v.valuationRepo.InsertMovement(ctx, tx, &valuation.CostMovement{
+		ProductId:    productId,
+		WarehouseId:  warehouseId,
+		MovementType: movementType,
+		Quantity:     received.quantity,
+		Value:        piecesValue(pieces),
+		CreatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if costingMethod == entity.CostingWeightedAverage {
+		// the open layers and the receipt fold into one layer at the new average
+		err = v.valuationRepo.CloseLayers(ctx, tx, productId, warehouseId)
+		if err != nil {
+			return err
+		}
+		pieces = []costPiece{mergePieces(append(layerPieces(costLayers), pieces...))}
+	}
+
+	receivedAt := time.Now()
+	for _, piece := range pieces {
+		if piece.quantity <= 0 {
+			continue
+		}
+		err = v.valuationRepo.InsertLayer(ctx, tx, &valuation.CostLayer{
+			ProductId:         productId,
+			WarehouseId:       warehouseId,
+			RemainingQuantity: piece.quantity,
+			UnitCost:          piece.unitCost,
+			ReceivedAt:        receivedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *ValuationUsecase) consume(ctx context.Context, tx *sqlx.Tx, movementType string, productId int, warehouseId int, quantity int) ([]costPiece, error) {
+	costingMethod, err := v.valuationRepo.GetCostingMethod(ctx, tx, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	costLayers, err := v.valuationRepo.GetOpenLayersForUpdate(ctx, tx, productId, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pieces []costPiece
+	if costingMethod == entity.CostingWeightedAverage {
+		pieces, err = v.consumeAverage(ctx, tx, productId, warehouseId, costLayers, quantity)
+	} else {
+		pieces, err = v.consumeFIFO(ctx, tx, costLayers, quantity)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	consumed := mergePieces(pieces)
+	err = v.valuationRepo.InsertMovement(ctx, tx, &valuation.CostMovement{
+		ProductId:    productId,
+		WarehouseId:  warehouseId,
+		MovementType: movementType,
+		Quantity:     -consumed.quantity,
+		Value:        -piecesValue(pieces),
+		CreatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pieces, nil
+}
+
+func (v *ValuationUsecase) consumeFIFO(ctx context.Context, tx *sqlx.Tx, costLayers []valuation.CostLayer, quantity int) ([]costPiece, error) {
+	takes, pieces := takeLayers(costLayers, quantity)
+	for _, take := range takes {
+		err := v.valuationRepo.SubstractLayer(ctx, tx, take.layerId, take.quantity)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pieces, nil
+}
+
+func (v *ValuationUsecase) consumeAverage(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int, costLayers []valuation.CostLayer, quantity int) ([]costPiece, error) {
+	average := mergePieces(layerPieces(costLayers))
+	if len(costLayers) == 1 {
+		err := v.valuationRepo.SubstractLayer(ctx, tx, costLayers[0].Id, min(quantity, average.quantity))
+		if err != nil {
+			return nil, err
+		}
+	} else if len(costLayers) > 1 {
+		// layers left over from FIFO, fold what remains into one
+		err := v.valuationRepo.CloseLayers(ctx, tx, productId, warehouseId)
+		if err != nil {
+			return nil, err
+		}
+		if average.quantity > quantity {
+			err = v.valuationRepo.InsertLayer(ctx, tx, &valuation.CostLayer{
+				ProductId:         productId,
+				WarehouseId:       warehouseId,
+				RemainingQuantity: average.quantity - quantity,
+				UnitCost:          average.unitCost,
+				ReceivedAt:        time.Now(),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	pieces := []costPiece{{quantity: min(quantity, average.quantity), unitCost: average.unitCost}}
+	if quantity > average.quantity {
+		pieces = append(pieces, costPiece{quantity: quantity - average.quantity})
+	}
+	return pieces, nil
+}
+
+// takeLayers consumes quantity from the layers in order. Whatever the layers
+// cannot cover is returned as a zero cost piece.
+func takeLayers(costLayers []valuation.CostLayer, quantity int) ([]layerTake, []costPiece) {
+	takes := []layerTake{}
+	pieces := []costPiece{}
+	remaining := quantity
+	for _, costLayer := range costLayers {
+		if remaining == 0 {
+			break
+		}
+		taken := min(costLayer.RemainingQuantity, remaining)
+		if taken <= 0 {
+			continue
+		}
+		takes = append(takes, layerTake{layerId: costLayer.Id, quantity: taken})
+		pieces = append(pieces, costPiece{quantity: taken, unitCost: costLayer.UnitCost})
+		remaining -= taken
+	}
+	if remaining > 0 {
+		pieces = append(pieces, costPiece{quantity: remaining})
+	}
+	return takes, pieces
+}
+
+func layerPieces(costLayers []valuation.CostLayer) []costPiece {
+	pieces := []costPiece{}
+	for _, costLayer := range costLayers {
+		pieces = append(pieces, costPiece{quantity: costLayer.RemainingQuantity, unitCost: costLayer.UnitCost})
+	}
+	return pieces
+}
+
+// mergePieces returns the total quantity of pieces at their weighted average
+// unit cost, rounded to the nearest ten-thousandth.
+func mergePieces(pieces []costPiece) costPiece {
+	merged := costPiece{}
+	for _, piece := range pieces {
+		merged.quantity += piece.quantity
+	}
+	if merged.quantity > 0 {
+		merged.unitCost = piecesValue(pieces).Per(merged.quantity)
+	}
+	return merged
+}
+
+// piecesValue is the exact value of pieces, movements are recorded at it
+// rather than at their rounded average.
+func piecesValue(pieces []costPiece) valuation.Money {
+	var value valuation.Money
+	for _, piece := range pieces {
+		value += pieceValue(piece)
+	}
+	return value
+}
+
+func pieceValue(piece costPiece) valuation.Money {
+	return piece.unitCost.Times(piece.quantity)
+}
+
+func buildInventoryValue(shopId int, asOf string, productValues []valuation.ProductValueRow) *valuation.InventoryValue {
+	inventoryValue := &valuation.InventoryValue{
+		ShopId:     shopId,
+		AsOf:       asOf,
+		Warehouses: []valuation.WarehouseValue{},
+	}
+	for _, productValue := range productValues {
+		if productValue.Quantity == 0 && productValue.Value == 0 {
+			continue
+		}
+		last := len(inventoryValue.Warehouses) - 1
+		if last < 0 || inventoryValue.Warehouses[last].WarehouseId != productValue.WarehouseId {
+			inventoryValue.Warehouses = append(inventoryValue.Warehouses, valuation.WarehouseValue{
+				WarehouseId: productValue.WarehouseId,
+				Products:    []valuation.ProductValue{},
+			})
+			last++
+		}
+		warehouseValue := &inventoryValue.Warehouses[last]
+		warehouseValue.Products = append(warehouseValue.Products, valuation.ProductValue{
+			ProductId: productValue.ProductId,
+			Quantity:  productValue.Quantity,
+			Value:     productValue.Value,
+		})
+		warehouseValue.Quantity += productValue.Quantity
+		warehouseValue.Value += productValue.Value
+		inventoryValue.Quantity += productValue.Quantity
+		inventoryValue.Value += productValue.Value
+	}
+	return inventoryValue
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,187 @@
+package valuation
+
+import (
+	"context"
+	"testing"
+	"time"
+	"warehouse-service/conn/mysql/mysqltest"
+	"warehouse-service/entity"
+	"warehouse-service/models/valuation"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock repository
+type MockValuationRepository struct {
+	mock.Mock
+}
+
+func (m *MockValuationRepository) GetCostingMethod(ctx context.Context, tx *sqlx.Tx, warehouseId int) (string, error) {
+	args := m.Called(warehouseId)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockValuationRepository) GetOpenLayersForUpdate(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) ([]valuation.CostLayer, error) {
+	args := m.Called(productId, warehouseId)
+	return args.Get(0).([]valuation.CostLayer), args.Error(1)
+}
+
+func (m *MockValuationRepository) InsertLayer(ctx context.Context, tx *sqlx.Tx, costLayer *valuation.CostLayer) error {
+	args := m.Called(costLayer)
+	return args.Error(0)
+}
+
+func (m *MockValuationRepository) SubstractLayer(ctx context.Context, tx *sqlx.Tx, id int, substractedQuantity int) error {
+	args := m.Called(id, substractedQuantity)
+	return args.Error(0)
+}
+
+func (m *MockValuationRepository) CloseLayers(ctx context.Context, tx *sqlx.Tx, productId int, warehouseId int) error {
+	args := m.Called(productId, warehouseId)
+	return args.Error(0)
+}
+
+func (m *MockValuationRepository) InsertMovement(ctx context.Context, tx *sqlx.Tx, costMovement *valuation.CostMovement) error {
+	args := m.Called(costMovement)
+	return args.Error(0)
+}
+
+func (m *MockValuationRepository) GetProductValues(ctx context.Context, shopId int, asOf time.Time) ([]valuation.ProductValueRow, error) {
+	args := m.Called(shopId, asOf)
+	return args.Get(0).([]valuation.ProductValueRow), args.Error(1)
+}
+
+func money(t *testing.T, text string) valuation.Money {
+	amount, err := valuation.ParseMoney(text)
+	assert.NoError(t, err)
+	return amount
+}
+
+func TestConsume_FIFOAcrossLayers(t *testing.T) {
+	mockRepo := new(MockValuationRepository)
+	db := mysqltest.NewDB()
+	tx, _ := db.BeginTxx(context.Background(), nil)
+
+	mockRepo.On("GetCostingMethod", 2).Return(entity.CostingFIFO, nil)
+	mockRepo.On("GetOpenLayersForUpdate", 7, 2).Return([]valuation.CostLayer{
+		{Id: 1, RemainingQuantity: 5, UnitCost: money(t, "10.10")},
+		{Id: 2, RemainingQuantity: 10, UnitCost: money(t, "12.0001")},
+	}, nil)
+	mockRepo.On("SubstractLayer", 1, 5).Return(nil)
+	mockRepo.On("SubstractLayer", 2, 3).Return(nil)
+	mockRepo.On("InsertMovement", mock.MatchedBy(func(costMovement *valuation.CostMovement) bool {
+		return costMovement.MovementType == entity.CostMovementShipment && costMovement.Quantity == -8 &&
+			costMovement.Value == -money(t, "86.5003") && costMovement.CreatedAt.Location() == time.UTC
+	})).Return(nil)
+
+	usecase := NewValuationUsecase(mockRepo)
+	err := usecase.Consume(context.Background(), tx, entity.CostMovementShipment, 7, 2, 8)
+
+	// Assertions
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CloseLayers", mock.Anything, mock.Anything)
+}
+
+func TestConsume_AverageFoldsLeftoverLayers(t *testing.T) {
+	mockRepo := new(MockValuationRepository)
+	db := mysqltest.NewDB()
+	tx, _ := db.BeginTxx(context.Background(), nil)
+
+	mockRepo.On("GetCostingMethod", 2).Return(entity.CostingWeightedAverage, nil)
+	mockRepo.On("GetOpenLayersForUpdate", 7, 2).Return([]valuation.CostLayer{
+		{Id: 1, RemainingQuantity: 4, UnitCost: money(t, "10")},
+		{Id: 2, RemainingQuantity: 6, UnitCost: money(t, "12.5")},
+	}, nil)
+	mockRepo.On("CloseLayers", 7, 2).Return(nil)
+	mockRepo.On("InsertLayer", mock.MatchedBy(func(costLayer *valuation.CostLayer) bool {
+		return costLayer.RemainingQuantity == 6 && costLayer.UnitCost == money(t, "11.5")
+	})).Return(nil)
+	mockRepo.On("InsertMovement", mock.MatchedBy(func(costMovement *valuation.CostMovement) bool {
+		return costMovement.Quantity == -4 && costMovement.Value == -money(t, "46")
+	})).Return(nil)
+
+	usecase := NewValuationUsecase(mockRepo)
+	err := usecase.Consume(context.Background(), tx, entity.CostMovementShipment, 7, 2, 4)
+
+	// Assertions
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "SubstractLayer", mock.Anything, mock.Anything)
+}
+
+func TestTakeLayers_FIFO(t *testing.T) {
+	costLayers := []valuation.CostLayer{
+		{Id: 1, RemainingQuantity: 5, UnitCost: money(t, "10")},
+		{Id: 2, RemainingQuantity: 10, UnitCost: money(t, "12")},
+	}
+
+	takes, pieces := takeLayers(costLayers, 8)
+
+	// Assertions
+	assert.Equal(t, []layerTake{{layerId: 1, quantity: 5}, {layerId: 2, quantity: 3}}, takes)
+	assert.Equal(t, []costPiece{{quantity: 5, unitCost: money(t, "10")}, {quantity: 3, unitCost: money(t, "12")}}, pieces)
+	assert.Equal(t, money(t, "86"), pieceValue(mergePieces(pieces)))
+}
+
+func TestTakeLayers_UncostedStock(t *testing.T) {
+	costLayers := []valuation.CostLayer{
+		{Id: 1, RemainingQuantity: 2, UnitCost: money(t, "10")},
+	}
+
+	takes, pieces := takeLayers(costLayers, 5)
+
+	// Assertions
+	assert.Equal(t, []layerTake{{layerId: 1, quantity: 2}}, takes)
+	assert.Equal(t, []costPiece{{quantity: 2, unitCost: money(t, "10")}, {quantity: 3}}, pieces)
+}
+
+func TestMergePieces_WeightedAverage(t *testing.T) {
+	merged := mergePieces([]costPiece{{quantity: 10, unitCost: money(t, "4")}, {quantity: 30, unitCost: money(t, "8")}})
+
+	// Assertions
+	assert.Equal(t, 40, merged.quantity)
+	assert.Equal(t, money(t, "7"), merged.unitCost)
+	assert.Equal(t, costPiece{}, mergePieces(nil))
+}
+
+func TestMergePieces_RoundsToTheNearestTenThousandth(t *testing.T) {
+	merged := mergePieces([]costPiece{{quantity: 1, unitCost: money(t, "1")}, {quantity: 2, unitCost: money(t, "2")}})
+
+	// Assertions
+	assert.Equal(t, money(t, "1.6667"), merged.unitCost)
+}
+
+func TestBuildInventoryValue(t *testing.T) {
+	productValues := []valuation.ProductValueRow{
+		{WarehouseId: 1, ProductId: 1, Quantity: 10, Value: money(t, "100.0004")},
+		{WarehouseId: 1, ProductId: 2, Quantity: 0, Value: 0},
+		{WarehouseId: 1, ProductId: 3, Quantity: 5, Value: money(t, "25.5")},
+		{WarehouseId: 2, ProductId: 1, Quantity: 3, Value: money(t, "36")},
+	}
+
+	inventoryValue := buildInventoryValue(7, "2026-09-30", productValues)
+
+	// Assertions
+	assert.Equal(t, 18, inventoryValue.Quantity)
+	assert.Equal(t, money(t, "161.5004"), inventoryValue.Value)
+	assert.Len(t, inventoryValue.Warehouses, 2)
+	assert.Len(t, inventoryValue.Warehouses[0].Products, 2)
+	assert.Equal(t, 15, inventoryValue.Warehouses[0].Quantity)
+	assert.Equal(t, money(t, "125.5004"), inventoryValue.Warehouses[0].Value)
+	assert.Equal(t, money(t, "36"), inventoryValue.Warehouses[1].Value)
+}
+
+func TestGetInventoryValue_AsOfEndOfUTCDay(t *testing.T) {
+	mockRepo := new(MockValuationRepository)
+	mockRepo.On("GetProductValues", 7, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)).Return([]valuation.ProductValueRow{}, nil)
+
+	usecase := NewValuationUsecase(mockRepo)
+	_, err := usecase.GetInventoryValue(context.Background(), &valuation.InventoryValueRequest{ShopId: 7, AsOf: "2026-09-30"})
+
+	// Assertions
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
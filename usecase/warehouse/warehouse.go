@@ -1,12 +1,19 @@
 package warehouse
 
 import (
+	"context"
+	"warehouse-service/entity"
 	"warehouse-service/models/warehouse"
+	"warehouse-service/tracing"
 )
 
 type WarehouseRepository interface {
-	Insert(warehouse *warehouse.RegisterRequest) error
-	UpdateStatus(id int, status string) error
+	Insert(ctx context.Context, warehouse *warehouse.RegisterRequest) error
+	UpdateStatus(ctx context.Context, id int, status string) error
+	UpdateLocation(ctx context.Context, id int, latitude float64, longitude float64) error
+	UpdateCapacity(ctx context.Context, id int, capacityUnits *int, capacityVolume *float64) error
+	UpdateCostingMethod(ctx context.Context, id int, costingMethod string) error
+	GetUtilizationByShop(ctx context.Context, shopId int) ([]warehouse.Utilization, error)
 }
 
 type WarehouseUsecase struct {
@@ -19,10 +26,66 @@ func NewWarehouseUsecase(warehouseRepo WarehouseRepository) *WarehouseUsecase {
 	}
 }
 
-func (w *WarehouseUsecase) Register(warehouseRegister *warehouse.RegisterRequest) error {
-	return w.warehouseRepo.Insert(warehouseRegister)
+func (w *WarehouseUsecase) Register(ctx context.Context, warehouseRegister *warehouse.RegisterRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseUsecase.Register")
+	defer func() { tracing.End(span, err) }()
+
+	if warehouseRegister.CostingMethod == "" {
+		warehouseRegister.CostingMethod = entity.CostingFIFO
+	}
+	return w.warehouseRepo.Insert(ctx, warehouseRegister)
+}
+
+func (w *WarehouseUsecase) UpdateStatus(ctx context.Context, updateStatus *warehouse.UpdateStatusRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseUsecase.UpdateStatus")
+	defer func() { tracing.End(span, err) }()
+
+	return w.warehouseRepo.UpdateStatus(ctx, updateStatus.Id, updateStatus.Status)
+}
+
+func (w *WarehouseUsecase) UpdateLocation(ctx context.Context, updateLocation *warehouse.UpdateLocationRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseUsecase.UpdateLocation")
+	defer func() { tracing.End(span, err) }()
+
+	return w.warehouseRepo.UpdateLocation(ctx, updateLocation.Id, *updateLocation.Latitude, *updateLocation.Longitude)
+}
+
+func (w *WarehouseUsecase) UpdateCapacity(ctx context.Context, updateCapacity *warehouse.UpdateCapacityRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseUsecase.UpdateCapacity")
+	defer func() { tracing.End(span, err) }()
+
+	return w.warehouseRepo.UpdateCapacity(ctx, updateCapacity.Id, updateCapacity.CapacityUnits, updateCapacity.CapacityVolume)
+}
+
+// UpdateCostingMethod only affects later stock movements, the value already
+// recorded is kept. Switching to weighted average folds the open layers on
+// the next movement.
+func (w *WarehouseUsecase) UpdateCostingMethod(ctx context.Context, updateCostingMethod *warehouse.UpdateCostingMethodRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseUsecase.UpdateCostingMethod")
+	defer func() { tracing.End(span, err) }()
+
+	return w.warehouseRepo.UpdateCostingMethod(ctx, updateCostingMethod.Id, updateCostingMethod.CostingMethod)
 }
 
-func (w *WarehouseUsecase) UpdateStatus(updateStatus *warehouse.UpdateStatusRequest) error {
-	return w.warehouseRepo.UpdateStatus(updateStatus.Id, updateStatus.Status)
+func (w *WarehouseUsecase) GetUtilization(ctx context.Context, shopId int) (_ []warehouse.Utilization, err error) {
+	ctx, span := tracing.Start(ctx, "WarehouseUsecase.GetUtilization")
+	defer func() { tracing.End(span, err) }()
+
+	utilizations, err := w.warehouseRepo.GetUtilizationByShop(ctx, shopId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range utilizations {
+		utilization := &utilizations[i]
+		if utilization.CapacityUnits != nil {
+			percent := float64(utilization.UsedUnits) / float64(*utilization.CapacityUnits) * 100
+			utilization.UnitsUsedPercent = &percent
+		}
+		if utilization.CapacityVolume != nil {
+			percent := utilization.UsedVolume / *utilization.CapacityVolume * 100
+			utilization.VolumeUsedPercent = &percent
+		}
+	}
+	return utilizations, nil
 }
@@ -1,6 +1,7 @@
 package warehouse
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"warehouse-service/models/warehouse"
@@ -14,16 +15,36 @@ type MockWarehouseRepository struct {
 	mock.Mock
 }
 
-func (m *MockWarehouseRepository) Insert(warehouse *warehouse.RegisterRequest) error {
+func (m *MockWarehouseRepository) Insert(ctx context.Context, warehouse *warehouse.RegisterRequest) error {
 	args := m.Called(warehouse)
 	return args.Error(0)
 }
 
-func (m *MockWarehouseRepository) UpdateStatus(id int, status string) error {
+func (m *MockWarehouseRepository) UpdateStatus(ctx context.Context, id int, status string) error {
 	args := m.Called(id, status)
 	return args.Error(0)
 }
 
+func (m *MockWarehouseRepository) UpdateLocation(ctx context.Context, id int, latitude float64, longitude float64) error {
+	args := m.Called(id, latitude, longitude)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) UpdateCapacity(ctx context.Context, id int, capacityUnits *int, capacityVolume *float64) error {
+	args := m.Called(id, capacityUnits, capacityVolume)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) UpdateCostingMethod(ctx context.Context, id int, costingMethod string) error {
+	args := m.Called(id, costingMethod)
+	return args.Error(0)
+}
+
+func (m *MockWarehouseRepository) GetUtilizationByShop(ctx context.Context, shopId int) ([]warehouse.Utilization, error) {
+	args := m.Called(shopId)
+	return args.Get(0).([]warehouse.Utilization), args.Error(1)
+}
+
 func TestRegister_Success(t *testing.T) {
 	mockRepo := new(MockWarehouseRepository)
 	warehouseUsecase := NewWarehouseUsecase(mockRepo)
@@ -36,7 +57,7 @@ func TestRegister_Success(t *testing.T) {
 	// Expect Insert to be called with mockRequest and return nil (success)
 	mockRepo.On("Insert", mockRequest).Return(nil)
 
-	err := warehouseUsecase.Register(mockRequest)
+	err := warehouseUsecase.Register(context.Background(), mockRequest)
 
 	// Assertions
 	assert.NoError(t, err)
@@ -55,10 +76,32 @@ func TestRegister_Fail(t *testing.T) {
 	// Simulate a database error
 	mockRepo.On("Insert", mockRequest).Return(errors.New("database error"))
 
-	err := warehouseUsecase.Register(mockRequest)
+	err := warehouseUsecase.Register(context.Background(), mockRequest)
 
 	// Assertions
 	assert.Error(t, err)
 	assert.Equal(t, "database error", err.Error())
 	mockRepo.AssertExpectations(t)
 }
+
+func TestGetUtilization_Success(t *testing.T) {
+	mockRepo := new(MockWarehouseRepository)
+	warehouseUsecase := NewWarehouseUsecase(mockRepo)
+
+	capacityUnits := 200
+	capacityVolume := 10.0
+	mockRepo.On("GetUtilizationByShop", 1).Return([]warehouse.Utilization{
+		{WarehouseId: 1, ShopId: 1, CapacityUnits: &capacityUnits, CapacityVolume: &capacityVolume, UsedUnits: 50, UsedVolume: 2.5},
+		{WarehouseId: 2, ShopId: 1, UsedUnits: 80},
+	}, nil)
+
+	utilizations, err := warehouseUsecase.GetUtilization(context.Background(), 1)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 25.0, *utilizations[0].UnitsUsedPercent)
+	assert.Equal(t, 25.0, *utilizations[0].VolumeUsedPercent)
+	assert.Nil(t, utilizations[1].UnitsUsedPercent)
+	assert.Nil(t, utilizations[1].VolumeUsedPercent)
+	mockRepo.AssertExpectations(t)
+}